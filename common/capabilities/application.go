@@ -33,6 +33,10 @@ const (
 
 	// ApplicationResourcesTreeExperimental is the capabilities string for private data using the experimental feature of collections/sideDB.
 	ApplicationResourcesTreeExperimental = "V1_1_RESOURCETREE_EXPERIMENTAL"
+
+	// ApplicationLifecycleCanonicalCollections is the capabilities string for storing the collection configs
+	// of chaincode definitions and approvals in the _lifecycle namespace sorted by collection name.
+	ApplicationLifecycleCanonicalCollections = "V2_0_LIFECYCLE_CANONICAL_COLLECTIONS"
 )
 
 // ApplicationProvider provides capabilities information for application level config.
@@ -44,6 +48,7 @@ type ApplicationProvider struct {
 	v142                   bool
 	v20                    bool
 	v11PvtDataExperimental bool
+	canonicalCollections   bool
 }
 
 // NewApplicationProvider creates a application capabilities provider.
@@ -56,6 +61,7 @@ func NewApplicationProvider(capabilities map[string]*cb.Capability) *Application
 	_, ap.v142 = capabilities[ApplicationV1_4_2]
 	_, ap.v20 = capabilities[ApplicationV2_0]
 	_, ap.v11PvtDataExperimental = capabilities[ApplicationPvtDataExperimental]
+	_, ap.canonicalCollections = capabilities[ApplicationLifecycleCanonicalCollections]
 	return ap
 }
 
@@ -139,6 +145,13 @@ func (ap *ApplicationProvider) StorePvtDataOfInvalidTx() bool {
 	return ap.v142 || ap.v20
 }
 
+// LifecycleCanonicalCollections returns true if this channel stores the collection configs of
+// chaincode definitions and approvals sorted by collection name, so that the order in which
+// the collections were supplied does not affect the bytes written to the ledger.
+func (ap *ApplicationProvider) LifecycleCanonicalCollections() bool {
+	return ap.canonicalCollections
+}
+
 // HasCapability returns true if the capability is supported by this binary.
 func (ap *ApplicationProvider) HasCapability(capability string) bool {
 	switch capability {
@@ -157,6 +170,8 @@ func (ap *ApplicationProvider) HasCapability(capability string) bool {
 		return true
 	case ApplicationResourcesTreeExperimental:
 		return true
+	case ApplicationLifecycleCanonicalCollections:
+		return true
 	default:
 		return false
 	}
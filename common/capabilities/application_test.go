@@ -87,6 +87,17 @@ func TestApplicationV20(t *testing.T) {
 	require.True(t, ap.PrivateChannelData())
 	require.True(t, ap.LifecycleV20())
 	require.True(t, ap.StorePvtDataOfInvalidTx())
+	require.False(t, ap.LifecycleCanonicalCollections())
+}
+
+func TestApplicationLifecycleCanonicalCollections(t *testing.T) {
+	ap := NewApplicationProvider(map[string]*cb.Capability{
+		ApplicationV2_0:                          {},
+		ApplicationLifecycleCanonicalCollections: {},
+	})
+	require.NoError(t, ap.Supported())
+	require.True(t, ap.V2_0Validation())
+	require.True(t, ap.LifecycleCanonicalCollections())
 }
 
 func TestApplicationPvtDataExperimental(t *testing.T) {
@@ -104,5 +115,6 @@ func TestHasCapability(t *testing.T) {
 	require.True(t, ap.HasCapability(ApplicationV2_0))
 	require.True(t, ap.HasCapability(ApplicationPvtDataExperimental))
 	require.True(t, ap.HasCapability(ApplicationResourcesTreeExperimental))
+	require.True(t, ap.HasCapability(ApplicationLifecycleCanonicalCollections))
 	require.False(t, ap.HasCapability("default"))
 }
@@ -7,7 +7,6 @@ SPDX-License-Identifier: Apache-2.0
 package lifecycle
 
 import (
-	"fmt"
 	"sort"
 	"strconv"
 	"sync"
@@ -84,6 +83,15 @@ type Cache struct {
 	eventBroker     *EventBroker
 	MetadataHandler MetadataHandler
 
+	// CommitListener, if set, is notified as blocks committing a new
+	// sequence of a chaincode definition are processed.  It is invoked
+	// with the cache lock held, and so must not call back into the cache.
+	CommitListener CommitListener
+
+	// AuditSink, if set, is given the digest of the parameters of each new
+	// sequence of a chaincode definition as it is committed to the ledger.
+	AuditSink AuditSink
+
 	chaincodeCustodian *ChaincodeCustodian
 }
 
@@ -422,6 +430,24 @@ func (c *Cache) GetInstalledChaincode(packageID string) (*chaincode.InstalledCha
 	return nil, errors.Errorf("could not find chaincode with package id '%s'", packageID)
 }
 
+// notifyCommitted informs the commit listener and audit sink of a newly
+// committed chaincode definition.  It should only be called with the write
+// lock already held.
+func (c *Cache) notifyCommitted(channelID, name string, cd *ChaincodeDefinition) {
+	if c.CommitListener != nil {
+		c.CommitListener.HandleChaincodeCommitted(channelID, name, cd)
+	}
+
+	if c.AuditSink != nil {
+		digest, err := cd.Parameters().Hash()
+		if err != nil {
+			logger.Errorf("Could not compute audit digest for chaincode '%s' on channel '%s': %s", name, channelID, err)
+			return
+		}
+		c.AuditSink.RecordCommit(channelID, name, cd.Sequence, digest)
+	}
+}
+
 // update should only be called with the write lock already held
 func (c *Cache) update(initializing bool, channelID string, dirtyChaincodes map[string]struct{}, qe ledger.SimpleQueryExecutor) error {
 	channelCache, ok := c.definedChaincodes[channelID]
@@ -468,7 +494,7 @@ func (c *Cache) update(initializing bool, channelID string, dirtyChaincodes map[
 			continue
 		}
 
-		privateName := fmt.Sprintf("%s#%d", name, chaincodeDefinition.Sequence)
+		privateName := PrivateName(name, chaincodeDefinition.Sequence)
 		hashKey := FieldKey(ChaincodeSourcesName, privateName, "PackageID")
 		hashOfCCHash, err := orgState.GetStateHash(hashKey)
 		if err != nil {
@@ -522,9 +548,15 @@ func (c *Cache) update(initializing bool, channelID string, dirtyChaincodes map[
 			}
 		}
 
+		committed := !initializing && (cachedChaincode.Definition == nil || cachedChaincode.Definition.Sequence != chaincodeDefinition.Sequence)
+
 		cachedChaincode.Definition = chaincodeDefinition
 		cachedChaincode.Approved = false
 
+		if committed {
+			c.notifyCommitted(channelID, name, chaincodeDefinition)
+		}
+
 		cachedChaincode.Hashes = []string{
 			string(util.ComputeSHA256([]byte(MetadataKey(NamespacesName, privateName)))),
 			string(util.ComputeSHA256([]byte(FieldKey(NamespacesName, privateName, "EndorsementInfo")))),
@@ -430,6 +430,14 @@ var _ = Describe("Cache", func() {
 			}
 		})
 
+		It("does not notify the commit listener", func() {
+			fakeCommitListener := &mock.CommitListener{}
+			c.CommitListener = fakeCommitListener
+			err := c.Initialize("channel-id", fakeQueryExecutor)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeCommitListener.HandleChaincodeCommittedCallCount()).To(Equal(0))
+		})
+
 		Context("when the chaincode is not installed", func() {
 			BeforeEach(func() {
 				err := resources.Serializer.Serialize(lifecycle.NamespacesName, "chaincode-name", &lifecycle.ChaincodeDefinition{
@@ -815,6 +823,85 @@ var _ = Describe("Cache", func() {
 				Expect(channelCache.Chaincodes["chaincode-name"].Definition.Sequence).To(Equal(int64(7)))
 			})
 
+			Context("when there is a commit listener", func() {
+				var fakeCommitListener *mock.CommitListener
+
+				BeforeEach(func() {
+					fakeCommitListener = &mock.CommitListener{}
+					c.CommitListener = fakeCommitListener
+				})
+
+				It("notifies the listener of the newly committed sequence", func() {
+					err := c.HandleStateUpdates(trigger)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(fakeCommitListener.HandleChaincodeCommittedCallCount()).To(Equal(1))
+					channelID, name, cd := fakeCommitListener.HandleChaincodeCommittedArgsForCall(0)
+					Expect(channelID).To(Equal("channel-id"))
+					Expect(name).To(Equal("chaincode-name"))
+					Expect(cd.Sequence).To(Equal(int64(7)))
+				})
+
+				Context("when the sequence is unchanged", func() {
+					BeforeEach(func() {
+						channelCache.Chaincodes["chaincode-name"].Definition.Sequence = 7
+					})
+
+					It("does not notify the listener", func() {
+						err := c.HandleStateUpdates(trigger)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(fakeCommitListener.HandleChaincodeCommittedCallCount()).To(Equal(0))
+					})
+				})
+
+				Context("when the update encounters an error", func() {
+					BeforeEach(func() {
+						fakeQueryExecutor.GetStateReturns(nil, fmt.Errorf("state-error"))
+					})
+
+					It("does not notify the listener", func() {
+						err := c.HandleStateUpdates(trigger)
+						Expect(err).To(HaveOccurred())
+						Expect(fakeCommitListener.HandleChaincodeCommittedCallCount()).To(Equal(0))
+					})
+				})
+			})
+
+			Context("when there is an audit sink", func() {
+				var fakeAuditSink *mock.AuditSink
+
+				BeforeEach(func() {
+					fakeAuditSink = &mock.AuditSink{}
+					c.AuditSink = fakeAuditSink
+				})
+
+				It("records the digest of the committed parameters", func() {
+					err := c.HandleStateUpdates(trigger)
+					Expect(err).NotTo(HaveOccurred())
+
+					expectedDigest, err := (&lifecycle.ChaincodeDefinition{Sequence: 7}).Parameters().Hash()
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(fakeAuditSink.RecordCommitCallCount()).To(Equal(1))
+					channelID, name, sequence, digest := fakeAuditSink.RecordCommitArgsForCall(0)
+					Expect(channelID).To(Equal("channel-id"))
+					Expect(name).To(Equal("chaincode-name"))
+					Expect(sequence).To(Equal(int64(7)))
+					Expect(digest).To(Equal(expectedDigest))
+				})
+
+				Context("when the sequence is unchanged", func() {
+					BeforeEach(func() {
+						channelCache.Chaincodes["chaincode-name"].Definition.Sequence = 7
+					})
+
+					It("does not record the commit", func() {
+						err := c.HandleStateUpdates(trigger)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(fakeAuditSink.RecordCommitCallCount()).To(Equal(0))
+					})
+				})
+			})
+
 			Context("when the update is not to the sequence", func() {
 				BeforeEach(func() {
 					trigger.StateUpdates["_lifecycle"].PublicUpdates[0].Key = "namespaces/fields/chaincode-name/EndorsementInfo"
@@ -1008,13 +1095,13 @@ var _ = Describe("Cache", func() {
 				}
 
 				approve = func(packageID, chaincodeName string, sequence int64) {
-					err := resources.Serializer.Serialize(lifecycle.NamespacesName, fmt.Sprintf("%s#%d", chaincodeName, sequence),
+					err := resources.Serializer.Serialize(lifecycle.NamespacesName, lifecycle.PrivateName(chaincodeName, sequence),
 						&lifecycle.ChaincodeParameters{
 							EndorsementInfo: &lb.ChaincodeEndorsementInfo{Version: "version-1"},
 						},
 						fakePrivateState)
 					Expect(err).NotTo(HaveOccurred())
-					err = resources.Serializer.Serialize(lifecycle.ChaincodeSourcesName, fmt.Sprintf("%s#%d", chaincodeName, sequence),
+					err = resources.Serializer.Serialize(lifecycle.ChaincodeSourcesName, lifecycle.PrivateName(chaincodeName, sequence),
 
 						&lifecycle.ChaincodeLocalPackage{
 							PackageID: packageID,
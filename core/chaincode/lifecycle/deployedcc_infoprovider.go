@@ -82,7 +82,7 @@ func (vc *ValidatorCommitter) UpdatedChaincodes(stateUpdates map[string][]*kvrws
 }
 
 func (vc *ValidatorCommitter) ChaincodeInfo(channelName, chaincodeName string, qe ledger.SimpleQueryExecutor) (*ledger.DeployedChaincodeInfo, error) {
-	exists, definedChaincode, err := vc.Resources.ChaincodeDefinitionIfDefined(chaincodeName, &SimpleQueryExecutorShim{
+	exists, definedChaincode, err := vc.Resources.ChaincodeDefinitionIfDefinedInChannel(channelName, chaincodeName, &SimpleQueryExecutorShim{
 		Namespace:           LifecycleNamespace,
 		SimpleQueryExecutor: qe,
 	})
@@ -178,7 +178,7 @@ func (vc *ValidatorCommitter) AllCollectionsConfigPkg(channelName, chaincodeName
 // CollectionInfo implements function in interface ledger.DeployedChaincodeInfoProvider, it returns config for
 // both static and implicit collections.
 func (vc *ValidatorCommitter) CollectionInfo(channelName, chaincodeName, collectionName string, qe ledger.SimpleQueryExecutor) (*pb.StaticCollectionConfig, error) {
-	exists, definedChaincode, err := vc.Resources.ChaincodeDefinitionIfDefined(chaincodeName, &SimpleQueryExecutorShim{
+	exists, definedChaincode, err := vc.Resources.ChaincodeDefinitionIfDefinedInChannel(channelName, chaincodeName, &SimpleQueryExecutorShim{
 		Namespace:           LifecycleNamespace,
 		SimpleQueryExecutor: qe,
 	})
@@ -209,7 +209,7 @@ func (vc *ValidatorCommitter) CollectionInfo(channelName, chaincodeName, collect
 // ImplicitCollections implements function in interface ledger.DeployedChaincodeInfoProvider.  It returns
 //a slice that contains one proto msg for each of the implicit collections
 func (vc *ValidatorCommitter) ImplicitCollections(channelName, chaincodeName string, qe ledger.SimpleQueryExecutor) ([]*pb.StaticCollectionConfig, error) {
-	exists, _, err := vc.Resources.ChaincodeDefinitionIfDefined(chaincodeName, &SimpleQueryExecutorShim{
+	exists, _, err := vc.Resources.ChaincodeDefinitionIfDefinedInChannel(channelName, chaincodeName, &SimpleQueryExecutorShim{
 		Namespace:           LifecycleNamespace,
 		SimpleQueryExecutor: qe,
 	})
@@ -325,7 +325,7 @@ func (vc *ValidatorCommitter) ImplicitCollectionEndorsementPolicyAsBytes(channel
 // error is not nil.
 func (vc *ValidatorCommitter) ValidationInfo(channelID, chaincodeName string, qe ledger.SimpleQueryExecutor) (plugin string, args []byte, unexpectedErr error, validationErr error) {
 	// TODO, this is a bit of an overkill check, and will need to be scaled back for non-chaincode type namespaces
-	exists, definedChaincode, err := vc.Resources.ChaincodeDefinitionIfDefined(chaincodeName, &SimpleQueryExecutorShim{
+	exists, definedChaincode, err := vc.Resources.ChaincodeDefinitionIfDefinedInChannel(channelID, chaincodeName, &SimpleQueryExecutorShim{
 		Namespace:           LifecycleNamespace,
 		SimpleQueryExecutor: qe,
 	})
@@ -353,7 +353,7 @@ func (vc *ValidatorCommitter) ValidationInfo(channelID, chaincodeName string, qe
 
 // CollectionValidationInfo returns information about collections to the validation component
 func (vc *ValidatorCommitter) CollectionValidationInfo(channelID, chaincodeName, collectionName string, state validationState.State) (args []byte, unexpectedErr, validationErr error) {
-	exists, definedChaincode, err := vc.Resources.ChaincodeDefinitionIfDefined(chaincodeName, &ValidatorStateShim{
+	exists, definedChaincode, err := vc.Resources.ChaincodeDefinitionIfDefinedInChannel(channelID, chaincodeName, &ValidatorStateShim{
 		Namespace:      LifecycleNamespace,
 		ValidatorState: state,
 	})
@@ -7,6 +7,9 @@ SPDX-License-Identifier: Apache-2.0
 package lifecycle
 
 import (
+	"sort"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
 	commonledger "github.com/hyperledger/fabric/common/ledger"
@@ -38,6 +41,31 @@ func StateIteratorToMap(itr StateIterator) (map[string][]byte, error) {
 	}
 }
 
+// StateRangePage returns the page of at most pageSize entries of the range whose keys sort
+// at or after the bookmark, along with the key which begins the next page.  The returned
+// bookmark is empty when there are no further entries.
+func StateRangePage(kvs map[string][]byte, bookmark string, pageSize int32) (map[string][]byte, string) {
+	keys := make([]string, 0, len(kvs))
+	for key := range kvs {
+		if key >= bookmark {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	nextBookmark := ""
+	if pageSize > 0 && len(keys) > int(pageSize) {
+		nextBookmark = keys[pageSize]
+		keys = keys[:pageSize]
+	}
+
+	result := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		result[key] = kvs[key]
+	}
+	return result, nextBookmark
+}
+
 // ChaincodePublicLedgerShim decorates the chaincode shim to support the state interfaces
 // required by the serialization code.
 type ChaincodePublicLedgerShim struct {
@@ -54,6 +82,21 @@ func (cls *ChaincodePublicLedgerShim) GetStateRange(prefix string) (map[string][
 	return StateIteratorToMap(&ChaincodeResultIteratorShim{ResultsIterator: itr})
 }
 
+// GetStateRangeWithPagination performs a range query for at most pageSize keys beginning
+// with a particular prefix, starting from the bookmark, and returns them as a map along with
+// the bookmark of the next page. This function assumes that keys contain only ascii chars from \x00 to \x7e.
+func (cls *ChaincodePublicLedgerShim) GetStateRangeWithPagination(prefix, bookmark string, pageSize int32) (map[string][]byte, string, error) {
+	itr, metadata, err := cls.GetStateByRangeWithPagination(prefix, prefix+"\x7f", pageSize, bookmark)
+	if err != nil {
+		return nil, "", errors.WithMessage(err, "could not get state iterator")
+	}
+	result, err := StateIteratorToMap(&ChaincodeResultIteratorShim{ResultsIterator: itr})
+	if err != nil {
+		return nil, "", err
+	}
+	return result, metadata.GetBookmark(), nil
+}
+
 type ChaincodeResultIteratorShim struct {
 	ResultsIterator shim.StateQueryIteratorInterface
 }
@@ -86,6 +129,19 @@ func (cls *ChaincodePrivateLedgerShim) GetStateRange(prefix string) (map[string]
 	return StateIteratorToMap(&ChaincodeResultIteratorShim{ResultsIterator: itr})
 }
 
+// GetStateRangeWithPagination performs a range query in the configured collection for at
+// most pageSize keys beginning with a particular prefix, starting from the bookmark.  The
+// chaincode shim does not support paginated private data queries, so the page is cut from
+// the full range.
+func (cls *ChaincodePrivateLedgerShim) GetStateRangeWithPagination(prefix, bookmark string, pageSize int32) (map[string][]byte, string, error) {
+	result, err := cls.GetStateRange(prefix)
+	if err != nil {
+		return nil, "", err
+	}
+	page, nextBookmark := StateRangePage(result, bookmark, pageSize)
+	return page, nextBookmark, nil
+}
+
 // GetState returns the value for the key in the configured collection.
 func (cls *ChaincodePrivateLedgerShim) GetState(key string) ([]byte, error) {
 	return cls.Stub.GetPrivateData(cls.Collection, key)
@@ -110,6 +166,16 @@ func (cls *ChaincodePrivateLedgerShim) CollectionName() string {
 	return cls.Collection
 }
 
+// GetTxID returns the ID of the transaction the stub is scoped to.
+func (cls *ChaincodePrivateLedgerShim) GetTxID() string {
+	return cls.Stub.GetTxID()
+}
+
+// GetTxTimestamp returns the timestamp of the transaction the stub is scoped to.
+func (cls *ChaincodePrivateLedgerShim) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return cls.Stub.GetTxTimestamp()
+}
+
 // SimpleQueryExecutorShim implements the ReadableState and RangeableState interfaces
 // based on an underlying ledger.SimpleQueryExecutor
 type SimpleQueryExecutorShim struct {
@@ -129,6 +195,18 @@ func (sqes *SimpleQueryExecutorShim) GetStateRange(prefix string) (map[string][]
 	return StateIteratorToMap(&ResultsIteratorShim{ResultsIterator: itr})
 }
 
+// GetStateRangeWithPagination performs a range query for at most pageSize keys beginning
+// with a particular prefix, starting from the bookmark.  The simple query executor does not
+// support paginated queries, so the page is cut from the full range.
+func (sqes *SimpleQueryExecutorShim) GetStateRangeWithPagination(prefix, bookmark string, pageSize int32) (map[string][]byte, string, error) {
+	result, err := sqes.GetStateRange(prefix)
+	if err != nil {
+		return nil, "", err
+	}
+	page, nextBookmark := StateRangePage(result, bookmark, pageSize)
+	return page, nextBookmark, nil
+}
+
 type ResultsIteratorShim struct {
 	ResultsIterator commonledger.ResultsIterator
 }
@@ -12,7 +12,9 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/hyperledger/fabric/core/chaincode/lifecycle"
 	"github.com/hyperledger/fabric/core/chaincode/lifecycle/mock"
 )
@@ -85,6 +87,69 @@ var _ = Describe("LedgerShims", func() {
 				})
 			})
 		})
+
+		Describe("GetStateRangeWithPagination", func() {
+			BeforeEach(func() {
+				fakeStub.GetStateByRangeWithPaginationReturns(fakeIterator, &pb.QueryResponseMetadata{
+					Bookmark: "next-bookmark",
+				}, nil)
+			})
+
+			It("passes through to the stub", func() {
+				res, bookmark, err := cls.GetStateRangeWithPagination("fake-prefix", "fake-bookmark", 10)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res).To(Equal(map[string][]byte{
+					"fake-prefix-key": []byte("fake-value"),
+				}))
+				Expect(bookmark).To(Equal("next-bookmark"))
+				Expect(fakeStub.GetStateByRangeWithPaginationCallCount()).To(Equal(1))
+				start, end, pageSize, passedBookmark := fakeStub.GetStateByRangeWithPaginationArgsForCall(0)
+				Expect(start).To(Equal("fake-prefix"))
+				Expect(end).To(Equal("fake-prefix\x7f"))
+				Expect(pageSize).To(Equal(int32(10)))
+				Expect(passedBookmark).To(Equal("fake-bookmark"))
+			})
+
+			Context("when the iterator cannot be retrieved", func() {
+				BeforeEach(func() {
+					fakeStub.GetStateByRangeWithPaginationReturns(nil, nil, fmt.Errorf("error-by-range"))
+				})
+
+				It("wraps and returns the error", func() {
+					_, _, err := cls.GetStateRangeWithPagination("fake-prefix", "", 10)
+					Expect(err).To(MatchError("could not get state iterator: error-by-range"))
+				})
+			})
+		})
+	})
+
+	Describe("StateRangePage", func() {
+		var kvs map[string][]byte
+
+		BeforeEach(func() {
+			kvs = map[string][]byte{
+				"key-a": []byte("value-a"),
+				"key-b": []byte("value-b"),
+				"key-c": []byte("value-c"),
+			}
+		})
+
+		It("returns the page and the bookmark of the next page", func() {
+			page, bookmark := lifecycle.StateRangePage(kvs, "", 2)
+			Expect(page).To(Equal(map[string][]byte{
+				"key-a": []byte("value-a"),
+				"key-b": []byte("value-b"),
+			}))
+			Expect(bookmark).To(Equal("key-c"))
+		})
+
+		It("starts the page at the bookmark", func() {
+			page, bookmark := lifecycle.StateRangePage(kvs, "key-c", 2)
+			Expect(page).To(Equal(map[string][]byte{
+				"key-c": []byte("value-c"),
+			}))
+			Expect(bookmark).To(BeEmpty())
+		})
 	})
 
 	Describe("ChaincodePrivateLedgerShim", func() {
@@ -190,6 +255,28 @@ var _ = Describe("LedgerShims", func() {
 				Expect(key).To(Equal("fake-key"))
 			})
 		})
+
+		Describe("GetTxID", func() {
+			BeforeEach(func() {
+				fakeStub.GetTxIDReturns("fake-txid")
+			})
+
+			It("passes through to the chaincode stub", func() {
+				Expect(cls.GetTxID()).To(Equal("fake-txid"))
+			})
+		})
+
+		Describe("GetTxTimestamp", func() {
+			BeforeEach(func() {
+				fakeStub.GetTxTimestampReturns(&timestamp.Timestamp{Seconds: 5}, fmt.Errorf("fake-timestamp-error"))
+			})
+
+			It("passes through to the chaincode stub", func() {
+				res, err := cls.GetTxTimestamp()
+				Expect(res).To(Equal(&timestamp.Timestamp{Seconds: 5}))
+				Expect(err).To(MatchError("fake-timestamp-error"))
+			})
+		})
 	})
 
 	Describe("SimpleQueryExecutorShim", func() {
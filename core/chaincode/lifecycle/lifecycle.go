@@ -8,26 +8,51 @@ package lifecycle
 
 import (
 	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	cb "github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
 	"github.com/hyperledger/fabric-protos-go/msp"
 	pb "github.com/hyperledger/fabric-protos-go/peer"
 	lb "github.com/hyperledger/fabric-protos-go/peer/lifecycle"
 	"github.com/hyperledger/fabric/common/chaincode"
 	"github.com/hyperledger/fabric/common/flogging"
+	"github.com/hyperledger/fabric/common/policies"
 	"github.com/hyperledger/fabric/common/policydsl"
+	"github.com/hyperledger/fabric/common/util"
 	"github.com/hyperledger/fabric/core/chaincode/persistence"
 	"github.com/hyperledger/fabric/core/container"
 	"github.com/hyperledger/fabric/protoutil"
 
+	"github.com/golang/protobuf/jsonpb"
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	version "github.com/hashicorp/go-version"
 	"github.com/pkg/errors"
 )
 
 var logger = flogging.MustGetLogger("lifecycle")
 
+// decorateLogger returns the logger annotated with the channel, chaincode
+// name, and sequence of a lifecycle operation, so that the logs of
+// concurrent operations can be told apart.
+func decorateLogger(logger *flogging.FabricLogger, chname, ccname string, sequence int64) *flogging.FabricLogger {
+	return logger.With("channel", chname, "chaincode", ccname, "sequence", sequence)
+}
+
 const (
 	// NamespacesName is the prefix (or namespace) of the DB which will be used to store
 	// the information about other namespaces (for things like chaincodes) in the DB.
@@ -35,6 +60,10 @@ const (
 	// in the future.
 	NamespacesName = "namespaces"
 
+	// ApprovalLogInfix is the infix under which the attempts to approve a chaincode definition are
+	// recorded in the org's implicit collection, when the approval audit log is enabled.
+	ApprovalLogInfix = "approval-log"
+
 	// ChaincodeSourcesName is the namespace reserved for storing the information about where
 	// to find the chaincode (such as as a package on the local filesystem, or in the future,
 	// at some network resource). This namespace is only populated in the org implicit collection.
@@ -122,6 +151,14 @@ type ChaincodeParameters struct {
 
 func (cp *ChaincodeParameters) Equal(ocp *ChaincodeParameters) error {
 	switch {
+	case cp.EndorsementInfo == nil:
+		return errors.New("expected EndorsementInfo is nil")
+	case ocp.EndorsementInfo == nil:
+		return errors.New("passed EndorsementInfo is nil")
+	case cp.ValidationInfo == nil:
+		return errors.New("expected ValidationInfo is nil")
+	case ocp.ValidationInfo == nil:
+		return errors.New("passed ValidationInfo is nil")
 	case cp.EndorsementInfo.Version != ocp.EndorsementInfo.Version:
 		return errors.Errorf("expected Version '%s' does not match passed Version '%s'", cp.EndorsementInfo.Version, ocp.EndorsementInfo.Version)
 	case cp.EndorsementInfo.EndorsementPlugin != ocp.EndorsementInfo.EndorsementPlugin:
@@ -132,13 +169,125 @@ func (cp *ChaincodeParameters) Equal(ocp *ChaincodeParameters) error {
 		return errors.Errorf("expected ValidationPlugin '%s' does not match passed ValidationPlugin '%s'", cp.ValidationInfo.ValidationPlugin, ocp.ValidationInfo.ValidationPlugin)
 	case !bytes.Equal(cp.ValidationInfo.ValidationParameter, ocp.ValidationInfo.ValidationParameter):
 		return errors.Errorf("expected ValidationParameter '%x' does not match passed ValidationParameter '%x'", cp.ValidationInfo.ValidationParameter, ocp.ValidationInfo.ValidationParameter)
-	case !proto.Equal(cp.Collections, ocp.Collections):
+	case !collectionsEqual(cp.Collections, ocp.Collections):
 		return errors.Errorf("Collections do not match")
 	default:
 	}
 	return nil
 }
 
+// collectionsEqual returns whether the collection config packages are equal,
+// treating a nil package as equivalent to an empty one.  The collections are
+// compared in the order listed, as approvals are stored and matched in that
+// order; only channels which store collections in canonical order sort them,
+// and they do so before comparing.
+func collectionsEqual(a, b *pb.CollectionConfigPackage) bool {
+	if a == nil {
+		a = &pb.CollectionConfigPackage{}
+	}
+	if b == nil {
+		b = &pb.CollectionConfigPackage{}
+	}
+	return proto.Equal(a, b)
+}
+
+// sortedCollections returns a copy of the collection config package with its
+// collections sorted by name.  The collections themselves are not copied, and
+// a nil package is returned as an empty one.
+func sortedCollections(collections *pb.CollectionConfigPackage) *pb.CollectionConfigPackage {
+	sorted := &pb.CollectionConfigPackage{
+		Config: append([]*pb.CollectionConfig(nil), collections.GetConfig()...),
+	}
+	sort.SliceStable(sorted.Config, func(i, j int) bool {
+		return sorted.Config[i].GetStaticCollectionConfig().GetName() < sorted.Config[j].GetStaticCollectionConfig().GetName()
+	})
+	return sorted
+}
+
+// Diff returns a human readable description of each parameter which differs
+// between these parameters and the passed parameters, in the form
+// "<field> <old> -> <new>".  Unlike Equal, which stops at the first mismatch,
+// every difference is reported.  An empty result indicates the parameters match.
+func (cp *ChaincodeParameters) Diff(ocp *ChaincodeParameters) []string {
+	var diffs []string
+	if cp.EndorsementInfo.Version != ocp.EndorsementInfo.Version {
+		diffs = append(diffs, fmt.Sprintf("Version '%s' -> '%s'", cp.EndorsementInfo.Version, ocp.EndorsementInfo.Version))
+	}
+	if cp.EndorsementInfo.EndorsementPlugin != ocp.EndorsementInfo.EndorsementPlugin {
+		diffs = append(diffs, fmt.Sprintf("EndorsementPlugin '%s' -> '%s'", cp.EndorsementInfo.EndorsementPlugin, ocp.EndorsementInfo.EndorsementPlugin))
+	}
+	if cp.EndorsementInfo.InitRequired != ocp.EndorsementInfo.InitRequired {
+		diffs = append(diffs, fmt.Sprintf("InitRequired %t -> %t", cp.EndorsementInfo.InitRequired, ocp.EndorsementInfo.InitRequired))
+	}
+	if cp.ValidationInfo.ValidationPlugin != ocp.ValidationInfo.ValidationPlugin {
+		diffs = append(diffs, fmt.Sprintf("ValidationPlugin '%s' -> '%s'", cp.ValidationInfo.ValidationPlugin, ocp.ValidationInfo.ValidationPlugin))
+	}
+	if !bytes.Equal(cp.ValidationInfo.ValidationParameter, ocp.ValidationInfo.ValidationParameter) {
+		diffs = append(diffs, fmt.Sprintf("ValidationParameter '%x' -> '%x'", cp.ValidationInfo.ValidationParameter, ocp.ValidationInfo.ValidationParameter))
+	}
+	if !collectionsEqual(cp.Collections, ocp.Collections) {
+		diffs = append(diffs, "Collections changed")
+	}
+	return diffs
+}
+
+// SerializeBytes returns the canonical serialized form of the parameters.
+// This is a marshaled KVRWSet containing exactly the values which
+// ApproveChaincodeDefinitionForOrg writes for an approval, with the keys
+// made relative to the approval's private name: the metadata is written to
+// key 'metadata', and each field to a key of its field name.  Collections
+// are kept in the order given, and no channel defaults are applied; see
+// ExternalFunctions.SerializeParametersBytes for the form stored on a channel.
+func (cp *ChaincodeParameters) SerializeBytes() ([]byte, error) {
+	const privateName = "parameters"
+
+	state := memoryState{}
+	serializer := &Serializer{}
+	if err := serializer.Serialize(NamespacesName, privateName, cp, state); err != nil {
+		return nil, errors.WithMessage(err, "could not serialize chaincode parameters")
+	}
+
+	metadataKey := MetadataKey(NamespacesName, privateName)
+	metadata := &lb.StateMetadata{}
+	if err := proto.Unmarshal(state[metadataKey], metadata); err != nil {
+		return nil, errors.Wrapf(err, "could not unmarshal metadata for chaincode parameters")
+	}
+
+	kvrwSet := &kvrwset.KVRWSet{}
+	kvrwSet.Writes = append(kvrwSet.Writes, &kvrwset.KVWrite{
+		Key:   MetadataInfix,
+		Value: state[metadataKey],
+	})
+	for _, field := range metadata.Fields {
+		kvrwSet.Writes = append(kvrwSet.Writes, &kvrwset.KVWrite{
+			Key:   field,
+			Value: state[FieldKey(NamespacesName, privateName, field)],
+		})
+	}
+
+	return protoutil.Marshal(kvrwSet)
+}
+
+// Hash returns the SHA-256 digest of the serialized form of the parameters,
+// as returned by SerializeBytes once the collections have been sorted by name.
+// The digest therefore does not depend on the order in which the collections
+// are listed, and because the fields are re-marshaled when serialized,
+// parameters for which Equal returns nil have identical hashes.
+func (cp *ChaincodeParameters) Hash() ([]byte, error) {
+	sorted := &ChaincodeParameters{
+		EndorsementInfo: cp.EndorsementInfo,
+		ValidationInfo:  cp.ValidationInfo,
+		Collections:     sortedCollections(cp.Collections),
+	}
+
+	serializedParameters, err := sorted.SerializeBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	return util.ComputeSHA256(serializedParameters), nil
+}
+
 // ChaincodeDefinition contains the chaincode parameters, as well as the sequence number of the definition.
 // Note, it does not embed ChaincodeParameters so as not to complicate the serialization.  It is expected
 // that any instance will have no nil fields once initialized.
@@ -151,6 +300,31 @@ type ChaincodeDefinition struct {
 	Collections     *pb.CollectionConfigPackage
 }
 
+// NewChaincodeDefinition returns a chaincode definition with the supplied
+// fields, enforcing that it has no nil fields.  The endorsement and validation
+// info are required, and a nil collection config package is replaced by an
+// empty one.
+func NewChaincodeDefinition(sequence int64, ei *lb.ChaincodeEndorsementInfo, vi *lb.ChaincodeValidationInfo, col *pb.CollectionConfigPackage) (*ChaincodeDefinition, error) {
+	if ei == nil {
+		return nil, errors.New("chaincode definition must specify endorsement info")
+	}
+
+	if vi == nil {
+		return nil, errors.New("chaincode definition must specify validation info")
+	}
+
+	if col == nil {
+		col = &pb.CollectionConfigPackage{}
+	}
+
+	return &ChaincodeDefinition{
+		Sequence:        sequence,
+		EndorsementInfo: ei,
+		ValidationInfo:  vi,
+		Collections:     col,
+	}, nil
+}
+
 type ApprovedChaincodeDefinition struct {
 	Sequence        int64
 	EndorsementInfo *lb.ChaincodeEndorsementInfo
@@ -159,7 +333,10 @@ type ApprovedChaincodeDefinition struct {
 	Source          *lb.ChaincodeSource
 }
 
-// Parameters returns the non-sequence info of the chaincode definition
+// Parameters returns the non-sequence info of the chaincode definition.  The
+// returned parameters share their messages with the definition, so modifying
+// them modifies the definition; use ParametersCopy to obtain parameters which
+// may be safely modified.
 func (cd *ChaincodeDefinition) Parameters() *ChaincodeParameters {
 	return &ChaincodeParameters{
 		EndorsementInfo: cd.EndorsementInfo,
@@ -168,6 +345,142 @@ func (cd *ChaincodeDefinition) Parameters() *ChaincodeParameters {
 	}
 }
 
+// ParametersCopy returns the non-sequence info of the chaincode definition
+// like Parameters, but the returned parameters share no messages with the
+// definition.
+func (cd *ChaincodeDefinition) ParametersCopy() *ChaincodeParameters {
+	return cd.DeepCopy().Parameters()
+}
+
+// SetApplicationPolicy marshals the application policy into the
+// validation parameter of the chaincode definition.
+func (cd *ChaincodeDefinition) SetApplicationPolicy(p *pb.ApplicationPolicy) error {
+	policyBytes, err := proto.Marshal(p)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal application policy")
+	}
+
+	if cd.ValidationInfo == nil {
+		cd.ValidationInfo = &lb.ChaincodeValidationInfo{}
+	}
+	cd.ValidationInfo.ValidationParameter = policyBytes
+	return nil
+}
+
+// GetApplicationPolicy unmarshals the validation parameter of the chaincode
+// definition as an application policy.  It returns an error if the validation
+// parameter does not encode an application policy.
+func (cd *ChaincodeDefinition) GetApplicationPolicy() (*pb.ApplicationPolicy, error) {
+	p := &pb.ApplicationPolicy{}
+	if err := proto.Unmarshal(cd.ValidationInfo.GetValidationParameter(), p); err != nil {
+		return nil, errors.Wrap(err, "validation parameter is not a valid application policy")
+	}
+
+	if p.Type == nil {
+		return nil, errors.New("validation parameter is not a valid application policy: no policy type set")
+	}
+
+	return p, nil
+}
+
+// DeepCopy returns a copy of the chaincode definition which shares no
+// messages with the original, so that it may be safely modified.
+func (cd *ChaincodeDefinition) DeepCopy() *ChaincodeDefinition {
+	return &ChaincodeDefinition{
+		Sequence:        cd.Sequence,
+		EndorsementInfo: proto.Clone(cd.EndorsementInfo).(*lb.ChaincodeEndorsementInfo),
+		ValidationInfo:  proto.Clone(cd.ValidationInfo).(*lb.ChaincodeValidationInfo),
+		Collections:     proto.Clone(cd.Collections).(*pb.CollectionConfigPackage),
+	}
+}
+
+// chaincodeDefinitionJSON is the JSON encoding of a ChaincodeDefinition.
+// encoding/json writes struct fields in declaration order, and the proto JSON
+// marshaler writes message fields in field number order, so the encoding is
+// deterministic.
+type chaincodeDefinitionJSON struct {
+	Sequence        int64           `json:"sequence"`
+	EndorsementInfo json.RawMessage `json:"endorsement_info,omitempty"`
+	ValidationInfo  json.RawMessage `json:"validation_info,omitempty"`
+	Collections     json.RawMessage `json:"collections,omitempty"`
+}
+
+// MarshalJSON encodes the chaincode definition as JSON.  The embedded messages
+// are encoded with the proto JSON marshaler, which encodes byte fields such as
+// the validation parameter as base64.  Nil messages are omitted.
+func (cd *ChaincodeDefinition) MarshalJSON() ([]byte, error) {
+	var err error
+	encoded := &chaincodeDefinitionJSON{
+		Sequence: cd.Sequence,
+	}
+
+	if encoded.EndorsementInfo, err = marshalProtoJSON(cd.EndorsementInfo); err != nil {
+		return nil, errors.Wrap(err, "could not marshal endorsement info")
+	}
+	if encoded.ValidationInfo, err = marshalProtoJSON(cd.ValidationInfo); err != nil {
+		return nil, errors.Wrap(err, "could not marshal validation info")
+	}
+	if encoded.Collections, err = marshalProtoJSON(cd.Collections); err != nil {
+		return nil, errors.Wrap(err, "could not marshal collections")
+	}
+
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON decodes a chaincode definition encoded by MarshalJSON.
+// Messages which are omitted from the encoding are left nil.
+func (cd *ChaincodeDefinition) UnmarshalJSON(data []byte) error {
+	encoded := &chaincodeDefinitionJSON{}
+	if err := json.Unmarshal(data, encoded); err != nil {
+		return errors.Wrap(err, "could not unmarshal chaincode definition")
+	}
+
+	decoded := &ChaincodeDefinition{
+		Sequence: encoded.Sequence,
+	}
+
+	if isEncoded(encoded.EndorsementInfo) {
+		decoded.EndorsementInfo = &lb.ChaincodeEndorsementInfo{}
+		if err := jsonpb.Unmarshal(bytes.NewReader(encoded.EndorsementInfo), decoded.EndorsementInfo); err != nil {
+			return errors.Wrap(err, "could not unmarshal endorsement info")
+		}
+	}
+	if isEncoded(encoded.ValidationInfo) {
+		decoded.ValidationInfo = &lb.ChaincodeValidationInfo{}
+		if err := jsonpb.Unmarshal(bytes.NewReader(encoded.ValidationInfo), decoded.ValidationInfo); err != nil {
+			return errors.Wrap(err, "could not unmarshal validation info")
+		}
+	}
+	if isEncoded(encoded.Collections) {
+		decoded.Collections = &pb.CollectionConfigPackage{}
+		if err := jsonpb.Unmarshal(bytes.NewReader(encoded.Collections), decoded.Collections); err != nil {
+			return errors.Wrap(err, "could not unmarshal collections")
+		}
+	}
+
+	*cd = *decoded
+	return nil
+}
+
+// marshalProtoJSON encodes a message with the proto JSON marshaler, returning
+// nil for a nil message.
+func marshalProtoJSON(msg proto.Message) (json.RawMessage, error) {
+	if reflect.ValueOf(msg).IsNil() {
+		return nil, nil
+	}
+
+	buf := &bytes.Buffer{}
+	if err := (&jsonpb.Marshaler{OrigName: true}).Marshal(buf, msg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// isEncoded returns whether a message was included in an encoding.
+func isEncoded(raw json.RawMessage) bool {
+	return len(raw) > 0 && !bytes.Equal(raw, []byte("null"))
+}
+
 func (cd *ChaincodeDefinition) String() string {
 	endorsementInfo := "endorsement info: <EMPTY>"
 	if cd.EndorsementInfo != nil {
@@ -194,6 +507,148 @@ func (cd *ChaincodeDefinition) String() string {
 	)
 }
 
+// ValidateCollections performs stateless checks of the collections in the
+// chaincode definition, so that malformed collection configs are rejected at
+// approve and commit time rather than at first use.  These are the checks the
+// lifecycle SCC makes before validating the collections against the channel's
+// MSPs.  Note that BlockToLive is unsigned in the collection config, so it is
+// always non-negative.
+func ValidateCollections(cd *ChaincodeDefinition) error {
+	collConfigs, err := extractStaticCollectionConfigs(cd.Collections)
+	if err != nil {
+		return err
+	}
+
+	return validateStaticCollectionConfigs(collConfigs)
+}
+
+// validateStaticCollectionConfigs performs the checks of ValidateCollections on
+// the extracted static collection configs.
+func validateStaticCollectionConfigs(collConfigs []*pb.StaticCollectionConfig) error {
+	collNames := map[string]struct{}{}
+	for _, coll := range collConfigs {
+		if !collectionNameRegExp.MatchString(coll.Name) {
+			return errors.Errorf("invalid collection name '%s'. Names can only consist of alphanumerics, '_', and '-' and cannot begin with '_'", coll.Name)
+		}
+
+		// Ensure that there are no duplicate collection names
+		if _, ok := collNames[coll.Name]; ok {
+			return errors.Errorf("collection-name: %s -- found duplicate in collection configuration", coll.Name)
+		}
+		collNames[coll.Name] = struct{}{}
+
+		// Validate gossip related parameters present in the collection config
+		if coll.MaximumPeerCount < coll.RequiredPeerCount {
+			return errors.Errorf("collection-name: %s -- maximum peer count (%d) cannot be less than the required peer count (%d)", coll.Name, coll.MaximumPeerCount, coll.RequiredPeerCount)
+		}
+
+		if coll.RequiredPeerCount < 0 {
+			return errors.Errorf("collection-name: %s -- requiredPeerCount (%d) cannot be less than zero", coll.Name, coll.RequiredPeerCount)
+		}
+
+		if coll.MemberOrgsPolicy == nil {
+			return errors.Errorf("collection member policy is not set for collection '%s'", coll.Name)
+		}
+
+		if coll.MemberOrgsPolicy.GetSignaturePolicy() == nil {
+			return errors.Errorf("collection member org policy is empty for collection '%s'", coll.Name)
+		}
+
+		if err := checkDuplicateMemberOrgs(coll.Name, coll.MemberOrgsPolicy.GetSignaturePolicy()); err != nil {
+			return err
+		}
+
+		if err := checkCollectionEndorsementPolicy(coll); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkCollectionEndorsementPolicy returns an error if a collection's signature
+// endorsement policy references an org which is not a member of the collection.
+// Endorsement policies which reference a channel config policy are not checked.
+func checkCollectionEndorsementPolicy(coll *pb.StaticCollectionConfig) error {
+	endorsementPolicy := coll.EndorsementPolicy.GetSignaturePolicy()
+	if endorsementPolicy == nil {
+		return nil
+	}
+
+	memberOrgs, err := roleMSPIDs(coll.Name, "member org policy", coll.MemberOrgsPolicy.GetSignaturePolicy())
+	if err != nil {
+		return err
+	}
+
+	endorsingOrgs, err := roleMSPIDs(coll.Name, "endorsement policy", endorsementPolicy)
+	if err != nil {
+		return err
+	}
+
+	for _, mspID := range endorsingOrgs {
+		found := false
+		for _, memberOrg := range memberOrgs {
+			if memberOrg == mspID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.Errorf("collection-name: %s -- endorsement policy references org %s which is not a member of the collection", coll.Name, mspID)
+		}
+	}
+
+	return nil
+}
+
+// roleMSPIDs returns the MSP IDs of the org role principals of a signature
+// policy, in the order in which they appear.
+func roleMSPIDs(collName, policyName string, policy *cb.SignaturePolicyEnvelope) ([]string, error) {
+	var mspIDs []string
+	for _, principal := range policy.Identities {
+		if principal.PrincipalClassification != msp.MSPPrincipal_ROLE {
+			continue
+		}
+
+		mspRole := &msp.MSPRole{}
+		if err := proto.Unmarshal(principal.Principal, mspRole); err != nil {
+			return nil, errors.Wrapf(err, "collection-name: %s -- could not unmarshal %s principal", collName, policyName)
+		}
+		mspIDs = append(mspIDs, mspRole.MspIdentifier)
+	}
+
+	return mspIDs, nil
+}
+
+// checkDuplicateMemberOrgs returns an error if the same org role principal
+// appears more than once in a collection's member org policy.
+func checkDuplicateMemberOrgs(collName string, policy *cb.SignaturePolicyEnvelope) error {
+	type orgRole struct {
+		mspID string
+		role  msp.MSPRole_MSPRoleType
+	}
+
+	seen := map[orgRole]struct{}{}
+	for _, principal := range policy.Identities {
+		if principal.PrincipalClassification != msp.MSPPrincipal_ROLE {
+			continue
+		}
+
+		mspRole := &msp.MSPRole{}
+		if err := proto.Unmarshal(principal.Principal, mspRole); err != nil {
+			return errors.Wrapf(err, "collection-name: %s -- cannot unmarshal identity bytes into MSPRole", collName)
+		}
+
+		key := orgRole{mspID: mspRole.MspIdentifier, role: mspRole.Role}
+		if _, ok := seen[key]; ok {
+			return errors.Errorf("collection-name: %s -- member org policy contains duplicate principal for org %s", collName, mspRole.MspIdentifier)
+		}
+		seen[key] = struct{}{}
+	}
+
+	return nil
+}
+
 //go:generate counterfeiter -o mock/chaincode_builder.go --fake-name ChaincodeBuilder . ChaincodeBuilder
 
 type ChaincodeBuilder interface {
@@ -203,13 +658,18 @@ type ChaincodeBuilder interface {
 // ChaincodeStore provides a way to persist chaincodes
 type ChaincodeStore interface {
 	Save(label string, ccInstallPkg []byte) (string, error)
+	SaveWithContext(ctx context.Context, label string, ccInstallPkg []byte) (string, error)
+	SaveStream(r io.Reader, maxSize int64) (string, *persistence.ChaincodePackageMetadata, error)
 	ListInstalledChaincodes() ([]chaincode.InstalledChaincode, error)
+	Count() (int, error)
 	Load(packageID string) (ccInstallPkg []byte, err error)
+	RetrieveHashByPackageID(packageID string) ([]byte, error)
 	Delete(packageID string) error
 }
 
 type PackageParser interface {
 	Parse(data []byte) (*persistence.ChaincodePackage, error)
+	ParseStream(r io.Reader) (*persistence.ChaincodePackageMetadata, error)
 }
 
 //go:generate counterfeiter -o mock/install_listener.go --fake-name InstallListener . InstallListener
@@ -217,12 +677,146 @@ type InstallListener interface {
 	HandleChaincodeInstalled(md *persistence.ChaincodePackageMetadata, packageID string)
 }
 
+//go:generate counterfeiter -o mock/commit_listener.go --fake-name CommitListener . CommitListener
+
+// CommitListener is notified when a new sequence of a chaincode definition
+// is committed to the ledger of a channel.
+type CommitListener interface {
+	HandleChaincodeCommitted(channelID, name string, cd *ChaincodeDefinition)
+}
+
+//go:generate counterfeiter -o mock/approval_endorsement_listener.go --fake-name ApprovalEndorsementListener . ApprovalEndorsementListener
+
+// ApprovalEndorsementListener is notified when this peer endorses an approval
+// of a chaincode definition for its org, along with the ID of the package
+// being approved.  It is invoked during transaction simulation, before the
+// approval has been ordered or validated, so the approval may never take
+// effect.
+type ApprovalEndorsementListener interface {
+	HandleChaincodeApprovalEndorsed(name string, cd *ChaincodeDefinition, packageID string)
+}
+
+//go:generate counterfeiter -o mock/audit_sink.go --fake-name AuditSink . AuditSink
+
+// AuditSink records an append-only trail of committed chaincode definitions.
+type AuditSink interface {
+	// RecordCommit is invoked with the digest of the parameters of each
+	// chaincode definition committed to the ledger of a channel.
+	RecordCommit(channelID, name string, sequence int64, digest []byte)
+}
+
+//go:generate counterfeiter -o mock/sequence_policy.go --fake-name SequencePolicy . SequencePolicy
+
+// SequencePolicy validates the sequence number an org is approving
+// a chaincode definition for, given the currently defined sequence.
+type SequencePolicy interface {
+	ValidateApprovalSequence(current, requested int64) error
+}
+
+// StrictSequencePolicy only permits approvals for the currently defined
+// sequence or the next sequence.
+type StrictSequencePolicy struct{}
+
+// ValidateApprovalSequence returns an error if the requested sequence is
+// neither the current sequence nor the next sequence, or if it is zero.
+func (StrictSequencePolicy) ValidateApprovalSequence(current, requested int64) error {
+	if current == requested && requested == 0 {
+		return errors.Errorf("requested sequence is 0, but first definable sequence number is 1")
+	}
+
+	if requested < current {
+		return errors.Errorf("currently defined sequence %d is larger than requested sequence %d", current, requested)
+	}
+
+	if requested > current+1 {
+		return errors.Errorf("requested sequence %d is larger than the next available sequence number %d", requested, current+1)
+	}
+
+	return nil
+}
+
+//go:generate counterfeiter -o mock/namespace_reservation.go --fake-name NamespaceReservation . NamespaceReservation
+
+// NamespaceReservation determines which orgs may define a chaincode name, so
+// that names may be reserved for particular orgs.
+type NamespaceReservation interface {
+	// CanDefine returns an error if the chaincode name may not be defined
+	// by a definition which the given orgs have approved.
+	CanDefine(name string, committingOrgs []string) error
+}
+
+// UnreservedNamespaces permits any orgs to define any chaincode name.
+type UnreservedNamespaces struct{}
+
+// CanDefine always returns nil.
+func (UnreservedNamespaces) CanDefine(name string, committingOrgs []string) error {
+	return nil
+}
+
+//go:generate counterfeiter -o mock/plugin_registry.go --fake-name PluginRegistry . PluginRegistry
+
+// PluginRegistry reports which endorsement and validation plugins are
+// available to the peer.
+type PluginRegistry interface {
+	EndorsementPluginExists(name string) bool
+	ValidationPluginExists(name string) bool
+}
+
+//go:generate counterfeiter -o mock/package_capability_checker.go --fake-name PackageCapabilityChecker . PackageCapabilityChecker
+
+// PackageCapabilityChecker determines the capabilities advertised by an
+// installed chaincode package.
+type PackageCapabilityChecker interface {
+	SupportsInit(md *persistence.ChaincodePackageMetadata) bool
+}
+
 //go:generate counterfeiter -o mock/installed_chaincodes_lister.go --fake-name InstalledChaincodesLister . InstalledChaincodesLister
 type InstalledChaincodesLister interface {
 	ListInstalledChaincodes() []*chaincode.InstalledChaincode
 	GetInstalledChaincode(packageID string) (*chaincode.InstalledChaincode, error)
 }
 
+// Datatype describes a datatype which may be recorded in the metadata of the
+// namespaces namespace.
+type Datatype struct {
+	// Type is the struct type the datatype is serialized from.
+	Type reflect.Type
+
+	// FriendlyName is the name exposed to the outside world for the datatype.
+	FriendlyName string
+}
+
+// DatatypeRegistry maps the datatype names recorded in namespace metadata to
+// their descriptions.
+type DatatypeRegistry map[string]Datatype
+
+// DefaultDatatypeRegistry returns a registry containing the datatypes defined
+// by the lifecycle, to which further datatypes may be registered.
+func DefaultDatatypeRegistry() DatatypeRegistry {
+	dr := DatatypeRegistry{}
+	dr.Register(&ChaincodeDefinition{}, FriendlyChaincodeDefinitionType)
+	return dr
+}
+
+// Register adds the datatype of the given structure, which must be a pointer
+// to a struct, under its type name.
+func (dr DatatypeRegistry) Register(structure interface{}, friendlyName string) {
+	structType := reflect.TypeOf(structure).Elem()
+	dr[structType.Name()] = Datatype{
+		Type:         structType,
+		FriendlyName: friendlyName,
+	}
+}
+
+// FriendlyName returns the friendly name of the datatype, or the datatype
+// itself if it is not registered.
+func (dr DatatypeRegistry) FriendlyName(datatype string) string {
+	if registered, ok := dr[datatype]; ok {
+		return registered.FriendlyName
+	}
+	return datatype
+}
+
 // Resources stores the common functions needed by all components of the lifecycle
 // by the SCC as well as internally.  It also has some utility methods attached to it
 // for querying the lifecycle definitions.
@@ -231,55 +825,383 @@ type Resources struct {
 	ChaincodeStore      ChaincodeStore
 	PackageParser       PackageParser
 	Serializer          *Serializer
+
+	// CachePolicies enables caching of the endorsement policies resolved
+	// from the channel config.  When set, InvalidatePolicyCache must be
+	// invoked whenever the config of a channel is updated.
+	CachePolicies bool
+
+	// CacheDefinitions enables caching of the chaincode definitions read by
+	// ChaincodeDefinitionIfDefinedInChannel.  A cached definition is only
+	// used while the committed sequence of the chaincode is unchanged.
+	CacheDefinitions bool
+
+	// MaxSequence is the largest sequence number a chaincode definition may
+	// be approved or committed at.  Zero means there is no limit.
+	MaxSequence int64
+
+	// SequencePolicy determines which sequences an org may approve a
+	// definition for.  When nil, StrictSequencePolicy is used.
+	SequencePolicy SequencePolicy
+
+	// NamespaceReservation determines which orgs may commit a definition
+	// for a chaincode name.  When nil, UnreservedNamespaces is used.
+	NamespaceReservation NamespaceReservation
+
+	// PluginRegistry, when set, is used to reject definitions which
+	// reference endorsement or validation plugins that do not exist.
+	PluginRegistry PluginRegistry
+
+	// RequirePackage, when set, rejects approvals which do not specify
+	// a package ID for the org's peers to run the chaincode with.
+	RequirePackage bool
+
+	// RequireValidationPolicy, when set, rejects approvals using the builtin
+	// validation plugin whose validation parameter is not a non-empty
+	// application policy, as such a definition can never be satisfied.
+	RequireValidationPolicy bool
+
+	// RejectInitRequiredChange, when set, rejects approvals which change
+	// InitRequired from the committed definition, unless the change is
+	// explicitly allowed with ApproveChaincodeDefinitionForOrgWithInitChange.
+	RejectInitRequiredChange bool
+
+	// EnforceVersionMonotonic, when set, rejects approvals whose semver
+	// version is lower than the version of the committed definition.
+	// Versions which are not semver are not checked.
+	EnforceVersionMonotonic bool
+
+	// RequireAgreementToCommit, when set, rejects commits of definitions
+	// which none of the supplied orgs have approved.
+	RequireAgreementToCommit bool
+
+	// RequireCollectionMembersInChannel, when set, rejects approvals and
+	// commits of definitions whose collection member org policies fail the
+	// validation the lifecycle SCC applies when approving, most notably
+	// those referencing an MSP which is not part of the channel.  This
+	// allows definitions which bypass the SCC to be held to the same checks.
+	RequireCollectionMembersInChannel bool
+
+	// DatatypeRegistry describes the datatypes which may be defined in the
+	// namespaces namespace.  When nil, DefaultDatatypeRegistry is used.
+	DatatypeRegistry DatatypeRegistry
+
+	policyCacheMutex sync.Mutex
+	policyCache      map[string]map[string][]byte
+
+	definitionCacheMutex sync.Mutex
+	definitionCache      map[string]map[string]*ChaincodeDefinition
 }
 
-// ChaincodeDefinitionIfDefined returns whether the chaincode name is defined in the new lifecycle, a shim around
-// the SimpleQueryExecutor to work with the serializer, or an error.  If the namespace is defined, but it is
-// not a chaincode, this is considered an error.
-func (r *Resources) ChaincodeDefinitionIfDefined(chaincodeName string, state ReadableState) (bool, *ChaincodeDefinition, error) {
-	if chaincodeName == LifecycleNamespace {
-		return true, &ChaincodeDefinition{
-			EndorsementInfo: &lb.ChaincodeEndorsementInfo{
-				InitRequired: false,
-			},
-			ValidationInfo: &lb.ChaincodeValidationInfo{},
-		}, nil
+// sequencePolicy returns the configured sequence policy, or the strict
+// sequence policy if none is configured.
+func (r *Resources) sequencePolicy() SequencePolicy {
+	if r.SequencePolicy == nil {
+		return StrictSequencePolicy{}
 	}
+	return r.SequencePolicy
+}
 
-	metadata, ok, err := r.Serializer.DeserializeMetadata(NamespacesName, chaincodeName, state)
-	if err != nil {
-		return false, nil, errors.WithMessagef(err, "could not deserialize metadata for chaincode %s", chaincodeName)
+// namespaceReservation returns the configured namespace reservation, or one
+// which reserves no names if none is configured.
+func (r *Resources) namespaceReservation() NamespaceReservation {
+	if r.NamespaceReservation == nil {
+		return UnreservedNamespaces{}
 	}
+	return r.NamespaceReservation
+}
 
-	if !ok {
-		return false, nil, nil
+// datatypeRegistry returns the configured datatype registry, or the default
+// registry if none is configured.
+func (r *Resources) datatypeRegistry() DatatypeRegistry {
+	if r.DatatypeRegistry == nil {
+		return DefaultDatatypeRegistry()
 	}
+	return r.DatatypeRegistry
+}
 
-	if metadata.Datatype != ChaincodeDefinitionType {
-		return false, nil, errors.Errorf("not a chaincode type: %s", metadata.Datatype)
+// checkVersionMonotonic returns an error if version monotonicity is enforced
+// and the requested version is a lower semver than the committed version.
+func (r *Resources) checkVersionMonotonic(committedVersion, requestedVersion string) error {
+	if !r.EnforceVersionMonotonic {
+		return nil
 	}
 
-	definedChaincode := &ChaincodeDefinition{}
-	err = r.Serializer.Deserialize(NamespacesName, chaincodeName, metadata, definedChaincode, state)
+	committed, err := version.NewSemver(committedVersion)
 	if err != nil {
-		return false, nil, errors.WithMessagef(err, "could not deserialize chaincode definition for chaincode %s", chaincodeName)
+		logger.Warningf("Not checking version monotonicity, committed version '%s' is not a semver: %s", committedVersion, err)
+		return nil
 	}
 
-	return true, definedChaincode, nil
-}
-
-func (r *Resources) LifecycleEndorsementPolicyAsBytes(channelID string) ([]byte, error) {
-	channelConfig := r.ChannelConfigSource.GetStableChannelConfig(channelID)
-	if channelConfig == nil {
-		return nil, errors.Errorf("could not get channel config for channel '%s'", channelID)
+	requested, err := version.NewSemver(requestedVersion)
+	if err != nil {
+		logger.Warningf("Not checking version monotonicity, requested version '%s' is not a semver: %s", requestedVersion, err)
+		return nil
 	}
 
-	if _, ok := channelConfig.PolicyManager().GetPolicy(LifecycleEndorsementPolicyRef); ok {
-		return LifecycleDefaultEndorsementPolicyBytes, nil
+	if requested.LessThan(committed) {
+		return errors.Errorf("requested version '%s' is lower than the committed version '%s'", requestedVersion, committedVersion)
 	}
 
-	// This was a channel which was upgraded or did not define a lifecycle endorsement policy, use a default
-	// of "a majority of orgs must have a member sign".
+	return nil
+}
+
+// checkPlugins returns an error if the definition references an endorsement
+// or validation plugin which is not registered.  An empty plugin name selects
+// the builtin plugin, and is always accepted.
+func (r *Resources) checkPlugins(cd *ChaincodeDefinition) error {
+	if r.PluginRegistry == nil {
+		return nil
+	}
+
+	if plugin := cd.EndorsementInfo.GetEndorsementPlugin(); plugin != "" && !r.PluginRegistry.EndorsementPluginExists(plugin) {
+		return errors.Errorf("endorsement plugin '%s' is not registered", plugin)
+	}
+
+	if plugin := cd.ValidationInfo.GetValidationPlugin(); plugin != "" && !r.PluginRegistry.ValidationPluginExists(plugin) {
+		return errors.Errorf("validation plugin '%s' is not registered", plugin)
+	}
+
+	return nil
+}
+
+// checkChannelPolicyReference returns an error if the validation parameter of
+// the definition is a channel config policy reference which does not name an
+// existing policy in the channel config.  Validation parameters which are not
+// application policies are left to the validation plugin.
+func (r *Resources) checkChannelPolicyReference(channelID string, cd *ChaincodeDefinition) error {
+	if r.ChannelConfigSource == nil {
+		return nil
+	}
+
+	ap := &pb.ApplicationPolicy{}
+	if err := proto.Unmarshal(cd.ValidationInfo.GetValidationParameter(), ap); err != nil {
+		return nil
+	}
+
+	policyName := ap.GetChannelConfigPolicyReference()
+	if policyName == "" {
+		return nil
+	}
+
+	channelConfig := r.ChannelConfigSource.GetStableChannelConfig(channelID)
+	if channelConfig == nil {
+		return errors.Errorf("could not get channel config for channel '%s'", channelID)
+	}
+
+	if _, ok := channelConfig.PolicyManager().GetPolicy(policyName); !ok {
+		return errors.Errorf("channel config policy '%s' referenced by the validation parameter does not exist", policyName)
+	}
+
+	return nil
+}
+
+// checkCollectionMembers returns an error if collection members are required to
+// be in the channel and a collection's member org policy does not pass the
+// member org policy validation the lifecycle SCC performs on approval.
+func (r *Resources) checkCollectionMembers(channelID string, cd *ChaincodeDefinition) error {
+	if !r.RequireCollectionMembersInChannel || len(cd.Collections.GetConfig()) == 0 {
+		return nil
+	}
+
+	channelConfig := r.ChannelConfigSource.GetStableChannelConfig(channelID)
+	if channelConfig == nil {
+		return errors.Errorf("could not get channel config for channel '%s'", channelID)
+	}
+
+	mspMgr := channelConfig.MSPManager()
+	if mspMgr == nil {
+		return errors.Errorf("could not get MSP manager for channel '%s'", channelID)
+	}
+
+	for _, collConfig := range cd.Collections.Config {
+		coll := collConfig.GetStaticCollectionConfig()
+		if coll == nil {
+			continue
+		}
+
+		if err := validateCollectionConfigMemberOrgsPolicy(coll, mspMgr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkValidationPolicy returns an error if validation policies are required,
+// the definition uses the builtin validation plugin, and its validation
+// parameter does not decode to a non-empty application policy.
+func (r *Resources) checkValidationPolicy(cd *ChaincodeDefinition) error {
+	if !r.RequireValidationPolicy {
+		return nil
+	}
+
+	if plugin := cd.ValidationInfo.GetValidationPlugin(); plugin != "" && plugin != "vscc" {
+		return nil
+	}
+
+	ap := &pb.ApplicationPolicy{}
+	if err := proto.Unmarshal(cd.ValidationInfo.GetValidationParameter(), ap); err != nil {
+		return errors.Wrap(err, "validation parameter for the builtin validation plugin is not an application policy")
+	}
+
+	if ap.GetChannelConfigPolicyReference() == "" && ap.GetSignaturePolicy().GetRule() == nil {
+		return errors.New("validation parameter for the builtin validation plugin must be a non-empty application policy")
+	}
+
+	return nil
+}
+
+// checkMaxSequence returns an error if the requested sequence exceeds the
+// configured maximum sequence.
+func (r *Resources) checkMaxSequence(requestedSequence int64) error {
+	if r.MaxSequence != 0 && requestedSequence > r.MaxSequence {
+		return errors.Errorf("requested sequence %d exceeds the maximum allowed sequence %d", requestedSequence, r.MaxSequence)
+	}
+	return nil
+}
+
+// ChaincodeDefinitionIfDefined returns whether the chaincode name is defined in the new lifecycle, a shim around
+// the SimpleQueryExecutor to work with the serializer, or an error.  If the namespace is defined, but it is
+// not a chaincode, this is considered an error.
+func (r *Resources) ChaincodeDefinitionIfDefined(chaincodeName string, state ReadableState) (bool, *ChaincodeDefinition, error) {
+	if chaincodeName == LifecycleNamespace {
+		return true, &ChaincodeDefinition{
+			EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+				InitRequired: false,
+			},
+			ValidationInfo: &lb.ChaincodeValidationInfo{},
+		}, nil
+	}
+
+	metadata, ok, err := r.Serializer.DeserializeMetadata(NamespacesName, chaincodeName, state)
+	if err != nil {
+		return false, nil, errors.WithMessagef(err, "could not deserialize metadata for chaincode %s", chaincodeName)
+	}
+
+	if !ok {
+		return false, nil, nil
+	}
+
+	if metadata.Datatype != ChaincodeDefinitionType {
+		return false, nil, ErrNotChaincodeType{Datatype: metadata.Datatype}
+	}
+
+	definedChaincode := &ChaincodeDefinition{}
+	err = r.Serializer.Deserialize(NamespacesName, chaincodeName, metadata, definedChaincode, state)
+	if err != nil {
+		return false, nil, errors.WithMessagef(err, "could not deserialize chaincode definition for chaincode %s", chaincodeName)
+	}
+
+	return true, definedChaincode, nil
+}
+
+// ChaincodeDefinitionIfDefinedInChannel behaves like ChaincodeDefinitionIfDefined,
+// except that when definition caching is enabled, the definition read for the
+// chaincode in the channel is cached by its sequence.  On subsequent calls,
+// only the sequence is read from state, and the cached definition is returned
+// if the sequence is unchanged.  The returned definition is a copy, so it may
+// be safely modified.
+func (r *Resources) ChaincodeDefinitionIfDefinedInChannel(channelID, chaincodeName string, state ReadableState) (bool, *ChaincodeDefinition, error) {
+	if !r.CacheDefinitions || chaincodeName == LifecycleNamespace {
+		return r.ChaincodeDefinitionIfDefined(chaincodeName, state)
+	}
+
+	sequence, err := r.Serializer.DeserializeFieldAsInt64(NamespacesName, chaincodeName, "Sequence", state)
+	if err != nil {
+		return false, nil, errors.WithMessagef(err, "could not get sequence for chaincode %s", chaincodeName)
+	}
+
+	r.definitionCacheMutex.Lock()
+	cached, ok := r.definitionCache[channelID][chaincodeName]
+	r.definitionCacheMutex.Unlock()
+	if ok && sequence != 0 && cached.Sequence == sequence {
+		return true, cached.DeepCopy(), nil
+	}
+
+	exists, definedChaincode, err := r.ChaincodeDefinitionIfDefined(chaincodeName, state)
+	if err != nil || !exists {
+		return exists, definedChaincode, err
+	}
+
+	r.definitionCacheMutex.Lock()
+	if r.definitionCache == nil {
+		r.definitionCache = map[string]map[string]*ChaincodeDefinition{}
+	}
+	if r.definitionCache[channelID] == nil {
+		r.definitionCache[channelID] = map[string]*ChaincodeDefinition{}
+	}
+	r.definitionCache[channelID][chaincodeName] = definedChaincode.DeepCopy()
+	r.definitionCacheMutex.Unlock()
+
+	return true, definedChaincode, nil
+}
+
+// invalidateDefinitionCache discards the definition cached for the chaincode
+// in the channel.
+func (r *Resources) invalidateDefinitionCache(channelID, chaincodeName string) {
+	r.definitionCacheMutex.Lock()
+	defer r.definitionCacheMutex.Unlock()
+
+	delete(r.definitionCache[channelID], chaincodeName)
+}
+
+// InvalidatePolicyCache discards any endorsement policies cached for the
+// channel, so that they are resolved from the channel config on next use.
+func (r *Resources) InvalidatePolicyCache(channelID string) {
+	r.policyCacheMutex.Lock()
+	defer r.policyCacheMutex.Unlock()
+
+	delete(r.policyCache, channelID)
+}
+
+// cachedPolicyAsBytes returns the policy bytes previously resolved for the
+// channel and policy reference, invoking resolve when caching is disabled
+// or when there is no cached value.  Failures to resolve are not cached.
+func (r *Resources) cachedPolicyAsBytes(channelID, policyRef string, resolve func(channelID string) ([]byte, error)) ([]byte, error) {
+	if !r.CachePolicies {
+		return resolve(channelID)
+	}
+
+	r.policyCacheMutex.Lock()
+	defer r.policyCacheMutex.Unlock()
+
+	if policyBytes, ok := r.policyCache[channelID][policyRef]; ok {
+		return policyBytes, nil
+	}
+
+	policyBytes, err := resolve(channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.policyCache == nil {
+		r.policyCache = map[string]map[string][]byte{}
+	}
+	if r.policyCache[channelID] == nil {
+		r.policyCache[channelID] = map[string][]byte{}
+	}
+	r.policyCache[channelID][policyRef] = policyBytes
+
+	return policyBytes, nil
+}
+
+func (r *Resources) LifecycleEndorsementPolicyAsBytes(channelID string) ([]byte, error) {
+	return r.cachedPolicyAsBytes(channelID, LifecycleEndorsementPolicyRef, r.lifecycleEndorsementPolicyAsBytes)
+}
+
+func (r *Resources) lifecycleEndorsementPolicyAsBytes(channelID string) ([]byte, error) {
+	channelConfig := r.ChannelConfigSource.GetStableChannelConfig(channelID)
+	if channelConfig == nil {
+		return nil, errors.Errorf("could not get channel config for channel '%s'", channelID)
+	}
+
+	if _, ok := channelConfig.PolicyManager().GetPolicy(LifecycleEndorsementPolicyRef); ok {
+		return LifecycleDefaultEndorsementPolicyBytes, nil
+	}
+
+	// This was a channel which was upgraded or did not define a lifecycle endorsement policy, use a default
+	// of "a majority of orgs must have a member sign".
 	ac, ok := channelConfig.ApplicationConfig()
 	if !ok {
 		return nil, errors.Errorf("could not get application config for channel '%s'", channelID)
@@ -304,40 +1226,168 @@ func (r *Resources) LifecycleEndorsementPolicyAsBytes(channelID string) ([]byte,
 // Instead, use the utility functions attached to the lifecycle Resources
 // when needed.
 type ExternalFunctions struct {
-	Resources                 *Resources
-	InstallListener           InstallListener
-	InstalledChaincodesLister InstalledChaincodesLister
-	ChaincodeBuilder          ChaincodeBuilder
-	BuildRegistry             *container.BuildRegistry
-	mutex                     sync.Mutex
-	BuildLocks                map[string]sync.Mutex
+	Resources                   *Resources
+	InstallListener             InstallListener
+	ApprovalEndorsementListener ApprovalEndorsementListener
+	PackageCapabilityChecker    PackageCapabilityChecker
+	Metrics                     *Metrics
+	Clock                       Clock
+	InstalledChaincodesLister   InstalledChaincodesLister
+	ChaincodeBuilder            ChaincodeBuilder
+	BuildRegistry               *container.BuildRegistry
+	mutex                       sync.Mutex
+	BuildLocks                  map[string]*sync.Mutex
+
+	// AllowedPluginCombos is the set of (endorsement plugin, validation plugin)
+	// pairs which may be committed.  When empty, all combinations are permitted.
+	AllowedPluginCombos [][2]string
+
+	// RequireUniformPackage, when set, rejects commits for which
+	// the approving orgs approved different packages.
+	RequireUniformPackage bool
+
+	// MaxInstallPackageSize is the largest chaincode install package, in
+	// bytes, which may be installed.  Zero means there is no limit.
+	MaxInstallPackageSize int64
+
+	// ApprovalAuditLog, when set, records every approval attempt in an
+	// append-only log in the org state, alongside the approval itself.
+	// The org state must then implement TransactionState.
+	ApprovalAuditLog bool
+
+	eventsMutex sync.Mutex
+	events      chan LifecycleEvent
+}
+
+//go:generate counterfeiter -o mock/clock.go --fake-name Clock . Clock
+
+// Clock supplies the current time, as nanoseconds since the Unix epoch,
+// to the lifecycle operations which record timestamps or durations.
+type Clock interface {
+	Now() int64
+}
+
+// now returns the current time from the configured clock, or from the
+// wall clock if none is configured.
+func (ef *ExternalFunctions) now() int64 {
+	if ef.Clock == nil {
+		return time.Now().UnixNano()
+	}
+	return ef.Clock.Now()
+}
+
+// metricsStartTime returns the time from which the duration of an operation
+// is recorded in the metrics.  The clock is not read when there are no
+// metrics to record.
+func (ef *ExternalFunctions) metricsStartTime() int64 {
+	if ef.Metrics == nil {
+		return 0
+	}
+	return ef.now()
+}
+
+// LifecycleEventType identifies the lifecycle operation a LifecycleEvent describes.
+type LifecycleEventType int
+
+// The approve and commit events are emitted when the peer endorses the
+// approval or commit, during the simulation of the transaction.  The
+// transaction may yet fail validation, or never be submitted for ordering,
+// so these events do not mean the approval or definition was committed to
+// the ledger; register a CommitListener on the Cache to learn of committed
+// definitions.
+const (
+	ChaincodeInstalledEvent LifecycleEventType = iota
+	ChaincodeApprovalEndorsedEvent
+	ChaincodeCommitEndorsedEvent
+)
+
+// lifecycleEventBufferSize is the number of events which may be pending
+// on the channel returned by Events before further events are dropped.
+const lifecycleEventBufferSize = 100
+
+// LifecycleEvent describes a successful install, or the endorsement of an
+// approve or commit operation.  ChannelID and Definition are not set for install events, and PackageID is
+// not set for commit events.  For install events, Name is the package label.
+// Timestamp is the time the operation completed, in Unix nanoseconds.
+type LifecycleEvent struct {
+	Type       LifecycleEventType
+	Timestamp  int64
+	ChannelID  string
+	Name       string
+	PackageID  string
+	Definition *ChaincodeDefinition
+}
+
+// Events returns a channel which receives an event for each successful
+// install, and each approve and commit endorsed, after the first call to
+// Events.  The
+// channel is buffered, and delivery never blocks the lifecycle operation; if
+// the buffer is full because the consumer has fallen behind, the event is
+// dropped.  Every call returns the same channel.
+func (ef *ExternalFunctions) Events() <-chan LifecycleEvent {
+	ef.eventsMutex.Lock()
+	defer ef.eventsMutex.Unlock()
+
+	if ef.events == nil {
+		ef.events = make(chan LifecycleEvent, lifecycleEventBufferSize)
+	}
+	return ef.events
+}
+
+// emitEvent delivers the event to the events channel.  Until Events has been
+// called there is no consumer, so the event is discarded.
+func (ef *ExternalFunctions) emitEvent(event LifecycleEvent) {
+	ef.eventsMutex.Lock()
+	events := ef.events
+	ef.eventsMutex.Unlock()
+
+	if events == nil {
+		return
+	}
+
+	event.Timestamp = ef.now()
+	select {
+	case events <- event:
+	default:
+		logger.Warningf("Dropping lifecycle event for '%s', event buffer is full", event.Name)
+	}
 }
 
 // CheckCommitReadiness takes a chaincode definition, checks that
 // its sequence number is the next allowable sequence number and checks which
 // organizations have approved the definition.
 func (ef *ExternalFunctions) CheckCommitReadiness(chname, ccname string, cd *ChaincodeDefinition, publicState ReadWritableState, orgStates []OpaqueState) (map[string]bool, error) {
-	currentSequence, err := ef.Resources.Serializer.DeserializeFieldAsInt64(NamespacesName, ccname, "Sequence", publicState)
+	approvals, _, err := ef.checkCommitReadiness(chname, ccname, cd, publicState, orgStates, false)
+	return approvals, err
+}
+
+// checkCommitReadiness performs the checks of CheckCommitReadiness.  When
+// tolerateOrgErrors is set, an org whose state cannot be read is reported as
+// not approving, and the error is returned keyed by the index of its state
+// in orgStates.  Otherwise, such an error fails the check.
+func (ef *ExternalFunctions) checkCommitReadiness(chname, ccname string, cd *ChaincodeDefinition, publicState ReadWritableState, orgStates []OpaqueState, tolerateOrgErrors bool) (map[string]bool, map[int]error, error) {
+	failures, err := ef.sequenceChecks(ccname, cd, publicState)
 	if err != nil {
-		return nil, errors.WithMessage(err, "could not get current sequence")
+		return nil, nil, err
 	}
-
-	if cd.Sequence != currentSequence+1 {
-		return nil, errors.Errorf("requested sequence is %d, but new definition must be sequence %d", cd.Sequence, currentSequence+1)
+	if len(failures) > 0 {
+		return nil, nil, failures[0]
 	}
 
 	if err := ef.SetChaincodeDefinitionDefaults(chname, cd); err != nil {
-		return nil, errors.WithMessagef(err, "could not set defaults for chaincode definition in channel %s", chname)
+		return nil, nil, errors.WithMessagef(err, "could not set defaults for chaincode definition in channel %s", chname)
 	}
 
-	var approvals map[string]bool
-	if approvals, err = ef.QueryOrgApprovals(ccname, cd, orgStates); err != nil {
-		return nil, err
+	approvals, orgErrs := ef.orgApprovals(ccname, cd, orgStates)
+	if !tolerateOrgErrors {
+		if err := firstOrgError(orgErrs); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	logger.Infof("Successfully checked commit readiness of chaincode name '%s' on channel '%s' with definition {%s}", ccname, chname, cd)
 
-	return approvals, nil
+	return approvals, orgErrs, nil
 }
 
 // CommitChaincodeDefinition takes a chaincode definition, checks that its
@@ -345,155 +1395,497 @@ func (ef *ExternalFunctions) CheckCommitReadiness(chname, ccname string, cd *Cha
 // organizations have approved the definition, and applies the definition to
 // the public world state. It is the responsibility of the caller to check
 // the approvals to determine if the result is valid (typically, this means
-// checking that the peer's own org has approved the definition).
+// checking that the peer's own org has approved the definition).  Committing
+// the definition which is already committed at the current sequence succeeds
+// without writing to the state, so that retried commits are idempotent.
 func (ef *ExternalFunctions) CommitChaincodeDefinition(chname, ccname string, cd *ChaincodeDefinition, publicState ReadWritableState, orgStates []OpaqueState) (map[string]bool, error) {
-	approvals, err := ef.CheckCommitReadiness(chname, ccname, cd, publicState, orgStates)
-	if err != nil {
-		return nil, err
-	}
+	approvals, _, err := ef.CommitChaincodeDefinitionForOrg(chname, ccname, cd, publicState, orgStates, -1)
+	return approvals, err
+}
 
-	if err = ef.Resources.Serializer.Serialize(NamespacesName, ccname, cd, publicState); err != nil {
-		return nil, errors.WithMessage(err, "could not serialize chaincode definition")
-	}
+// CommitChaincodeDefinitionForOrg commits the chaincode definition like
+// CommitChaincodeDefinition, and additionally reports whether the org whose
+// state is at myOrgIndex in orgStates approved the definition. A negative
+// myOrgIndex indicates the caller does not care, and myOrgAgreed is false.
+func (ef *ExternalFunctions) CommitChaincodeDefinitionForOrg(chname, ccname string, cd *ChaincodeDefinition, publicState ReadWritableState, orgStates []OpaqueState, myOrgIndex int) (_ map[string]bool, myOrgAgreed bool, err error) {
+	startTime := ef.metricsStartTime()
+	defer func() { ef.Metrics.observeCommit(startTime, ef.now, err) }()
 
-	return approvals, nil
-}
+	if myOrgIndex >= len(orgStates) {
+		return nil, false, errors.Errorf("org index %d is out of range for %d org states", myOrgIndex, len(orgStates))
+	}
 
-// DefaultEndorsementPolicyAsBytes returns a marshalled version
-// of the default chaincode endorsement policy in the supplied channel
-func (ef *ExternalFunctions) DefaultEndorsementPolicyAsBytes(channelID string) ([]byte, error) {
-	channelConfig := ef.Resources.ChannelConfigSource.GetStableChannelConfig(channelID)
-	if channelConfig == nil {
-		return nil, errors.Errorf("could not get channel config for channel '%s'", channelID)
+	approvals, _, err := ef.commitChaincodeDefinition(chname, ccname, cd, publicState, orgStates, false)
+	if err != nil {
+		return nil, false, err
 	}
 
-	// see if the channel defines a default
-	if _, ok := channelConfig.PolicyManager().GetPolicy(DefaultEndorsementPolicyRef); ok {
-		return DefaultEndorsementPolicyBytes, nil
+	if myOrgIndex >= 0 {
+		myOrgAgreed = approvals[OrgFromImplicitCollectionName(orgStates[myOrgIndex].CollectionName())]
 	}
 
-	return nil, errors.Errorf(
-		"policy '%s' must be defined for channel '%s' before chaincode operations can be attempted",
-		DefaultEndorsementPolicyRef,
-		channelID,
-	)
+	return approvals, myOrgAgreed, nil
 }
 
-// SetChaincodeDefinitionDefaults fills any empty fields in the
-// supplied ChaincodeDefinition with the supplied channel's defaults
-func (ef *ExternalFunctions) SetChaincodeDefinitionDefaults(chname string, cd *ChaincodeDefinition) error {
-	if cd.EndorsementInfo.EndorsementPlugin == "" {
-		// TODO:
-		// 1) rename to "default" or "builtin"
-		// 2) retrieve from channel config
-		cd.EndorsementInfo.EndorsementPlugin = "escc"
+// CommitChaincodeDefinitionWithDisagreements commits the chaincode definition
+// like CommitChaincodeDefinition.  When collectDisagreements is set, it
+// additionally returns, for each org which did not approve the definition, a
+// short description of how its approval differs from the committed
+// definition, keyed by org.  For orgs whose state is readable, the
+// differences are described as by Diff, from the committed parameters to the
+// org's approved parameters.  Otherwise, only the names of the differing
+// fields are known.
+func (ef *ExternalFunctions) CommitChaincodeDefinitionWithDisagreements(chname, ccname string, cd *ChaincodeDefinition, publicState ReadWritableState, orgStates []OpaqueState, collectDisagreements bool) (map[string]bool, map[string][]string, error) {
+	approvals, err := ef.CommitChaincodeDefinition(chname, ccname, cd, publicState, orgStates)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if cd.ValidationInfo.ValidationPlugin == "" {
-		// TODO:
-		// 1) rename to "default" or "builtin"
-		// 2) retrieve from channel config
-		cd.ValidationInfo.ValidationPlugin = "vscc"
+	if !collectDisagreements {
+		return approvals, nil, nil
 	}
 
-	if len(cd.ValidationInfo.ValidationParameter) == 0 {
-		policyBytes, err := ef.DefaultEndorsementPolicyAsBytes(chname)
+	disagreements := map[string][]string{}
+	privateName := PrivateName(ccname, cd.Sequence)
+	for _, orgState := range orgStates {
+		org := OrgFromImplicitCollectionName(orgState.CollectionName())
+		if approvals[org] {
+			continue
+		}
+
+		metadataMatches, unmatchedFields, err := ef.Resources.Serializer.UnmatchedFields(NamespacesName, privateName, cd.Parameters(), orgState)
 		if err != nil {
-			return err
+			return nil, nil, errors.WithMessagef(err, "could not compare approval of org %s", org)
+		}
+		if !metadataMatches {
+			disagreements[org] = []string{fmt.Sprintf("no approval for sequence %d", cd.Sequence)}
+			continue
 		}
 
-		cd.ValidationInfo.ValidationParameter = policyBytes
+		if readableOrgState, ok := orgState.(ReadableState); ok {
+			approved, _, err := ef.QueryApprovedChaincode(ccname, cd.Sequence, readableOrgState)
+			if err != nil {
+				return nil, nil, errors.WithMessagef(err, "could not query approval of org %s", org)
+			}
+			disagreements[org] = cd.Parameters().Diff(approved)
+			continue
+		}
+
+		for _, field := range unmatchedFields {
+			disagreements[org] = append(disagreements[org], fmt.Sprintf("%s changed", field))
+		}
 	}
 
-	return nil
+	return approvals, disagreements, nil
 }
 
-// ApproveChaincodeDefinitionForOrg adds a chaincode definition entry into the passed in Org state.  The definition must be
-// for either the currently defined sequence number or the next sequence number.  If the definition is
-// for the current sequence number, then it must match exactly the current definition or it will be rejected.
-func (ef *ExternalFunctions) ApproveChaincodeDefinitionForOrg(chname, ccname string, cd *ChaincodeDefinition, packageID string, publicState ReadableState, orgState ReadWritableState) error {
-	// Get the current sequence from the public state
-	currentSequence, err := ef.Resources.Serializer.DeserializeFieldAsInt64(NamespacesName, ccname, "Sequence", publicState)
-	if err != nil {
-		return errors.WithMessage(err, "could not get current sequence")
-	}
+// CommitChaincodeDefinitionWithOrgErrors commits the chaincode definition like
+// CommitChaincodeDefinition, except that an org whose state cannot be read
+// does not fail the commit.  Such an org is instead reported as not
+// approving, and its error is returned keyed by the index of its state in
+// orgStates, so that callers may tell an org which disagreed apart from an
+// org whose state is unreadable.
+func (ef *ExternalFunctions) CommitChaincodeDefinitionWithOrgErrors(chname, ccname string, cd *ChaincodeDefinition, publicState ReadWritableState, orgStates []OpaqueState) (_ map[string]bool, orgErrs map[int]error, err error) {
+	startTime := ef.metricsStartTime()
+	defer func() { ef.Metrics.observeCommit(startTime, ef.now, err) }()
 
-	requestedSequence := cd.Sequence
+	return ef.commitChaincodeDefinition(chname, ccname, cd, publicState, orgStates, true)
+}
 
-	if currentSequence == requestedSequence && requestedSequence == 0 {
-		return errors.Errorf("requested sequence is 0, but first definable sequence number is 1")
+// PreviewCommitWrites returns the key-value pairs which committing the
+// chaincode definition would write to an empty public state, without
+// requiring a state backend.  Against an existing definition, a commit only
+// writes those keys whose values change, which is a subset of the preview.
+// No validation of the definition is performed.
+func (ef *ExternalFunctions) PreviewCommitWrites(name string, cd *ChaincodeDefinition) (map[string][]byte, error) {
+	state := memoryState{}
+	if err := ef.Resources.Serializer.Serialize(NamespacesName, name, cd, state); err != nil {
+		return nil, errors.WithMessage(err, "could not serialize chaincode definition")
 	}
 
-	if requestedSequence < currentSequence {
-		return errors.Errorf("currently defined sequence %d is larger than requested sequence %d", currentSequence, requestedSequence)
-	}
+	return state, nil
+}
 
-	if requestedSequence > currentSequence+1 {
-		return errors.Errorf("requested sequence %d is larger than the next available sequence number %d", requestedSequence, currentSequence+1)
+// SerializeParametersBytes returns the serialized form of the parameters of
+// the chaincode definition, as returned by SerializeBytes, once the defaults
+// of the supplied channel have been applied.  Unlike SerializeBytes, the
+// result is what ApproveChaincodeDefinitionForOrg would store on the channel,
+// including the sorting of the collections on channels which store them in
+// canonical order.  The supplied definition is not modified.
+func (ef *ExternalFunctions) SerializeParametersBytes(chname string, cd *ChaincodeDefinition) ([]byte, error) {
+	defaulted := cd.DeepCopy()
+	if err := ef.SetChaincodeDefinitionDefaults(chname, defaulted); err != nil {
+		return nil, errors.WithMessage(err, "could not set defaults for chaincode definition")
 	}
 
-	if err := ef.SetChaincodeDefinitionDefaults(chname, cd); err != nil {
-		return errors.WithMessagef(err, "could not set defaults for chaincode definition in channel %s", chname)
+	return defaulted.Parameters().SerializeBytes()
+}
+
+func (ef *ExternalFunctions) commitChaincodeDefinition(chname, ccname string, cd *ChaincodeDefinition, publicState ReadWritableState, orgStates []OpaqueState, tolerateOrgErrors bool) (map[string]bool, map[int]error, error) {
+	logger := decorateLogger(logger, chname, ccname, cd.Sequence)
+
+	if failures := ef.definitionChecks(chname, ccname, cd); len(failures) > 0 {
+		return nil, nil, failures[0]
 	}
 
-	if requestedSequence == currentSequence {
-		metadata, ok, err := ef.Resources.Serializer.DeserializeMetadata(NamespacesName, ccname, publicState)
+	committedDefinition, alreadyCommitted, err := ef.alreadyCommitted(chname, ccname, cd, publicState)
+	if err != nil {
+		return nil, nil, err
+	}
+	if alreadyCommitted {
+		// a retried or duplicate commit of the current definition
+		// succeeds without writing anything
+		approvals, orgErrs := ef.orgApprovals(ccname, committedDefinition, orgStates)
+		if !tolerateOrgErrors {
+			if err := firstOrgError(orgErrs); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		logger.Infof("Chaincode name '%s' on channel '%s' is already committed with definition {%s}, nothing to commit", ccname, chname, committedDefinition)
+		return approvals, orgErrs, nil
+	}
+
+	approvals, orgErrs, err := ef.checkCommitReadiness(chname, ccname, cd, publicState, orgStates, tolerateOrgErrors)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if failures := ef.approvalChecks(ccname, cd, approvals, orgStates); len(failures) > 0 {
+		return nil, nil, failures[0]
+	}
+
+	if err = ef.Resources.Serializer.Serialize(NamespacesName, ccname, cd, publicState); err != nil {
+		return nil, nil, errors.WithMessage(err, "could not serialize chaincode definition")
+	}
+	if err := clearDefinitionAuxiliaryFields(ccname, publicState); err != nil {
+		return nil, nil, err
+	}
+	ef.Resources.invalidateDefinitionCache(chname, ccname)
+
+	ef.emitEvent(LifecycleEvent{
+		Type:       ChaincodeCommitEndorsedEvent,
+		ChannelID:  chname,
+		Name:       ccname,
+		Definition: cd,
+	})
+
+	return approvals, orgErrs, nil
+}
+
+// definitionChecks runs the checks of the chaincode definition itself which
+// must pass for it to be committed, and returns each failure in the order
+// the checks are run.
+func (ef *ExternalFunctions) definitionChecks(chname, ccname string, cd *ChaincodeDefinition) []error {
+	var failures []error
+
+	if err := ValidateChaincodeName(ccname); err != nil {
+		failures = append(failures, err)
+	}
+
+	if err := ValidateCollections(cd); err != nil {
+		failures = append(failures, errors.WithMessage(err, "invalid collection configuration"))
+	}
+
+	if err := ef.Resources.checkCollectionMembers(chname, cd); err != nil {
+		failures = append(failures, errors.WithMessage(err, "invalid collection configuration"))
+	}
+
+	if err := ef.Resources.checkPlugins(cd); err != nil {
+		failures = append(failures, err)
+	}
+
+	if err := ef.Resources.checkChannelPolicyReference(chname, cd); err != nil {
+		failures = append(failures, err)
+	}
+
+	return failures
+}
+
+// sequenceChecks runs the checks of the sequence of the chaincode definition
+// against the committed sequence, and returns each failure.  An error is
+// returned only if the committed sequence could not be read.
+func (ef *ExternalFunctions) sequenceChecks(ccname string, cd *ChaincodeDefinition, publicState ReadableState) ([]error, error) {
+	currentSequence, err := ef.Resources.Serializer.DeserializeFieldAsInt64(NamespacesName, ccname, "Sequence", publicState)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not get current sequence")
+	}
+
+	var failures []error
+
+	if cd.Sequence != currentSequence+1 {
+		failures = append(failures, ErrWrongSequence{Requested: cd.Sequence, Expected: currentSequence + 1})
+	}
+
+	if err := ef.Resources.checkMaxSequence(cd.Sequence); err != nil {
+		failures = append(failures, err)
+	}
+
+	return failures, nil
+}
+
+// approvalChecks runs the checks of the approvals of the defaulted chaincode
+// definition which must pass for it to be committed, and returns each failure
+// in the order the checks are run.
+func (ef *ExternalFunctions) approvalChecks(ccname string, cd *ChaincodeDefinition, approvals map[string]bool, orgStates []OpaqueState) []error {
+	var failures []error
+
+	if ef.Resources.RequireAgreementToCommit && !anyApproved(approvals) {
+		failures = append(failures, errors.Errorf("no org agrees with the chaincode definition for %s at sequence %d", ccname, cd.Sequence))
+	}
+
+	if err := ef.checkPluginCombination(cd); err != nil {
+		failures = append(failures, err)
+	}
+
+	var committingOrgs []string
+	for org, approved := range approvals {
+		if approved {
+			committingOrgs = append(committingOrgs, org)
+		}
+	}
+	sort.Strings(committingOrgs)
+	if err := ef.Resources.namespaceReservation().CanDefine(ccname, committingOrgs); err != nil {
+		failures = append(failures, errors.WithMessagef(err, "chaincode name '%s' may not be defined by orgs %v", ccname, committingOrgs))
+	}
+
+	if ef.RequireUniformPackage {
+		if err := ef.checkUniformPackage(ccname, cd, approvals, orgStates); err != nil {
+			failures = append(failures, err)
+		}
+	}
+
+	return failures
+}
+
+// alreadyCommitted returns whether the definition, once its defaults are set,
+// is byte for byte the definition already committed at the current sequence,
+// along with the defaulted definition.  Definitions which cannot have their
+// defaults set are reported as not committed, so that the usual commit checks
+// report the problem.
+func (ef *ExternalFunctions) alreadyCommitted(chname, ccname string, cd *ChaincodeDefinition, publicState ReadableState) (*ChaincodeDefinition, bool, error) {
+	currentSequence, err := ef.Resources.Serializer.DeserializeFieldAsInt64(NamespacesName, ccname, "Sequence", publicState)
+	if err != nil {
+		return nil, false, errors.WithMessage(err, "could not get current sequence")
+	}
+
+	if currentSequence == 0 || cd.Sequence != currentSequence || cd.EndorsementInfo == nil || cd.ValidationInfo == nil {
+		return nil, false, nil
+	}
+
+	defaulted := cd.DeepCopy()
+	if err := ef.SetChaincodeDefinitionDefaults(chname, defaulted); err != nil {
+		return nil, false, nil
+	}
+
+	writes, err := ef.PreviewCommitWrites(ccname, defaulted)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for key, value := range writes {
+		existingValue, err := publicState.GetState(key)
 		if err != nil {
-			return errors.WithMessage(err, "could not fetch metadata for current definition")
+			return nil, false, errors.WithMessagef(err, "could not get state for key %s", key)
 		}
-		if !ok {
-			return errors.Errorf("missing metadata for currently committed sequence number (%d)", currentSequence)
+		if !bytes.Equal(existingValue, value) {
+			return nil, false, nil
 		}
+	}
 
-		definedChaincode := &ChaincodeDefinition{}
-		if err := ef.Resources.Serializer.Deserialize(NamespacesName, ccname, metadata, definedChaincode, publicState); err != nil {
-			return errors.WithMessagef(err, "could not deserialize namespace %s as chaincode", ccname)
+	return defaulted, true, nil
+}
+
+// anyApproved returns whether at least one org in the approvals agrees.
+func anyApproved(approvals map[string]bool) bool {
+	for _, approved := range approvals {
+		if approved {
+			return true
 		}
+	}
+	return false
+}
 
-		if err := definedChaincode.Parameters().Equal(cd.Parameters()); err != nil {
-			return errors.WithMessagef(err, "attempted to redefine the current committed sequence (%d) for namespace %s with different parameters", currentSequence, ccname)
+// checkPluginCombination returns an error if the endorsement and validation
+// plugins of the definition are not an allowed combination.
+func (ef *ExternalFunctions) checkPluginCombination(cd *ChaincodeDefinition) error {
+	if len(ef.AllowedPluginCombos) == 0 {
+		return nil
+	}
+
+	combo := [2]string{cd.EndorsementInfo.EndorsementPlugin, cd.ValidationInfo.ValidationPlugin}
+	for _, allowed := range ef.AllowedPluginCombos {
+		if allowed == combo {
+			return nil
 		}
 	}
 
-	privateName := fmt.Sprintf("%s#%d", ccname, requestedSequence)
+	return errors.Errorf("endorsement plugin '%s' and validation plugin '%s' are not an allowed combination", combo[0], combo[1])
+}
+
+// checkUniformPackage returns an error if the orgs which approved the
+// definition did not all approve the same package.  As the org states are
+// opaque, the hashes of the approved package IDs are compared.
+func (ef *ExternalFunctions) checkUniformPackage(ccname string, cd *ChaincodeDefinition, approvals map[string]bool, orgStates []OpaqueState) error {
+	privateName := PrivateName(ccname, cd.Sequence)
+	packageIDKey := FieldKey(ChaincodeSourcesName, privateName, "PackageID")
 
-	// if requested sequence is not committed, and attempt is made to update its content,
-	// we need to check whether new definition actually contains updated content, to avoid
-	// empty write set.
-	if requestedSequence == currentSequence+1 {
-		uncommittedMetadata, ok, err := ef.Resources.Serializer.DeserializeMetadata(NamespacesName, privateName, orgState)
+	var firstOrg string
+	var firstHash []byte
+	for _, orgState := range orgStates {
+		org := OrgFromImplicitCollectionName(orgState.CollectionName())
+		if !approvals[org] {
+			continue
+		}
+
+		hash, err := orgState.GetStateHash(packageIDKey)
 		if err != nil {
-			return errors.WithMessage(err, "could not fetch uncommitted definition")
+			return errors.WithMessagef(err, "could not get state hash for key %s", packageIDKey)
 		}
 
-		if ok {
-			logger.Debugf("Attempting to redefine uncommitted definition at sequence %d", requestedSequence)
+		if firstOrg == "" {
+			firstOrg, firstHash = org, hash
+			continue
+		}
 
-			uncommittedParameters := &ChaincodeParameters{}
-			if err := ef.Resources.Serializer.Deserialize(NamespacesName, privateName, uncommittedMetadata, uncommittedParameters, orgState); err != nil {
-				return errors.WithMessagef(err, "could not deserialize namespace %s as chaincode", privateName)
-			}
+		if !bytes.Equal(hash, firstHash) {
+			return errors.Errorf("agreeing orgs '%s' and '%s' approved different packages", firstOrg, org)
+		}
+	}
 
-			if err := uncommittedParameters.Equal(cd.Parameters()); err == nil {
-				// also check package ID updates
-				metadata, ok, err := ef.Resources.Serializer.DeserializeMetadata(ChaincodeSourcesName, privateName, orgState)
-				if err != nil {
-					return errors.WithMessagef(err, "could not deserialize chaincode-source metadata for %s", privateName)
-				}
-				if ok {
-					ccLocalPackage := &ChaincodeLocalPackage{}
-					if err := ef.Resources.Serializer.Deserialize(ChaincodeSourcesName, privateName, metadata, ccLocalPackage, orgState); err != nil {
-						return errors.WithMessagef(err, "could not deserialize chaincode package for %s", privateName)
-					}
+	return nil
+}
 
-					if ccLocalPackage.PackageID == packageID {
-						return errors.Errorf("attempted to redefine uncommitted sequence (%d) for namespace %s with unchanged content", requestedSequence, ccname)
-					}
-				}
-			}
+// DefaultEndorsementPolicyAsBytes returns a marshalled version
+// of the default chaincode endorsement policy in the supplied channel
+func (ef *ExternalFunctions) DefaultEndorsementPolicyAsBytes(channelID string) ([]byte, error) {
+	return ef.Resources.DefaultEndorsementPolicyAsBytes(channelID)
+}
+
+// DefaultEndorsementPolicyAsBytes returns a marshalled version
+// of the default chaincode endorsement policy in the supplied channel
+func (r *Resources) DefaultEndorsementPolicyAsBytes(channelID string) ([]byte, error) {
+	return r.cachedPolicyAsBytes(channelID, DefaultEndorsementPolicyRef, r.defaultEndorsementPolicyAsBytes)
+}
+
+// ResolveEffectiveValidationParameter returns the validation parameter which
+// governs the supplied definition in the supplied channel.  This is the
+// explicit validation parameter of the definition if one is set, and
+// otherwise the marshalled default chaincode endorsement policy of the channel.
+func (r *Resources) ResolveEffectiveValidationParameter(channelID string, cd *ChaincodeDefinition) ([]byte, error) {
+	if validationParameter := cd.ValidationInfo.GetValidationParameter(); len(validationParameter) != 0 {
+		return validationParameter, nil
+	}
+
+	policyBytes, err := r.DefaultEndorsementPolicyAsBytes(channelID)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "could not resolve default endorsement policy for channel '%s'", channelID)
+	}
+
+	return policyBytes, nil
+}
+
+func (r *Resources) defaultEndorsementPolicyAsBytes(channelID string) ([]byte, error) {
+	channelConfig := r.ChannelConfigSource.GetStableChannelConfig(channelID)
+	if channelConfig == nil {
+		return nil, errors.Errorf("could not get channel config for channel '%s'", channelID)
+	}
+
+	// see if the channel defines a default
+	if _, ok := channelConfig.PolicyManager().GetPolicy(DefaultEndorsementPolicyRef); ok {
+		return DefaultEndorsementPolicyBytes, nil
+	}
+
+	return nil, errors.Errorf(
+		"policy '%s' must be defined for channel '%s' before chaincode operations can be attempted",
+		DefaultEndorsementPolicyRef,
+		channelID,
+	)
+}
+
+// SetChaincodeDefinitionDefaults fills any empty fields in the
+// supplied ChaincodeDefinition with the supplied channel's defaults.
+// If the channel stores collections in canonical order, the collections
+// are also replaced by a copy sorted by name.
+func (ef *ExternalFunctions) SetChaincodeDefinitionDefaults(chname string, cd *ChaincodeDefinition) error {
+	if cd.EndorsementInfo.EndorsementPlugin == "" {
+		// TODO:
+		// 1) rename to "default" or "builtin"
+		// 2) retrieve from channel config
+		cd.EndorsementInfo.EndorsementPlugin = "escc"
+	}
+
+	if cd.ValidationInfo.ValidationPlugin == "" {
+		// TODO:
+		// 1) rename to "default" or "builtin"
+		// 2) retrieve from channel config
+		cd.ValidationInfo.ValidationPlugin = "vscc"
+	}
+
+	if len(cd.ValidationInfo.ValidationParameter) == 0 {
+		policyBytes, err := ef.DefaultEndorsementPolicyAsBytes(chname)
+		if err != nil {
+			return err
 		}
+
+		cd.ValidationInfo.ValidationParameter = policyBytes
+	}
+
+	if len(cd.Collections.GetConfig()) > 1 && ef.Resources.canonicalCollections(chname) {
+		cd.Collections = sortedCollections(cd.Collections)
+	}
+
+	return nil
+}
+
+// canonicalCollections returns whether the supplied channel has the capability
+// to store the collections of definitions and approvals sorted by name.  Until
+// all peers of a channel sort the collections, they must be stored in the order
+// given, or peers would produce different write sets for the same proposal.
+func (r *Resources) canonicalCollections(channelID string) bool {
+	channelConfig := r.ChannelConfigSource.GetStableChannelConfig(channelID)
+	if channelConfig == nil {
+		return false
+	}
+
+	ac, ok := channelConfig.ApplicationConfig()
+	if !ok || ac.Capabilities() == nil {
+		return false
+	}
+
+	return ac.Capabilities().LifecycleCanonicalCollections()
+}
+
+// ApproveChaincodeDefinitionForOrg adds a chaincode definition entry into the passed in Org state.  The definition must be
+// for either the currently defined sequence number or the next sequence number.  If the definition is
+// for the current sequence number, then it must match exactly the current definition or it will be rejected.
+// If RejectInitRequiredChange is set, a definition which changes InitRequired from the committed
+// definition is rejected; use ApproveChaincodeDefinitionForOrgWithInitChange to approve such a
+// change deliberately.
+func (ef *ExternalFunctions) ApproveChaincodeDefinitionForOrg(chname, ccname string, cd *ChaincodeDefinition, packageID string, publicState ReadableState, orgState ReadWritableState) error {
+	return ef.ApproveChaincodeDefinitionForOrgWithInitChange(chname, ccname, cd, packageID, publicState, orgState, false)
+}
+
+// ApproveChaincodeDefinitionForOrgWithInitChange approves the chaincode definition like
+// ApproveChaincodeDefinitionForOrg, but when allowInitChange is set it permits the
+// definition to change InitRequired from the currently committed definition even
+// if RejectInitRequiredChange is set.
+func (ef *ExternalFunctions) ApproveChaincodeDefinitionForOrgWithInitChange(chname, ccname string, cd *ChaincodeDefinition, packageID string, publicState ReadableState, orgState ReadWritableState, allowInitChange bool) (err error) {
+	startTime := ef.metricsStartTime()
+	defer func() { ef.Metrics.observeApprove(startTime, ef.now, err) }()
+
+	if err := ef.checkApproveChaincodeDefinitionForOrg(chname, ccname, cd, packageID, publicState, orgState, allowInitChange); err != nil {
+		return err
 	}
 
+	logger := decorateLogger(logger, chname, ccname, cd.Sequence)
+
+	if packageID == "" {
+		logger.Warningf("Approving chaincode name '%s' on channel '%s' without a package ID, this org's peers will not be able to run it", ccname, chname)
+	}
+
+	privateName := PrivateName(ccname, cd.Sequence)
+
 	if err := ef.Resources.Serializer.Serialize(NamespacesName, privateName, cd.Parameters(), orgState); err != nil {
 		return errors.WithMessage(err, "could not serialize chaincode parameters to state")
 	}
@@ -510,261 +1902,2580 @@ func (ef *ExternalFunctions) ApproveChaincodeDefinitionForOrg(chname, ccname str
 		return errors.WithMessage(err, "could not serialize chaincode package info to state")
 	}
 
+	if ef.ApprovalAuditLog {
+		if err := ef.recordApprovalAttempt(privateName, cd, packageID, orgState); err != nil {
+			return err
+		}
+	}
+
 	logger.Infof("Successfully endorsed chaincode approval with name '%s', package ID '%s', on channel '%s' with definition {%s}", ccname, packageID, chname, cd)
 
+	if ef.ApprovalEndorsementListener != nil {
+		ef.ApprovalEndorsementListener.HandleChaincodeApprovalEndorsed(ccname, cd, packageID)
+	}
+
+	ef.emitEvent(LifecycleEvent{
+		Type:       ChaincodeApprovalEndorsedEvent,
+		ChannelID:  chname,
+		Name:       ccname,
+		PackageID:  packageID,
+		Definition: cd,
+	})
+
 	return nil
 }
 
-// QueryApprovedChaincodeDefinition returns the approved chaincode definition in Org state by using the given parameters.
-// If the parameter of sequence is not provided, this function returns the latest approved chaincode definition
-// (latest: new one of the currently defined sequence number and the next sequence number).
-func (ef *ExternalFunctions) QueryApprovedChaincodeDefinition(chname, ccname string, sequence int64, publicState ReadableState, orgState ReadableState) (*ApprovedChaincodeDefinition, error) {
+// ApprovalAttempt is an entry of the approval audit log, recording a single
+// attempt by an org to approve a chaincode definition.  The timestamp is the
+// timestamp of the approving transaction, in nanoseconds since the Unix epoch.
+type ApprovalAttempt struct {
+	Timestamp      int64  `json:"timestamp"`
+	TxID           string `json:"tx_id"`
+	PackageID      string `json:"package_id"`
+	ParametersHash []byte `json:"parameters_hash"`
+}
 
-	requestedSequence := sequence
+// TransactionState is implemented by states which are scoped to a single
+// transaction, such as the ChaincodePrivateLedgerShim supplied by the SCC,
+// to identify the transaction on whose behalf the state is accessed.
+type TransactionState interface {
+	GetTxID() string
+	GetTxTimestamp() (*timestamp.Timestamp, error)
+}
 
-	// If requested sequence is not provided,
-	// set the latest sequence number (either the currently defined sequence number or the next sequence number)
-	if requestedSequence == 0 {
-		currentSequence, err := ef.Resources.Serializer.DeserializeFieldAsInt64(NamespacesName, ccname, "Sequence", publicState)
-		if err != nil {
-			return nil, errors.WithMessage(err, "could not get current sequence")
+// approvalLogPrefix returns the prefix of the keys of the approval audit log
+// entries for the given private name.
+func approvalLogPrefix(privateName string) string {
+	return fmt.Sprintf("%s/%s/%s/", NamespacesName, ApprovalLogInfix, privateName)
+}
+
+// recordApprovalAttempt appends an entry for the approval to the approval
+// audit log.  The entries are keyed by the zero padded transaction timestamp
+// followed by the transaction ID, so that they sort in the order in which they
+// were made, and so that every endorser of the approval writes the same entry.
+// They are stored outside of the serialized approval, so they do not take part
+// in the opaque checks.  The org state must implement TransactionState.
+func (ef *ExternalFunctions) recordApprovalAttempt(privateName string, cd *ChaincodeDefinition, packageID string, orgState ReadWritableState) error {
+	parametersHash, err := cd.Parameters().Hash()
+	if err != nil {
+		return errors.WithMessage(err, "could not compute hash of approved parameters")
+	}
+
+	txState, ok := orgState.(TransactionState)
+	if !ok {
+		return errors.Errorf("could not record approval attempt for %s: org state does not identify the transaction", privateName)
+	}
+
+	txTimestamp, err := txState.GetTxTimestamp()
+	if err != nil {
+		return errors.WithMessagef(err, "could not get transaction timestamp for approval attempt of %s", privateName)
+	}
+
+	attempt := &ApprovalAttempt{
+		Timestamp:      txTimestamp.GetSeconds()*int64(time.Second) + int64(txTimestamp.GetNanos()),
+		TxID:           txState.GetTxID(),
+		PackageID:      packageID,
+		ParametersHash: parametersHash,
+	}
+	attemptBytes, err := json.Marshal(attempt)
+	if err != nil {
+		return errors.Wrapf(err, "could not marshal approval attempt for %s", privateName)
+	}
+
+	key := fmt.Sprintf("%s%020d/%s", approvalLogPrefix(privateName), attempt.Timestamp, attempt.TxID)
+	if err := orgState.PutState(key, attemptBytes); err != nil {
+		return errors.WithMessagef(err, "could not record approval attempt for %s", privateName)
+	}
+
+	return nil
+}
+
+// QueryApprovalAttempts returns the attempts recorded in the approval audit
+// log to approve the named chaincode at the given sequence, in the order in
+// which they were made.
+func (ef *ExternalFunctions) QueryApprovalAttempts(name string, sequence int64, orgState RangeableState) ([]*ApprovalAttempt, error) {
+	privateName := PrivateName(name, sequence)
+	kvs, err := orgState.GetStateRange(approvalLogPrefix(privateName))
+	if err != nil {
+		return nil, errors.WithMessagef(err, "could not get state range for approval attempts of %s", privateName)
+	}
+
+	keys := make([]string, 0, len(kvs))
+	for key := range kvs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attempts := make([]*ApprovalAttempt, 0, len(keys))
+	for _, key := range keys {
+		attempt := &ApprovalAttempt{}
+		if err := json.Unmarshal(kvs[key], attempt); err != nil {
+			return nil, errors.Wrapf(err, "could not unmarshal approval attempt for key %s", key)
 		}
-		requestedSequence = currentSequence
+		attempts = append(attempts, attempt)
+	}
+
+	return attempts, nil
+}
+
+// BatchApproveChaincodeDefinitionsForOrg approves each of the supplied chaincode
+// definitions, keyed by chaincode name, on behalf of the org.  The package ID to
+// approve for each chaincode is looked up by name, and chaincodes without one are
+// approved with an empty package ID.  A failed approval does not prevent the others,
+// and its error is returned in the map keyed by chaincode name.  The second return
+// value is only set if the batch could not be processed at all, such as when the
+// public state is unreadable, in which case nothing is approved.
+func (ef *ExternalFunctions) BatchApproveChaincodeDefinitionsForOrg(chname string, defs map[string]*ChaincodeDefinition, packageIDs map[string]string, publicState ReadableState, orgState ReadWritableState) (map[string]error, error) {
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		if _, err := ef.CurrentSequence(name, publicState); err != nil {
+			return nil, errors.WithMessagef(err, "could not read public state for chaincode %s", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := map[string]error{}
+	for _, name := range names {
+		if err := ef.ApproveChaincodeDefinitionForOrg(chname, name, defs[name], packageIDs[name], publicState, orgState); err != nil {
+			results[name] = err
+		}
+	}
+
+	return results, nil
+}
+
+// ValidateChaincodeDefinition performs every check of the approve and commit
+// paths which does not require access to the ledger, so that clients may
+// reject a malformed definition before ever contacting a peer.  In addition,
+// the validation parameter of a definition using the builtin validation
+// plugin must unmarshal as an application policy.  Checks against the
+// current sequence, the channel config, or the approvals of other orgs are
+// not performed.
+func (ef *ExternalFunctions) ValidateChaincodeDefinition(name string, cd *ChaincodeDefinition) error {
+	if err := ValidateChaincodeName(name); err != nil {
+		return err
+	}
+
+	switch {
+	case cd == nil:
+		return errors.New("chaincode definition must not be nil")
+	case cd.EndorsementInfo == nil:
+		return errors.New("chaincode definition must specify endorsement info")
+	case cd.ValidationInfo == nil:
+		return errors.New("chaincode definition must specify validation info")
+	case cd.Sequence < 1:
+		return errors.Errorf("chaincode definition sequence %d must be greater than zero", cd.Sequence)
+	}
+
+	if err := ef.Resources.checkMaxSequence(cd.Sequence); err != nil {
+		return err
+	}
+
+	if err := ValidateCollections(cd); err != nil {
+		return errors.WithMessage(err, "invalid collection configuration")
+	}
+
+	if err := ef.Resources.checkPlugins(cd); err != nil {
+		return err
+	}
+
+	if err := ef.checkPluginCombination(cd); err != nil {
+		return err
+	}
+
+	if plugin := cd.ValidationInfo.ValidationPlugin; plugin == "" || plugin == "vscc" {
+		if err := proto.Unmarshal(cd.ValidationInfo.ValidationParameter, &pb.ApplicationPolicy{}); err != nil {
+			return errors.Wrap(err, "validation parameter for the builtin validation plugin is not an application policy")
+		}
+	}
+
+	return ef.Resources.checkValidationPolicy(cd)
+}
+
+// SimulateApproveChaincodeDefinitionForOrg performs every validation which
+// ApproveChaincodeDefinitionForOrg would perform for the given definition, but
+// does not write the approval to the org state.  This allows an approval which
+// will be rejected to be detected before it is submitted.
+func (ef *ExternalFunctions) SimulateApproveChaincodeDefinitionForOrg(chname, ccname string, cd *ChaincodeDefinition, packageID string, publicState ReadableState, orgState ReadableState) error {
+	return ef.checkApproveChaincodeDefinitionForOrg(chname, ccname, cd, packageID, publicState, orgState, false)
+}
+
+func (ef *ExternalFunctions) checkApproveChaincodeDefinitionForOrg(chname, ccname string, cd *ChaincodeDefinition, packageID string, publicState ReadableState, orgState ReadableState, allowInitChange bool) error {
+	if err := ValidateChaincodeName(ccname); err != nil {
+		return err
+	}
+
+	if packageID == "" && ef.Resources.RequirePackage {
+		return errors.Errorf("a package ID is required to approve chaincode %s", ccname)
+	}
+
+	if err := ValidateCollections(cd); err != nil {
+		return errors.WithMessage(err, "invalid collection configuration")
+	}
+
+	if err := ef.Resources.checkCollectionMembers(chname, cd); err != nil {
+		return errors.WithMessage(err, "invalid collection configuration")
+	}
+
+	if err := ef.Resources.checkPlugins(cd); err != nil {
+		return err
+	}
+
+	// Get the current sequence from the public state
+	currentSequence, err := ef.Resources.Serializer.DeserializeFieldAsInt64(NamespacesName, ccname, "Sequence", publicState)
+	if err != nil {
+		return errors.WithMessage(err, "could not get current sequence")
+	}
+
+	requestedSequence := cd.Sequence
+
+	if err := ef.Resources.sequencePolicy().ValidateApprovalSequence(currentSequence, requestedSequence); err != nil {
+		return err
+	}
+
+	if err := ef.Resources.checkMaxSequence(requestedSequence); err != nil {
+		return err
+	}
+
+	if err := ef.SetChaincodeDefinitionDefaults(chname, cd); err != nil {
+		return errors.WithMessagef(err, "could not set defaults for chaincode definition in channel %s", chname)
+	}
+
+	if err := ef.Resources.checkValidationPolicy(cd); err != nil {
+		return err
+	}
+
+	if requestedSequence == currentSequence {
+		metadata, ok, err := ef.Resources.Serializer.DeserializeMetadata(NamespacesName, ccname, publicState)
+		if err != nil {
+			return errors.WithMessage(err, "could not fetch metadata for current definition")
+		}
+		if !ok {
+			return errors.Errorf("missing metadata for currently committed sequence number (%d)", currentSequence)
+		}
+
+		definedChaincode := &ChaincodeDefinition{}
+		if err := ef.Resources.Serializer.Deserialize(NamespacesName, ccname, metadata, definedChaincode, publicState); err != nil {
+			return errors.WithMessagef(err, "could not deserialize namespace %s as chaincode", ccname)
+		}
+
+		if err := definedChaincode.Parameters().Equal(cd.Parameters()); err != nil {
+			return errors.WithMessagef(err, "attempted to redefine the current committed sequence (%d) for namespace %s with different parameters", currentSequence, ccname)
+		}
+	}
+
+	if requestedSequence == currentSequence+1 && currentSequence > 0 {
+		ok, definedChaincode, err := ef.Resources.ChaincodeDefinitionIfDefined(ccname, publicState)
+		if err != nil {
+			return errors.WithMessage(err, "could not fetch current definition")
+		}
+		if !ok {
+			return errors.Errorf("missing metadata for currently committed sequence number (%d)", currentSequence)
+		}
+
+		committedInitRequired := definedChaincode.EndorsementInfo.GetInitRequired()
+		requestedInitRequired := cd.EndorsementInfo.GetInitRequired()
+		if ef.Resources.RejectInitRequiredChange && !allowInitChange && committedInitRequired != requestedInitRequired {
+			return errors.Errorf("attempted to change InitRequired from %t to %t for namespace %s without explicitly allowing it", committedInitRequired, requestedInitRequired, ccname)
+		}
+
+		if err := ef.Resources.checkVersionMonotonic(definedChaincode.EndorsementInfo.GetVersion(), cd.EndorsementInfo.GetVersion()); err != nil {
+			return err
+		}
+	}
+
+	privateName := PrivateName(ccname, requestedSequence)
+
+	// if requested sequence is not committed, and attempt is made to update its content,
+	// we need to check whether new definition actually contains updated content, to avoid
+	// empty write set.
+	if requestedSequence == currentSequence+1 {
+		uncommittedMetadata, ok, err := ef.Resources.Serializer.DeserializeMetadata(NamespacesName, privateName, orgState)
+		if err != nil {
+			return errors.WithMessage(err, "could not fetch uncommitted definition")
+		}
+
+		if ok {
+			logger.Debugf("Attempting to redefine uncommitted definition at sequence %d", requestedSequence)
+
+			uncommittedParameters := &ChaincodeParameters{}
+			if err := ef.Resources.Serializer.Deserialize(NamespacesName, privateName, uncommittedMetadata, uncommittedParameters, orgState); err != nil {
+				return errors.WithMessagef(err, "could not deserialize namespace %s as chaincode", privateName)
+			}
+
+			if err := uncommittedParameters.Equal(cd.Parameters()); err == nil {
+				// also check package ID updates
+				metadata, ok, err := ef.Resources.Serializer.DeserializeMetadata(ChaincodeSourcesName, privateName, orgState)
+				if err != nil {
+					return errors.WithMessagef(err, "could not deserialize chaincode-source metadata for %s", privateName)
+				}
+				if ok {
+					ccLocalPackage := &ChaincodeLocalPackage{}
+					if err := ef.Resources.Serializer.Deserialize(ChaincodeSourcesName, privateName, metadata, ccLocalPackage, orgState); err != nil {
+						return errors.WithMessagef(err, "could not deserialize chaincode package for %s", privateName)
+					}
+
+					if ccLocalPackage.PackageID == packageID {
+						return errors.Errorf("attempted to redefine uncommitted sequence (%d) for namespace %s with unchanged content", requestedSequence, ccname)
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// QueryApprovedChaincodeDefinition returns the approved chaincode definition in Org state by using the given parameters.
+// If the parameter of sequence is not provided, this function returns the latest approved chaincode definition
+// (latest: new one of the currently defined sequence number and the next sequence number).
+func (ef *ExternalFunctions) QueryApprovedChaincodeDefinition(chname, ccname string, sequence int64, publicState ReadableState, orgState ReadableState) (*ApprovedChaincodeDefinition, error) {
+
+	requestedSequence := sequence
+
+	// If requested sequence is not provided,
+	// set the latest sequence number (either the currently defined sequence number or the next sequence number)
+	if requestedSequence == 0 {
+		currentSequence, err := ef.Resources.Serializer.DeserializeFieldAsInt64(NamespacesName, ccname, "Sequence", publicState)
+		if err != nil {
+			return nil, errors.WithMessage(err, "could not get current sequence")
+		}
+		requestedSequence = currentSequence
+
+		nextSequence := currentSequence + 1
+		privateName := PrivateName(ccname, nextSequence)
+		_, ok, err := ef.Resources.Serializer.DeserializeMetadata(NamespacesName, privateName, orgState)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "could not deserialize namespace metadata for next sequence %d", nextSequence)
+		}
+		if ok {
+			requestedSequence = nextSequence
+		}
+	}
+
+	logger.Infof("Attempting to fetch approved definition (name: '%s', sequence: '%d') on channel '%s'", ccname, requestedSequence, chname)
+	privateName := PrivateName(ccname, requestedSequence)
+	metadata, ok, err := ef.Resources.Serializer.DeserializeMetadata(NamespacesName, privateName, orgState)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "could not deserialize namespace metadata for %s", privateName)
+	}
+
+	if !ok {
+		return nil, errors.Errorf("could not fetch approved chaincode definition (name: '%s', sequence: '%d') on channel '%s'", ccname, sequence, chname)
+	}
+
+	if metadata.Datatype != ChaincodeParametersType {
+		return nil, errors.Errorf("not a chaincode parameters type: %s", metadata.Datatype)
+	}
+
+	// Get chaincode parameters for the request sequence
+	ccParameters := &ChaincodeParameters{}
+	if err := ef.Resources.Serializer.Deserialize(NamespacesName, privateName, metadata, ccParameters, orgState); err != nil {
+		return nil, errors.WithMessagef(err, "could not deserialize chaincode parameters for %s", privateName)
+	}
+
+	// Get package ID for the requested sequence
+	metadata, ok, err = ef.Resources.Serializer.DeserializeMetadata(ChaincodeSourcesName, privateName, orgState)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "could not deserialize chaincode-source metadata for %s", privateName)
+	}
+	if !ok {
+		return nil, errors.Errorf("could not fetch approved chaincode definition (name: '%s', sequence: '%d') on channel '%s'", ccname, sequence, chname)
+	}
+	if metadata.Datatype != ChaincodeLocalPackageType {
+		return nil, errors.Errorf("not a chaincode local package type: %s", metadata.Datatype)
+	}
+
+	ccLocalPackage := &ChaincodeLocalPackage{}
+	if err := ef.Resources.Serializer.Deserialize(ChaincodeSourcesName, privateName, metadata, ccLocalPackage, orgState); err != nil {
+		return nil, errors.WithMessagef(err, "could not deserialize chaincode package for %s", privateName)
+	}
+
+	// Convert to lb.ChaincodeSource
+	// An empty package ID means that the chaincode won't be invocable
+	var ccsrc *lb.ChaincodeSource
+	if ccLocalPackage.PackageID != "" {
+		ccsrc = &lb.ChaincodeSource{
+			Type: &lb.ChaincodeSource_LocalPackage{
+				LocalPackage: &lb.ChaincodeSource_Local{
+					PackageId: ccLocalPackage.PackageID,
+				},
+			},
+		}
+	} else {
+		ccsrc = &lb.ChaincodeSource{
+			Type: &lb.ChaincodeSource_Unavailable_{
+				Unavailable: &lb.ChaincodeSource_Unavailable{},
+			},
+		}
+	}
+
+	return &ApprovedChaincodeDefinition{
+		Sequence:        requestedSequence,
+		EndorsementInfo: ccParameters.EndorsementInfo,
+		ValidationInfo:  ccParameters.ValidationInfo,
+		Collections:     ccParameters.Collections,
+		Source:          ccsrc,
+	}, nil
+}
+
+// ErrNamespaceNotDefined is the error returned when a namespace
+// is not defined. This indicates that the chaincode definition
+// has not been committed.
+type ErrNamespaceNotDefined struct {
+	Namespace string
+}
+
+func (e ErrNamespaceNotDefined) Error() string {
+	return fmt.Sprintf("namespace %s is not defined", e.Namespace)
+}
+
+// ErrWrongSequence is the error returned when a chaincode definition
+// is committed at a sequence other than the next sequence.
+type ErrWrongSequence struct {
+	Requested int64
+	Expected  int64
+}
+
+func (e ErrWrongSequence) Error() string {
+	return fmt.Sprintf("requested sequence is %d, but new definition must be sequence %d", e.Requested, e.Expected)
+}
+
+// ErrNotChaincodeType is the error returned when a namespace
+// is defined, but its definition is not a chaincode definition.
+type ErrNotChaincodeType struct {
+	Datatype string
+}
+
+func (e ErrNotChaincodeType) Error() string {
+	return fmt.Sprintf("not a chaincode type: %s", e.Datatype)
+}
+
+// QueryChaincodeDefinition returns the defined chaincode by the given name (if it is committed, and a chaincode)
+// or otherwise returns an error.
+func (ef *ExternalFunctions) QueryChaincodeDefinition(name string, publicState ReadableState) (*ChaincodeDefinition, error) {
+	metadata, ok, err := ef.Resources.Serializer.DeserializeMetadata(NamespacesName, name, publicState)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "could not fetch metadata for namespace %s", name)
+	}
+	if !ok {
+		return nil, ErrNamespaceNotDefined{Namespace: name}
+	}
+	if metadata.Datatype != ChaincodeDefinitionType {
+		return nil, ErrNotChaincodeType{Datatype: metadata.Datatype}
+	}
+
+	definedChaincode := &ChaincodeDefinition{}
+	if err := ef.Resources.Serializer.Deserialize(NamespacesName, name, metadata, definedChaincode, publicState); err != nil {
+		return nil, errors.WithMessagef(err, "could not deserialize namespace %s as chaincode", name)
+	}
+
+	logger.Infof("Successfully queried chaincode name '%s' with definition {%s},", name, definedChaincode)
+
+	return definedChaincode, nil
+}
+
+// QueryValidationParameter returns the validation parameter of the committed
+// definition of the named chaincode, exactly as it is stored.  Only the
+// metadata and the validation info of the definition are read.
+func (ef *ExternalFunctions) QueryValidationParameter(name string, publicState ReadableState) ([]byte, error) {
+	metadata, ok, err := ef.Resources.Serializer.DeserializeMetadata(NamespacesName, name, publicState)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "could not fetch metadata for namespace %s", name)
+	}
+	if !ok {
+		return nil, ErrNamespaceNotDefined{Namespace: name}
+	}
+	if metadata.Datatype != ChaincodeDefinitionType {
+		return nil, ErrNotChaincodeType{Datatype: metadata.Datatype}
+	}
+
+	validationInfo := &lb.ChaincodeValidationInfo{}
+	if err := ef.Resources.Serializer.DeserializeFieldAsProto(NamespacesName, name, "ValidationInfo", publicState, validationInfo); err != nil {
+		return nil, errors.WithMessagef(err, "could not deserialize validation info for chaincode %s", name)
+	}
+
+	return validationInfo.ValidationParameter, nil
+}
+
+// QueryChaincodeDefinitionWithApprovals returns the committed definition of the
+// named chaincode along with which of the orgs have approved it at its
+// committed sequence.
+func (ef *ExternalFunctions) QueryChaincodeDefinitionWithApprovals(name string, publicState ReadableState, orgStates []OpaqueState) (*ChaincodeDefinition, map[string]bool, error) {
+	definedChaincode, err := ef.QueryChaincodeDefinition(name, publicState)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	approvals, err := ef.QueryOrgApprovals(name, definedChaincode, orgStates)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return definedChaincode, approvals, nil
+}
+
+// CurrentSequence returns the sequence of the currently committed definition
+// of the named chaincode, or 0 if the chaincode is not defined.
+func (ef *ExternalFunctions) CurrentSequence(name string, publicState ReadableState) (int64, error) {
+	currentSequence, err := ef.Resources.Serializer.DeserializeFieldAsInt64(NamespacesName, name, "Sequence", publicState)
+	if err != nil {
+		return 0, errors.WithMessage(err, "could not get current sequence")
+	}
+
+	return currentSequence, nil
+}
+
+// SequenceIfDefined returns the sequence of the currently committed
+// definition of the named chaincode.  Unlike CurrentSequence, the existence of
+// the namespace is checked first, so that defined is false, rather than the
+// sequence being zero, when the chaincode is not defined.  A namespace which
+// is defined, but not as a chaincode, is an error.
+func (ef *ExternalFunctions) SequenceIfDefined(name string, publicState ReadableState) (sequence int64, defined bool, err error) {
+	metadata, ok, err := ef.Resources.Serializer.DeserializeMetadata(NamespacesName, name, publicState)
+	if err != nil {
+		return 0, false, errors.WithMessagef(err, "could not deserialize metadata for chaincode %s", name)
+	}
+	if !ok {
+		return 0, false, nil
+	}
+	if metadata.Datatype != ChaincodeDefinitionType {
+		return 0, false, ErrNotChaincodeType{Datatype: metadata.Datatype}
+	}
+
+	sequence, err = ef.CurrentSequence(name, publicState)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return sequence, true, nil
+}
+
+// AnnotationsField is the field of a chaincode's namespace under which its
+// annotations are stored.  It is not a field of the serialized definition.
+const AnnotationsField = "Annotations"
+
+// definitionAuxiliaryFields are the fields of a chaincode's namespace which
+// are stored outside of its serialized definition, but which describe the
+// committed sequence they were set against.
+var definitionAuxiliaryFields = []string{AnnotationsField, ValidationFallbackField}
+
+// clearDefinitionAuxiliaryFields deletes the auxiliary fields of the named
+// chaincode, so that they do not carry over to a newly committed sequence.
+func clearDefinitionAuxiliaryFields(name string, publicState ReadWritableState) error {
+	for _, field := range definitionAuxiliaryFields {
+		key := FieldKey(NamespacesName, name, field)
+		value, err := publicState.GetState(key)
+		if err != nil {
+			return errors.WithMessagef(err, "could not get state for key %s", key)
+		}
+		if value == nil {
+			continue
+		}
+
+		if err := publicState.DelState(key); err != nil {
+			return errors.WithMessagef(err, "could not clear field %s of chaincode %s", field, name)
+		}
+	}
+
+	return nil
+}
+
+// SetAnnotations attaches free-form annotations to the committed definition
+// of the named chaincode, replacing any existing annotations.  Annotations
+// are stored outside of the serialized definition, so they do not take part
+// in the comparison of approved parameters, and they are cleared when a new
+// sequence of the definition is committed.  Setting empty annotations
+// removes them.
+func (ef *ExternalFunctions) SetAnnotations(name string, annotations map[string]string, publicState ReadWritableState) error {
+	if _, err := ef.QueryChaincodeDefinition(name, publicState); err != nil {
+		return err
+	}
+
+	key := FieldKey(NamespacesName, name, AnnotationsField)
+	if len(annotations) == 0 {
+		if err := publicState.DelState(key); err != nil {
+			return errors.WithMessagef(err, "could not delete annotations for chaincode %s", name)
+		}
+		return nil
+	}
+
+	// encoding/json writes map keys in sorted order, so the encoding is deterministic
+	annotationsBytes, err := json.Marshal(annotations)
+	if err != nil {
+		return errors.Wrapf(err, "could not marshal annotations for chaincode %s", name)
+	}
+
+	value, err := proto.Marshal(&lb.StateData{
+		Type: &lb.StateData_Bytes{Bytes: annotationsBytes},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not marshal annotations for chaincode %s", name)
+	}
+
+	existingValue, err := publicState.GetState(key)
+	if err != nil {
+		return errors.WithMessagef(err, "could not get state for key %s", key)
+	}
+	if bytes.Equal(existingValue, value) {
+		return nil
+	}
+
+	if err := publicState.PutState(key, value); err != nil {
+		return errors.WithMessagef(err, "could not store annotations for chaincode %s", name)
+	}
+
+	return nil
+}
+
+// GetAnnotations returns the annotations attached to the committed definition
+// of the named chaincode.  If none have been set, the result is empty.
+func (ef *ExternalFunctions) GetAnnotations(name string, publicState ReadableState) (map[string]string, error) {
+	annotationsBytes, err := ef.Resources.Serializer.DeserializeFieldAsBytes(NamespacesName, name, AnnotationsField, publicState)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "could not get annotations for chaincode %s", name)
+	}
+
+	annotations := map[string]string{}
+	if len(annotationsBytes) == 0 {
+		return annotations, nil
+	}
+
+	if err := json.Unmarshal(annotationsBytes, &annotations); err != nil {
+		return nil, errors.Wrapf(err, "could not unmarshal annotations for chaincode %s", name)
+	}
+
+	return annotations, nil
+}
+
+// ValidationFallbackField is the field of a chaincode's namespace under which
+// the name of its fallback validation plugin is stored.  Like the annotations,
+// it is not a field of the serialized definition.
+const ValidationFallbackField = "ValidationFallback"
+
+// SetValidationFallback records a fallback validation plugin for the committed
+// definition of the named chaincode, replacing any existing fallback.  The
+// fallback is advisory only; it is not part of the approved parameters and
+// the lifecycle does not itself switch to it.  Like the annotations, it is
+// cleared when a new sequence of the definition is committed.  Setting an
+// empty plugin name removes the fallback.
+func (ef *ExternalFunctions) SetValidationFallback(name, plugin string, publicState ReadWritableState) error {
+	definedChaincode, err := ef.QueryChaincodeDefinition(name, publicState)
+	if err != nil {
+		return err
+	}
+
+	key := FieldKey(NamespacesName, name, ValidationFallbackField)
+	if plugin == "" {
+		if err := publicState.DelState(key); err != nil {
+			return errors.WithMessagef(err, "could not delete validation fallback for chaincode %s", name)
+		}
+		return nil
+	}
+
+	if plugin == definedChaincode.ValidationInfo.GetValidationPlugin() {
+		return errors.Errorf("validation fallback for chaincode %s must differ from its validation plugin '%s'", name, plugin)
+	}
+
+	if ef.Resources.PluginRegistry != nil && !ef.Resources.PluginRegistry.ValidationPluginExists(plugin) {
+		return errors.Errorf("validation plugin '%s' is not registered", plugin)
+	}
+
+	value, err := proto.Marshal(&lb.StateData{
+		Type: &lb.StateData_String_{String_: plugin},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not marshal validation fallback for chaincode %s", name)
+	}
+
+	if err := publicState.PutState(key, value); err != nil {
+		return errors.WithMessagef(err, "could not store validation fallback for chaincode %s", name)
+	}
+
+	return nil
+}
+
+// QueryValidationFallback returns the fallback validation plugin recorded for
+// the committed definition of the named chaincode, or the empty string if
+// none has been set.
+func (ef *ExternalFunctions) QueryValidationFallback(name string, publicState ReadableState) (string, error) {
+	plugin, err := ef.Resources.Serializer.DeserializeFieldAsString(NamespacesName, name, ValidationFallbackField, publicState)
+	if err != nil {
+		return "", errors.WithMessagef(err, "could not get validation fallback for chaincode %s", name)
+	}
+
+	return plugin, nil
+}
+
+// VerifyRoundTrip deserializes the committed definition of the named
+// chaincode, re-serializes it, and checks that the resulting keys are
+// byte for byte identical to those in the public state.  This detects
+// serializer bugs which would otherwise cause spurious state updates.
+func (ef *ExternalFunctions) VerifyRoundTrip(name string, publicState ReadableState) error {
+	definedChaincode, err := ef.QueryChaincodeDefinition(name, publicState)
+	if err != nil {
+		return err
+	}
+
+	reserialized := memoryState{}
+	if err := ef.Resources.Serializer.Serialize(NamespacesName, name, definedChaincode, reserialized); err != nil {
+		return errors.WithMessagef(err, "could not re-serialize chaincode definition for %s", name)
+	}
+
+	keys := make([]string, 0, len(reserialized))
+	for key := range reserialized {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value, err := publicState.GetState(key)
+		if err != nil {
+			return errors.WithMessagef(err, "could not get state for key %s", key)
+		}
+
+		if !bytes.Equal(value, reserialized[key]) {
+			return errors.Errorf("round trip mismatch for key %s: state contains '%x' but re-serialized to '%x'", key, value, reserialized[key])
+		}
+	}
+
+	return nil
+}
+
+// CollectionDiff compares the collections of a proposed definition against
+// those of the committed definition of the named chaincode, by collection
+// name.  It returns the sorted names of the collections which the proposal
+// adds, removes, and modifies.  If the chaincode is not yet defined, every
+// proposed collection is reported as added.
+func (ef *ExternalFunctions) CollectionDiff(name string, proposed *ChaincodeDefinition, publicState ReadableState) (added, removed, modified []string, err error) {
+	committed := &ChaincodeDefinition{}
+	definedChaincode, err := ef.QueryChaincodeDefinition(name, publicState)
+	switch err.(type) {
+	case nil:
+		committed = definedChaincode
+	case ErrNamespaceNotDefined:
+	default:
+		return nil, nil, nil, err
+	}
+
+	committedColls := collectionsByName(committed.Collections)
+	proposedColls := collectionsByName(proposed.Collections)
+
+	for collName, proposedColl := range proposedColls {
+		committedColl, ok := committedColls[collName]
+		switch {
+		case !ok:
+			added = append(added, collName)
+		case !proto.Equal(committedColl, proposedColl):
+			modified = append(modified, collName)
+		}
+	}
+
+	for collName := range committedColls {
+		if _, ok := proposedColls[collName]; !ok {
+			removed = append(removed, collName)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(modified)
+
+	return added, removed, modified, nil
+}
+
+// collectionsByName indexes the collection configs of a package by name.
+func collectionsByName(ccp *pb.CollectionConfigPackage) map[string]*pb.CollectionConfig {
+	result := map[string]*pb.CollectionConfig{}
+	for _, collConfig := range ccp.GetConfig() {
+		result[collConfig.GetStaticCollectionConfig().GetName()] = collConfig
+	}
+	return result
+}
+
+// QueryOrgApprovals returns a map containing the orgs whose orgStates were
+// provided and whether or not they have approved a chaincode definition with
+// the specified parameters.
+func (ef *ExternalFunctions) QueryOrgApprovals(name string, cd *ChaincodeDefinition, orgStates []OpaqueState) (map[string]bool, error) {
+	approvals, orgErrs := ef.orgApprovals(name, cd, orgStates)
+	if err := firstOrgError(orgErrs); err != nil {
+		return nil, err
+	}
+
+	return approvals, nil
+}
+
+// orgApprovals reports, for each org state, whether the org approved the
+// definition.  An org whose state cannot be read is reported as not
+// approving, and the error is returned keyed by the index of its state in
+// orgStates, so that an unreadable org state may be told apart from an org
+// which approved different parameters.
+func (ef *ExternalFunctions) orgApprovals(name string, cd *ChaincodeDefinition, orgStates []OpaqueState) (map[string]bool, map[int]error) {
+	approvals := map[string]bool{}
+	orgErrs := map[int]error{}
+	privateName := PrivateName(name, cd.Sequence)
+	for i, orgState := range orgStates {
+		match, err := ef.Resources.Serializer.IsSerialized(NamespacesName, privateName, cd.Parameters(), orgState)
+		if err != nil {
+			orgErrs[i] = errors.WithMessagef(err, "serialization check failed for key %s", privateName)
+		}
+
+		org := OrgFromImplicitCollectionName(orgState.CollectionName())
+		approvals[org] = err == nil && match
+	}
+
+	return approvals, orgErrs
+}
+
+// firstOrgError returns the error of the org state with the lowest index, or
+// nil if there are no errors.
+func firstOrgError(orgErrs map[int]error) error {
+	first := -1
+	for i := range orgErrs {
+		if first == -1 || i < first {
+			first = i
+		}
+	}
+	if first == -1 {
+		return nil
+	}
+	return orgErrs[first]
+}
+
+// RecomputeAgreement reads the committed chaincode definition and reports,
+// for each of the supplied orgs, whether the org's approval at the given
+// sequence matches it.  The orgStates are keyed by MSP ID.  Only the
+// currently committed definition is stored, so the sequence must be the
+// committed sequence.
+func (ef *ExternalFunctions) RecomputeAgreement(name string, sequence int64, publicState ReadableState, orgStates map[string]OpaqueState) (map[string]bool, error) {
+	definedChaincode, err := ef.QueryChaincodeDefinition(name, publicState)
+	if err != nil {
+		return nil, err
+	}
+
+	if definedChaincode.Sequence != sequence {
+		return nil, errors.Errorf("requested sequence %d does not match the committed sequence %d for chaincode %s", sequence, definedChaincode.Sequence, name)
+	}
+
+	agreement := map[string]bool{}
+	privateName := PrivateName(name, sequence)
+	for org, orgState := range orgStates {
+		match, err := ef.Resources.Serializer.IsSerialized(NamespacesName, privateName, definedChaincode.Parameters(), orgState)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "serialization check failed for key %s", privateName)
+		}
+
+		agreement[org] = match
+	}
+
+	return agreement, nil
+}
+
+// ReadinessPercent returns the fraction of the application orgs in the channel
+// which have approved a chaincode definition with the specified parameters.
+// The orgStates are keyed by MSP ID, and supplied orgs which are not members
+// of the channel are not counted.
+func (ef *ExternalFunctions) ReadinessPercent(name string, cd *ChaincodeDefinition, channelID string, orgStates map[string]OpaqueState) (float64, error) {
+	channelConfig := ef.Resources.ChannelConfigSource.GetStableChannelConfig(channelID)
+	if channelConfig == nil {
+		return 0, errors.Errorf("could not get channel config for channel '%s'", channelID)
+	}
+
+	ac, ok := channelConfig.ApplicationConfig()
+	if !ok {
+		return 0, errors.Errorf("could not get application config for channel '%s'", channelID)
+	}
+
+	orgs := ac.Organizations()
+	if len(orgs) == 0 {
+		return 0, errors.Errorf("no application orgs defined for channel '%s'", channelID)
+	}
+
+	privateName := PrivateName(name, cd.Sequence)
+	agreed := 0
+	for _, org := range orgs {
+		orgState, ok := orgStates[org.MSPID()]
+		if !ok {
+			continue
+		}
+
+		match, err := ef.Resources.Serializer.IsSerialized(NamespacesName, privateName, cd.Parameters(), orgState)
+		if err != nil {
+			return 0, errors.WithMessagef(err, "serialization check failed for key %s", privateName)
+		}
+
+		if match {
+			agreed++
+		}
+	}
+
+	return float64(agreed) / float64(len(orgs)), nil
+}
+
+// PreflightResult enumerates the problems which would prevent a chaincode
+// definition from being committed, as found by CommitPreflight.
+type PreflightResult struct {
+	// Approvals records, by MSP ID, whether each supplied org has approved
+	// the definition.
+	Approvals map[string]bool
+
+	// Issues describes each problem found.  It is empty when the definition
+	// is ready to be committed.
+	Issues []string
+}
+
+// Ready returns whether the preflight found no issues.
+func (pr *PreflightResult) Ready() bool {
+	return len(pr.Issues) == 0
+}
+
+// CommitPreflight runs the checks for committing a chaincode definition,
+// without committing it.  Rather than stopping at the first failed check,
+// every issue found is recorded in the result.  The checks are those made by
+// CommitChaincodeDefinition, along with checks that the endorsement policy of
+// the definition may be satisfied by the orgs of the channel and that the
+// approving orgs may satisfy the channel's lifecycle endorsement policy.
+// Committing the definition which is already committed at the current
+// sequence is reported as ready, as such a commit succeeds without writing.
+// The orgStates are keyed by MSP ID, and each must be the implicit collection
+// of its org.  An error is returned only if the checks could not be run.
+func (ef *ExternalFunctions) CommitPreflight(name string, cd *ChaincodeDefinition, channelID string, publicState ReadableState, orgStates map[string]OpaqueState) (*PreflightResult, error) {
+	result := &PreflightResult{
+		Approvals: map[string]bool{},
+	}
+	addIssues := func(errs ...error) {
+		for _, err := range errs {
+			result.Issues = append(result.Issues, err.Error())
+		}
+	}
+
+	channelConfig := ef.Resources.ChannelConfigSource.GetStableChannelConfig(channelID)
+	if channelConfig == nil {
+		return nil, errors.Errorf("could not get channel config for channel '%s'", channelID)
+	}
+
+	ac, ok := channelConfig.ApplicationConfig()
+	if !ok {
+		return nil, errors.Errorf("could not get application config for channel '%s'", channelID)
+	}
+
+	channelOrgs := map[string]struct{}{}
+	for _, org := range ac.Organizations() {
+		channelOrgs[org.MSPID()] = struct{}{}
+	}
+
+	mspIDs := make([]string, 0, len(orgStates))
+	for mspID := range orgStates {
+		mspIDs = append(mspIDs, mspID)
+	}
+	sort.Strings(mspIDs)
+	orderedOrgStates := make([]OpaqueState, len(mspIDs))
+	for i, mspID := range mspIDs {
+		orderedOrgStates[i] = orgStates[mspID]
+	}
+
+	addIssues(ef.definitionChecks(channelID, name, cd)...)
+
+	committedDefinition, alreadyCommitted, err := ef.alreadyCommitted(channelID, name, cd, publicState)
+	if err != nil {
+		return nil, err
+	}
+	if alreadyCommitted {
+		cd = committedDefinition
+	} else {
+		failures, err := ef.sequenceChecks(name, cd, publicState)
+		if err != nil {
+			return nil, err
+		}
+		addIssues(failures...)
+
+		if cd.EndorsementInfo == nil || cd.ValidationInfo == nil {
+			addIssues(errors.New("chaincode definition must specify endorsement info and validation info"))
+			return result, nil
+		}
+
+		cd = cd.DeepCopy()
+		if err := ef.SetChaincodeDefinitionDefaults(channelID, cd); err != nil {
+			addIssues(errors.WithMessagef(err, "could not set defaults for chaincode definition in channel %s", channelID))
+		}
+	}
+
+	privateName := PrivateName(name, cd.Sequence)
+	approvals := map[string]bool{}
+	approvingOrgs := map[string]struct{}{}
+	for i, mspID := range mspIDs {
+		match, err := ef.Resources.Serializer.IsSerialized(NamespacesName, privateName, cd.Parameters(), orderedOrgStates[i])
+		if err != nil {
+			return nil, errors.WithMessagef(err, "serialization check failed for key %s", privateName)
+		}
+
+		result.Approvals[mspID] = match
+		approvals[OrgFromImplicitCollectionName(orderedOrgStates[i].CollectionName())] = match
+		if _, ok := channelOrgs[mspID]; ok && match {
+			approvingOrgs[mspID] = struct{}{}
+		}
+	}
+
+	if alreadyCommitted {
+		return result, nil
+	}
+
+	if err := checkSignaturePolicySatisfiable(cd, channelOrgs); err != nil {
+		addIssues(err)
+	}
+
+	addIssues(ef.approvalChecks(name, cd, approvals, orderedOrgStates)...)
+
+	satisfied, err := ef.Resources.lifecycleEndorsementSatisfiable(channelID, approvingOrgs)
+	if err != nil {
+		return nil, err
+	}
+	if !satisfied {
+		addIssues(errors.Errorf("the %d approving orgs of the %d channel orgs cannot satisfy the lifecycle endorsement policy", len(approvingOrgs), len(channelOrgs)))
+	}
+
+	return result, nil
+}
+
+// lifecycleEndorsementSatisfiable returns whether endorsements from peers of
+// the supplied orgs could satisfy the lifecycle endorsement policy of the
+// channel.  Principals which are not role principals are assumed not to be
+// satisfiable, as they cannot be attributed to an org.
+func (r *Resources) lifecycleEndorsementSatisfiable(channelID string, orgs map[string]struct{}) (bool, error) {
+	policyBytes, err := r.LifecycleEndorsementPolicyAsBytes(channelID)
+	if err != nil {
+		return false, err
+	}
+
+	ap := &pb.ApplicationPolicy{}
+	if err := proto.Unmarshal(policyBytes, ap); err != nil {
+		return false, errors.Wrap(err, "could not unmarshal lifecycle endorsement policy")
+	}
+
+	var spe *cb.SignaturePolicyEnvelope
+	switch policy := ap.Type.(type) {
+	case *pb.ApplicationPolicy_SignaturePolicy:
+		spe = policy.SignaturePolicy
+	case *pb.ApplicationPolicy_ChannelConfigPolicyReference:
+		channelConfig := r.ChannelConfigSource.GetStableChannelConfig(channelID)
+		if channelConfig == nil {
+			return false, errors.Errorf("could not get channel config for channel '%s'", channelID)
+		}
+
+		p, ok := channelConfig.PolicyManager().GetPolicy(policy.ChannelConfigPolicyReference)
+		if !ok {
+			return false, errors.Errorf("could not find policy '%s' on channel '%s'", policy.ChannelConfigPolicyReference, channelID)
+		}
+
+		cp, ok := p.(policies.Converter)
+		if !ok {
+			return false, errors.Errorf("policy '%s' on channel '%s' is not convertible to SignaturePolicyEnvelope", policy.ChannelConfigPolicyReference, channelID)
+		}
+
+		spe, err = cp.Convert()
+		if err != nil {
+			return false, errors.WithMessagef(err, "error converting policy '%s' on channel '%s' to SignaturePolicyEnvelope", policy.ChannelConfigPolicyReference, channelID)
+		}
+	default:
+		return false, errors.Errorf("unsupported lifecycle endorsement policy type %T on channel '%s'", policy, channelID)
+	}
+
+	satisfiable, err := rolePrincipalsSatisfiable(spe.Identities, orgs, false)
+	if err != nil {
+		return false, err
+	}
+
+	return signaturePolicySatisfiable(spe.Rule, satisfiable), nil
+}
+
+// checkSignaturePolicySatisfiable returns an error if the validation parameter
+// of the definition is a signature policy which cannot be satisfied by role
+// principals of the supplied orgs.  Principals which are not role principals
+// are assumed to be satisfiable.  Validation parameters which are not signature
+// policies are not checked.
+func checkSignaturePolicySatisfiable(cd *ChaincodeDefinition, orgs map[string]struct{}) error {
+	ap := &pb.ApplicationPolicy{}
+	if err := proto.Unmarshal(cd.ValidationInfo.GetValidationParameter(), ap); err != nil {
+		return nil
+	}
+
+	policy := ap.GetSignaturePolicy()
+	if policy == nil {
+		return nil
+	}
+
+	satisfiable, err := rolePrincipalsSatisfiable(policy.Identities, orgs, true)
+	if err != nil {
+		return err
+	}
+
+	if !signaturePolicySatisfiable(policy.Rule, satisfiable) {
+		return errors.New("endorsement policy cannot be satisfied by the orgs of the channel")
+	}
+
+	return nil
+}
+
+// rolePrincipalsSatisfiable returns, for each principal, whether it is a role
+// principal of one of the supplied orgs.  Principals which are not role
+// principals are reported as otherSatisfiable.
+func rolePrincipalsSatisfiable(principals []*msp.MSPPrincipal, orgs map[string]struct{}, otherSatisfiable bool) ([]bool, error) {
+	satisfiable := make([]bool, len(principals))
+	for i, principal := range principals {
+		if principal.PrincipalClassification != msp.MSPPrincipal_ROLE {
+			satisfiable[i] = otherSatisfiable
+			continue
+		}
+
+		mspRole := &msp.MSPRole{}
+		if err := proto.Unmarshal(principal.Principal, mspRole); err != nil {
+			return nil, errors.Wrap(err, "could not unmarshal endorsement policy principal")
+		}
+
+		_, satisfiable[i] = orgs[mspRole.MspIdentifier]
+	}
+
+	return satisfiable, nil
+}
+
+func signaturePolicySatisfiable(rule *cb.SignaturePolicy, satisfiable []bool) bool {
+	switch t := rule.GetType().(type) {
+	case *cb.SignaturePolicy_SignedBy:
+		return t.SignedBy >= 0 && int(t.SignedBy) < len(satisfiable) && satisfiable[t.SignedBy]
+	case *cb.SignaturePolicy_NOutOf_:
+		met := int32(0)
+		for _, subRule := range t.NOutOf.Rules {
+			if signaturePolicySatisfiable(subRule, satisfiable) {
+				met++
+			}
+		}
+		return met >= t.NOutOf.N
+	default:
+		return false
+	}
+}
+
+// QueryApprovedChaincode returns the chaincode parameters which the org
+// approved for the given name and sequence.  If the org has not approved
+// any parameters for that sequence, ok is false and no error is returned.
+// Note, the org state must be readable (rather than opaque), so this is only
+// useful against the implicit collection of the peer's own org.
+func (ef *ExternalFunctions) QueryApprovedChaincode(name string, sequence int64, orgState ReadableState) (*ChaincodeParameters, bool, error) {
+	privateName := PrivateName(name, sequence)
+	metadata, ok, err := ef.Resources.Serializer.DeserializeMetadata(NamespacesName, privateName, orgState)
+	if err != nil {
+		return nil, false, errors.WithMessagef(err, "could not deserialize namespace metadata for %s", privateName)
+	}
+
+	if !ok {
+		return nil, false, nil
+	}
+
+	if metadata.Datatype != ChaincodeParametersType {
+		return nil, false, errors.Errorf("not a chaincode parameters type: %s", metadata.Datatype)
+	}
+
+	ccParameters := &ChaincodeParameters{}
+	if err := ef.Resources.Serializer.Deserialize(NamespacesName, privateName, metadata, ccParameters, orgState); err != nil {
+		return nil, false, errors.WithMessagef(err, "could not deserialize chaincode parameters for %s", privateName)
+	}
+
+	return ccParameters, true, nil
+}
+
+// SelfApprovalForCommit reports whether the local org's approval matches the
+// given chaincode definition, and returns the parameters the org approved for
+// the definition's sequence.  The approval matches if the hashes of the
+// serialized parameters of the definition match those stored for the
+// approval, as they must when the definition is committed.  If no approval
+// exists for the sequence, approved is nil.
+func (ef *ExternalFunctions) SelfApprovalForCommit(name string, cd *ChaincodeDefinition, selfOrg ReadableState) (bool, *ChaincodeParameters, error) {
+	approved, ok, err := ef.QueryApprovedChaincode(name, cd.Sequence, selfOrg)
+	if err != nil {
+		return false, nil, err
+	}
+	if !ok {
+		return false, nil, nil
+	}
+
+	privateName := PrivateName(name, cd.Sequence)
+	matched, err := ef.Resources.Serializer.IsSerialized(NamespacesName, privateName, cd.Parameters(), hashedState{selfOrg})
+	if err != nil {
+		return false, nil, errors.WithMessagef(err, "serialization check failed for key %s", privateName)
+	}
+
+	return matched, approved, nil
+}
+
+// QueryHistoricalDefinition reconstructs the parameters of the named chaincode
+// as of a past sequence.  The public state only retains the fields of the
+// current sequence, so the parameters are instead read back from the org
+// approvals at that sequence, and those approved by the most orgs are
+// returned.  If orgs are evenly split between different parameters, an error
+// is returned.  The result is unverified: an approval only reflects what was
+// committed if the definition at that sequence was in fact committed with
+// those parameters, which callers must establish separately.
+func (ef *ExternalFunctions) QueryHistoricalDefinition(name string, sequence int64, orgStates []ReadableState) (*ChaincodeDefinition, error) {
+	type tally struct {
+		parameters *ChaincodeParameters
+		orgs       int
+	}
+
+	var tallies []*tally
+	for i, orgState := range orgStates {
+		approved, ok, err := ef.QueryApprovedChaincode(name, sequence, orgState)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "could not query approval in org state %d", i)
+		}
+		if !ok {
+			continue
+		}
+
+		counted := false
+		for _, t := range tallies {
+			if t.parameters.Equal(approved) == nil {
+				t.orgs++
+				counted = true
+				break
+			}
+		}
+		if !counted {
+			tallies = append(tallies, &tally{parameters: approved, orgs: 1})
+		}
+	}
+
+	if len(tallies) == 0 {
+		return nil, errors.Errorf("no org has approved chaincode %s at sequence %d", name, sequence)
+	}
+
+	var majority *tally
+	tied := false
+	for _, t := range tallies {
+		switch {
+		case majority == nil || t.orgs > majority.orgs:
+			majority, tied = t, false
+		case t.orgs == majority.orgs:
+			tied = true
+		}
+	}
+	if tied {
+		return nil, errors.Errorf("orgs are evenly split between different parameters for chaincode %s at sequence %d", name, sequence)
+	}
+
+	return &ChaincodeDefinition{
+		Sequence:        sequence,
+		EndorsementInfo: majority.parameters.EndorsementInfo,
+		ValidationInfo:  majority.parameters.ValidationInfo,
+		Collections:     majority.parameters.Collections,
+	}, nil
+}
+
+// QueryChaincodeSourcePackageID returns the package ID which the org approved
+// for the named chaincode at the given sequence.  If the org approved the
+// definition without a local package, ok is false and no error is returned.
+func (ef *ExternalFunctions) QueryChaincodeSourcePackageID(name string, sequence int64, orgState ReadableState) (string, bool, error) {
+	privateName := PrivateName(name, sequence)
+	metadata, ok, err := ef.Resources.Serializer.DeserializeMetadata(ChaincodeSourcesName, privateName, orgState)
+	if err != nil {
+		return "", false, errors.WithMessagef(err, "could not deserialize chaincode-source metadata for %s", privateName)
+	}
+
+	if !ok {
+		return "", false, nil
+	}
+
+	if metadata.Datatype != ChaincodeLocalPackageType {
+		return "", false, errors.Errorf("not a chaincode local package type: %s", metadata.Datatype)
+	}
+
+	ccLocalPackage := &ChaincodeLocalPackage{}
+	if err := ef.Resources.Serializer.Deserialize(ChaincodeSourcesName, privateName, metadata, ccLocalPackage, orgState); err != nil {
+		return "", false, errors.WithMessagef(err, "could not deserialize chaincode package for %s", privateName)
+	}
+
+	if ccLocalPackage.PackageID == "" {
+		return "", false, nil
+	}
+
+	return ccLocalPackage.PackageID, true, nil
+}
+
+// QueryChaincodeSourceHash returns the hash of the package which the org
+// approved for the named chaincode at the given sequence, as decoded from the
+// approved package ID.  If the org approved the definition without a local
+// package, ok is false and no error is returned.
+func (ef *ExternalFunctions) QueryChaincodeSourceHash(name string, sequence int64, orgState ReadableState) ([]byte, bool, error) {
+	packageID, ok, err := ef.QueryChaincodeSourcePackageID(name, sequence, orgState)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	hash, err := hex.DecodeString(packageHash(packageID))
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "could not decode hash of package ID '%s' for %s", packageID, PrivateName(name, sequence))
+	}
+
+	return hash, true, nil
+}
+
+// CheckDefinitionReadiness reports whether the package which the org approved
+// for the chaincode definition is installed in the peer's chaincode store.  If
+// the org approved the definition without a local package, installed is false.
+func (ef *ExternalFunctions) CheckDefinitionReadiness(name string, cd *ChaincodeDefinition, orgState ReadableState) (installed bool, err error) {
+	packageID, ok, err := ef.QueryChaincodeSourcePackageID(name, cd.Sequence, orgState)
+	if err != nil {
+		return false, err
+	}
+
+	if !ok {
+		return false, nil
+	}
+
+	if _, err := ef.Resources.ChaincodeStore.RetrieveHashByPackageID(packageID); err != nil {
+		switch errors.Cause(err).(type) {
+		case persistence.CodePackageNotFoundErr, *persistence.CodePackageNotFoundErr:
+			return false, nil
+		default:
+			return false, errors.WithMessagef(err, "could not retrieve hash for package '%s'", packageID)
+		}
+	}
+
+	return true, nil
+}
+
+// QueryApprovalHistory returns the sorted sequence numbers at which the org
+// has approved parameters for the named chaincode, including sequences which
+// were never committed.
+func (ef *ExternalFunctions) QueryApprovalHistory(name string, orgState RangeableState) ([]int64, error) {
+	prefix := MetadataKey(NamespacesName, name+"#")
+	kvs, err := orgState.GetStateRange(prefix)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "could not get state range for approvals of chaincode %s", name)
+	}
+
+	sequences := []int64{}
+	for key, value := range kvs {
+		metadata := &lb.StateMetadata{}
+		if err := proto.Unmarshal(value, metadata); err != nil {
+			return nil, errors.Wrapf(err, "error unmarshaling metadata for key %s", key)
+		}
+
+		if metadata.Datatype != ChaincodeParametersType {
+			continue
+		}
+
+		approvedName, sequence, ok := ParsePrivateName(key[len(MetadataKey(NamespacesName, "")):])
+		if !ok || approvedName != name {
+			continue
+		}
+
+		sequences = append(sequences, sequence)
+	}
+
+	sort.Slice(sequences, func(i, j int) bool { return sequences[i] < sequences[j] })
+
+	return sequences, nil
+}
+
+// QueryPendingApprovals returns the chaincodes which the org has approved at
+// the sequence following the committed sequence, but which have not yet
+// been committed at that sequence.  The result maps each chaincode name to
+// the approved sequence.  Chaincodes which were never committed are pending
+// if the org approved them at sequence 1.
+func (ef *ExternalFunctions) QueryPendingApprovals(publicState RangeableState, orgState RangeableState) (map[string]int64, error) {
+	committedSequences, err := ef.committedSequences(publicState)
+	if err != nil {
+		return nil, err
+	}
+
+	metadatas, err := ef.Resources.Serializer.DeserializeAllMetadata(NamespacesName, orgState)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not query approved namespace metadata")
+	}
+
+	pending := map[string]int64{}
+	for privateName, metadata := range metadatas {
+		if metadata.Datatype != ChaincodeParametersType {
+			continue
+		}
+
+		name, sequence, ok := ParsePrivateName(privateName)
+		if !ok {
+			continue
+		}
+
+		if sequence == committedSequences[name]+1 {
+			pending[name] = sequence
+		}
+	}
+
+	return pending, nil
+}
+
+// committedSequences returns the committed sequence of each chaincode defined
+// in the public state, keyed by chaincode name.
+func (ef *ExternalFunctions) committedSequences(publicState RangeableState) (map[string]int64, error) {
+	prefix := fmt.Sprintf("%s/%s/", NamespacesName, FieldsInfix)
+	kvs, err := publicState.GetStateRange(prefix)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not query committed namespace fields")
+	}
+
+	sequences := map[string]int64{}
+	for key, value := range kvs {
+		name := strings.TrimSuffix(key[len(prefix):], "/Sequence")
+		if name == key[len(prefix):] {
+			continue
+		}
+
+		stateData := &lb.StateData{}
+		if err := proto.Unmarshal(value, stateData); err != nil {
+			return nil, errors.Wrapf(err, "could not unmarshal state for key %s", key)
+		}
+
+		sequence, ok := stateData.Type.(*lb.StateData_Int64)
+		if !ok {
+			return nil, errors.Errorf("expected key %s to encode a value of type Int64, but was %T", key, stateData.Type)
+		}
+		sequences[name] = sequence.Int64
+	}
+
+	return sequences, nil
+}
+
+// CompareApprovalToCommitted returns the committed sequence of the named
+// chaincode and the sequences at which the org has approved it, reporting
+// whether the committed sequence is among the approved sequences.  Only the
+// presence of an approval is checked, not whether its parameters match those
+// of the committed definition.  If the chaincode is not committed, the
+// committed sequence is 0 and matches is false.
+func (ef *ExternalFunctions) CompareApprovalToCommitted(name string, publicState ReadableState, orgState RangeableState) (committedSeq int64, approvedSeqs []int64, matches bool, err error) {
+	committedSeq, err = ef.CurrentSequence(name, publicState)
+	if err != nil {
+		return 0, nil, false, err
+	}
+
+	approvedSeqs, err = ef.QueryApprovalHistory(name, orgState)
+	if err != nil {
+		return 0, nil, false, err
+	}
+
+	for _, approvedSeq := range approvedSeqs {
+		if committedSeq != 0 && approvedSeq == committedSeq {
+			matches = true
+			break
+		}
+	}
+
+	return committedSeq, approvedSeqs, matches, nil
+}
+
+// QueryApprovalGaps returns the sorted committed sequences of the named
+// chaincode, from 1 up to and including the current sequence, at which the
+// org has no recorded approval.  As with CompareApprovalToCommitted, only the
+// presence of an approval is checked, not whether its parameters match those
+// which were committed.  If the chaincode is not committed, there are no gaps.
+func (ef *ExternalFunctions) QueryApprovalGaps(name string, publicState ReadableState, orgState RangeableState) ([]int64, error) {
+	currentSequence, err := ef.CurrentSequence(name, publicState)
+	if err != nil {
+		return nil, err
+	}
+
+	approvedSeqs, err := ef.QueryApprovalHistory(name, orgState)
+	if err != nil {
+		return nil, err
+	}
+
+	approved := map[int64]struct{}{}
+	for _, approvedSeq := range approvedSeqs {
+		approved[approvedSeq] = struct{}{}
+	}
+
+	gaps := []int64{}
+	for sequence := int64(1); sequence <= currentSequence; sequence++ {
+		if _, ok := approved[sequence]; !ok {
+			gaps = append(gaps, sequence)
+		}
+	}
+
+	return gaps, nil
+}
+
+// FindOrphanedSources returns the sorted sequence numbers at which the org has
+// recorded a chaincode source for the named chaincode, but which are below the
+// currently committed sequence.  Such sources can never be used again, and are
+// candidates for cleanup with PruneOrphanedSources.
+func (ef *ExternalFunctions) FindOrphanedSources(name string, publicState ReadableState, orgState RangeableState) ([]int64, error) {
+	currentSequence, err := ef.CurrentSequence(name, publicState)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := MetadataKey(ChaincodeSourcesName, name+"#")
+	kvs, err := orgState.GetStateRange(prefix)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "could not get state range for chaincode sources of chaincode %s", name)
+	}
+
+	sequences := []int64{}
+	for key := range kvs {
+		sourceName, sequence, ok := ParsePrivateName(key[len(MetadataKey(ChaincodeSourcesName, "")):])
+		if !ok || sourceName != name {
+			continue
+		}
+
+		if sequence < currentSequence {
+			sequences = append(sequences, sequence)
+		}
+	}
+
+	sort.Slice(sequences, func(i, j int) bool { return sequences[i] < sequences[j] })
+
+	return sequences, nil
+}
+
+// PruneableState is an org state which may be both ranged over and written to,
+// as is required to find and remove orphaned chaincode sources.
+type PruneableState interface {
+	ReadWritableState
+	RangeableState
+}
+
+// PruneOrphanedSources deletes the chaincode sources which FindOrphanedSources
+// reports for the named chaincode from the org state, and returns the
+// sequence numbers of the deleted sources.
+func (ef *ExternalFunctions) PruneOrphanedSources(name string, publicState ReadableState, orgState PruneableState) ([]int64, error) {
+	sequences, err := ef.FindOrphanedSources(name, publicState, orgState)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sequence := range sequences {
+		privateName := PrivateName(name, sequence)
+		metadata, ok, err := ef.Resources.Serializer.DeserializeMetadata(ChaincodeSourcesName, privateName, orgState)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "could not deserialize chaincode-source metadata for %s", privateName)
+		}
+		if !ok {
+			continue
+		}
+
+		for _, field := range metadata.Fields {
+			if err := orgState.DelState(FieldKey(ChaincodeSourcesName, privateName, field)); err != nil {
+				return nil, errors.WithMessagef(err, "could not delete chaincode source for %s", privateName)
+			}
+		}
+
+		if err := orgState.DelState(MetadataKey(ChaincodeSourcesName, privateName)); err != nil {
+			return nil, errors.WithMessagef(err, "could not delete chaincode source for %s", privateName)
+		}
+	}
+
+	return sequences, nil
+}
+
+// ChannelChaincodeReadiness reports, for every chaincode committed in the
+// channel, whether the org has approved the parameters of the currently
+// committed definition.  The approval is compared with the committed
+// parameters by the hashes of their serialized form, as during a commit, so
+// an approval for the committed sequence whose parameters differ from the
+// committed ones is reported as not ready.
+func (ef *ExternalFunctions) ChannelChaincodeReadiness(publicState RangeableState, orgState RangeableState) (map[string]bool, error) {
+	definitions, err := ef.QueryChaincodeDefinitions(publicState)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not query chaincode definitions")
+	}
+
+	kvs, err := orgState.GetStateRange(NamespacesName + "/")
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not query approved namespaces")
+	}
+	approvedState := rangeResultState(kvs)
+
+	result := map[string]bool{}
+	for name, definition := range definitions {
+		privateName := PrivateName(name, definition.Sequence)
+		match, err := ef.Resources.Serializer.IsSerialized(NamespacesName, privateName, definition.Parameters(), hashedState{approvedState})
+		if err != nil {
+			return nil, errors.WithMessagef(err, "serialization check failed for key %s", privateName)
+		}
+
+		result[name] = match
+	}
+
+	return result, nil
+}
+
+// PrepareRollbackDefinition returns a chaincode definition which restores the
+// parameters that were committed at an earlier target sequence, stamped with
+// the next sequence so that it is ready to be approved and committed.  The
+// public state only retains the currently committed definition, so the
+// parameters are read from the approval stored in the supplied org state,
+// which must be the state of an org whose approval at the target sequence
+// matched the definition committed there.
+func (ef *ExternalFunctions) PrepareRollbackDefinition(name string, targetSequence int64, publicState ReadableState, orgState ReadableState) (*ChaincodeDefinition, error) {
+	currentSequence, err := ef.CurrentSequence(name, publicState)
+	if err != nil {
+		return nil, err
+	}
+
+	if targetSequence < 1 || targetSequence >= currentSequence {
+		return nil, errors.Errorf("target sequence %d must be a previous sequence of chaincode %s, whose committed sequence is %d", targetSequence, name, currentSequence)
+	}
+
+	ccParameters, ok, err := ef.QueryApprovedChaincode(name, targetSequence, orgState)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "could not query approved parameters for chaincode %s at sequence %d", name, targetSequence)
+	}
+
+	if !ok {
+		return nil, errors.Errorf("no approved parameters found for chaincode %s at sequence %d", name, targetSequence)
+	}
+
+	return &ChaincodeDefinition{
+		Sequence:        currentSequence + 1,
+		EndorsementInfo: ccParameters.EndorsementInfo,
+		ValidationInfo:  ccParameters.ValidationInfo,
+		Collections:     ccParameters.Collections,
+	}, nil
+}
+
+// InstallChaincode installs a given chaincode to the peer's chaincode store.
+// It returns the hash to reference the chaincode by or an error on failure.
+func (ef *ExternalFunctions) InstallChaincode(chaincodeInstallPackage []byte) (*chaincode.InstalledChaincode, error) {
+	return ef.InstallChaincodeWithContext(context.Background(), chaincodeInstallPackage)
+}
+
+// InstallChaincodeWithContext installs a given chaincode like InstallChaincode,
+// but aborts the install if the supplied context is cancelled while the package
+// is being saved.
+func (ef *ExternalFunctions) InstallChaincodeWithContext(ctx context.Context, chaincodeInstallPackage []byte) (_ *chaincode.InstalledChaincode, err error) {
+	startTime := ef.metricsStartTime()
+	defer func() { ef.Metrics.observeInstall(startTime, ef.now, err) }()
+
+	if ef.MaxInstallPackageSize > 0 && int64(len(chaincodeInstallPackage)) > ef.MaxInstallPackageSize {
+		return nil, errors.Errorf("chaincode install package exceeds the maximum size of %d bytes", ef.MaxInstallPackageSize)
+	}
+
+	// Let's validate that the chaincodeInstallPackage is at least well formed before writing it
+	pkg, err := ef.Resources.PackageParser.Parse(chaincodeInstallPackage)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not parse as a chaincode install package")
+	}
+
+	if pkg.Metadata == nil {
+		return nil, errors.New("empty metadata for supplied chaincode")
+	}
+
+	// serialize concurrent installs of the same package, from
+	// saving the package through notifying the install listener
+	buildLock := ef.getBuildLock(hex.EncodeToString(util.ComputeSHA256(chaincodeInstallPackage)))
+	buildLock.Lock()
+	defer buildLock.Unlock()
+
+	return ef.saveInstallPackage(ctx, pkg.Metadata, chaincodeInstallPackage)
+}
+
+// saveInstallPackage saves the parsed chaincode install package, then builds
+// it and notifies the install listener.  It should only be called with the
+// build lock of the package already held.
+func (ef *ExternalFunctions) saveInstallPackage(ctx context.Context, metadata *persistence.ChaincodePackageMetadata, chaincodeInstallPackage []byte) (*chaincode.InstalledChaincode, error) {
+	packageID, err := ef.Resources.ChaincodeStore.SaveWithContext(ctx, metadata.Label, chaincodeInstallPackage)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not save cc install package")
+	}
+
+	return ef.buildInstalledChaincode(metadata, packageID)
+}
+
+// InstallChaincodeStream installs a chaincode install package read from the
+// supplied reader.  Rather than holding the whole package in memory, it is
+// streamed through the package parser, which performs the same checks as for
+// InstallChaincode, and into the chaincode store, which computes the package
+// hash as it writes.  The package is only saved once it has been parsed.
+func (ef *ExternalFunctions) InstallChaincodeStream(r io.Reader) (_ *chaincode.InstalledChaincode, err error) {
+	startTime := ef.metricsStartTime()
+	defer func() { ef.Metrics.observeInstall(startTime, ef.now, err) }()
+
+	type savedPackage struct {
+		packageID string
+		metadata  *persistence.ChaincodePackageMetadata
+		err       error
+	}
+
+	pr, pw := io.Pipe()
+	savedC := make(chan savedPackage, 1)
+	go func() {
+		packageID, metadata, err := ef.Resources.ChaincodeStore.SaveStream(pr, ef.MaxInstallPackageSize)
+		// the store may stop reading early, which must not block the parser
+		pr.Close()
+		savedC <- savedPackage{packageID: packageID, metadata: metadata, err: err}
+	}()
+
+	tr := io.TeeReader(r, pw)
+	_, parseErr := ef.Resources.PackageParser.ParseStream(tr)
+	if parseErr == nil {
+		// the parser need not read to the end of the package, but the
+		// store must receive all of it
+		_, parseErr = io.Copy(ioutil.Discard, tr)
+	}
+	// failing the stream prevents the store from saving the package
+	pw.CloseWithError(parseErr)
+	saved := <-savedC
+
+	// a closed pipe means the store stopped reading, and its error is the cause
+	if parseErr != nil && errors.Cause(parseErr) != io.ErrClosedPipe {
+		return nil, errors.WithMessage(parseErr, "could not parse as a chaincode install package")
+	}
+	if saved.err != nil {
+		return nil, errors.WithMessage(saved.err, "could not save cc install package")
+	}
+
+	// the package hash is only known once the package is saved, so
+	// concurrent installs of the same package are serialized from here
+	buildLock := ef.getBuildLock(packageHash(saved.packageID))
+	buildLock.Lock()
+	defer buildLock.Unlock()
+
+	return ef.buildInstalledChaincode(saved.metadata, saved.packageID)
+}
+
+// buildInstalledChaincode builds a saved chaincode install package and
+// notifies the install listener.  The caller must hold the build lock for
+// the package.
+func (ef *ExternalFunctions) buildInstalledChaincode(metadata *persistence.ChaincodePackageMetadata, packageID string) (*chaincode.InstalledChaincode, error) {
+	buildStatus, ok := ef.BuildRegistry.BuildStatus(packageID)
+	if ok {
+		// another invocation of lifecycle has concurrently
+		// installed a chaincode with this package id
+		<-buildStatus.Done()
+		if buildStatus.Err() == nil {
+			return nil, errors.New("chaincode already successfully installed")
+		}
+		buildStatus = ef.BuildRegistry.ResetBuildStatus(packageID)
+	}
+	err := ef.ChaincodeBuilder.Build(packageID)
+	buildStatus.Notify(err)
+	<-buildStatus.Done()
+	if err := buildStatus.Err(); err != nil {
+		ef.Resources.ChaincodeStore.Delete(packageID)
+		return nil, errors.WithMessage(err, "could not build chaincode")
+	}
+
+	if ef.InstallListener != nil {
+		ef.InstallListener.HandleChaincodeInstalled(metadata, packageID)
+	}
+
+	ef.emitEvent(LifecycleEvent{
+		Type:      ChaincodeInstalledEvent,
+		Name:      metadata.Label,
+		PackageID: packageID,
+	})
+
+	logger.Infof("Successfully installed chaincode with package ID '%s'", packageID)
+
+	return &chaincode.InstalledChaincode{
+		PackageID: packageID,
+		Label:     metadata.Label,
+	}, nil
+}
+
+// InstallChaincodeIfAbsent installs the supplied chaincode install package
+// unless an identical package is already installed.  When it is, the existing
+// installed chaincode is returned, the install listener is not notified, and
+// alreadyInstalled is true.  The check and the install are made under the
+// build lock of the package, so that of several concurrent calls with the
+// same package, only one installs it.
+func (ef *ExternalFunctions) InstallChaincodeIfAbsent(chaincodeInstallPackage []byte) (_ *chaincode.InstalledChaincode, alreadyInstalled bool, err error) {
+	startTime := ef.metricsStartTime()
+	defer func() {
+		if !alreadyInstalled {
+			ef.Metrics.observeInstall(startTime, ef.now, err)
+		}
+	}()
+
+	if ef.MaxInstallPackageSize > 0 && int64(len(chaincodeInstallPackage)) > ef.MaxInstallPackageSize {
+		return nil, false, errors.Errorf("chaincode install package exceeds the maximum size of %d bytes", ef.MaxInstallPackageSize)
+	}
+
+	pkg, err := ef.Resources.PackageParser.Parse(chaincodeInstallPackage)
+	if err != nil {
+		return nil, false, errors.WithMessage(err, "could not parse as a chaincode install package")
+	}
+
+	if pkg.Metadata == nil {
+		return nil, false, errors.New("empty metadata for supplied chaincode")
+	}
+
+	hash := util.ComputeSHA256(chaincodeInstallPackage)
+	buildLock := ef.getBuildLock(hex.EncodeToString(hash))
+	buildLock.Lock()
+	defer buildLock.Unlock()
+
+	installedChaincodes, err := ef.Resources.ChaincodeStore.ListInstalledChaincodes()
+	if err != nil {
+		return nil, false, errors.WithMessage(err, "could not list installed chaincodes")
+	}
+
+	for _, installedChaincode := range installedChaincodes {
+		if bytes.Equal(installedChaincode.Hash, hash) {
+			return &chaincode.InstalledChaincode{
+				PackageID: installedChaincode.PackageID,
+				Label:     installedChaincode.Label,
+			}, true, nil
+		}
+	}
+
+	installedChaincode, err := ef.saveInstallPackage(context.Background(), pkg.Metadata, chaincodeInstallPackage)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return installedChaincode, false, nil
+}
+
+// InstallChaincodesFromDir installs every chaincode install package with a
+// .tar.gz extension in the supplied directory, and returns the hash of each
+// package which was installed, keyed by its file name.  A package which
+// cannot be read or installed does not prevent the remaining packages from
+// being installed; the failures are instead reported together in the
+// returned error.
+func (ef *ExternalFunctions) InstallChaincodesFromDir(dir string) (map[string][]byte, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read chaincode install package directory '%s'", dir)
+	}
+
+	hashes := map[string][]byte{}
+	var failures []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".tar.gz") {
+			continue
+		}
+
+		pkgBytes, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", entry.Name(), err))
+			continue
+		}
+
+		if _, err := ef.InstallChaincode(pkgBytes); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", entry.Name(), err))
+			continue
+		}
+
+		hashes[entry.Name()] = util.ComputeSHA256(pkgBytes)
+	}
+
+	if len(failures) > 0 {
+		return hashes, errors.Errorf("could not install %d chaincode install package(s) from '%s': %s", len(failures), dir, strings.Join(failures, "; "))
+	}
+
+	return hashes, nil
+}
+
+// getBuildLock returns the lock for the install package with the given hash.
+func (ef *ExternalFunctions) getBuildLock(packageHash string) *sync.Mutex {
+	ef.mutex.Lock()
+	defer ef.mutex.Unlock()
+
+	if ef.BuildLocks == nil {
+		ef.BuildLocks = map[string]*sync.Mutex{}
+	}
+
+	buildLock, ok := ef.BuildLocks[packageHash]
+	if !ok {
+		buildLock = &sync.Mutex{}
+		ef.BuildLocks[packageHash] = buildLock
+	}
+
+	return buildLock
+}
+
+// CheckInitCapability returns an error if the chaincode definition requires
+// initialization but the installed package described by the supplied
+// metadata does not advertise support for Init.  If no
+// PackageCapabilityChecker is configured, the check is skipped.
+func (ef *ExternalFunctions) CheckInitCapability(cd *ChaincodeDefinition, md *persistence.ChaincodePackageMetadata) error {
+	if ef.PackageCapabilityChecker == nil || !cd.EndorsementInfo.InitRequired {
+		return nil
+	}
+
+	if !ef.PackageCapabilityChecker.SupportsInit(md) {
+		return errors.Errorf("chaincode definition requires initialization, but package with label '%s' does not support Init", md.Label)
+	}
+
+	return nil
+}
+
+// GetInstalledChaincodePackage retrieves the installed chaincode with the given package ID
+// from the peer's chaincode store.
+func (ef *ExternalFunctions) GetInstalledChaincodePackage(packageID string) ([]byte, error) {
+	pkgBytes, err := ef.Resources.ChaincodeStore.Load(packageID)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not load cc install package")
+	}
+
+	return pkgBytes, nil
+}
+
+// QueryNamespaceDefinitions lists the publicly defined namespaces in a channel, along with the friendly
+// name of their datatype from the datatype registry.  Today it should only ever find Datatype encodings
+// of 'ChaincodeDefinition'.
+func (ef *ExternalFunctions) QueryNamespaceDefinitions(publicState RangeableState) (map[string]string, error) {
+	metadatas, err := ef.Resources.Serializer.DeserializeAllMetadata(NamespacesName, publicState)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not query namespace metadata")
+	}
+
+	registry := ef.Resources.datatypeRegistry()
+	result := map[string]string{}
+	for key, value := range metadatas {
+		// Unregistered datatypes should never be found, but reporting their raw name
+		// seems preferable to returning an error
+		result[key] = registry.FriendlyName(value.Datatype)
+	}
+	return result, nil
+}
+
+// QueryChaincodeSources lists the chaincode sources recorded in the org's
+// state, mapping the private name (<name>#<sequence>) of each approval to the
+// hash of the package it points to.  Approvals made without a local package
+// map to a nil hash.
+func (ef *ExternalFunctions) QueryChaincodeSources(orgState RangeableState) (map[string][]byte, error) {
+	localPackages, err := ef.localPackages(orgState)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string][]byte{}
+	for privateName, ccLocalPackage := range localPackages {
+		if ccLocalPackage.PackageID == "" {
+			result[privateName] = nil
+			continue
+		}
+
+		hash, err := hex.DecodeString(packageHash(ccLocalPackage.PackageID))
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not decode hash of package ID '%s' for %s", ccLocalPackage.PackageID, privateName)
+		}
+		result[privateName] = hash
+	}
+
+	return result, nil
+}
+
+// QueryOrgsWithPackage returns the sorted indices of the supplied org states
+// in which an approval of the named chaincode, at any sequence, references the
+// installed package with the given hash.
+func (ef *ExternalFunctions) QueryOrgsWithPackage(name string, packageHash []byte, orgStates []RangeableState) ([]int, error) {
+	orgs := []int{}
+	for i, orgState := range orgStates {
+		sources, err := ef.QueryChaincodeSources(orgState)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "could not query chaincode sources for org %d", i)
+		}
+
+		for privateName, hash := range sources {
+			sourceName, _, ok := ParsePrivateName(privateName)
+			if !ok || sourceName != name || hash == nil {
+				continue
+			}
+
+			if bytes.Equal(hash, packageHash) {
+				orgs = append(orgs, i)
+				break
+			}
+		}
+	}
+
+	return orgs, nil
+}
+
+// QueryNamespaceDefinitionsByType returns the sorted names of the publicly
+// defined namespaces in a channel whose datatype matches the requested one.
+// The datatype is matched case-insensitively against either the internal
+// datatype name, such as 'ChaincodeDefinition', or its friendly name, such
+// as 'Chaincode'.
+func (ef *ExternalFunctions) QueryNamespaceDefinitionsByType(publicState RangeableState, datatype string) ([]string, error) {
+	metadatas, err := ef.Resources.Serializer.DeserializeAllMetadata(NamespacesName, publicState)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not query namespace metadata")
+	}
+
+	registry := ef.Resources.datatypeRegistry()
+	names := []string{}
+	for key, value := range metadatas {
+		if strings.EqualFold(value.Datatype, datatype) || strings.EqualFold(registry.FriendlyName(value.Datatype), datatype) {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// NamespaceType returns the friendly name of the datatype of a single publicly
+// defined namespace, as QueryNamespaceDefinitions reports it, reading only the
+// metadata of that namespace.  If the namespace is not defined, ok is false.
+func (ef *ExternalFunctions) NamespaceType(name string, publicState ReadableState) (friendlyType string, ok bool, err error) {
+	metadata, ok, err := ef.Resources.Serializer.DeserializeMetadata(NamespacesName, name, publicState)
+	if err != nil {
+		return "", false, errors.WithMessagef(err, "could not query metadata for namespace %s", name)
+	}
+	if !ok {
+		return "", false, nil
+	}
+
+	return ef.Resources.datatypeRegistry().FriendlyName(metadata.Datatype), true, nil
+}
+
+// QueryNamespaceDefinitionsPaginated lists a page of at most pageSize publicly defined
+// namespaces in a channel, starting from the bookmark, as QueryNamespaceDefinitions does.
+// It also returns the bookmark of the next page, which is empty once the last page has
+// been returned.  An empty bookmark requests the first page.
+func (ef *ExternalFunctions) QueryNamespaceDefinitionsPaginated(publicState RangeableState, bookmark string, pageSize int32) (map[string]string, string, error) {
+	if pageSize <= 0 {
+		return nil, "", errors.Errorf("page size must be positive, but was %d", pageSize)
+	}
+
+	metadatas, nextBookmark, err := ef.Resources.Serializer.DeserializeMetadataPage(NamespacesName, publicState, bookmark, pageSize)
+	if err != nil {
+		return nil, "", errors.WithMessage(err, "could not query namespace metadata")
+	}
+
+	registry := ef.Resources.datatypeRegistry()
+	result := map[string]string{}
+	for key, value := range metadatas {
+		result[key] = registry.FriendlyName(value.Datatype)
+	}
+	return result, nextBookmark, nil
+}
+
+// QueryChaincodeDefinitions returns the definitions of all the chaincodes
+// defined in a channel, keyed by chaincode name.  The definitions are read
+// with a single range query over the public state.  Namespaces which are
+// not chaincode definitions are skipped.
+func (ef *ExternalFunctions) QueryChaincodeDefinitions(publicState RangeableState) (map[string]*ChaincodeDefinition, error) {
+	kvs, err := publicState.GetStateRange(NamespacesName + "/")
+	if err != nil {
+		return nil, errors.WithMessagef(err, "could not get state range for namespace %s", NamespacesName)
+	}
+	rangeState := rangeResultState(kvs)
+
+	metadataPrefix := fmt.Sprintf("%s/%s/", NamespacesName, MetadataInfix)
+	result := map[string]*ChaincodeDefinition{}
+	for key, value := range kvs {
+		if !strings.HasPrefix(key, metadataPrefix) {
+			continue
+		}
+		name := key[len(metadataPrefix):]
+
+		metadata := &lb.StateMetadata{}
+		if err := proto.Unmarshal(value, metadata); err != nil {
+			return nil, errors.Wrapf(err, "error unmarshaling metadata for key %s", key)
+		}
+
+		if metadata.Datatype != ChaincodeDefinitionType {
+			continue
+		}
+
+		definedChaincode := &ChaincodeDefinition{}
+		if err := ef.Resources.Serializer.Deserialize(NamespacesName, name, metadata, definedChaincode, rangeState); err != nil {
+			return nil, errors.WithMessagef(err, "could not deserialize namespace %s as chaincode", name)
+		}
+
+		result[name] = definedChaincode
+	}
+
+	return result, nil
+}
+
+// QueryInitRequiredChaincodes returns the sorted names of the chaincodes
+// defined in a channel whose current definition requires initialization.
+func (ef *ExternalFunctions) QueryInitRequiredChaincodes(publicState RangeableState) ([]string, error) {
+	definitions, err := ef.QueryChaincodeDefinitions(publicState)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not query chaincode definitions")
+	}
+
+	var result []string
+	for name, definition := range definitions {
+		if definition.EndorsementInfo.GetInitRequired() {
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+
+	return result, nil
+}
+
+// QueryChaincodeDefinitionsByPlugin returns the sorted names of the chaincodes
+// defined in a channel whose current definition uses the given endorsement
+// plugin.  An empty plugin name matches the chaincodes using the builtin
+// endorsement plugin, whether it was named explicitly or left empty.
+func (ef *ExternalFunctions) QueryChaincodeDefinitionsByPlugin(publicState RangeableState, endorsementPlugin string) ([]string, error) {
+	definitions, err := ef.QueryChaincodeDefinitions(publicState)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not query chaincode definitions")
+	}
+
+	var result []string
+	for name, definition := range definitions {
+		plugin := definition.EndorsementInfo.GetEndorsementPlugin()
+		if plugin == endorsementPlugin || (endorsementPlugin == "" && plugin == "escc") {
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+
+	return result, nil
+}
 
-		nextSequence := currentSequence + 1
-		privateName := fmt.Sprintf("%s#%d", ccname, nextSequence)
-		_, ok, err := ef.Resources.Serializer.DeserializeMetadata(NamespacesName, privateName, orgState)
-		if err != nil {
-			return nil, errors.WithMessagef(err, "could not deserialize namespace metadata for next sequence %d", nextSequence)
+// QueryInstalledChaincode returns metadata for the chaincode with the supplied package ID.
+// If the package ID has an empty hash (i.e. it is of the form '<label>:'), the installed
+// chaincode is instead looked up by label alone, which must match exactly one installed
+// chaincode.
+func (ef *ExternalFunctions) QueryInstalledChaincode(packageID string) (*chaincode.InstalledChaincode, error) {
+	if !strings.HasSuffix(packageID, ":") {
+		return ef.InstalledChaincodesLister.GetInstalledChaincode(packageID)
+	}
+
+	label := strings.TrimSuffix(packageID, ":")
+	var matches []*chaincode.InstalledChaincode
+	for _, installedChaincode := range ef.InstalledChaincodesLister.ListInstalledChaincodes() {
+		if installedChaincode.Label == label {
+			matches = append(matches, installedChaincode)
 		}
-		if ok {
-			requestedSequence = nextSequence
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, errors.Errorf("could not find chaincode with label '%s'", label)
+	case 1:
+		return matches[0], nil
+	default:
+		candidates := make([]string, len(matches))
+		for i, match := range matches {
+			candidates[i] = match.PackageID
 		}
+		sort.Strings(candidates)
+		return nil, errors.Errorf("found multiple chaincodes with label '%s', candidate package IDs: %s", label, strings.Join(candidates, ", "))
 	}
+}
 
-	logger.Infof("Attempting to fetch approved definition (name: '%s', sequence: '%d') on channel '%s'", ccname, requestedSequence, chname)
-	privateName := fmt.Sprintf("%s#%d", ccname, requestedSequence)
-	metadata, ok, err := ef.Resources.Serializer.DeserializeMetadata(NamespacesName, privateName, orgState)
+// QueryInstalledChaincodeByPackageID returns the hash of the installed
+// chaincode package with the supplied package ID.
+func (ef *ExternalFunctions) QueryInstalledChaincodeByPackageID(packageID string) ([]byte, error) {
+	hash, err := ef.Resources.ChaincodeStore.RetrieveHashByPackageID(packageID)
 	if err != nil {
-		return nil, errors.WithMessagef(err, "could not deserialize namespace metadata for %s", privateName)
+		return nil, errors.WithMessage(err, "could not retrieve hash for cc install package")
 	}
 
-	if !ok {
-		return nil, errors.Errorf("could not fetch approved chaincode definition (name: '%s', sequence: '%d') on channel '%s'", ccname, sequence, chname)
-	}
+	return hash, nil
+}
 
-	if metadata.Datatype != ChaincodeParametersType {
-		return nil, errors.Errorf("not a chaincode parameters type: %s", metadata.Datatype)
+// VerifyInstalledChaincode re-verifies the integrity of an installed
+// chaincode package, so that packages which have been corrupted on disk may
+// be detected.  The package is loaded and re-parsed, and the hash of its
+// bytes is compared to the hash it was stored under.  If the package does
+// not parse or its hash does not match, ok is false and an error describing
+// the problem is returned.
+func (ef *ExternalFunctions) VerifyInstalledChaincode(packageID string) (ok bool, err error) {
+	storedHash, err := ef.Resources.ChaincodeStore.RetrieveHashByPackageID(packageID)
+	if err != nil {
+		return false, errors.WithMessagef(err, "could not retrieve hash for package '%s'", packageID)
 	}
 
-	// Get chaincode parameters for the request sequence
-	ccParameters := &ChaincodeParameters{}
-	if err := ef.Resources.Serializer.Deserialize(NamespacesName, privateName, metadata, ccParameters, orgState); err != nil {
-		return nil, errors.WithMessagef(err, "could not deserialize chaincode parameters for %s", privateName)
+	pkgBytes, err := ef.Resources.ChaincodeStore.Load(packageID)
+	if err != nil {
+		return false, errors.WithMessage(err, "could not load cc install package")
 	}
 
-	// Get package ID for the requested sequence
-	metadata, ok, err = ef.Resources.Serializer.DeserializeMetadata(ChaincodeSourcesName, privateName, orgState)
-	if err != nil {
-		return nil, errors.WithMessagef(err, "could not deserialize chaincode-source metadata for %s", privateName)
+	if _, err := ef.Resources.PackageParser.Parse(pkgBytes); err != nil {
+		return false, errors.WithMessagef(err, "chaincode install package '%s' could not be parsed", packageID)
 	}
-	if !ok {
-		return nil, errors.Errorf("could not fetch approved chaincode definition (name: '%s', sequence: '%d') on channel '%s'", ccname, sequence, chname)
+
+	if hash := util.ComputeSHA256(pkgBytes); !bytes.Equal(hash, storedHash) {
+		return false, errors.Errorf("chaincode install package '%s' has hash %x but was stored with hash %x", packageID, hash, storedHash)
 	}
-	if metadata.Datatype != ChaincodeLocalPackageType {
-		return nil, errors.Errorf("not a chaincode local package type: %s", metadata.Datatype)
+
+	return true, nil
+}
+
+// QueryInstalledChaincodes returns a list of installed chaincodes
+func (ef *ExternalFunctions) QueryInstalledChaincodes() []*chaincode.InstalledChaincode {
+	return ef.InstalledChaincodesLister.ListInstalledChaincodes()
+}
+
+// CountInstalledChaincodes returns the number of chaincodes installed in the
+// peer's chaincode store, without listing them.
+func (ef *ExternalFunctions) CountInstalledChaincodes() (int, error) {
+	count, err := ef.Resources.ChaincodeStore.Count()
+	if err != nil {
+		return 0, errors.WithMessage(err, "could not count installed chaincodes")
 	}
 
-	ccLocalPackage := &ChaincodeLocalPackage{}
-	if err := ef.Resources.Serializer.Deserialize(ChaincodeSourcesName, privateName, metadata, ccLocalPackage, orgState); err != nil {
-		return nil, errors.WithMessagef(err, "could not deserialize chaincode package for %s", privateName)
+	return count, nil
+}
+
+// QueryInstalledChaincodesByLabel returns the chaincodes installed in the
+// peer's chaincode store whose package label begins with the supplied prefix.
+// The match is case-sensitive, and an empty prefix returns every installed
+// chaincode.
+func (ef *ExternalFunctions) QueryInstalledChaincodesByLabel(prefix string) ([]chaincode.InstalledChaincode, error) {
+	installedChaincodes, err := ef.Resources.ChaincodeStore.ListInstalledChaincodes()
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not list installed chaincodes")
 	}
 
-	// Convert to lb.ChaincodeSource
-	// An empty package ID means that the chaincode won't be invocable
-	var ccsrc *lb.ChaincodeSource
-	if ccLocalPackage.PackageID != "" {
-		ccsrc = &lb.ChaincodeSource{
-			Type: &lb.ChaincodeSource_LocalPackage{
-				LocalPackage: &lb.ChaincodeSource_Local{
-					PackageId: ccLocalPackage.PackageID,
-				},
-			},
-		}
-	} else {
-		ccsrc = &lb.ChaincodeSource{
-			Type: &lb.ChaincodeSource_Unavailable_{
-				Unavailable: &lb.ChaincodeSource_Unavailable{},
-			},
+	result := []chaincode.InstalledChaincode{}
+	for _, installedChaincode := range installedChaincodes {
+		if strings.HasPrefix(installedChaincode.Label, prefix) {
+			result = append(result, installedChaincode)
 		}
 	}
 
-	return &ApprovedChaincodeDefinition{
-		Sequence:        requestedSequence,
-		EndorsementInfo: ccParameters.EndorsementInfo,
-		ValidationInfo:  ccParameters.ValidationInfo,
-		Collections:     ccParameters.Collections,
-		Source:          ccsrc,
-	}, nil
+	return result, nil
 }
 
-// ErrNamespaceNotDefined is the error returned when a namespace
-// is not defined. This indicates that the chaincode definition
-// has not been committed.
-type ErrNamespaceNotDefined struct {
-	Namespace string
+// InstalledChaincodeWithMetadata is an installed chaincode along with the
+// metadata parsed from its install package.  If the package could not be
+// loaded or parsed, Metadata is nil and Err describes the failure.
+type InstalledChaincodeWithMetadata struct {
+	*chaincode.InstalledChaincode
+	Metadata *persistence.ChaincodePackageMetadata
+	Err      error
 }
 
-func (e ErrNamespaceNotDefined) Error() string {
-	return fmt.Sprintf("namespace %s is not defined", e.Namespace)
+// QueryInstalledChaincodesWithMetadata returns a list of installed chaincodes
+// along with the metadata of each of their install packages.  A package which
+// cannot be loaded or parsed is reported on its entry, and does not prevent
+// the remainder of the chaincodes from being listed.
+func (ef *ExternalFunctions) QueryInstalledChaincodesWithMetadata() []*InstalledChaincodeWithMetadata {
+	installedChaincodes := ef.InstalledChaincodesLister.ListInstalledChaincodes()
+	result := make([]*InstalledChaincodeWithMetadata, 0, len(installedChaincodes))
+	for _, installedChaincode := range installedChaincodes {
+		entry := &InstalledChaincodeWithMetadata{
+			InstalledChaincode: installedChaincode,
+		}
+		entry.Metadata, entry.Err = ef.installedPackageMetadata(installedChaincode.PackageID)
+		result = append(result, entry)
+	}
+
+	return result
 }
 
-// QueryChaincodeDefinition returns the defined chaincode by the given name (if it is committed, and a chaincode)
-// or otherwise returns an error.
-func (ef *ExternalFunctions) QueryChaincodeDefinition(name string, publicState ReadableState) (*ChaincodeDefinition, error) {
-	metadata, ok, err := ef.Resources.Serializer.DeserializeMetadata(NamespacesName, name, publicState)
+func (ef *ExternalFunctions) installedPackageMetadata(packageID string) (*persistence.ChaincodePackageMetadata, error) {
+	pkgBytes, err := ef.Resources.ChaincodeStore.Load(packageID)
 	if err != nil {
-		return nil, errors.WithMessagef(err, "could not fetch metadata for namespace %s", name)
+		return nil, errors.WithMessagef(err, "could not load cc install package '%s'", packageID)
 	}
-	if !ok {
-		return nil, ErrNamespaceNotDefined{Namespace: name}
+
+	pkg, err := ef.Resources.PackageParser.Parse(pkgBytes)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "could not parse cc install package '%s'", packageID)
 	}
 
-	definedChaincode := &ChaincodeDefinition{}
-	if err := ef.Resources.Serializer.Deserialize(NamespacesName, name, metadata, definedChaincode, publicState); err != nil {
-		return nil, errors.WithMessagef(err, "could not deserialize namespace %s as chaincode", name)
+	if pkg.Metadata == nil {
+		return nil, errors.Errorf("empty metadata for cc install package '%s'", packageID)
 	}
 
-	logger.Infof("Successfully queried chaincode name '%s' with definition {%s},", name, definedChaincode)
+	return pkg.Metadata, nil
+}
 
-	return definedChaincode, nil
+var (
+	semverVersionRegExp = regexp.MustCompile(`^v?[0-9]+\.[0-9]+\.[0-9]+(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+	dateVersionRegExp   = regexp.MustCompile(`^[0-9]{4}[-.]?[0-9]{2}[-.]?[0-9]{2}([-_.+][0-9A-Za-z._-]+)?$`)
+)
+
+// versionScheme returns the name of the scheme the version conforms to,
+// or the empty string if it conforms to no known scheme.
+func versionScheme(version string) string {
+	switch {
+	case semverVersionRegExp.MatchString(version):
+		return "semver"
+	case dateVersionRegExp.MatchString(version):
+		return "date"
+	default:
+		return ""
+	}
 }
 
-// QueryOrgApprovals returns a map containing the orgs whose orgStates were
-// provided and whether or not they have approved a chaincode definition with
-// the specified parameters.
-func (ef *ExternalFunctions) QueryOrgApprovals(name string, cd *ChaincodeDefinition, orgStates []OpaqueState) (map[string]bool, error) {
-	approvals := map[string]bool{}
-	privateName := fmt.Sprintf("%s#%d", name, cd.Sequence)
-	for _, orgState := range orgStates {
-		match, err := ef.Resources.Serializer.IsSerialized(NamespacesName, privateName, cd.Parameters(), orgState)
-		if err != nil {
-			return nil, errors.WithMessagef(err, "serialization check failed for key %s", privateName)
+// ValidateVersionConsistency checks that the versions of a family of related
+// chaincode definitions, keyed by chaincode name, all follow the same version
+// scheme (either semver or date-based).  The scheme shared by the most
+// definitions is expected, and the definitions which do not conform to it
+// are reported in the returned error.
+func (ef *ExternalFunctions) ValidateVersionConsistency(defs map[string]*ChaincodeDefinition) error {
+	schemes := map[string]string{}
+	counts := map[string]int{}
+	for name, cd := range defs {
+		scheme := versionScheme(cd.EndorsementInfo.Version)
+		schemes[name] = scheme
+		if scheme != "" {
+			counts[scheme]++
 		}
+	}
 
-		org := OrgFromImplicitCollectionName(orgState.CollectionName())
-		approvals[org] = match
+	expected := "semver"
+	if counts["date"] > counts["semver"] {
+		expected = "date"
 	}
 
-	return approvals, nil
+	var outliers []string
+	for name, scheme := range schemes {
+		if scheme != expected {
+			outliers = append(outliers, fmt.Sprintf("%s ('%s')", name, defs[name].EndorsementInfo.Version))
+		}
+	}
+
+	if len(outliers) == 0 {
+		return nil
+	}
+
+	sort.Strings(outliers)
+	return errors.Errorf("chaincode versions do not follow the %s version scheme: %s", expected, strings.Join(outliers, ", "))
 }
 
-// InstallChaincode installs a given chaincode to the peer's chaincode store.
-// It returns the hash to reference the chaincode by or an error on failure.
-func (ef *ExternalFunctions) InstallChaincode(chaincodeInstallPackage []byte) (*chaincode.InstalledChaincode, error) {
-	// Let's validate that the chaincodeInstallPackage is at least well formed before writing it
-	pkg, err := ef.Resources.PackageParser.Parse(chaincodeInstallPackage)
+// MissingPackages returns the IDs of the packages which the org approved for
+// the currently committed definition of each chaincode, but which are not
+// installed in the peer's chaincode store.  This is useful for pre-staging
+// packages on a new peer.
+func (ef *ExternalFunctions) MissingPackages(publicState ReadableState, orgState RangeableState) ([]string, error) {
+	localPackages, err := ef.localPackages(orgState)
 	if err != nil {
-		return nil, errors.WithMessage(err, "could not parse as a chaincode install package")
+		return nil, err
 	}
 
-	if pkg.Metadata == nil {
-		return nil, errors.New("empty metadata for supplied chaincode")
+	installedChaincodes, err := ef.Resources.ChaincodeStore.ListInstalledChaincodes()
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not list installed chaincodes")
 	}
 
-	packageID, err := ef.Resources.ChaincodeStore.Save(pkg.Metadata.Label, chaincodeInstallPackage)
-	if err != nil {
-		return nil, errors.WithMessage(err, "could not save cc install package")
+	installed := map[string]struct{}{}
+	for _, installedChaincode := range installedChaincodes {
+		installed[installedChaincode.PackageID] = struct{}{}
 	}
 
-	buildLock := ef.getBuildLock(packageID)
-	buildLock.Lock()
-	defer buildLock.Unlock()
+	missing := map[string]struct{}{}
+	for privateName, ccLocalPackage := range localPackages {
+		if ccLocalPackage.PackageID == "" {
+			continue
+		}
 
-	buildStatus, ok := ef.BuildRegistry.BuildStatus(packageID)
-	if ok {
-		// another invocation of lifecycle has concurrently
-		// installed a chaincode with this package id
-		<-buildStatus.Done()
-		if buildStatus.Err() == nil {
-			return nil, errors.New("chaincode already successfully installed")
+		name, sequence, ok := ParsePrivateName(privateName)
+		if !ok {
+			continue
 		}
-		buildStatus = ef.BuildRegistry.ResetBuildStatus(packageID)
-	}
-	err = ef.ChaincodeBuilder.Build(packageID)
-	buildStatus.Notify(err)
-	<-buildStatus.Done()
-	if err := buildStatus.Err(); err != nil {
-		ef.Resources.ChaincodeStore.Delete(packageID)
-		return nil, errors.WithMessage(err, "could not build chaincode")
-	}
 
-	if ef.InstallListener != nil {
-		ef.InstallListener.HandleChaincodeInstalled(pkg.Metadata, packageID)
+		currentSequence, err := ef.Resources.Serializer.DeserializeFieldAsInt64(NamespacesName, name, "Sequence", publicState)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "could not get current sequence for chaincode %s", name)
+		}
+
+		if sequence != currentSequence {
+			continue
+		}
+
+		if _, ok := installed[ccLocalPackage.PackageID]; !ok {
+			missing[ccLocalPackage.PackageID] = struct{}{}
+		}
 	}
 
-	logger.Infof("Successfully installed chaincode with package ID '%s'", packageID)
+	result := make([]string, 0, len(missing))
+	for packageID := range missing {
+		result = append(result, packageID)
+	}
+	sort.Strings(result)
 
-	return &chaincode.InstalledChaincode{
-		PackageID: packageID,
-		Label:     pkg.Metadata.Label,
-	}, nil
+	return result, nil
 }
 
-func (ef *ExternalFunctions) getBuildLock(packageID string) *sync.Mutex {
-	ef.mutex.Lock()
-	defer ef.mutex.Unlock()
+// GroupChaincodesByPackage returns the names of the committed chaincodes,
+// grouped by the hex hash of the package the org approved for the currently
+// committed definition.  The hash is taken from the package ID, which is of
+// the form <label>:<hex hash>.  Chaincodes with no approved package are omitted.
+func (ef *ExternalFunctions) GroupChaincodesByPackage(publicState RangeableState, orgState RangeableState) (map[string][]string, error) {
+	definitions, err := ef.QueryChaincodeDefinitions(publicState)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not query chaincode definitions")
+	}
 
-	if ef.BuildLocks == nil {
-		ef.BuildLocks = map[string]sync.Mutex{}
+	localPackages, err := ef.localPackages(orgState)
+	if err != nil {
+		return nil, err
 	}
 
-	buildLock, ok := ef.BuildLocks[packageID]
-	if !ok {
-		ef.BuildLocks[packageID] = sync.Mutex{}
+	result := map[string][]string{}
+	for name, definition := range definitions {
+		ccLocalPackage, ok := localPackages[PrivateName(name, definition.Sequence)]
+		if !ok || ccLocalPackage.PackageID == "" {
+			continue
+		}
+
+		hash := packageHash(ccLocalPackage.PackageID)
+		result[hash] = append(result[hash], name)
 	}
 
-	return &buildLock
+	for _, names := range result {
+		sort.Strings(names)
+	}
+
+	return result, nil
 }
 
-// GetInstalledChaincodePackage retrieves the installed chaincode with the given package ID
-// from the peer's chaincode store.
-func (ef *ExternalFunctions) GetInstalledChaincodePackage(packageID string) ([]byte, error) {
-	pkgBytes, err := ef.Resources.ChaincodeStore.Load(packageID)
+// QueryUnreferencedInstalledChaincodes returns the sorted package IDs of the
+// installed chaincodes whose package hash is not referenced by this org's
+// approval of any committed chaincode definition in the supplied channels.
+// The public and org states are keyed by channel ID, and an org state must be
+// supplied for every channel.
+func (ef *ExternalFunctions) QueryUnreferencedInstalledChaincodes(channelStates map[string]RangeableState, channelOrgStates map[string]RangeableState) ([]string, error) {
+	referenced := map[string]struct{}{}
+	for channelID, publicState := range channelStates {
+		orgState, ok := channelOrgStates[channelID]
+		if !ok {
+			return nil, errors.Errorf("no org state supplied for channel '%s'", channelID)
+		}
+
+		definitions, err := ef.QueryChaincodeDefinitions(publicState)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "could not query chaincode definitions for channel '%s'", channelID)
+		}
+
+		localPackages, err := ef.localPackages(orgState)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "could not query local packages for channel '%s'", channelID)
+		}
+
+		for name, definition := range definitions {
+			ccLocalPackage, ok := localPackages[PrivateName(name, definition.Sequence)]
+			if !ok || ccLocalPackage.PackageID == "" {
+				continue
+			}
+
+			referenced[packageHash(ccLocalPackage.PackageID)] = struct{}{}
+		}
+	}
+
+	installedChaincodes, err := ef.Resources.ChaincodeStore.ListInstalledChaincodes()
 	if err != nil {
-		return nil, errors.WithMessage(err, "could not load cc install package")
+		return nil, errors.WithMessage(err, "could not list installed chaincodes")
 	}
 
-	return pkgBytes, nil
+	result := []string{}
+	for _, installedChaincode := range installedChaincodes {
+		if _, ok := referenced[packageHash(installedChaincode.PackageID)]; !ok {
+			result = append(result, installedChaincode.PackageID)
+		}
+	}
+	sort.Strings(result)
+
+	return result, nil
 }
 
-// QueryNamespaceDefinitions lists the publicly defined namespaces in a channel.  Today it should only ever
-// find Datatype encodings of 'ChaincodeDefinition'.
-func (ef *ExternalFunctions) QueryNamespaceDefinitions(publicState RangeableState) (map[string]string, error) {
-	metadatas, err := ef.Resources.Serializer.DeserializeAllMetadata(NamespacesName, publicState)
+// packageHash returns the hash portion of a package ID.
+func packageHash(packageID string) string {
+	return packageID[strings.LastIndex(packageID, ":")+1:]
+}
+
+// localPackages returns the chaincode local packages recorded in the org's
+// state, keyed by the private name (<name>#<sequence>) of the approval.
+func (ef *ExternalFunctions) localPackages(orgState RangeableState) (map[string]*ChaincodeLocalPackage, error) {
+	metadatas, err := ef.Resources.Serializer.DeserializeAllMetadata(ChaincodeSourcesName, orgState)
 	if err != nil {
-		return nil, errors.WithMessage(err, "could not query namespace metadata")
+		return nil, errors.WithMessage(err, "could not query chaincode-source metadata")
 	}
 
-	result := map[string]string{}
-	for key, value := range metadatas {
-		switch value.Datatype {
-		case ChaincodeDefinitionType:
-			result[key] = FriendlyChaincodeDefinitionType
-		default:
-			// This should never execute, but seems preferable to returning an error
-			result[key] = value.Datatype
+	fields, err := orgState.GetStateRange(fmt.Sprintf("%s/%s/", ChaincodeSourcesName, FieldsInfix))
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not query chaincode-source fields")
+	}
+	fieldState := rangeResultState(fields)
+
+	result := map[string]*ChaincodeLocalPackage{}
+	for privateName, metadata := range metadatas {
+		if metadata.Datatype != ChaincodeLocalPackageType {
+			continue
+		}
+
+		ccLocalPackage := &ChaincodeLocalPackage{}
+		if err := ef.Resources.Serializer.Deserialize(ChaincodeSourcesName, privateName, metadata, ccLocalPackage, fieldState); err != nil {
+			return nil, errors.WithMessagef(err, "could not deserialize chaincode package for %s", privateName)
 		}
+
+		result[privateName] = ccLocalPackage
 	}
+
 	return result, nil
 }
 
-// QueryInstalledChaincode returns metadata for the chaincode with the supplied package ID.
-func (ef *ExternalFunctions) QueryInstalledChaincode(packageID string) (*chaincode.InstalledChaincode, error) {
-	return ef.InstalledChaincodesLister.GetInstalledChaincode(packageID)
+// PrivateName returns the name of the form <name>#<sequence> under which an
+// org's approval of the chaincode definition at the given sequence is stored.
+func PrivateName(name string, sequence int64) string {
+	return fmt.Sprintf("%s#%d", name, sequence)
 }
 
-// QueryInstalledChaincodes returns a list of installed chaincodes
-func (ef *ExternalFunctions) QueryInstalledChaincodes() []*chaincode.InstalledChaincode {
-	return ef.InstalledChaincodesLister.ListInstalledChaincodes()
+// ParsePrivateName splits a private name of the form <name>#<sequence> into
+// its chaincode name and sequence.  If the private name is not of this form,
+// ok is false.
+func ParsePrivateName(privateName string) (name string, sequence int64, ok bool) {
+	i := strings.LastIndex(privateName, "#")
+	if i < 0 {
+		return "", 0, false
+	}
+
+	sequence, err := strconv.ParseInt(privateName[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return privateName[:i], sequence, true
+}
+
+// memoryState is a ReadWritableState backed by a map, used to capture
+// the writes made by the serializer.
+type memoryState map[string][]byte
+
+func (m memoryState) GetState(key string) ([]byte, error) {
+	return m[key], nil
+}
+
+func (m memoryState) PutState(key string, value []byte) error {
+	m[key] = value
+	return nil
+}
+
+func (m memoryState) DelState(key string) error {
+	delete(m, key)
+	return nil
+}
+
+// rangeResultState allows the results of a range query to be read back
+// as a ReadableState.
+type rangeResultState map[string][]byte
+
+func (r rangeResultState) GetState(key string) ([]byte, error) {
+	return r[key], nil
+}
+
+// hashedState allows a ReadableState to be read as an OpaqueState, by
+// hashing the values read from it, so that the serialized form of a
+// structure may be checked against it as against the hashed org state
+// during a commit.
+type hashedState struct {
+	ReadableState
+}
+
+func (h hashedState) GetStateHash(key string) ([]byte, error) {
+	value, err := h.GetState(key)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	return util.ComputeSHA256(value), nil
+}
+
+func (h hashedState) CollectionName() string {
+	return ""
 }
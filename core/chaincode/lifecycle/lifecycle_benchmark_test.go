@@ -0,0 +1,101 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lifecycle_test
+
+import (
+	"testing"
+
+	lb "github.com/hyperledger/fabric-protos-go/peer/lifecycle"
+	"github.com/hyperledger/fabric/core/chaincode/lifecycle"
+	"github.com/hyperledger/fabric/core/chaincode/lifecycle/mock"
+)
+
+func benchmarkCheckCommitReadiness(b *testing.B, cachePolicies bool) {
+	fakeChannelConfigSource := &mock.ChannelConfigSource{}
+	fakeChannelConfig := &mock.ChannelConfig{}
+	fakeChannelConfigSource.GetStableChannelConfigReturns(fakeChannelConfig)
+	fakePolicyManager := &mock.PolicyManager{}
+	fakePolicyManager.GetPolicyReturns(nil, true)
+	fakeChannelConfig.PolicyManagerReturns(fakePolicyManager)
+
+	ef := &lifecycle.ExternalFunctions{
+		Resources: &lifecycle.Resources{
+			ChannelConfigSource: fakeChannelConfigSource,
+			Serializer:          &lifecycle.Serializer{},
+			CachePolicies:       cachePolicies,
+		},
+	}
+
+	publicState := MapLedgerShim(map[string][]byte{})
+	orgState := &mock.ReadWritableState{}
+	orgState.CollectionNameReturns("_implicit_org_org0")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cd := &lifecycle.ChaincodeDefinition{
+			Sequence:        1,
+			EndorsementInfo: &lb.ChaincodeEndorsementInfo{},
+			ValidationInfo:  &lb.ChaincodeValidationInfo{},
+		}
+		if _, err := ef.CheckCommitReadiness("channel-id", "cc-name", cd, publicState, []lifecycle.OpaqueState{orgState}); err != nil {
+			b.Fatalf("check commit readiness failed: %s", err)
+		}
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(fakeChannelConfigSource.GetStableChannelConfigCallCount())/float64(b.N), "config-fetches/op")
+}
+
+func BenchmarkCheckCommitReadinessUncachedPolicies(b *testing.B) {
+	benchmarkCheckCommitReadiness(b, false)
+}
+
+func BenchmarkCheckCommitReadinessCachedPolicies(b *testing.B) {
+	benchmarkCheckCommitReadiness(b, true)
+}
+
+func benchmarkChaincodeDefinitionIfDefined(b *testing.B, cacheDefinitions bool) {
+	resources := &lifecycle.Resources{
+		Serializer:       &lifecycle.Serializer{},
+		CacheDefinitions: cacheDefinitions,
+	}
+
+	publicKVS := MapLedgerShim(map[string][]byte{})
+	err := resources.Serializer.Serialize(lifecycle.NamespacesName, "cc-name", &lifecycle.ChaincodeDefinition{
+		Sequence: 1,
+		EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+			Version: "version",
+		},
+		ValidationInfo: &lb.ChaincodeValidationInfo{
+			ValidationPlugin:    "vscc",
+			ValidationParameter: []byte("validation-parameter"),
+		},
+	}, publicKVS)
+	if err != nil {
+		b.Fatalf("serialize failed: %s", err)
+	}
+	publicState := &mock.ReadWritableState{}
+	publicState.GetStateStub = publicKVS.GetState
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := resources.ChaincodeDefinitionIfDefinedInChannel("channel-id", "cc-name", publicState); err != nil {
+			b.Fatalf("chaincode definition if defined failed: %s", err)
+		}
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(publicState.GetStateCallCount())/float64(b.N), "state-reads/op")
+}
+
+func BenchmarkChaincodeDefinitionIfDefinedUncached(b *testing.B) {
+	benchmarkChaincodeDefinitionIfDefined(b, false)
+}
+
+func BenchmarkChaincodeDefinitionIfDefinedCached(b *testing.B) {
+	benchmarkChaincodeDefinitionIfDefined(b, true)
+}
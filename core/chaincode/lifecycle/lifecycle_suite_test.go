@@ -10,13 +10,17 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/hyperledger/fabric/common/channelconfig"
 	commonledger "github.com/hyperledger/fabric/common/ledger"
 	"github.com/hyperledger/fabric/common/policies"
+	"github.com/hyperledger/fabric/common/policydsl"
 	"github.com/hyperledger/fabric/common/util"
 	"github.com/hyperledger/fabric/core/aclmgmt"
 	"github.com/hyperledger/fabric/core/chaincode/lifecycle"
+	"github.com/hyperledger/fabric/core/chaincode/lifecycle/mock"
 	validation "github.com/hyperledger/fabric/core/handlers/validation/api/state"
 	"github.com/hyperledger/fabric/core/ledger"
 	"github.com/hyperledger/fabric/msp"
@@ -75,6 +79,11 @@ type readWritableState interface {
 	lifecycle.RangeableState
 }
 
+//go:generate counterfeiter -o mock/opaque_state.go --fake-name OpaqueState . opaqueState
+type opaqueState interface {
+	lifecycle.OpaqueState
+}
+
 //go:generate counterfeiter -o mock/query_executor.go --fake-name SimpleQueryExecutor . simpleQueryExecutor
 type simpleQueryExecutor interface {
 	ledger.SimpleQueryExecutor
@@ -175,6 +184,15 @@ func (m MapLedgerShim) GetStateHash(key string) (value []byte, err error) {
 	return nil, nil
 }
 
+func (m MapLedgerShim) GetStateRangeWithPagination(prefix, bookmark string, pageSize int32) (map[string][]byte, string, error) {
+	result, err := m.GetStateRange(prefix)
+	if err != nil {
+		return nil, "", err
+	}
+	page, nextBookmark := lifecycle.StateRangePage(result, bookmark, pageSize)
+	return page, nextBookmark, nil
+}
+
 func (m MapLedgerShim) GetStateRange(prefix string) (map[string][]byte, error) {
 	result := map[string][]byte{}
 	for key, value := range m {
@@ -185,3 +203,41 @@ func (m MapLedgerShim) GetStateRange(prefix string) (map[string][]byte, error) {
 	}
 	return result, nil
 }
+
+// collectionsNamed returns a collection config package containing a static
+// collection config for each name, in the order given.  Each collection has
+// org0 as its only member.
+func collectionsNamed(names ...string) *pb.CollectionConfigPackage {
+	collections := &pb.CollectionConfigPackage{}
+	for _, name := range names {
+		collections.Config = append(collections.Config, &pb.CollectionConfig{
+			Payload: &pb.CollectionConfig_StaticCollectionConfig{
+				StaticCollectionConfig: &pb.StaticCollectionConfig{
+					Name: name,
+					MemberOrgsPolicy: &pb.CollectionPolicyConfig{
+						Payload: &pb.CollectionPolicyConfig_SignaturePolicy{
+							SignaturePolicy: policydsl.SignedByMspMember("org0"),
+						},
+					},
+				},
+			},
+		})
+	}
+	return collections
+}
+
+// TxOrgState is a ReadWritableState scoped to a transaction, as the org
+// state supplied by the SCC is.
+type TxOrgState struct {
+	*mock.ReadWritableState
+	TxID        string
+	TxTimestamp *timestamp.Timestamp
+}
+
+func (t *TxOrgState) GetTxID() string {
+	return t.TxID
+}
+
+func (t *TxOrgState) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return t.TxTimestamp, nil
+}
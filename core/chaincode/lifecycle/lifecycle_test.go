@@ -7,18 +7,35 @@ SPDX-License-Identifier: Apache-2.0
 package lifecycle_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
 	cb "github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/ledger/rwset/kvrwset"
+	mb "github.com/hyperledger/fabric-protos-go/msp"
 	pb "github.com/hyperledger/fabric-protos-go/peer"
 	lb "github.com/hyperledger/fabric-protos-go/peer/lifecycle"
 	"github.com/hyperledger/fabric/common/chaincode"
 	"github.com/hyperledger/fabric/common/channelconfig"
+	"github.com/hyperledger/fabric/common/metrics/metricsfakes"
+	"github.com/hyperledger/fabric/common/policies"
+	"github.com/hyperledger/fabric/common/policydsl"
+	"github.com/hyperledger/fabric/common/util"
 	"github.com/hyperledger/fabric/core/chaincode/lifecycle"
 	"github.com/hyperledger/fabric/core/chaincode/lifecycle/mock"
 	"github.com/hyperledger/fabric/core/chaincode/persistence"
 	"github.com/hyperledger/fabric/core/container"
+	"github.com/hyperledger/fabric/msp"
 	"github.com/hyperledger/fabric/protoutil"
 	"github.com/pkg/errors"
 
@@ -107,1016 +124,6950 @@ var _ = Describe("ChaincodeParameters", func() {
 				Expect(lhs.Equal(rhs)).To(MatchError("Collections do not match"))
 			})
 		})
-	})
-})
-
-var _ = Describe("Resources", func() {
-	var (
-		resources               *lifecycle.Resources
-		fakeChannelConfigSource *mock.ChannelConfigSource
-		fakeChannelConfig       *mock.ChannelConfig
-		fakeApplicationConfig   *mock.ApplicationConfig
-		fakeOrgConfigs          []*mock.ApplicationOrgConfig
-		fakePolicyManager       *mock.PolicyManager
-	)
-
-	BeforeEach(func() {
-		fakeChannelConfigSource = &mock.ChannelConfigSource{}
-		fakeChannelConfig = &mock.ChannelConfig{}
-		fakeChannelConfigSource.GetStableChannelConfigReturns(fakeChannelConfig)
-		fakeApplicationConfig = &mock.ApplicationConfig{}
-		fakeChannelConfig.ApplicationConfigReturns(fakeApplicationConfig, true)
-		fakeOrgConfigs = []*mock.ApplicationOrgConfig{{}, {}}
-		fakeOrgConfigs[0].MSPIDReturns("first-mspid")
-		fakeOrgConfigs[1].MSPIDReturns("second-mspid")
-		fakePolicyManager = &mock.PolicyManager{}
-		fakePolicyManager.GetPolicyReturns(nil, true)
-		fakeChannelConfig.PolicyManagerReturns(fakePolicyManager)
-
-		fakeApplicationConfig.OrganizationsReturns(map[string]channelconfig.ApplicationOrg{
-			"org0": fakeOrgConfigs[0],
-			"org1": fakeOrgConfigs[1],
-		})
-
-		resources = &lifecycle.Resources{
-			ChannelConfigSource: fakeChannelConfigSource,
-			Serializer:          &lifecycle.Serializer{},
-		}
-	})
 
-	Describe("ChaincodeDefinitionIfDefined", func() {
-		var (
-			fakePublicState   MapLedgerShim
-			fakeReadableState *mock.ReadWritableState
-		)
+		Context("when one side has nil Collections and the other empty Collections", func() {
+			BeforeEach(func() {
+				rhs.Collections = nil
+			})
 
-		BeforeEach(func() {
-			fakePublicState = map[string][]byte{}
-			err := resources.Serializer.Serialize(lifecycle.NamespacesName, "cc-name", &lifecycle.ChaincodeDefinition{
-				Sequence: 5,
-				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
-					Version:           "version",
-					EndorsementPlugin: "my endorsement plugin",
-				},
-				ValidationInfo: &lb.ChaincodeValidationInfo{
-					ValidationPlugin:    "my validation plugin",
-					ValidationParameter: []byte("some awesome policy"),
-				},
-				Collections: &pb.CollectionConfigPackage{},
-			}, fakePublicState)
-			Expect(err).NotTo(HaveOccurred())
-			fakeReadableState = &mock.ReadWritableState{}
-			fakeReadableState.GetStateStub = fakePublicState.GetState
+			It("returns nil", func() {
+				Expect(lhs.Equal(rhs)).NotTo(HaveOccurred())
+				Expect(rhs.Equal(lhs)).NotTo(HaveOccurred())
+			})
 		})
 
-		It("returns that the chaincode is defined and that the chaincode definition can be converted to a string suitable for log messages", func() {
-			exists, definition, err := resources.ChaincodeDefinitionIfDefined("cc-name", fakeReadableState)
-			Expect(err).NotTo(HaveOccurred())
-			Expect(exists).To(BeTrue())
-			Expect(definition.EndorsementInfo.Version).To(Equal("version"))
-			Expect(fmt.Sprintf("{%s}", definition)).To(Equal("{sequence: 5, endorsement info: (version: 'version', plugin: 'my endorsement plugin', init required: false), validation info: (plugin: 'my validation plugin', policy: '736f6d6520617765736f6d6520706f6c696379'), collections: ()}"))
-		})
+		Context("when the Collections are listed in a different order", func() {
+			BeforeEach(func() {
+				lhs.Collections = collectionsNamed("coll-a", "coll-b", "coll-c")
+				rhs.Collections = collectionsNamed("coll-c", "coll-a", "coll-b")
+			})
 
-		Context("when the requested chaincode is _lifecycle", func() {
-			It("it returns true", func() {
-				exists, definition, err := resources.ChaincodeDefinitionIfDefined("_lifecycle", fakeReadableState)
-				Expect(err).NotTo(HaveOccurred())
-				Expect(exists).To(BeTrue())
-				Expect(definition).NotTo(BeNil())
-				Expect(fakeReadableState.GetStateCallCount()).To(Equal(0))
+			It("returns an error", func() {
+				Expect(lhs.Equal(rhs)).To(MatchError("Collections do not match"))
 			})
 		})
 
-		Context("when the metadata is not for a chaincode", func() {
+		Context("when the passed EndorsementInfo is nil", func() {
 			BeforeEach(func() {
-				type badStruct struct{}
-				err := resources.Serializer.Serialize(lifecycle.NamespacesName,
-					"cc-name",
-					&badStruct{},
-					fakePublicState,
-				)
-				Expect(err).NotTo(HaveOccurred())
+				rhs.EndorsementInfo = nil
 			})
 
 			It("returns an error", func() {
-				_, _, err := resources.ChaincodeDefinitionIfDefined("cc-name", fakeReadableState)
-				Expect(err).To(MatchError("not a chaincode type: badStruct"))
+				Expect(lhs.Equal(rhs)).To(MatchError("passed EndorsementInfo is nil"))
 			})
 		})
 
-		Context("when the ledger returns an error", func() {
+		Context("when the expected ValidationInfo is nil", func() {
 			BeforeEach(func() {
-				fakeReadableState.GetStateReturns(nil, fmt.Errorf("state-error"))
+				lhs.ValidationInfo = nil
 			})
 
-			It("wraps and returns the error", func() {
-				_, _, err := resources.ChaincodeDefinitionIfDefined("cc-name", fakeReadableState)
-				Expect(err).To(MatchError("could not deserialize metadata for chaincode cc-name: could not query metadata for namespace namespaces/cc-name: state-error"))
+			It("returns an error", func() {
+				Expect(lhs.Equal(rhs)).To(MatchError("expected ValidationInfo is nil"))
 			})
 		})
 	})
 
-	Describe("LifecycleEndorsementPolicyAsBytes", func() {
-		It("returns the endorsement policy for the lifecycle chaincode", func() {
-			b, err := resources.LifecycleEndorsementPolicyAsBytes("channel-id")
-			Expect(err).NotTo(HaveOccurred())
-			Expect(b).NotTo(BeNil())
-			policy := &cb.ApplicationPolicy{}
-			err = proto.Unmarshal(b, policy)
-			Expect(err).NotTo(HaveOccurred())
-			Expect(policy.GetChannelConfigPolicyReference()).To(Equal("/Channel/Application/LifecycleEndorsement"))
+	Describe("Diff", func() {
+		It("returns no differences when the parameters match", func() {
+			Expect(lhs.Diff(rhs)).To(BeEmpty())
 		})
 
-		Context("when the endorsement policy reference is not found", func() {
+		Context("when a single parameter differs", func() {
 			BeforeEach(func() {
-				fakePolicyManager.GetPolicyReturns(nil, false)
+				lhs.EndorsementInfo.Version = "1.3"
+				rhs.EndorsementInfo.Version = "1.4"
 			})
 
-			It("returns an error", func() {
-				b, err := resources.LifecycleEndorsementPolicyAsBytes("channel-id")
-				Expect(err).NotTo(HaveOccurred())
-				policy := &cb.ApplicationPolicy{}
-				err = proto.Unmarshal(b, policy)
-				Expect(err).NotTo(HaveOccurred())
-				Expect(policy.GetSignaturePolicy()).NotTo(BeNil())
+			It("describes the change", func() {
+				Expect(lhs.Diff(rhs)).To(Equal([]string{"Version '1.3' -> '1.4'"}))
 			})
+		})
 
-			Context("when the application config cannot be retrieved", func() {
-				BeforeEach(func() {
-					fakeChannelConfig.ApplicationConfigReturns(nil, false)
-				})
+		Context("when only the order of the collections differs", func() {
+			BeforeEach(func() {
+				lhs.Collections = collectionsNamed("coll-a", "coll-b")
+				rhs.Collections = collectionsNamed("coll-b", "coll-a")
+			})
 
-				It("returns an error", func() {
-					_, err := resources.LifecycleEndorsementPolicyAsBytes("channel-id")
-					Expect(err).To(MatchError("could not get application config for channel 'channel-id'"))
-				})
+			It("reports the collections as changed", func() {
+				Expect(lhs.Diff(rhs)).To(Equal([]string{"Collections changed"}))
 			})
 		})
 
-		Context("when the channel config cannot be retrieved", func() {
+		Context("when every parameter differs", func() {
 			BeforeEach(func() {
-				fakeChannelConfigSource.GetStableChannelConfigReturns(nil)
+				rhs.EndorsementInfo = &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "endorsement-plugin",
+					InitRequired:      true,
+				}
+				rhs.ValidationInfo = &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: []byte("different"),
+				}
+				rhs.Collections = &pb.CollectionConfigPackage{
+					Config: []*pb.CollectionConfig{
+						{
+							Payload: &pb.CollectionConfig_StaticCollectionConfig{
+								StaticCollectionConfig: &pb.StaticCollectionConfig{Name: "foo"},
+							},
+						},
+					},
+				}
 			})
 
-			It("returns an error", func() {
-				_, err := resources.LifecycleEndorsementPolicyAsBytes("channel-id")
-				Expect(err).To(MatchError("could not get channel config for channel 'channel-id'"))
+			It("describes every change", func() {
+				Expect(lhs.Diff(rhs)).To(Equal([]string{
+					"Version '' -> 'version'",
+					"EndorsementPlugin '' -> 'endorsement-plugin'",
+					"InitRequired false -> true",
+					"ValidationPlugin '' -> 'validation-plugin'",
+					"ValidationParameter '' -> '646966666572656e74'",
+					"Collections changed",
+				}))
 			})
 		})
 	})
-})
 
-var _ = Describe("ExternalFunctions", func() {
-	var (
-		resources               *lifecycle.Resources
-		ef                      *lifecycle.ExternalFunctions
-		fakeCCStore             *mock.ChaincodeStore
-		fakeChaincodeBuilder    *mock.ChaincodeBuilder
-		fakeParser              *mock.PackageParser
-		fakeListener            *mock.InstallListener
-		fakeLister              *mock.InstalledChaincodesLister
-		fakeChannelConfigSource *mock.ChannelConfigSource
-		fakeChannelConfig       *mock.ChannelConfig
-		fakeApplicationConfig   *mock.ApplicationConfig
-		fakeOrgConfigs          []*mock.ApplicationOrgConfig
-		fakePolicyManager       *mock.PolicyManager
-	)
+	Describe("SerializeBytes", func() {
+		BeforeEach(func() {
+			lhs.EndorsementInfo.Version = "version"
+			lhs.ValidationInfo.ValidationParameter = []byte("validation-parameter")
+			lhs.Collections.Config = []*pb.CollectionConfig{
+				{
+					Payload: &pb.CollectionConfig_StaticCollectionConfig{
+						StaticCollectionConfig: &pb.StaticCollectionConfig{Name: "foo"},
+					},
+				},
+			}
+		})
 
-	BeforeEach(func() {
-		fakeCCStore = &mock.ChaincodeStore{}
-		fakeChaincodeBuilder = &mock.ChaincodeBuilder{}
-		fakeParser = &mock.PackageParser{}
-		fakeListener = &mock.InstallListener{}
-		fakeLister = &mock.InstalledChaincodesLister{}
-		fakeChannelConfigSource = &mock.ChannelConfigSource{}
-		fakeChannelConfig = &mock.ChannelConfig{}
-		fakeChannelConfigSource.GetStableChannelConfigReturns(fakeChannelConfig)
-		fakeApplicationConfig = &mock.ApplicationConfig{}
-		fakeChannelConfig.ApplicationConfigReturns(fakeApplicationConfig, true)
-		fakeOrgConfigs = []*mock.ApplicationOrgConfig{{}, {}}
-		fakeOrgConfigs[0].MSPIDReturns("first-mspid")
-		fakeOrgConfigs[1].MSPIDReturns("second-mspid")
-		fakePolicyManager = &mock.PolicyManager{}
-		fakePolicyManager.GetPolicyReturns(nil, true)
-		fakeChannelConfig.PolicyManagerReturns(fakePolicyManager)
+		It("matches the values stored for an approval", func() {
+			approvalKVS := MapLedgerShim(map[string][]byte{})
+			err := (&lifecycle.Serializer{}).Serialize("namespaces", "cc-name#5", lhs, approvalKVS)
+			Expect(err).NotTo(HaveOccurred())
 
-		fakeApplicationConfig.OrganizationsReturns(map[string]channelconfig.ApplicationOrg{
-			"org0": fakeOrgConfigs[0],
-			"org1": fakeOrgConfigs[1],
+			serialized, err := lhs.SerializeBytes()
+			Expect(err).NotTo(HaveOccurred())
+
+			kvrwSet := &kvrwset.KVRWSet{}
+			err = proto.Unmarshal(serialized, kvrwSet)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(kvrwSet.Writes).To(HaveLen(len(approvalKVS)))
+			Expect(kvrwSet.Writes[0].Key).To(Equal("metadata"))
+			Expect(kvrwSet.Writes[0].Value).To(Equal(approvalKVS["namespaces/metadata/cc-name#5"]))
+			for _, write := range kvrwSet.Writes[1:] {
+				Expect(write.Value).To(Equal(approvalKVS["namespaces/fields/cc-name#5/"+write.Key]))
+			}
 		})
 
-		resources = &lifecycle.Resources{
-			PackageParser:       fakeParser,
-			ChaincodeStore:      fakeCCStore,
-			Serializer:          &lifecycle.Serializer{},
-			ChannelConfigSource: fakeChannelConfigSource,
-		}
+		It("is deterministic", func() {
+			first, err := lhs.SerializeBytes()
+			Expect(err).NotTo(HaveOccurred())
+			second, err := lhs.SerializeBytes()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(first).To(Equal(second))
+		})
 
-		ef = &lifecycle.ExternalFunctions{
-			Resources:                 resources,
-			InstallListener:           fakeListener,
-			InstalledChaincodesLister: fakeLister,
-			ChaincodeBuilder:          fakeChaincodeBuilder,
-			BuildRegistry:             &container.BuildRegistry{},
-		}
+		Context("when the parameters differ", func() {
+			It("produces different bytes", func() {
+				lhsBytes, err := lhs.SerializeBytes()
+				Expect(err).NotTo(HaveOccurred())
+				rhsBytes, err := rhs.SerializeBytes()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lhsBytes).NotTo(Equal(rhsBytes))
+			})
+		})
 	})
 
-	Describe("InstallChaincode", func() {
+	Describe("Hash", func() {
 		BeforeEach(func() {
-			fakeParser.ParseReturns(&persistence.ChaincodePackage{
-				Metadata: &persistence.ChaincodePackageMetadata{
-					Type:  "cc-type",
-					Path:  "cc-path",
-					Label: "cc-label",
+			lhs.Collections = &pb.CollectionConfigPackage{
+				Config: []*pb.CollectionConfig{
+					{
+						Payload: &pb.CollectionConfig_StaticCollectionConfig{
+							StaticCollectionConfig: &pb.StaticCollectionConfig{
+								Name:              "collection-name",
+								RequiredPeerCount: 1,
+								MaximumPeerCount:  3,
+							},
+						},
+					},
 				},
-			}, nil)
-			fakeCCStore.SaveReturns("fake-hash", nil)
-		})
+			}
 
-		It("saves the chaincode", func() {
-			cc, err := ef.InstallChaincode([]byte("cc-package"))
+			// decode the same collection from an encoding with its fields
+			// in reverse order, as concatenated messages are merged
+			var reordered []byte
+			for _, field := range []*pb.StaticCollectionConfig{
+				{MaximumPeerCount: 3},
+				{RequiredPeerCount: 1},
+				{Name: "collection-name"},
+			} {
+				reordered = append(reordered, protoutil.MarshalOrPanic(field)...)
+			}
+			staticCollectionConfig := &pb.StaticCollectionConfig{}
+			err := proto.Unmarshal(reordered, staticCollectionConfig)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(cc).To(Equal(&chaincode.InstalledChaincode{
-				PackageID: "fake-hash",
-				Label:     "cc-label",
-			}))
-
-			Expect(fakeParser.ParseCallCount()).To(Equal(1))
-			Expect(fakeParser.ParseArgsForCall(0)).To(Equal([]byte("cc-package")))
-
-			Expect(fakeCCStore.SaveCallCount()).To(Equal(1))
-			name, msg := fakeCCStore.SaveArgsForCall(0)
-			Expect(name).To(Equal("cc-label"))
-			Expect(msg).To(Equal([]byte("cc-package")))
 
-			Expect(fakeListener.HandleChaincodeInstalledCallCount()).To(Equal(1))
-			md, packageID := fakeListener.HandleChaincodeInstalledArgsForCall(0)
-			Expect(md).To(Equal(&persistence.ChaincodePackageMetadata{
-				Type:  "cc-type",
-				Path:  "cc-path",
-				Label: "cc-label",
-			}))
-			Expect(packageID).To(Equal("fake-hash"))
+			rhs.Collections = &pb.CollectionConfigPackage{
+				Config: []*pb.CollectionConfig{
+					{
+						Payload: &pb.CollectionConfig_StaticCollectionConfig{
+							StaticCollectionConfig: staticCollectionConfig,
+						},
+					},
+				},
+			}
 		})
 
-		It("builds the chaincode", func() {
-			_, err := ef.InstallChaincode([]byte("cc-package"))
-			Expect(err).NotTo(HaveOccurred())
+		It("produces identical hashes for equal parameters", func() {
+			Expect(lhs.Equal(rhs)).To(Succeed())
 
-			Expect(fakeChaincodeBuilder.BuildCallCount()).To(Equal(1))
-			ccid := fakeChaincodeBuilder.BuildArgsForCall(0)
-			Expect(ccid).To(Equal("fake-hash"))
+			lhsHash, err := lhs.Hash()
+			Expect(err).NotTo(HaveOccurred())
+			rhsHash, err := rhs.Hash()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lhsHash).To(Equal(rhsHash))
+			Expect(lhsHash).To(HaveLen(32))
 		})
 
-		When("building the chaincode fails", func() {
+		Context("when the parameters differ", func() {
 			BeforeEach(func() {
-				fakeChaincodeBuilder.BuildReturns(fmt.Errorf("fake-build-error"))
-			})
-
-			It("returns the wrapped error to the caller", func() {
-				_, err := ef.InstallChaincode([]byte("cc-package"))
-				Expect(err).To(MatchError("could not build chaincode: fake-build-error"))
+				rhs.EndorsementInfo.Version = "different"
 			})
 
-			It("deletes the chaincode from disk", func() {
-				ef.InstallChaincode([]byte("cc-package"))
-				Expect(fakeCCStore.DeleteCallCount()).To(Equal(1))
-				Expect(fakeCCStore.DeleteArgsForCall(0)).To(Equal("fake-hash"))
+			It("produces different hashes", func() {
+				lhsHash, err := lhs.Hash()
+				Expect(err).NotTo(HaveOccurred())
+				rhsHash, err := rhs.Hash()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lhsHash).NotTo(Equal(rhsHash))
 			})
 		})
 
-		When("the chaincode is already being built and succeeds", func() {
+		Context("when the collections are listed in a different order", func() {
 			BeforeEach(func() {
-				bs, ok := ef.BuildRegistry.BuildStatus("fake-hash")
-				Expect(ok).To(BeFalse())
-				bs.Notify(nil)
-			})
-
-			It("does not attempt to rebuild it itself", func() {
-				_, err := ef.InstallChaincode([]byte("cc-package"))
-				Expect(err).To(MatchError("chaincode already successfully installed"))
-				Expect(fakeChaincodeBuilder.BuildCallCount()).To(Equal(0))
+				lhs.Collections = collectionsNamed("collection-a", "collection-b", "collection-c")
+				rhs.Collections = collectionsNamed("collection-c", "collection-a", "collection-b")
 			})
-		})
 
-		When("the chaincode is already being built and fails", func() {
-			BeforeEach(func() {
-				bs, ok := ef.BuildRegistry.BuildStatus("fake-hash")
-				Expect(ok).To(BeFalse())
-				bs.Notify(fmt.Errorf("fake-other-builder-error"))
+			It("produces identical hashes", func() {
+				lhsHash, err := lhs.Hash()
+				Expect(err).NotTo(HaveOccurred())
+				rhsHash, err := rhs.Hash()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lhsHash).To(Equal(rhsHash))
 			})
 
-			It("attempts to rebuild it itself", func() {
-				_, err := ef.InstallChaincode([]byte("cc-package"))
-				Expect(err).To(BeNil())
-				Expect(fakeChaincodeBuilder.BuildCallCount()).To(Equal(1))
+			It("does not reorder the collections of the parameters", func() {
+				_, err := rhs.Hash()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(proto.Equal(rhs.Collections, collectionsNamed("collection-c", "collection-a", "collection-b"))).To(BeTrue())
 			})
 		})
+	})
+})
 
-		Context("when the package does not have metadata", func() {
-			BeforeEach(func() {
-				fakeParser.ParseReturns(&persistence.ChaincodePackage{}, nil)
-			})
+var _ = Describe("PrivateName", func() {
+	It("joins the name and sequence", func() {
+		Expect(lifecycle.PrivateName("cc-name", 4)).To(Equal("cc-name#4"))
+	})
 
-			It("wraps and returns the error", func() {
-				hash, err := ef.InstallChaincode([]byte("fake-package"))
-				Expect(hash).To(BeNil())
-				Expect(err.Error()).To(ContainSubstring("empty metadata for supplied chaincode"))
-			})
-		})
+	It("round trips through ParsePrivateName", func() {
+		name, sequence, ok := lifecycle.ParsePrivateName(lifecycle.PrivateName("cc-name", 4))
+		Expect(ok).To(BeTrue())
+		Expect(name).To(Equal("cc-name"))
+		Expect(sequence).To(Equal(int64(4)))
+	})
 
-		Context("when saving the chaincode fails", func() {
-			BeforeEach(func() {
-				fakeCCStore.SaveReturns("", fmt.Errorf("fake-error"))
-			})
+	It("rejects names which are not private names", func() {
+		_, _, ok := lifecycle.ParsePrivateName("cc-name")
+		Expect(ok).To(BeFalse())
+		_, _, ok = lifecycle.ParsePrivateName("cc-name#four")
+		Expect(ok).To(BeFalse())
+	})
+})
 
-			It("wraps and returns the error", func() {
-				cc, err := ef.InstallChaincode([]byte("cc-package"))
-				Expect(cc).To(BeNil())
-				Expect(err).To(MatchError("could not save cc install package: fake-error"))
-			})
+var _ = Describe("ChaincodeDefinition", func() {
+	Describe("NewChaincodeDefinition", func() {
+		It("returns a definition with the supplied fields", func() {
+			cd, err := lifecycle.NewChaincodeDefinition(
+				3,
+				&lb.ChaincodeEndorsementInfo{Version: "version"},
+				&lb.ChaincodeValidationInfo{ValidationPlugin: "validation-plugin"},
+				nil,
+			)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cd.Sequence).To(Equal(int64(3)))
+			Expect(cd.EndorsementInfo.Version).To(Equal("version"))
+			Expect(cd.ValidationInfo.ValidationPlugin).To(Equal("validation-plugin"))
+			Expect(proto.Equal(cd.Collections, &pb.CollectionConfigPackage{})).To(BeTrue())
 		})
 
-		Context("when parsing the chaincode package fails", func() {
-			BeforeEach(func() {
-				fakeParser.ParseReturns(nil, fmt.Errorf("parse-error"))
-			})
+		It("rejects a nil endorsement info", func() {
+			_, err := lifecycle.NewChaincodeDefinition(1, nil, &lb.ChaincodeValidationInfo{}, &pb.CollectionConfigPackage{})
+			Expect(err).To(MatchError("chaincode definition must specify endorsement info"))
+		})
 
-			It("wraps and returns the error", func() {
-				hash, err := ef.InstallChaincode([]byte("fake-package"))
-				Expect(hash).To(BeNil())
-				Expect(err).To(MatchError("could not parse as a chaincode install package: parse-error"))
-			})
+		It("rejects a nil validation info", func() {
+			_, err := lifecycle.NewChaincodeDefinition(1, &lb.ChaincodeEndorsementInfo{}, nil, &pb.CollectionConfigPackage{})
+			Expect(err).To(MatchError("chaincode definition must specify validation info"))
 		})
 	})
 
-	Describe("GetInstalledChaincodePackage", func() {
+	Describe("ApplicationPolicy", func() {
+		var cd *lifecycle.ChaincodeDefinition
+
 		BeforeEach(func() {
-			fakeCCStore.LoadReturns([]byte("code-package"), nil)
+			cd = &lifecycle.ChaincodeDefinition{}
 		})
 
-		It("returns the chaincode install package", func() {
-			pkgBytes, err := ef.GetInstalledChaincodePackage("some-package-id")
+		It("round trips the application policy through the validation parameter", func() {
+			policy := &pb.ApplicationPolicy{
+				Type: &pb.ApplicationPolicy_ChannelConfigPolicyReference{
+					ChannelConfigPolicyReference: "/Channel/Application/Endorsement",
+				},
+			}
+			err := cd.SetApplicationPolicy(policy)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(pkgBytes).To(Equal([]byte("code-package")))
+			Expect(cd.ValidationInfo.ValidationParameter).To(Equal(protoutil.MarshalOrPanic(policy)))
 
-			Expect(fakeCCStore.LoadCallCount()).To(Equal(1))
-			packageID := fakeCCStore.LoadArgsForCall(0)
-			Expect(packageID).To(Equal("some-package-id"))
+			retrieved, err := cd.GetApplicationPolicy()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(proto.Equal(retrieved, policy)).To(BeTrue())
 		})
 
-		Context("when loading the chaincode fails", func() {
+		It("preserves the validation plugin", func() {
+			cd.ValidationInfo = &lb.ChaincodeValidationInfo{ValidationPlugin: "vscc"}
+			err := cd.SetApplicationPolicy(&pb.ApplicationPolicy{
+				Type: &pb.ApplicationPolicy_SignaturePolicy{
+					SignaturePolicy: policydsl.SignedByMspMember("org0"),
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cd.ValidationInfo.ValidationPlugin).To(Equal("vscc"))
+		})
+
+		Context("when the validation parameter is not an application policy", func() {
 			BeforeEach(func() {
-				fakeCCStore.LoadReturns(nil, fmt.Errorf("fake-error"))
+				cd.ValidationInfo = &lb.ChaincodeValidationInfo{ValidationParameter: []byte("garbage")}
 			})
 
-			It("wraps and returns the error", func() {
-				pkgBytes, err := ef.GetInstalledChaincodePackage("some-package-id")
-				Expect(pkgBytes).To(BeNil())
-				Expect(err).To(MatchError("could not load cc install package: fake-error"))
+			It("returns an error", func() {
+				_, err := cd.GetApplicationPolicy()
+				Expect(err).To(MatchError(ContainSubstring("validation parameter is not a valid application policy")))
+			})
+		})
+
+		Context("when the validation parameter is empty", func() {
+			It("returns an error", func() {
+				_, err := cd.GetApplicationPolicy()
+				Expect(err).To(MatchError("validation parameter is not a valid application policy: no policy type set"))
 			})
 		})
 	})
 
-	Describe("QueryInstalledChaincode", func() {
+	Describe("DeepCopy", func() {
+		var cd *lifecycle.ChaincodeDefinition
+
 		BeforeEach(func() {
-			fakeLister.GetInstalledChaincodeReturns(&chaincode.InstalledChaincode{
-				Label:     "installed-cc2",
-				PackageID: "installed-package-id2",
-				References: map[string][]*chaincode.Metadata{
-					"test-channel": {
-						&chaincode.Metadata{
-							Name:    "test-chaincode",
-							Version: "test-version",
-						},
-						&chaincode.Metadata{
-							Name:    "hello-chaincode",
-							Version: "hello-version",
-						},
-					},
-					"another-channel": {
-						&chaincode.Metadata{
-							Name:    "another-chaincode",
-							Version: "another-version",
-						},
-					},
+			cd = &lifecycle.ChaincodeDefinition{
+				Sequence: 3,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "endorsement-plugin",
 				},
-			}, nil)
-		})
-
-		It("returns installed chaincode information", func() {
-			result, err := ef.QueryInstalledChaincode("installed-package-id2")
-			Expect(err).NotTo(HaveOccurred())
-			Expect(result).To(Equal(
-				&chaincode.InstalledChaincode{
-					Label:     "installed-cc2",
-					PackageID: "installed-package-id2",
-					References: map[string][]*chaincode.Metadata{
-						"test-channel": {
-							&chaincode.Metadata{
-								Name:    "test-chaincode",
-								Version: "test-version",
-							},
-							&chaincode.Metadata{
-								Name:    "hello-chaincode",
-								Version: "hello-version",
-							},
-						},
-						"another-channel": {
-							&chaincode.Metadata{
-								Name:    "another-chaincode",
-								Version: "another-version",
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: []byte("validation-parameter"),
+				},
+				Collections: &pb.CollectionConfigPackage{
+					Config: []*pb.CollectionConfig{
+						{
+							Payload: &pb.CollectionConfig_StaticCollectionConfig{
+								StaticCollectionConfig: &pb.StaticCollectionConfig{
+									Name: "collection-name",
+								},
 							},
 						},
 					},
 				},
-			))
+			}
 		})
 
-		Context("when the chaincode isn't installed", func() {
-			BeforeEach(func() {
-				fakeLister.GetInstalledChaincodeReturns(nil, errors.New("another-fake-error"))
-			})
+		It("returns an equal definition", func() {
+			cp := cd.DeepCopy()
+			Expect(cp.Sequence).To(Equal(int64(3)))
+			Expect(proto.Equal(cp.EndorsementInfo, cd.EndorsementInfo)).To(BeTrue())
+			Expect(proto.Equal(cp.ValidationInfo, cd.ValidationInfo)).To(BeTrue())
+			Expect(proto.Equal(cp.Collections, cd.Collections)).To(BeTrue())
+		})
 
-			It("returns an error", func() {
-				result, err := ef.QueryInstalledChaincode("not-there")
-				Expect(err.Error()).To(Equal("another-fake-error"))
-				Expect(result).To(BeNil())
+		It("does not share messages with the original", func() {
+			cp := cd.DeepCopy()
+			cp.Sequence = 4
+			cp.EndorsementInfo.Version = "other-version"
+			cp.ValidationInfo.ValidationParameter[0] = 'V'
+			cp.Collections.Config[0].GetStaticCollectionConfig().Name = "other-name"
+
+			Expect(cd.Sequence).To(Equal(int64(3)))
+			Expect(cd.EndorsementInfo.Version).To(Equal("version"))
+			Expect(cd.ValidationInfo.ValidationParameter).To(Equal([]byte("validation-parameter")))
+			Expect(cd.Collections.Config[0].GetStaticCollectionConfig().Name).To(Equal("collection-name"))
+		})
+
+		Context("when the definition has nil fields", func() {
+			It("copies them as nil", func() {
+				cp := (&lifecycle.ChaincodeDefinition{Sequence: 1}).DeepCopy()
+				Expect(cp).To(Equal(&lifecycle.ChaincodeDefinition{Sequence: 1}))
 			})
 		})
 	})
 
-	Describe("QueryInstalledChaincodes", func() {
-		var chaincodes []*chaincode.InstalledChaincode
+	Describe("ParametersCopy", func() {
+		var cd *lifecycle.ChaincodeDefinition
 
 		BeforeEach(func() {
-			chaincodes = []*chaincode.InstalledChaincode{
-				{
-					Label:     "installed-cc1",
-					PackageID: "installed-package-id1",
+			cd = &lifecycle.ChaincodeDefinition{
+				Sequence: 3,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version: "version",
 				},
-				{
-					Label:     "installed-cc2",
-					PackageID: "installed-package-id2",
-					References: map[string][]*chaincode.Metadata{
-						"test-channel": {
-							&chaincode.Metadata{
-								Name:    "test-chaincode",
-								Version: "test-version",
-							},
-							&chaincode.Metadata{
-								Name:    "hello-chaincode",
-								Version: "hello-version",
-							},
-						},
-						"another-channel": {
-							&chaincode.Metadata{
-								Name:    "another-chaincode",
-								Version: "another-version",
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationParameter: []byte("validation-parameter"),
+				},
+				Collections: &pb.CollectionConfigPackage{
+					Config: []*pb.CollectionConfig{
+						{
+							Payload: &pb.CollectionConfig_StaticCollectionConfig{
+								StaticCollectionConfig: &pb.StaticCollectionConfig{
+									Name: "collection-name",
+								},
 							},
 						},
 					},
 				},
 			}
-
-			fakeLister.ListInstalledChaincodesReturns(chaincodes)
 		})
 
-		It("passes through to the cache", func() {
-			result := ef.QueryInstalledChaincodes()
-			Expect(result).To(ConsistOf(
-				&chaincode.InstalledChaincode{
-					Label:     "installed-cc1",
-					PackageID: "installed-package-id1",
-				},
-				&chaincode.InstalledChaincode{
-					Label:     "installed-cc2",
-					PackageID: "installed-package-id2",
-					References: map[string][]*chaincode.Metadata{
-						"test-channel": {
-							&chaincode.Metadata{
-								Name:    "test-chaincode",
-								Version: "test-version",
-							},
-							&chaincode.Metadata{
-								Name:    "hello-chaincode",
-								Version: "hello-version",
-							},
-						},
-						"another-channel": {
-							&chaincode.Metadata{
-								Name:    "another-chaincode",
-								Version: "another-version",
-							},
-						},
-					},
-				},
-			))
+		It("returns parameters equal to the shared parameters", func() {
+			Expect(cd.ParametersCopy().Equal(cd.Parameters())).To(Succeed())
 		})
-	})
 
-	Describe("ApproveChaincodeDefinitionForOrg", func() {
-		var (
-			fakePublicState *mock.ReadWritableState
-			fakeOrgState    *mock.ReadWritableState
+		It("returns parameters which are independent of the definition", func() {
+			params := cd.ParametersCopy()
+			params.EndorsementInfo.Version = "other-version"
+			params.ValidationInfo.ValidationParameter[0] = 'V'
+			params.Collections.Config[0].GetStaticCollectionConfig().Name = "other-name"
 
-			fakeOrgKVStore    MapLedgerShim
-			fakePublicKVStore MapLedgerShim
+			Expect(cd.EndorsementInfo.Version).To(Equal("version"))
+			Expect(cd.ValidationInfo.ValidationParameter).To(Equal([]byte("validation-parameter")))
+			Expect(cd.Collections.Config[0].GetStaticCollectionConfig().Name).To(Equal("collection-name"))
+		})
 
-			testDefinition *lifecycle.ChaincodeDefinition
-		)
+		It("differs from Parameters, which shares messages with the definition", func() {
+			cd.Parameters().EndorsementInfo.Version = "other-version"
+			Expect(cd.EndorsementInfo.Version).To(Equal("other-version"))
+		})
+	})
+
+	Describe("JSON", func() {
+		var cd *lifecycle.ChaincodeDefinition
 
 		BeforeEach(func() {
-			testDefinition = &lifecycle.ChaincodeDefinition{
-				Sequence: 5,
+			cd = &lifecycle.ChaincodeDefinition{
+				Sequence: 3,
 				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
 					Version:           "version",
-					EndorsementPlugin: "my endorsement plugin",
+					EndorsementPlugin: "endorsement-plugin",
+					InitRequired:      true,
 				},
 				ValidationInfo: &lb.ChaincodeValidationInfo{
-					ValidationPlugin:    "my validation plugin",
-					ValidationParameter: []byte("some awesome policy"),
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: []byte("validation-parameter"),
 				},
-				Collections: &pb.CollectionConfigPackage{},
-			}
-
+				Collections: &pb.CollectionConfigPackage{
+					Config: []*pb.CollectionConfig{
+						{
+							Payload: &pb.CollectionConfig_StaticCollectionConfig{
+								StaticCollectionConfig: &pb.StaticCollectionConfig{
+									Name:              "collection-name",
+									RequiredPeerCount: 1,
+									MaximumPeerCount:  2,
+								},
+							},
+						},
+					},
+				},
+			}
+		})
+
+		It("encodes the definition deterministically", func() {
+			encoded, err := json.Marshal(cd)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(encoded)).To(Equal(`{"sequence":3,` +
+				`"endorsement_info":{"version":"version","init_required":true,"endorsement_plugin":"endorsement-plugin"},` +
+				`"validation_info":{"validation_plugin":"validation-plugin","validation_parameter":"dmFsaWRhdGlvbi1wYXJhbWV0ZXI="},` +
+				`"collections":{"config":[{"static_collection_config":{"name":"collection-name","required_peer_count":1,"maximum_peer_count":2}}]}}`))
+
+			again, err := json.Marshal(cd)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(again).To(Equal(encoded))
+		})
+
+		It("round trips the definition", func() {
+			encoded, err := json.Marshal(cd)
+			Expect(err).NotTo(HaveOccurred())
+
+			decoded := &lifecycle.ChaincodeDefinition{}
+			err = json.Unmarshal(encoded, decoded)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(decoded.Sequence).To(Equal(int64(3)))
+			Expect(proto.Equal(decoded.EndorsementInfo, cd.EndorsementInfo)).To(BeTrue())
+			Expect(proto.Equal(decoded.ValidationInfo, cd.ValidationInfo)).To(BeTrue())
+			Expect(proto.Equal(decoded.Collections, cd.Collections)).To(BeTrue())
+		})
+
+		Context("when messages are nil", func() {
+			It("omits them and leaves them nil when decoded", func() {
+				encoded, err := json.Marshal(&lifecycle.ChaincodeDefinition{Sequence: 1})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(encoded)).To(Equal(`{"sequence":1}`))
+
+				decoded := &lifecycle.ChaincodeDefinition{}
+				err = json.Unmarshal(encoded, decoded)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(decoded).To(Equal(&lifecycle.ChaincodeDefinition{Sequence: 1}))
+			})
+		})
+
+		Context("when an embedded message cannot be decoded", func() {
+			It("returns an error", func() {
+				decoded := &lifecycle.ChaincodeDefinition{}
+				err := json.Unmarshal([]byte(`{"sequence":1,"validation_info":{"validation_parameter":7}}`), decoded)
+				Expect(err).To(MatchError(ContainSubstring("could not unmarshal validation info")))
+			})
+		})
+	})
+})
+
+var _ = Describe("ValidateCollections", func() {
+	var (
+		cd *lifecycle.ChaincodeDefinition
+	)
+
+	newCollection := func(name string) *pb.StaticCollectionConfig {
+		return &pb.StaticCollectionConfig{
+			Name: name,
+			MemberOrgsPolicy: &pb.CollectionPolicyConfig{
+				Payload: &pb.CollectionPolicyConfig_SignaturePolicy{
+					SignaturePolicy: policydsl.SignedByMspMember("org0"),
+				},
+			},
+			RequiredPeerCount: 1,
+			MaximumPeerCount:  2,
+		}
+	}
+
+	addCollection := func(coll *pb.StaticCollectionConfig) {
+		cd.Collections.Config = append(cd.Collections.Config, &pb.CollectionConfig{
+			Payload: &pb.CollectionConfig_StaticCollectionConfig{
+				StaticCollectionConfig: coll,
+			},
+		})
+	}
+
+	BeforeEach(func() {
+		cd = &lifecycle.ChaincodeDefinition{
+			Collections: &pb.CollectionConfigPackage{},
+		}
+		addCollection(newCollection("collection1"))
+		addCollection(newCollection("collection2"))
+	})
+
+	It("accepts well formed collections", func() {
+		Expect(lifecycle.ValidateCollections(cd)).To(Succeed())
+	})
+
+	Context("when the collections are nil", func() {
+		BeforeEach(func() {
+			cd.Collections = nil
+		})
+
+		It("accepts the definition", func() {
+			Expect(lifecycle.ValidateCollections(cd)).To(Succeed())
+		})
+	})
+
+	Context("when a collection name is duplicated", func() {
+		BeforeEach(func() {
+			addCollection(newCollection("collection1"))
+		})
+
+		It("returns an error", func() {
+			Expect(lifecycle.ValidateCollections(cd)).To(MatchError("collection-name: collection1 -- found duplicate in collection configuration"))
+		})
+	})
+
+	Context("when the required peer count exceeds the maximum peer count", func() {
+		BeforeEach(func() {
+			coll := newCollection("collection3")
+			coll.RequiredPeerCount = 3
+			addCollection(coll)
+		})
+
+		It("returns an error", func() {
+			Expect(lifecycle.ValidateCollections(cd)).To(MatchError("collection-name: collection3 -- maximum peer count (2) cannot be less than the required peer count (3)"))
+		})
+	})
+
+	Context("when the required peer count is negative", func() {
+		BeforeEach(func() {
+			coll := newCollection("collection3")
+			coll.RequiredPeerCount = -1
+			addCollection(coll)
+		})
+
+		It("returns an error", func() {
+			Expect(lifecycle.ValidateCollections(cd)).To(MatchError("collection-name: collection3 -- requiredPeerCount (-1) cannot be less than zero"))
+		})
+	})
+
+	Context("when the collection name is invalid", func() {
+		BeforeEach(func() {
+			addCollection(newCollection("_collection3"))
+		})
+
+		It("returns an error", func() {
+			Expect(lifecycle.ValidateCollections(cd)).To(MatchError("invalid collection name '_collection3'. Names can only consist of alphanumerics, '_', and '-' and cannot begin with '_'"))
+		})
+	})
+
+	Context("when the member org policy is missing", func() {
+		BeforeEach(func() {
+			coll := newCollection("collection3")
+			coll.MemberOrgsPolicy = nil
+			addCollection(coll)
+		})
+
+		It("returns an error", func() {
+			Expect(lifecycle.ValidateCollections(cd)).To(MatchError("collection member policy is not set for collection 'collection3'"))
+		})
+	})
+
+	Context("when the member org policy lists several distinct orgs", func() {
+		BeforeEach(func() {
+			coll := newCollection("collection3")
+			coll.MemberOrgsPolicy.Payload = &pb.CollectionPolicyConfig_SignaturePolicy{
+				SignaturePolicy: policydsl.SignedByAnyMember([]string{"org0", "org1"}),
+			}
+			addCollection(coll)
+		})
+
+		It("accepts the definition", func() {
+			Expect(lifecycle.ValidateCollections(cd)).To(Succeed())
+		})
+	})
+
+	Context("when the member org policy lists an org twice", func() {
+		BeforeEach(func() {
+			coll := newCollection("collection3")
+			coll.MemberOrgsPolicy.Payload = &pb.CollectionPolicyConfig_SignaturePolicy{
+				SignaturePolicy: policydsl.SignedByAnyMember([]string{"org0", "org1", "org0"}),
+			}
+			addCollection(coll)
+		})
+
+		It("returns an error", func() {
+			Expect(lifecycle.ValidateCollections(cd)).To(MatchError("collection-name: collection3 -- member org policy contains duplicate principal for org org0"))
+		})
+	})
+
+	Context("when the collection endorsement policy references member orgs", func() {
+		BeforeEach(func() {
+			coll := newCollection("collection3")
+			coll.MemberOrgsPolicy.Payload = &pb.CollectionPolicyConfig_SignaturePolicy{
+				SignaturePolicy: policydsl.SignedByAnyMember([]string{"org0", "org1"}),
+			}
+			coll.EndorsementPolicy = &pb.ApplicationPolicy{
+				Type: &pb.ApplicationPolicy_SignaturePolicy{
+					SignaturePolicy: policydsl.SignedByMspPeer("org1"),
+				},
+			}
+			addCollection(coll)
+		})
+
+		It("accepts the definition", func() {
+			Expect(lifecycle.ValidateCollections(cd)).To(Succeed())
+		})
+	})
+
+	Context("when the collection endorsement policy references a non-member org", func() {
+		BeforeEach(func() {
+			coll := newCollection("collection3")
+			coll.EndorsementPolicy = &pb.ApplicationPolicy{
+				Type: &pb.ApplicationPolicy_SignaturePolicy{
+					SignaturePolicy: policydsl.SignedByAnyMember([]string{"org0", "org2"}),
+				},
+			}
+			addCollection(coll)
+		})
+
+		It("returns an error", func() {
+			Expect(lifecycle.ValidateCollections(cd)).To(MatchError("collection-name: collection3 -- endorsement policy references org org2 which is not a member of the collection"))
+		})
+	})
+
+	Context("when the collection endorsement policy references a channel config policy", func() {
+		BeforeEach(func() {
+			coll := newCollection("collection3")
+			coll.EndorsementPolicy = &pb.ApplicationPolicy{
+				Type: &pb.ApplicationPolicy_ChannelConfigPolicyReference{
+					ChannelConfigPolicyReference: "/Channel/Application/Endorsement",
+				},
+			}
+			addCollection(coll)
+		})
+
+		It("accepts the definition", func() {
+			Expect(lifecycle.ValidateCollections(cd)).To(Succeed())
+		})
+	})
+
+	Context("when the collection config is not a static collection config", func() {
+		BeforeEach(func() {
+			cd.Collections.Config = append(cd.Collections.Config, &pb.CollectionConfig{})
+		})
+
+		It("returns an error", func() {
+			Expect(lifecycle.ValidateCollections(cd)).To(MatchError("collection config contains unexpected payload type: <nil>"))
+		})
+	})
+})
+
+var _ = Describe("Resources", func() {
+	var (
+		resources               *lifecycle.Resources
+		fakeChannelConfigSource *mock.ChannelConfigSource
+		fakeChannelConfig       *mock.ChannelConfig
+		fakeApplicationConfig   *mock.ApplicationConfig
+		fakeOrgConfigs          []*mock.ApplicationOrgConfig
+		fakePolicyManager       *mock.PolicyManager
+	)
+
+	BeforeEach(func() {
+		fakeChannelConfigSource = &mock.ChannelConfigSource{}
+		fakeChannelConfig = &mock.ChannelConfig{}
+		fakeChannelConfigSource.GetStableChannelConfigReturns(fakeChannelConfig)
+		fakeApplicationConfig = &mock.ApplicationConfig{}
+		fakeChannelConfig.ApplicationConfigReturns(fakeApplicationConfig, true)
+		fakeOrgConfigs = []*mock.ApplicationOrgConfig{{}, {}}
+		fakeOrgConfigs[0].MSPIDReturns("first-mspid")
+		fakeOrgConfigs[1].MSPIDReturns("second-mspid")
+		fakePolicyManager = &mock.PolicyManager{}
+		fakePolicyManager.GetPolicyReturns(nil, true)
+		fakeChannelConfig.PolicyManagerReturns(fakePolicyManager)
+
+		fakeApplicationConfig.OrganizationsReturns(map[string]channelconfig.ApplicationOrg{
+			"org0": fakeOrgConfigs[0],
+			"org1": fakeOrgConfigs[1],
+		})
+
+		resources = &lifecycle.Resources{
+			ChannelConfigSource: fakeChannelConfigSource,
+			Serializer:          &lifecycle.Serializer{},
+		}
+	})
+
+	Describe("ChaincodeDefinitionIfDefined", func() {
+		var (
+			fakePublicState   MapLedgerShim
+			fakeReadableState *mock.ReadWritableState
+		)
+
+		BeforeEach(func() {
+			fakePublicState = map[string][]byte{}
+			err := resources.Serializer.Serialize(lifecycle.NamespacesName, "cc-name", &lifecycle.ChaincodeDefinition{
+				Sequence: 5,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "my endorsement plugin",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "my validation plugin",
+					ValidationParameter: []byte("some awesome policy"),
+				},
+				Collections: &pb.CollectionConfigPackage{},
+			}, fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			fakeReadableState = &mock.ReadWritableState{}
+			fakeReadableState.GetStateStub = fakePublicState.GetState
+		})
+
+		It("returns that the chaincode is defined and that the chaincode definition can be converted to a string suitable for log messages", func() {
+			exists, definition, err := resources.ChaincodeDefinitionIfDefined("cc-name", fakeReadableState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeTrue())
+			Expect(definition.EndorsementInfo.Version).To(Equal("version"))
+			Expect(fmt.Sprintf("{%s}", definition)).To(Equal("{sequence: 5, endorsement info: (version: 'version', plugin: 'my endorsement plugin', init required: false), validation info: (plugin: 'my validation plugin', policy: '736f6d6520617765736f6d6520706f6c696379'), collections: ()}"))
+		})
+
+		Context("when the requested chaincode is _lifecycle", func() {
+			It("it returns true", func() {
+				exists, definition, err := resources.ChaincodeDefinitionIfDefined("_lifecycle", fakeReadableState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(exists).To(BeTrue())
+				Expect(definition).NotTo(BeNil())
+				Expect(fakeReadableState.GetStateCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the metadata is not for a chaincode", func() {
+			BeforeEach(func() {
+				type badStruct struct{}
+				err := resources.Serializer.Serialize(lifecycle.NamespacesName,
+					"cc-name",
+					&badStruct{},
+					fakePublicState,
+				)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("returns an error", func() {
+				_, _, err := resources.ChaincodeDefinitionIfDefined("cc-name", fakeReadableState)
+				Expect(err).To(MatchError("not a chaincode type: badStruct"))
+				Expect(err).To(Equal(lifecycle.ErrNotChaincodeType{Datatype: "badStruct"}))
+			})
+		})
+
+		Context("when the ledger returns an error", func() {
+			BeforeEach(func() {
+				fakeReadableState.GetStateReturns(nil, fmt.Errorf("state-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				_, _, err := resources.ChaincodeDefinitionIfDefined("cc-name", fakeReadableState)
+				Expect(err).To(MatchError("could not deserialize metadata for chaincode cc-name: could not query metadata for namespace namespaces/cc-name: state-error"))
+			})
+		})
+	})
+
+	Describe("ChaincodeDefinitionIfDefinedInChannel", func() {
+		var (
+			fakePublicState   MapLedgerShim
+			fakeReadableState *mock.ReadWritableState
+		)
+
+		definitionAt := func(sequence int64, version string) *lifecycle.ChaincodeDefinition {
+			return &lifecycle.ChaincodeDefinition{
+				Sequence: sequence,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version: version,
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{},
+				Collections:    &pb.CollectionConfigPackage{},
+			}
+		}
+
+		BeforeEach(func() {
+			fakePublicState = map[string][]byte{}
+			err := resources.Serializer.Serialize(lifecycle.NamespacesName, "cc-name", definitionAt(5, "version"), fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			fakeReadableState = &mock.ReadWritableState{}
+			fakeReadableState.GetStateStub = fakePublicState.GetState
+			resources.CacheDefinitions = true
+		})
+
+		It("only reads the sequence once the definition is cached", func() {
+			exists, definition, err := resources.ChaincodeDefinitionIfDefinedInChannel("channel-id", "cc-name", fakeReadableState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeTrue())
+			Expect(definition.EndorsementInfo.Version).To(Equal("version"))
+			uncachedReads := fakeReadableState.GetStateCallCount()
+
+			definition.EndorsementInfo.Version = "modified"
+
+			exists, definition, err = resources.ChaincodeDefinitionIfDefinedInChannel("channel-id", "cc-name", fakeReadableState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exists).To(BeTrue())
+			Expect(definition.EndorsementInfo.Version).To(Equal("version"))
+			Expect(fakeReadableState.GetStateCallCount() - uncachedReads).To(Equal(1))
+		})
+
+		It("re-reads the definition when the sequence changes", func() {
+			_, _, err := resources.ChaincodeDefinitionIfDefinedInChannel("channel-id", "cc-name", fakeReadableState)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = resources.Serializer.Serialize(lifecycle.NamespacesName, "cc-name", definitionAt(6, "new-version"), fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, definition, err := resources.ChaincodeDefinitionIfDefinedInChannel("channel-id", "cc-name", fakeReadableState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(definition.Sequence).To(Equal(int64(6)))
+			Expect(definition.EndorsementInfo.Version).To(Equal("new-version"))
+		})
+
+		It("caches definitions separately for each channel", func() {
+			_, _, err := resources.ChaincodeDefinitionIfDefinedInChannel("channel-id", "cc-name", fakeReadableState)
+			Expect(err).NotTo(HaveOccurred())
+
+			otherState := MapLedgerShim(map[string][]byte{})
+			err = resources.Serializer.Serialize(lifecycle.NamespacesName, "cc-name", definitionAt(5, "other-version"), otherState)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, definition, err := resources.ChaincodeDefinitionIfDefinedInChannel("other-channel-id", "cc-name", otherState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(definition.EndorsementInfo.Version).To(Equal("other-version"))
+		})
+
+		Context("when the chaincode is not defined", func() {
+			It("returns that it is not defined", func() {
+				exists, _, err := resources.ChaincodeDefinitionIfDefinedInChannel("channel-id", "other-name", fakeReadableState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(exists).To(BeFalse())
+			})
+		})
+
+		Context("when the sequence cannot be read", func() {
+			BeforeEach(func() {
+				fakeReadableState.GetStateReturns(nil, fmt.Errorf("state-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				_, _, err := resources.ChaincodeDefinitionIfDefinedInChannel("channel-id", "cc-name", fakeReadableState)
+				Expect(err).To(MatchError("could not get sequence for chaincode cc-name: could not get state for key namespaces/fields/cc-name/Sequence: state-error"))
+			})
+		})
+	})
+
+	Describe("LifecycleEndorsementPolicyAsBytes", func() {
+		It("returns the endorsement policy for the lifecycle chaincode", func() {
+			b, err := resources.LifecycleEndorsementPolicyAsBytes("channel-id")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(b).NotTo(BeNil())
+			policy := &cb.ApplicationPolicy{}
+			err = proto.Unmarshal(b, policy)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy.GetChannelConfigPolicyReference()).To(Equal("/Channel/Application/LifecycleEndorsement"))
+		})
+
+		Context("when the endorsement policy reference is not found", func() {
+			BeforeEach(func() {
+				fakePolicyManager.GetPolicyReturns(nil, false)
+			})
+
+			It("returns an error", func() {
+				b, err := resources.LifecycleEndorsementPolicyAsBytes("channel-id")
+				Expect(err).NotTo(HaveOccurred())
+				policy := &cb.ApplicationPolicy{}
+				err = proto.Unmarshal(b, policy)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(policy.GetSignaturePolicy()).NotTo(BeNil())
+			})
+
+			Context("when the application config cannot be retrieved", func() {
+				BeforeEach(func() {
+					fakeChannelConfig.ApplicationConfigReturns(nil, false)
+				})
+
+				It("returns an error", func() {
+					_, err := resources.LifecycleEndorsementPolicyAsBytes("channel-id")
+					Expect(err).To(MatchError("could not get application config for channel 'channel-id'"))
+				})
+			})
+		})
+
+		Context("when the channel config cannot be retrieved", func() {
+			BeforeEach(func() {
+				fakeChannelConfigSource.GetStableChannelConfigReturns(nil)
+			})
+
+			It("returns an error", func() {
+				_, err := resources.LifecycleEndorsementPolicyAsBytes("channel-id")
+				Expect(err).To(MatchError("could not get channel config for channel 'channel-id'"))
+			})
+		})
+	})
+
+	Describe("ResolveEffectiveValidationParameter", func() {
+		var cd *lifecycle.ChaincodeDefinition
+
+		BeforeEach(func() {
+			cd = &lifecycle.ChaincodeDefinition{
+				ValidationInfo: &lb.ChaincodeValidationInfo{},
+			}
+		})
+
+		It("returns the channel default endorsement policy", func() {
+			res, err := resources.ResolveEffectiveValidationParameter("channel-id", cd)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal(lifecycle.DefaultEndorsementPolicyBytes))
+			Expect(fakeChannelConfigSource.GetStableChannelConfigArgsForCall(0)).To(Equal("channel-id"))
+		})
+
+		Context("when the definition has an explicit validation parameter", func() {
+			BeforeEach(func() {
+				cd.ValidationInfo.ValidationParameter = []byte("validation-parameter")
+			})
+
+			It("returns the explicit validation parameter", func() {
+				res, err := resources.ResolveEffectiveValidationParameter("channel-id", cd)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res).To(Equal([]byte("validation-parameter")))
+				Expect(fakeChannelConfigSource.GetStableChannelConfigCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the definition has no validation info", func() {
+			BeforeEach(func() {
+				cd.ValidationInfo = nil
+			})
+
+			It("returns the channel default endorsement policy", func() {
+				res, err := resources.ResolveEffectiveValidationParameter("channel-id", cd)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res).To(Equal(lifecycle.DefaultEndorsementPolicyBytes))
+			})
+		})
+
+		Context("when the channel does not define a default endorsement policy", func() {
+			BeforeEach(func() {
+				fakePolicyManager.GetPolicyReturns(nil, false)
+			})
+
+			It("returns an error", func() {
+				_, err := resources.ResolveEffectiveValidationParameter("channel-id", cd)
+				Expect(err).To(MatchError("could not resolve default endorsement policy for channel 'channel-id': policy '/Channel/Application/Endorsement' must be defined for channel 'channel-id' before chaincode operations can be attempted"))
+			})
+		})
+	})
+
+	Describe("policy caching", func() {
+		BeforeEach(func() {
+			resources.CachePolicies = true
+		})
+
+		It("resolves each policy from the channel config only once", func() {
+			for i := 0; i < 3; i++ {
+				_, err := resources.DefaultEndorsementPolicyAsBytes("channel-id")
+				Expect(err).NotTo(HaveOccurred())
+				_, err = resources.LifecycleEndorsementPolicyAsBytes("channel-id")
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(fakeChannelConfigSource.GetStableChannelConfigCallCount()).To(Equal(2))
+		})
+
+		It("refetches the channel config after invalidation", func() {
+			_, err := resources.DefaultEndorsementPolicyAsBytes("channel-id")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeChannelConfigSource.GetStableChannelConfigCallCount()).To(Equal(1))
+
+			resources.InvalidatePolicyCache("other-channel-id")
+			_, err = resources.DefaultEndorsementPolicyAsBytes("channel-id")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeChannelConfigSource.GetStableChannelConfigCallCount()).To(Equal(1))
+
+			resources.InvalidatePolicyCache("channel-id")
+			_, err = resources.DefaultEndorsementPolicyAsBytes("channel-id")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeChannelConfigSource.GetStableChannelConfigCallCount()).To(Equal(2))
+			Expect(fakeChannelConfigSource.GetStableChannelConfigArgsForCall(1)).To(Equal("channel-id"))
+		})
+
+		Context("when resolving the policy fails", func() {
+			BeforeEach(func() {
+				fakePolicyManager.GetPolicyReturns(nil, false)
+			})
+
+			It("does not cache the failure", func() {
+				_, err := resources.DefaultEndorsementPolicyAsBytes("channel-id")
+				Expect(err).To(MatchError("policy '/Channel/Application/Endorsement' must be defined for channel 'channel-id' before chaincode operations can be attempted"))
+
+				fakePolicyManager.GetPolicyReturns(nil, true)
+				policyBytes, err := resources.DefaultEndorsementPolicyAsBytes("channel-id")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(policyBytes).To(Equal(lifecycle.DefaultEndorsementPolicyBytes))
+				Expect(fakeChannelConfigSource.GetStableChannelConfigCallCount()).To(Equal(2))
+			})
+		})
+
+		Context("when caching is disabled", func() {
+			BeforeEach(func() {
+				resources.CachePolicies = false
+			})
+
+			It("resolves the policy from the channel config each time", func() {
+				for i := 0; i < 3; i++ {
+					_, err := resources.DefaultEndorsementPolicyAsBytes("channel-id")
+					Expect(err).NotTo(HaveOccurred())
+				}
+				Expect(fakeChannelConfigSource.GetStableChannelConfigCallCount()).To(Equal(3))
+			})
+		})
+	})
+})
+
+var _ = Describe("ExternalFunctions", func() {
+	var (
+		resources               *lifecycle.Resources
+		ef                      *lifecycle.ExternalFunctions
+		fakeCCStore             *mock.ChaincodeStore
+		fakeChaincodeBuilder    *mock.ChaincodeBuilder
+		fakeParser              *mock.PackageParser
+		fakeListener            *mock.InstallListener
+		fakeLister              *mock.InstalledChaincodesLister
+		fakeChannelConfigSource *mock.ChannelConfigSource
+		fakeChannelConfig       *mock.ChannelConfig
+		fakeApplicationConfig   *mock.ApplicationConfig
+		fakeOrgConfigs          []*mock.ApplicationOrgConfig
+		fakePolicyManager       *mock.PolicyManager
+	)
+
+	BeforeEach(func() {
+		fakeCCStore = &mock.ChaincodeStore{}
+		fakeChaincodeBuilder = &mock.ChaincodeBuilder{}
+		fakeParser = &mock.PackageParser{}
+		fakeListener = &mock.InstallListener{}
+		fakeLister = &mock.InstalledChaincodesLister{}
+		fakeChannelConfigSource = &mock.ChannelConfigSource{}
+		fakeChannelConfig = &mock.ChannelConfig{}
+		fakeChannelConfigSource.GetStableChannelConfigReturns(fakeChannelConfig)
+		fakeApplicationConfig = &mock.ApplicationConfig{}
+		fakeChannelConfig.ApplicationConfigReturns(fakeApplicationConfig, true)
+		fakeOrgConfigs = []*mock.ApplicationOrgConfig{{}, {}}
+		fakeOrgConfigs[0].MSPIDReturns("first-mspid")
+		fakeOrgConfigs[1].MSPIDReturns("second-mspid")
+		fakePolicyManager = &mock.PolicyManager{}
+		fakePolicyManager.GetPolicyReturns(nil, true)
+		fakeChannelConfig.PolicyManagerReturns(fakePolicyManager)
+
+		fakeApplicationConfig.OrganizationsReturns(map[string]channelconfig.ApplicationOrg{
+			"org0": fakeOrgConfigs[0],
+			"org1": fakeOrgConfigs[1],
+		})
+
+		resources = &lifecycle.Resources{
+			PackageParser:       fakeParser,
+			ChaincodeStore:      fakeCCStore,
+			Serializer:          &lifecycle.Serializer{},
+			ChannelConfigSource: fakeChannelConfigSource,
+		}
+
+		ef = &lifecycle.ExternalFunctions{
+			Resources:                 resources,
+			InstallListener:           fakeListener,
+			InstalledChaincodesLister: fakeLister,
+			ChaincodeBuilder:          fakeChaincodeBuilder,
+			BuildRegistry:             &container.BuildRegistry{},
+		}
+	})
+
+	Describe("InstallChaincode", func() {
+		BeforeEach(func() {
+			fakeParser.ParseReturns(&persistence.ChaincodePackage{
+				Metadata: &persistence.ChaincodePackageMetadata{
+					Type:  "cc-type",
+					Path:  "cc-path",
+					Label: "cc-label",
+				},
+			}, nil)
+			fakeCCStore.SaveWithContextReturns("fake-hash", nil)
+		})
+
+		It("saves the chaincode", func() {
+			cc, err := ef.InstallChaincode([]byte("cc-package"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cc).To(Equal(&chaincode.InstalledChaincode{
+				PackageID: "fake-hash",
+				Label:     "cc-label",
+			}))
+
+			Expect(fakeParser.ParseCallCount()).To(Equal(1))
+			Expect(fakeParser.ParseArgsForCall(0)).To(Equal([]byte("cc-package")))
+
+			Expect(fakeCCStore.SaveWithContextCallCount()).To(Equal(1))
+			ctx, name, msg := fakeCCStore.SaveWithContextArgsForCall(0)
+			Expect(ctx).To(Equal(context.Background()))
+			Expect(name).To(Equal("cc-label"))
+			Expect(msg).To(Equal([]byte("cc-package")))
+
+			Expect(fakeListener.HandleChaincodeInstalledCallCount()).To(Equal(1))
+			md, packageID := fakeListener.HandleChaincodeInstalledArgsForCall(0)
+			Expect(md).To(Equal(&persistence.ChaincodePackageMetadata{
+				Type:  "cc-type",
+				Path:  "cc-path",
+				Label: "cc-label",
+			}))
+			Expect(packageID).To(Equal("fake-hash"))
+		})
+
+		It("builds the chaincode", func() {
+			_, err := ef.InstallChaincode([]byte("cc-package"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeChaincodeBuilder.BuildCallCount()).To(Equal(1))
+			ccid := fakeChaincodeBuilder.BuildArgsForCall(0)
+			Expect(ccid).To(Equal("fake-hash"))
+		})
+
+		When("building the chaincode fails", func() {
+			BeforeEach(func() {
+				fakeChaincodeBuilder.BuildReturns(fmt.Errorf("fake-build-error"))
+			})
+
+			It("returns the wrapped error to the caller", func() {
+				_, err := ef.InstallChaincode([]byte("cc-package"))
+				Expect(err).To(MatchError("could not build chaincode: fake-build-error"))
+			})
+
+			It("deletes the chaincode from disk", func() {
+				ef.InstallChaincode([]byte("cc-package"))
+				Expect(fakeCCStore.DeleteCallCount()).To(Equal(1))
+				Expect(fakeCCStore.DeleteArgsForCall(0)).To(Equal("fake-hash"))
+			})
+		})
+
+		When("the chaincode is already being built and succeeds", func() {
+			BeforeEach(func() {
+				bs, ok := ef.BuildRegistry.BuildStatus("fake-hash")
+				Expect(ok).To(BeFalse())
+				bs.Notify(nil)
+			})
+
+			It("does not attempt to rebuild it itself", func() {
+				_, err := ef.InstallChaincode([]byte("cc-package"))
+				Expect(err).To(MatchError("chaincode already successfully installed"))
+				Expect(fakeChaincodeBuilder.BuildCallCount()).To(Equal(0))
+			})
+		})
+
+		When("the chaincode is already being built and fails", func() {
+			BeforeEach(func() {
+				bs, ok := ef.BuildRegistry.BuildStatus("fake-hash")
+				Expect(ok).To(BeFalse())
+				bs.Notify(fmt.Errorf("fake-other-builder-error"))
+			})
+
+			It("attempts to rebuild it itself", func() {
+				_, err := ef.InstallChaincode([]byte("cc-package"))
+				Expect(err).To(BeNil())
+				Expect(fakeChaincodeBuilder.BuildCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the chaincode store is a memory chaincode store", func() {
+			var memoryStore *mock.MemoryChaincodeStore
+
+			BeforeEach(func() {
+				memoryStore = &mock.MemoryChaincodeStore{}
+				ef.Resources.ChaincodeStore = memoryStore
+			})
+
+			It("stores the package under its real package ID", func() {
+				cc, err := ef.InstallChaincode([]byte("cc-package"))
+				Expect(err).NotTo(HaveOccurred())
+				packageID := fmt.Sprintf("cc-label:%x", util.ComputeSHA256([]byte("cc-package")))
+				Expect(cc.PackageID).To(Equal(packageID))
+
+				installed, err := memoryStore.ListInstalledChaincodes()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(installed).To(Equal([]chaincode.InstalledChaincode{
+					{
+						PackageID: packageID,
+						Label:     "cc-label",
+						Hash:      util.ComputeSHA256([]byte("cc-package")),
+					},
+				}))
+
+				pkgBytes, err := memoryStore.Load(packageID)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pkgBytes).To(Equal([]byte("cc-package")))
+
+				hash, err := memoryStore.RetrieveHashByPackageID(packageID)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(hash).To(Equal(util.ComputeSHA256([]byte("cc-package"))))
+			})
+
+			It("removes the package when building the chaincode fails", func() {
+				fakeChaincodeBuilder.BuildReturns(fmt.Errorf("fake-build-error"))
+				_, err := ef.InstallChaincode([]byte("cc-package"))
+				Expect(err).To(MatchError("could not build chaincode: fake-build-error"))
+
+				count, err := memoryStore.Count()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(count).To(Equal(0))
+
+				_, err = memoryStore.Load(fmt.Sprintf("cc-label:%x", util.ComputeSHA256([]byte("cc-package"))))
+				Expect(err).To(MatchError(ContainSubstring("not found")))
+			})
+		})
+
+		Context("when the package does not have metadata", func() {
+			BeforeEach(func() {
+				fakeParser.ParseReturns(&persistence.ChaincodePackage{}, nil)
+			})
+
+			It("wraps and returns the error", func() {
+				hash, err := ef.InstallChaincode([]byte("fake-package"))
+				Expect(hash).To(BeNil())
+				Expect(err.Error()).To(ContainSubstring("empty metadata for supplied chaincode"))
+			})
+		})
+
+		Context("when saving the chaincode fails", func() {
+			BeforeEach(func() {
+				fakeCCStore.SaveWithContextReturns("", fmt.Errorf("fake-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				cc, err := ef.InstallChaincode([]byte("cc-package"))
+				Expect(cc).To(BeNil())
+				Expect(err).To(MatchError("could not save cc install package: fake-error"))
+			})
+		})
+
+		Context("when the package exceeds the maximum install package size", func() {
+			BeforeEach(func() {
+				ef.MaxInstallPackageSize = 4
+			})
+
+			It("returns an error without saving the package", func() {
+				cc, err := ef.InstallChaincode([]byte("cc-package"))
+				Expect(cc).To(BeNil())
+				Expect(err).To(MatchError("chaincode install package exceeds the maximum size of 4 bytes"))
+				Expect(fakeParser.ParseCallCount()).To(Equal(0))
+				Expect(fakeCCStore.SaveWithContextCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when a context is supplied", func() {
+			var (
+				ctx    context.Context
+				cancel context.CancelFunc
+			)
+
+			BeforeEach(func() {
+				ctx, cancel = context.WithCancel(context.Background())
+			})
+
+			AfterEach(func() {
+				cancel()
+			})
+
+			It("passes the context to the chaincode store", func() {
+				_, err := ef.InstallChaincodeWithContext(ctx, []byte("cc-package"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeCCStore.SaveWithContextCallCount()).To(Equal(1))
+				savedCtx, _, _ := fakeCCStore.SaveWithContextArgsForCall(0)
+				Expect(savedCtx).To(Equal(ctx))
+			})
+
+			Context("when the context is cancelled during the save", func() {
+				BeforeEach(func() {
+					fakeCCStore.SaveWithContextStub = func(ctx context.Context, label string, pkg []byte) (string, error) {
+						cancel()
+						return "", ctx.Err()
+					}
+				})
+
+				It("does not build or announce the chaincode", func() {
+					cc, err := ef.InstallChaincodeWithContext(ctx, []byte("cc-package"))
+					Expect(cc).To(BeNil())
+					Expect(err).To(MatchError("could not save cc install package: context canceled"))
+					Expect(fakeChaincodeBuilder.BuildCallCount()).To(Equal(0))
+					Expect(fakeListener.HandleChaincodeInstalledCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		Context("when parsing the chaincode package fails", func() {
+			BeforeEach(func() {
+				fakeParser.ParseReturns(nil, fmt.Errorf("parse-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				hash, err := ef.InstallChaincode([]byte("fake-package"))
+				Expect(hash).To(BeNil())
+				Expect(err).To(MatchError("could not parse as a chaincode install package: parse-error"))
+			})
+		})
+
+		Context("when the same package is installed concurrently", func() {
+			It("notifies the install listener exactly once", func() {
+				var wg sync.WaitGroup
+				for i := 0; i < 20; i++ {
+					wg.Add(1)
+					go func() {
+						defer GinkgoRecover()
+						defer wg.Done()
+						ef.InstallChaincode([]byte("cc-package"))
+					}()
+				}
+				wg.Wait()
+
+				Expect(fakeListener.HandleChaincodeInstalledCallCount()).To(Equal(1))
+				Expect(fakeChaincodeBuilder.BuildCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when a different package is being saved", func() {
+			var saveBlocked chan struct{}
+
+			BeforeEach(func() {
+				saveBlocked = make(chan struct{})
+				fakeCCStore.SaveWithContextStub = func(ctx context.Context, label string, pkg []byte) (string, error) {
+					if string(pkg) == "slow-package" {
+						<-saveBlocked
+						return "slow-hash", nil
+					}
+					return "fake-hash", nil
+				}
+			})
+
+			AfterEach(func() {
+				close(saveBlocked)
+			})
+
+			It("does not wait for it", func() {
+				go ef.InstallChaincode([]byte("slow-package"))
+				Eventually(fakeCCStore.SaveWithContextCallCount).Should(Equal(1))
+
+				_, err := ef.InstallChaincode([]byte("cc-package"))
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when saving an earlier install of the package fails", func() {
+			BeforeEach(func() {
+				fakeCCStore.SaveWithContextReturnsOnCall(0, "", fmt.Errorf("fake-error"))
+				fakeCCStore.SaveWithContextReturnsOnCall(1, "fake-hash", nil)
+			})
+
+			It("releases the lock for the package", func() {
+				_, err := ef.InstallChaincode([]byte("cc-package"))
+				Expect(err).To(MatchError("could not save cc install package: fake-error"))
+
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					_, err := ef.InstallChaincode([]byte("cc-package"))
+					Expect(err).NotTo(HaveOccurred())
+					close(done)
+				}()
+				Eventually(done).Should(BeClosed())
+			})
+		})
+	})
+
+	Describe("InstallChaincodeStream", func() {
+		var (
+			storedBytes  []byte
+			storeReadErr error
+		)
+
+		BeforeEach(func() {
+			storedBytes, storeReadErr = nil, nil
+			fakeParser.ParseStreamStub = func(r io.Reader) (*persistence.ChaincodePackageMetadata, error) {
+				// parsers need not read the whole package
+				if _, err := io.ReadFull(r, make([]byte, 2)); err != nil {
+					return nil, err
+				}
+				return &persistence.ChaincodePackageMetadata{Label: "cc-label"}, nil
+			}
+			fakeCCStore.SaveStreamStub = func(r io.Reader, maxSize int64) (string, *persistence.ChaincodePackageMetadata, error) {
+				storedBytes, storeReadErr = ioutil.ReadAll(r)
+				if storeReadErr != nil {
+					return "", nil, storeReadErr
+				}
+				return "cc-label:fake-hash", &persistence.ChaincodePackageMetadata{
+					Type:  "cc-type",
+					Path:  "cc-path",
+					Label: "cc-label",
+				}, nil
+			}
+			ef.MaxInstallPackageSize = 1024
+		})
+
+		It("streams the package through the parser into the chaincode store", func() {
+			cc, err := ef.InstallChaincodeStream(bytes.NewReader([]byte("cc-package")))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cc).To(Equal(&chaincode.InstalledChaincode{
+				PackageID: "cc-label:fake-hash",
+				Label:     "cc-label",
+			}))
+
+			Expect(fakeParser.ParseCallCount()).To(Equal(0))
+			Expect(fakeParser.ParseStreamCallCount()).To(Equal(1))
+			Expect(fakeCCStore.SaveStreamCallCount()).To(Equal(1))
+			_, maxSize := fakeCCStore.SaveStreamArgsForCall(0)
+			Expect(storedBytes).To(Equal([]byte("cc-package")))
+			Expect(maxSize).To(Equal(int64(1024)))
+		})
+
+		Context("when parsing the package fails", func() {
+			BeforeEach(func() {
+				fakeParser.ParseStreamStub = nil
+				fakeParser.ParseStreamReturns(nil, fmt.Errorf("parse-error"))
+			})
+
+			It("fails the stream into the chaincode store", func() {
+				cc, err := ef.InstallChaincodeStream(bytes.NewReader([]byte("cc-package")))
+				Expect(cc).To(BeNil())
+				Expect(err).To(MatchError("could not parse as a chaincode install package: parse-error"))
+				Expect(storeReadErr).To(MatchError("parse-error"))
+				Expect(fakeChaincodeBuilder.BuildCallCount()).To(Equal(0))
+			})
+		})
+
+		It("builds the chaincode and notifies the install listener", func() {
+			_, err := ef.InstallChaincodeStream(bytes.NewReader([]byte("cc-package")))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeChaincodeBuilder.BuildCallCount()).To(Equal(1))
+			Expect(fakeChaincodeBuilder.BuildArgsForCall(0)).To(Equal("cc-label:fake-hash"))
+			Expect(fakeListener.HandleChaincodeInstalledCallCount()).To(Equal(1))
+			md, packageID := fakeListener.HandleChaincodeInstalledArgsForCall(0)
+			Expect(md.Label).To(Equal("cc-label"))
+			Expect(packageID).To(Equal("cc-label:fake-hash"))
+		})
+
+		Context("when saving the package fails", func() {
+			BeforeEach(func() {
+				fakeCCStore.SaveStreamStub = nil
+				fakeCCStore.SaveStreamReturns("", nil, fmt.Errorf("chaincode install package exceeds the maximum size of 1024 bytes"))
+			})
+
+			It("wraps and returns the error", func() {
+				cc, err := ef.InstallChaincodeStream(bytes.NewReader([]byte("cc-package")))
+				Expect(cc).To(BeNil())
+				Expect(err).To(MatchError("could not save cc install package: chaincode install package exceeds the maximum size of 1024 bytes"))
+				Expect(fakeChaincodeBuilder.BuildCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when building the chaincode fails", func() {
+			BeforeEach(func() {
+				fakeChaincodeBuilder.BuildReturns(fmt.Errorf("fake-build-error"))
+			})
+
+			It("deletes the saved package", func() {
+				_, err := ef.InstallChaincodeStream(bytes.NewReader([]byte("cc-package")))
+				Expect(err).To(MatchError("could not build chaincode: fake-build-error"))
+				Expect(fakeCCStore.DeleteCallCount()).To(Equal(1))
+				Expect(fakeCCStore.DeleteArgsForCall(0)).To(Equal("cc-label:fake-hash"))
+			})
+		})
+	})
+
+	Describe("InstallChaincodeIfAbsent", func() {
+		var existingPackageID string
+
+		BeforeEach(func() {
+			fakeParser.ParseReturns(&persistence.ChaincodePackage{
+				Metadata: &persistence.ChaincodePackageMetadata{
+					Type:  "cc-type",
+					Path:  "cc-path",
+					Label: "cc-label",
+				},
+			}, nil)
+			fakeCCStore.SaveWithContextReturns("fake-hash", nil)
+
+			existingPackageID = "existing-package-id"
+			fakeCCStore.ListInstalledChaincodesReturns([]chaincode.InstalledChaincode{
+				{
+					PackageID: "other-label:other-hash",
+					Hash:      []byte("other-hash"),
+					Label:     "other-label",
+				},
+				{
+					PackageID: existingPackageID,
+					Hash:      util.ComputeSHA256([]byte("cc-package")),
+					Label:     "cc-label",
+				},
+			}, nil)
+		})
+
+		It("returns the existing chaincode without reinstalling it", func() {
+			cc, alreadyInstalled, err := ef.InstallChaincodeIfAbsent([]byte("cc-package"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(alreadyInstalled).To(BeTrue())
+			Expect(cc).To(Equal(&chaincode.InstalledChaincode{
+				PackageID: existingPackageID,
+				Label:     "cc-label",
+			}))
+			Expect(fakeCCStore.SaveWithContextCallCount()).To(Equal(0))
+			Expect(fakeChaincodeBuilder.BuildCallCount()).To(Equal(0))
+			Expect(fakeListener.HandleChaincodeInstalledCallCount()).To(Equal(0))
+		})
+
+		Context("when the package is not installed", func() {
+			It("installs the chaincode", func() {
+				cc, alreadyInstalled, err := ef.InstallChaincodeIfAbsent([]byte("new-cc-package"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(alreadyInstalled).To(BeFalse())
+				Expect(cc).To(Equal(&chaincode.InstalledChaincode{
+					PackageID: "fake-hash",
+					Label:     "cc-label",
+				}))
+				Expect(fakeCCStore.SaveWithContextCallCount()).To(Equal(1))
+				Expect(fakeListener.HandleChaincodeInstalledCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the installed chaincodes cannot be listed", func() {
+			BeforeEach(func() {
+				fakeCCStore.ListInstalledChaincodesReturns(nil, fmt.Errorf("fake-list-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				_, _, err := ef.InstallChaincodeIfAbsent([]byte("cc-package"))
+				Expect(err).To(MatchError("could not list installed chaincodes: fake-list-error"))
+				Expect(fakeCCStore.SaveWithContextCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the package cannot be parsed", func() {
+			BeforeEach(func() {
+				fakeParser.ParseReturns(nil, fmt.Errorf("parse-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				_, _, err := ef.InstallChaincodeIfAbsent([]byte("cc-package"))
+				Expect(err).To(MatchError("could not parse as a chaincode install package: parse-error"))
+			})
+		})
+
+		Context("when the package exceeds the maximum install package size", func() {
+			BeforeEach(func() {
+				ef.MaxInstallPackageSize = 4
+			})
+
+			It("returns an error without parsing the package", func() {
+				_, _, err := ef.InstallChaincodeIfAbsent([]byte("new-cc-package"))
+				Expect(err).To(MatchError("chaincode install package exceeds the maximum size of 4 bytes"))
+				Expect(fakeParser.ParseCallCount()).To(Equal(0))
+				Expect(fakeCCStore.SaveWithContextCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the same package is installed concurrently", func() {
+			BeforeEach(func() {
+				var mutex sync.Mutex
+				var installed []chaincode.InstalledChaincode
+				fakeCCStore.SaveWithContextStub = func(_ context.Context, label string, ccInstallPkg []byte) (string, error) {
+					mutex.Lock()
+					defer mutex.Unlock()
+					installed = append(installed, chaincode.InstalledChaincode{
+						PackageID: "fake-hash",
+						Hash:      util.ComputeSHA256(ccInstallPkg),
+						Label:     label,
+					})
+					return "fake-hash", nil
+				}
+				fakeCCStore.ListInstalledChaincodesStub = func() ([]chaincode.InstalledChaincode, error) {
+					mutex.Lock()
+					defer mutex.Unlock()
+					return append([]chaincode.InstalledChaincode(nil), installed...), nil
+				}
+			})
+
+			It("installs and notifies the listener only once", func() {
+				var wg sync.WaitGroup
+				for i := 0; i < 20; i++ {
+					wg.Add(1)
+					go func() {
+						defer GinkgoRecover()
+						defer wg.Done()
+						cc, _, err := ef.InstallChaincodeIfAbsent([]byte("new-cc-package"))
+						Expect(err).NotTo(HaveOccurred())
+						Expect(cc.PackageID).To(Equal("fake-hash"))
+					}()
+				}
+				wg.Wait()
+
+				Expect(fakeCCStore.SaveWithContextCallCount()).To(Equal(1))
+				Expect(fakeListener.HandleChaincodeInstalledCallCount()).To(Equal(1))
+			})
+		})
+	})
+
+	Describe("InstallChaincodesFromDir", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "install-dir")
+			Expect(err).NotTo(HaveOccurred())
+
+			for name, contents := range map[string]string{
+				"good.tar.gz":  "good-package",
+				"bad.tar.gz":   "bad-package",
+				"ignored.json": "not-a-package",
+			} {
+				err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0600)
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			fakeParser.ParseStub = func(data []byte) (*persistence.ChaincodePackage, error) {
+				if string(data) == "bad-package" {
+					return nil, fmt.Errorf("parse-error")
+				}
+				return &persistence.ChaincodePackage{
+					Metadata: &persistence.ChaincodePackageMetadata{
+						Label: "cc-label",
+					},
+				}, nil
+			}
+			fakeCCStore.SaveWithContextReturns("cc-label:hash", nil)
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("installs every package and reports the failures", func() {
+			hashes, err := ef.InstallChaincodesFromDir(dir)
+			Expect(err).To(MatchError("could not install 1 chaincode install package(s) from '" + dir + "': bad.tar.gz: could not parse as a chaincode install package: parse-error"))
+			Expect(hashes).To(Equal(map[string][]byte{
+				"good.tar.gz": util.ComputeSHA256([]byte("good-package")),
+			}))
+			Expect(fakeParser.ParseCallCount()).To(Equal(2))
+			Expect(fakeCCStore.SaveWithContextCallCount()).To(Equal(1))
+		})
+
+		Context("when the directory cannot be read", func() {
+			It("wraps and returns the error", func() {
+				_, err := ef.InstallChaincodesFromDir(filepath.Join(dir, "missing"))
+				Expect(err).To(MatchError(ContainSubstring("could not read chaincode install package directory")))
+			})
+		})
+	})
+
+	Describe("GetInstalledChaincodePackage", func() {
+		BeforeEach(func() {
+			fakeCCStore.LoadReturns([]byte("code-package"), nil)
+		})
+
+		It("returns the chaincode install package", func() {
+			pkgBytes, err := ef.GetInstalledChaincodePackage("some-package-id")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pkgBytes).To(Equal([]byte("code-package")))
+
+			Expect(fakeCCStore.LoadCallCount()).To(Equal(1))
+			packageID := fakeCCStore.LoadArgsForCall(0)
+			Expect(packageID).To(Equal("some-package-id"))
+		})
+
+		Context("when loading the chaincode fails", func() {
+			BeforeEach(func() {
+				fakeCCStore.LoadReturns(nil, fmt.Errorf("fake-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				pkgBytes, err := ef.GetInstalledChaincodePackage("some-package-id")
+				Expect(pkgBytes).To(BeNil())
+				Expect(err).To(MatchError("could not load cc install package: fake-error"))
+			})
+		})
+	})
+
+	Describe("CheckInitCapability", func() {
+		var (
+			fakeCapabilityChecker *mock.PackageCapabilityChecker
+			testDefinition        *lifecycle.ChaincodeDefinition
+			metadata              *persistence.ChaincodePackageMetadata
+		)
+
+		BeforeEach(func() {
+			fakeCapabilityChecker = &mock.PackageCapabilityChecker{}
+			ef.PackageCapabilityChecker = fakeCapabilityChecker
+
+			testDefinition = &lifecycle.ChaincodeDefinition{
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					InitRequired: true,
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{},
+			}
+
+			metadata = &persistence.ChaincodePackageMetadata{
+				Type:  "cc-type",
+				Path:  "cc-path",
+				Label: "cc-label",
+			}
+		})
+
+		Context("when the package supports init", func() {
+			BeforeEach(func() {
+				fakeCapabilityChecker.SupportsInitReturns(true)
+			})
+
+			It("succeeds", func() {
+				err := ef.CheckInitCapability(testDefinition, metadata)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeCapabilityChecker.SupportsInitCallCount()).To(Equal(1))
+				Expect(fakeCapabilityChecker.SupportsInitArgsForCall(0)).To(Equal(metadata))
+			})
+		})
+
+		Context("when the package does not support init", func() {
+			It("flags the package", func() {
+				err := ef.CheckInitCapability(testDefinition, metadata)
+				Expect(err).To(MatchError("chaincode definition requires initialization, but package with label 'cc-label' does not support Init"))
+			})
+
+			Context("when the definition does not require init", func() {
+				BeforeEach(func() {
+					testDefinition.EndorsementInfo.InitRequired = false
+				})
+
+				It("skips the check", func() {
+					err := ef.CheckInitCapability(testDefinition, metadata)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(fakeCapabilityChecker.SupportsInitCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when no capability checker is supplied", func() {
+				BeforeEach(func() {
+					ef.PackageCapabilityChecker = nil
+				})
+
+				It("skips the check", func() {
+					err := ef.CheckInitCapability(testDefinition, metadata)
+					Expect(err).NotTo(HaveOccurred())
+				})
+			})
+		})
+	})
+
+	Describe("QueryInstalledChaincode", func() {
+		BeforeEach(func() {
+			fakeLister.GetInstalledChaincodeReturns(&chaincode.InstalledChaincode{
+				Label:     "installed-cc2",
+				PackageID: "installed-package-id2",
+				References: map[string][]*chaincode.Metadata{
+					"test-channel": {
+						&chaincode.Metadata{
+							Name:    "test-chaincode",
+							Version: "test-version",
+						},
+						&chaincode.Metadata{
+							Name:    "hello-chaincode",
+							Version: "hello-version",
+						},
+					},
+					"another-channel": {
+						&chaincode.Metadata{
+							Name:    "another-chaincode",
+							Version: "another-version",
+						},
+					},
+				},
+			}, nil)
+		})
+
+		It("returns installed chaincode information", func() {
+			result, err := ef.QueryInstalledChaincode("installed-package-id2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(
+				&chaincode.InstalledChaincode{
+					Label:     "installed-cc2",
+					PackageID: "installed-package-id2",
+					References: map[string][]*chaincode.Metadata{
+						"test-channel": {
+							&chaincode.Metadata{
+								Name:    "test-chaincode",
+								Version: "test-version",
+							},
+							&chaincode.Metadata{
+								Name:    "hello-chaincode",
+								Version: "hello-version",
+							},
+						},
+						"another-channel": {
+							&chaincode.Metadata{
+								Name:    "another-chaincode",
+								Version: "another-version",
+							},
+						},
+					},
+				},
+			))
+		})
+
+		Context("when the chaincode isn't installed", func() {
+			BeforeEach(func() {
+				fakeLister.GetInstalledChaincodeReturns(nil, errors.New("another-fake-error"))
+			})
+
+			It("returns an error", func() {
+				result, err := ef.QueryInstalledChaincode("not-there")
+				Expect(err.Error()).To(Equal("another-fake-error"))
+				Expect(result).To(BeNil())
+			})
+		})
+
+		Context("when the package ID has no hash", func() {
+			BeforeEach(func() {
+				fakeLister.ListInstalledChaincodesReturns([]*chaincode.InstalledChaincode{
+					{
+						Label:     "installed-cc1",
+						PackageID: "installed-cc1:hash1",
+					},
+					{
+						Label:     "installed-cc2",
+						PackageID: "installed-cc2:hash2",
+					},
+					{
+						Label:     "installed-cc2",
+						PackageID: "installed-cc2:hash3",
+					},
+				})
+			})
+
+			It("looks up the chaincode by label", func() {
+				result, err := ef.QueryInstalledChaincode("installed-cc1:")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result).To(Equal(&chaincode.InstalledChaincode{
+					Label:     "installed-cc1",
+					PackageID: "installed-cc1:hash1",
+				}))
+				Expect(fakeLister.GetInstalledChaincodeCallCount()).To(Equal(0))
+			})
+
+			Context("when no chaincode has the label", func() {
+				It("returns an error", func() {
+					_, err := ef.QueryInstalledChaincode("missing-cc:")
+					Expect(err).To(MatchError("could not find chaincode with label 'missing-cc'"))
+				})
+			})
+
+			Context("when several chaincodes have the label", func() {
+				It("returns an error listing the candidates", func() {
+					_, err := ef.QueryInstalledChaincode("installed-cc2:")
+					Expect(err).To(MatchError("found multiple chaincodes with label 'installed-cc2', candidate package IDs: installed-cc2:hash2, installed-cc2:hash3"))
+				})
+			})
+		})
+	})
+
+	Describe("QueryInstalledChaincodeByPackageID", func() {
+		BeforeEach(func() {
+			fakeCCStore.RetrieveHashByPackageIDReturns([]byte("hash"), nil)
+		})
+
+		It("returns the hash of the installed package", func() {
+			hash, err := ef.QueryInstalledChaincodeByPackageID("label:hash")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hash).To(Equal([]byte("hash")))
+
+			Expect(fakeCCStore.RetrieveHashByPackageIDCallCount()).To(Equal(1))
+			Expect(fakeCCStore.RetrieveHashByPackageIDArgsForCall(0)).To(Equal("label:hash"))
+		})
+
+		Context("when the package cannot be found", func() {
+			BeforeEach(func() {
+				fakeCCStore.RetrieveHashByPackageIDReturns(nil, errors.New("fake-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				hash, err := ef.QueryInstalledChaincodeByPackageID("label:hash")
+				Expect(err).To(MatchError("could not retrieve hash for cc install package: fake-error"))
+				Expect(hash).To(BeNil())
+			})
+		})
+	})
+
+	Describe("VerifyInstalledChaincode", func() {
+		BeforeEach(func() {
+			fakeCCStore.LoadReturns([]byte("package"), nil)
+			fakeCCStore.RetrieveHashByPackageIDReturns(util.ComputeSHA256([]byte("package")), nil)
+		})
+
+		It("verifies the package against its stored hash", func() {
+			ok, err := ef.VerifyInstalledChaincode("label:hash")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+
+			Expect(fakeCCStore.LoadArgsForCall(0)).To(Equal("label:hash"))
+			Expect(fakeParser.ParseCallCount()).To(Equal(1))
+			Expect(fakeParser.ParseArgsForCall(0)).To(Equal([]byte("package")))
+		})
+
+		Context("when the package bytes do not match the stored hash", func() {
+			BeforeEach(func() {
+				fakeCCStore.RetrieveHashByPackageIDReturns([]byte{0x0a}, nil)
+			})
+
+			It("reports the mismatch", func() {
+				ok, err := ef.VerifyInstalledChaincode("label:hash")
+				Expect(err).To(MatchError(fmt.Sprintf("chaincode install package 'label:hash' has hash %x but was stored with hash 0a", util.ComputeSHA256([]byte("package")))))
+				Expect(ok).To(BeFalse())
+			})
+		})
+
+		Context("when the package does not parse", func() {
+			BeforeEach(func() {
+				fakeParser.ParseReturns(nil, errors.New("parse-error"))
+			})
+
+			It("reports the failure", func() {
+				ok, err := ef.VerifyInstalledChaincode("label:hash")
+				Expect(err).To(MatchError("chaincode install package 'label:hash' could not be parsed: parse-error"))
+				Expect(ok).To(BeFalse())
+			})
+		})
+
+		Context("when the package cannot be loaded", func() {
+			BeforeEach(func() {
+				fakeCCStore.LoadReturns(nil, errors.New("load-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				ok, err := ef.VerifyInstalledChaincode("label:hash")
+				Expect(err).To(MatchError("could not load cc install package: load-error"))
+				Expect(ok).To(BeFalse())
+			})
+		})
+
+		Context("when the stored hash cannot be retrieved", func() {
+			BeforeEach(func() {
+				fakeCCStore.RetrieveHashByPackageIDReturns(nil, errors.New("hash-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				ok, err := ef.VerifyInstalledChaincode("label:hash")
+				Expect(err).To(MatchError("could not retrieve hash for package 'label:hash': hash-error"))
+				Expect(ok).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("QueryInstalledChaincodes", func() {
+		var chaincodes []*chaincode.InstalledChaincode
+
+		BeforeEach(func() {
+			chaincodes = []*chaincode.InstalledChaincode{
+				{
+					Label:     "installed-cc1",
+					PackageID: "installed-package-id1",
+				},
+				{
+					Label:     "installed-cc2",
+					PackageID: "installed-package-id2",
+					References: map[string][]*chaincode.Metadata{
+						"test-channel": {
+							&chaincode.Metadata{
+								Name:    "test-chaincode",
+								Version: "test-version",
+							},
+							&chaincode.Metadata{
+								Name:    "hello-chaincode",
+								Version: "hello-version",
+							},
+						},
+						"another-channel": {
+							&chaincode.Metadata{
+								Name:    "another-chaincode",
+								Version: "another-version",
+							},
+						},
+					},
+				},
+			}
+
+			fakeLister.ListInstalledChaincodesReturns(chaincodes)
+		})
+
+		It("passes through to the cache", func() {
+			result := ef.QueryInstalledChaincodes()
+			Expect(result).To(ConsistOf(
+				&chaincode.InstalledChaincode{
+					Label:     "installed-cc1",
+					PackageID: "installed-package-id1",
+				},
+				&chaincode.InstalledChaincode{
+					Label:     "installed-cc2",
+					PackageID: "installed-package-id2",
+					References: map[string][]*chaincode.Metadata{
+						"test-channel": {
+							&chaincode.Metadata{
+								Name:    "test-chaincode",
+								Version: "test-version",
+							},
+							&chaincode.Metadata{
+								Name:    "hello-chaincode",
+								Version: "hello-version",
+							},
+						},
+						"another-channel": {
+							&chaincode.Metadata{
+								Name:    "another-chaincode",
+								Version: "another-version",
+							},
+						},
+					},
+				},
+			))
+		})
+	})
+
+	Describe("CountInstalledChaincodes", func() {
+		BeforeEach(func() {
+			fakeCCStore.CountReturns(3, nil)
+		})
+
+		It("passes through to the chaincode store", func() {
+			count, err := ef.CountInstalledChaincodes()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count).To(Equal(3))
+			Expect(fakeCCStore.CountCallCount()).To(Equal(1))
+			Expect(fakeCCStore.ListInstalledChaincodesCallCount()).To(Equal(0))
+		})
+
+		Context("when the chaincode store cannot count the installed chaincodes", func() {
+			BeforeEach(func() {
+				fakeCCStore.CountReturns(0, fmt.Errorf("fake-count-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				_, err := ef.CountInstalledChaincodes()
+				Expect(err).To(MatchError("could not count installed chaincodes: fake-count-error"))
+			})
+		})
+	})
+
+	Describe("QueryInstalledChaincodesByLabel", func() {
+		BeforeEach(func() {
+			fakeCCStore.ListInstalledChaincodesReturns([]chaincode.InstalledChaincode{
+				{
+					Label:     "mycc",
+					PackageID: "mycc:hash1",
+				},
+				{
+					Label:     "mycc-v2",
+					PackageID: "mycc-v2:hash2",
+				},
+				{
+					Label:     "MyCC",
+					PackageID: "MyCC:hash3",
+				},
+				{
+					Label:     "othercc",
+					PackageID: "othercc:hash4",
+				},
+			}, nil)
+		})
+
+		It("returns the chaincodes whose label matches the prefix", func() {
+			result, err := ef.QueryInstalledChaincodesByLabel("mycc")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal([]chaincode.InstalledChaincode{
+				{
+					Label:     "mycc",
+					PackageID: "mycc:hash1",
+				},
+				{
+					Label:     "mycc-v2",
+					PackageID: "mycc-v2:hash2",
+				},
+			}))
+		})
+
+		Context("when the prefix is empty", func() {
+			It("returns all installed chaincodes", func() {
+				result, err := ef.QueryInstalledChaincodesByLabel("")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result).To(HaveLen(4))
+			})
+		})
+
+		Context("when no label matches", func() {
+			It("returns an empty list", func() {
+				result, err := ef.QueryInstalledChaincodesByLabel("missing")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result).To(BeEmpty())
+			})
+		})
+
+		Context("when the chaincode store cannot list the installed chaincodes", func() {
+			BeforeEach(func() {
+				fakeCCStore.ListInstalledChaincodesReturns(nil, fmt.Errorf("fake-list-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				_, err := ef.QueryInstalledChaincodesByLabel("mycc")
+				Expect(err).To(MatchError("could not list installed chaincodes: fake-list-error"))
+			})
+		})
+	})
+
+	Describe("QueryInstalledChaincodesWithMetadata", func() {
+		var chaincodes []*chaincode.InstalledChaincode
+
+		BeforeEach(func() {
+			chaincodes = []*chaincode.InstalledChaincode{
+				{
+					Label:     "installed-cc1",
+					PackageID: "installed-package-id1",
+				},
+				{
+					Label:     "installed-cc2",
+					PackageID: "installed-package-id2",
+				},
+			}
+			fakeLister.ListInstalledChaincodesReturns(chaincodes)
+
+			fakeCCStore.LoadStub = func(packageID string) ([]byte, error) {
+				return []byte(packageID + "-bytes"), nil
+			}
+			fakeParser.ParseStub = func(data []byte) (*persistence.ChaincodePackage, error) {
+				return &persistence.ChaincodePackage{
+					Metadata: &persistence.ChaincodePackageMetadata{
+						Type:  "cc-type",
+						Path:  string(data),
+						Label: "cc-label",
+					},
+				}, nil
+			}
+		})
+
+		It("returns the installed chaincodes with their package metadata", func() {
+			result := ef.QueryInstalledChaincodesWithMetadata()
+			Expect(result).To(Equal([]*lifecycle.InstalledChaincodeWithMetadata{
+				{
+					InstalledChaincode: chaincodes[0],
+					Metadata: &persistence.ChaincodePackageMetadata{
+						Type:  "cc-type",
+						Path:  "installed-package-id1-bytes",
+						Label: "cc-label",
+					},
+				},
+				{
+					InstalledChaincode: chaincodes[1],
+					Metadata: &persistence.ChaincodePackageMetadata{
+						Type:  "cc-type",
+						Path:  "installed-package-id2-bytes",
+						Label: "cc-label",
+					},
+				},
+			}))
+		})
+
+		Context("when a package cannot be loaded", func() {
+			BeforeEach(func() {
+				fakeCCStore.LoadStub = func(packageID string) ([]byte, error) {
+					if packageID == "installed-package-id1" {
+						return nil, errors.New("load-error")
+					}
+					return []byte(packageID + "-bytes"), nil
+				}
+			})
+
+			It("reports the error on the entry and lists the rest", func() {
+				result := ef.QueryInstalledChaincodesWithMetadata()
+				Expect(result).To(HaveLen(2))
+				Expect(result[0].Metadata).To(BeNil())
+				Expect(result[0].Err).To(MatchError("could not load cc install package 'installed-package-id1': load-error"))
+				Expect(result[1].Err).NotTo(HaveOccurred())
+				Expect(result[1].Metadata.Path).To(Equal("installed-package-id2-bytes"))
+			})
+		})
+
+		Context("when a package is corrupt", func() {
+			BeforeEach(func() {
+				fakeParser.ParseStub = func(data []byte) (*persistence.ChaincodePackage, error) {
+					if string(data) == "installed-package-id2-bytes" {
+						return nil, errors.New("parse-error")
+					}
+					return &persistence.ChaincodePackage{
+						Metadata: &persistence.ChaincodePackageMetadata{},
+					}, nil
+				}
+			})
+
+			It("reports the error on the entry and lists the rest", func() {
+				result := ef.QueryInstalledChaincodesWithMetadata()
+				Expect(result).To(HaveLen(2))
+				Expect(result[0].Err).NotTo(HaveOccurred())
+				Expect(result[1].Metadata).To(BeNil())
+				Expect(result[1].Err).To(MatchError("could not parse cc install package 'installed-package-id2': parse-error"))
+			})
+		})
+
+		Context("when a package has no metadata", func() {
+			BeforeEach(func() {
+				fakeParser.ParseReturns(&persistence.ChaincodePackage{}, nil)
+			})
+
+			It("reports the error on the entry", func() {
+				result := ef.QueryInstalledChaincodesWithMetadata()
+				Expect(result).To(HaveLen(2))
+				Expect(result[0].Err).To(MatchError("empty metadata for cc install package 'installed-package-id1'"))
+			})
+		})
+	})
+
+	Describe("ApproveChaincodeDefinitionForOrg", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+			fakeOrgState    *mock.ReadWritableState
+
+			fakeOrgKVStore    MapLedgerShim
+			fakePublicKVStore MapLedgerShim
+
+			testDefinition *lifecycle.ChaincodeDefinition
+		)
+
+		BeforeEach(func() {
+			testDefinition = &lifecycle.ChaincodeDefinition{
+				Sequence: 5,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "my endorsement plugin",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "my validation plugin",
+					ValidationParameter: []byte("some awesome policy"),
+				},
+				Collections: &pb.CollectionConfigPackage{},
+			}
+
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicKVStore = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.PutStateStub = fakePublicKVStore.PutState
+			fakePublicState.GetStateStub = fakePublicKVStore.GetState
+
+			fakeOrgKVStore = MapLedgerShim(map[string][]byte{})
+			fakeOrgState = &mock.ReadWritableState{}
+			fakeOrgState.PutStateStub = fakeOrgKVStore.PutState
+			fakeOrgState.GetStateStub = fakeOrgKVStore.GetState
+
+			err := resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
+				Sequence: 4,
+			}, fakePublicKVStore)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("serializes the chaincode parameters to the org scoped collection", func() {
+			err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+			Expect(err).NotTo(HaveOccurred())
+
+			metadata, ok, err := resources.Serializer.DeserializeMetadata("namespaces", "cc-name#5", fakeOrgState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			committedDefinition := &lifecycle.ChaincodeParameters{}
+			err = resources.Serializer.Deserialize("namespaces", "cc-name#5", metadata, committedDefinition, fakeOrgState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(committedDefinition.EndorsementInfo.Version).To(Equal("version"))
+			Expect(committedDefinition.EndorsementInfo.EndorsementPlugin).To(Equal("my endorsement plugin"))
+			Expect(proto.Equal(committedDefinition.ValidationInfo, &lb.ChaincodeValidationInfo{
+				ValidationPlugin:    "my validation plugin",
+				ValidationParameter: []byte("some awesome policy"),
+			})).To(BeTrue())
+			Expect(proto.Equal(committedDefinition.Collections, &pb.CollectionConfigPackage{})).To(BeTrue())
+
+			metadata, ok, err = resources.Serializer.DeserializeMetadata("chaincode-sources", "cc-name#5", fakeOrgState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			localPackage := &lifecycle.ChaincodeLocalPackage{}
+			err = resources.Serializer.Deserialize("chaincode-sources", "cc-name#5", metadata, localPackage, fakeOrgState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(localPackage).To(Equal(&lifecycle.ChaincodeLocalPackage{
+				PackageID: "hash",
+			}))
+		})
+
+		It("does not record approval attempts by default", func() {
+			err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+			Expect(err).NotTo(HaveOccurred())
+			for key := range fakeOrgKVStore {
+				Expect(key).NotTo(HavePrefix("namespaces/approval-log/"))
+			}
+		})
+
+		Context("when the approval audit log is enabled", func() {
+			var txOrgState *TxOrgState
+
+			BeforeEach(func() {
+				ef.ApprovalAuditLog = true
+				fakeOrgState.GetStateRangeStub = fakeOrgKVStore.GetStateRange
+				fakeOrgState.GetStateHashStub = fakeOrgKVStore.GetStateHash
+				txOrgState = &TxOrgState{
+					ReadWritableState: fakeOrgState,
+					TxID:              "txid-1",
+					TxTimestamp:       &timestamp.Timestamp{Seconds: 1, Nanos: 10},
+				}
+			})
+
+			It("records each approval attempt alongside the approval", func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, txOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				txOrgState.TxID = "txid-2"
+				txOrgState.TxTimestamp = &timestamp.Timestamp{Seconds: 2}
+				err = ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "other-hash", fakePublicState, txOrgState)
+				Expect(err).NotTo(HaveOccurred())
+
+				parametersHash, err := testDefinition.Parameters().Hash()
+				Expect(err).NotTo(HaveOccurred())
+
+				attempts, err := ef.QueryApprovalAttempts("cc-name", 5, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(attempts).To(Equal([]*lifecycle.ApprovalAttempt{
+					{Timestamp: 1000000010, TxID: "txid-1", PackageID: "hash", ParametersHash: parametersHash},
+					{Timestamp: 2000000000, TxID: "txid-2", PackageID: "other-hash", ParametersHash: parametersHash},
+				}))
+
+				matched, err := resources.Serializer.IsSerialized("namespaces", "cc-name#5", testDefinition.Parameters(), fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(matched).To(BeTrue())
+			})
+
+			It("writes the same entry for every endorsement of the approval", func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, txOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				firstKey, firstValue := fakeOrgState.PutStateArgsForCall(fakeOrgState.PutStateCallCount() - 1)
+
+				otherKVStore := MapLedgerShim(map[string][]byte{})
+				otherOrgState := &mock.ReadWritableState{}
+				otherOrgState.GetStateStub = otherKVStore.GetState
+				otherOrgState.PutStateStub = otherKVStore.PutState
+				txOrgState.ReadWritableState = otherOrgState
+				err = ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, txOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				secondKey, secondValue := otherOrgState.PutStateArgsForCall(otherOrgState.PutStateCallCount() - 1)
+
+				Expect(firstKey).To(Equal("namespaces/approval-log/cc-name#5/00000000001000000010/txid-1"))
+				Expect(secondKey).To(Equal(firstKey))
+				Expect(secondValue).To(Equal(firstValue))
+			})
+
+			It("returns no attempts for a sequence which was not approved", func() {
+				attempts, err := ef.QueryApprovalAttempts("cc-name", 6, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(attempts).To(BeEmpty())
+			})
+
+			Context("when the org state does not identify the transaction", func() {
+				It("returns an error", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).To(MatchError("could not record approval attempt for cc-name#5: org state does not identify the transaction"))
+				})
+			})
+
+			Context("when the approval log cannot be ranged over", func() {
+				BeforeEach(func() {
+					fakeOrgState.GetStateRangeReturns(nil, fmt.Errorf("range-error"))
+				})
+
+				It("wraps and returns the error", func() {
+					_, err := ef.QueryApprovalAttempts("cc-name", 5, fakeOrgState)
+					Expect(err).To(MatchError("could not get state range for approval attempts of cc-name#5: range-error"))
+				})
+			})
+		})
+
+		Context("when collection members are required to be in the channel", func() {
+			var fakeMSPManager *mock.MSPManager
+
+			BeforeEach(func() {
+				resources.RequireCollectionMembersInChannel = true
+				fakeMSPManager = &mock.MSPManager{}
+				fakeMSPManager.GetMSPsReturns(map[string]msp.MSP{"org0": &mock.MSP{}}, nil)
+				fakeChannelConfig.MSPManagerReturns(fakeMSPManager)
+				testDefinition.Collections = &pb.CollectionConfigPackage{
+					Config: []*pb.CollectionConfig{
+						{
+							Payload: &pb.CollectionConfig_StaticCollectionConfig{
+								StaticCollectionConfig: &pb.StaticCollectionConfig{
+									Name: "collection-name",
+									MemberOrgsPolicy: &pb.CollectionPolicyConfig{
+										Payload: &pb.CollectionPolicyConfig_SignaturePolicy{
+											SignaturePolicy: policydsl.SignedByAnyMember([]string{"org0"}),
+										},
+									},
+								},
+							},
+						},
+					},
+				}
+			})
+
+			It("accepts collections whose members are in the channel", func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			Context("when a collection member is not in the channel", func() {
+				BeforeEach(func() {
+					fakeMSPManager.GetMSPsReturns(map[string]msp.MSP{"org1": &mock.MSP{}}, nil)
+				})
+
+				It("names the collection and the member", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).To(MatchError("invalid collection configuration: collection-name: collection-name -- collection member 'org0' is not part of the channel"))
+					Expect(fakeOrgState.PutStateCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when the MSPs cannot be retrieved", func() {
+				BeforeEach(func() {
+					fakeMSPManager.GetMSPsReturns(nil, fmt.Errorf("msp-error"))
+				})
+
+				It("wraps and returns the error", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).To(MatchError("invalid collection configuration: could not get MSPs: msp-error"))
+				})
+			})
+
+			Context("when the channel has no MSP manager", func() {
+				BeforeEach(func() {
+					fakeChannelConfig.MSPManagerReturns(nil)
+				})
+
+				It("returns an error", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).To(MatchError("invalid collection configuration: could not get MSP manager for channel 'my-channel'"))
+				})
+			})
+		})
+
+		Context("when a validation policy is required", func() {
+			BeforeEach(func() {
+				resources.RequireValidationPolicy = true
+				testDefinition.ValidationInfo.ValidationPlugin = "vscc"
+			})
+
+			It("accepts a non-empty policy for the builtin validation plugin", func() {
+				testDefinition.ValidationInfo.ValidationParameter = protoutil.MarshalOrPanic(&pb.ApplicationPolicy{
+					Type: &pb.ApplicationPolicy_SignaturePolicy{
+						SignaturePolicy: policydsl.SignedByMspMember("org0"),
+					},
+				})
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("rejects an empty signature policy for the builtin validation plugin", func() {
+				testDefinition.ValidationInfo.ValidationParameter = protoutil.MarshalOrPanic(&pb.ApplicationPolicy{
+					Type: &pb.ApplicationPolicy_SignaturePolicy{
+						SignaturePolicy: &cb.SignaturePolicyEnvelope{},
+					},
+				})
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("validation parameter for the builtin validation plugin must be a non-empty application policy"))
+				Expect(fakeOrgState.PutStateCallCount()).To(Equal(0))
+			})
+
+			It("rejects a validation parameter which is not an application policy", func() {
+				testDefinition.ValidationInfo.ValidationParameter = []byte("garbage")
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError(ContainSubstring("validation parameter for the builtin validation plugin is not an application policy")))
+			})
+
+			It("does not check the parameter of other validation plugins", func() {
+				testDefinition.ValidationInfo.ValidationPlugin = "my validation plugin"
+				testDefinition.ValidationInfo.ValidationParameter = []byte("garbage")
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when an approval endorsement listener is configured", func() {
+			var fakeApprovalEndorsementListener *mock.ApprovalEndorsementListener
+
+			BeforeEach(func() {
+				fakeApprovalEndorsementListener = &mock.ApprovalEndorsementListener{}
+				ef.ApprovalEndorsementListener = fakeApprovalEndorsementListener
+			})
+
+			It("notifies the listener of the endorsed approval", func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeApprovalEndorsementListener.HandleChaincodeApprovalEndorsedCallCount()).To(Equal(1))
+				name, cd, packageID := fakeApprovalEndorsementListener.HandleChaincodeApprovalEndorsedArgsForCall(0)
+				Expect(name).To(Equal("cc-name"))
+				Expect(cd).To(Equal(testDefinition))
+				Expect(packageID).To(Equal("hash"))
+			})
+
+			It("does not notify the listener when the approval fails", func() {
+				testDefinition.Sequence = 7
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).To(HaveOccurred())
+				Expect(fakeApprovalEndorsementListener.HandleChaincodeApprovalEndorsedCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when no package ID is supplied", func() {
+			It("approves the definition without a package", func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+
+				packageID, ok, err := ef.QueryChaincodeSourcePackageID("cc-name", 5, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeFalse())
+				Expect(packageID).To(BeEmpty())
+			})
+
+			Context("when a package is required", func() {
+				BeforeEach(func() {
+					resources.RequirePackage = true
+				})
+
+				It("returns an error", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "", fakePublicState, fakeOrgState)
+					Expect(err).To(MatchError("a package ID is required to approve chaincode cc-name"))
+					Expect(fakeOrgState.PutStateCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		Context("when version monotonicity is enforced", func() {
+			BeforeEach(func() {
+				resources.EnforceVersionMonotonic = true
+				err := resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
+					Sequence: 4,
+					EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+						Version: "2.0.0",
+					},
+				}, fakePublicKVStore)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("rejects a lower version than the committed version", func() {
+				testDefinition.EndorsementInfo.Version = "1.9.3"
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("requested version '1.9.3' is lower than the committed version '2.0.0'"))
+				Expect(fakeOrgState.PutStateCallCount()).To(Equal(0))
+			})
+
+			It("accepts a higher version than the committed version", func() {
+				testDefinition.EndorsementInfo.Version = "2.1.0"
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("does not check versions which are not semver", func() {
+				testDefinition.EndorsementInfo.Version = "version"
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when the definition changes InitRequired from the committed definition", func() {
+			approvedInitRequired := func() bool {
+				metadata, ok, err := resources.Serializer.DeserializeMetadata("namespaces", "cc-name#5", fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeTrue())
+				approvedParameters := &lifecycle.ChaincodeParameters{}
+				err = resources.Serializer.Deserialize("namespaces", "cc-name#5", metadata, approvedParameters, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				return approvedParameters.EndorsementInfo.InitRequired
+			}
+
+			BeforeEach(func() {
+				testDefinition.EndorsementInfo.InitRequired = true
+			})
+
+			It("approves the definition", func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(approvedInitRequired()).To(BeTrue())
+			})
+
+			Context("when InitRequired changes are rejected", func() {
+				BeforeEach(func() {
+					resources.RejectInitRequiredChange = true
+				})
+
+				It("returns an error", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).To(MatchError("attempted to change InitRequired from false to true for namespace cc-name without explicitly allowing it"))
+					Expect(fakeOrgState.PutStateCallCount()).To(Equal(0))
+				})
+
+				It("approves the definition when the change is explicitly allowed", func() {
+					err := ef.ApproveChaincodeDefinitionForOrgWithInitChange("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState, true)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(approvedInitRequired()).To(BeTrue())
+				})
+			})
+		})
+
+		Context("when the peer sets defaults", func() {
+			BeforeEach(func() {
+				testDefinition.EndorsementInfo.EndorsementPlugin = ""
+				testDefinition.ValidationInfo.ValidationPlugin = ""
+				testDefinition.ValidationInfo.ValidationParameter = nil
+			})
+
+			It("uses appropriate defaults", func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+
+				metadata, ok, err := resources.Serializer.DeserializeMetadata("namespaces", "cc-name#5", fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeTrue())
+				committedDefinition := &lifecycle.ChaincodeParameters{}
+				err = resources.Serializer.Deserialize("namespaces", "cc-name#5", metadata, committedDefinition, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(committedDefinition.EndorsementInfo.Version).To(Equal("version"))
+				Expect(committedDefinition.EndorsementInfo.EndorsementPlugin).To(Equal("escc"))
+				Expect(proto.Equal(committedDefinition.ValidationInfo, &lb.ChaincodeValidationInfo{
+					ValidationPlugin: "vscc",
+					ValidationParameter: protoutil.MarshalOrPanic(
+						&pb.ApplicationPolicy{
+							Type: &pb.ApplicationPolicy_ChannelConfigPolicyReference{
+								ChannelConfigPolicyReference: "/Channel/Application/Endorsement",
+							},
+						}),
+				})).To(BeTrue())
+				Expect(proto.Equal(committedDefinition.Collections, &pb.CollectionConfigPackage{})).To(BeTrue())
+			})
+
+			Context("when no default endorsement policy is defined on thc channel", func() {
+				BeforeEach(func() {
+					fakePolicyManager.GetPolicyReturns(nil, false)
+				})
+
+				It("returns an error", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).To(MatchError(ContainSubstring("could not set defaults for chaincode definition in channel my-channel: policy '/Channel/Application/Endorsement' must be defined for channel 'my-channel' before chaincode operations can be attempted")))
+				})
+			})
+
+			Context("when obtaining a stable channel config fails", func() {
+				BeforeEach(func() {
+					fakeChannelConfigSource.GetStableChannelConfigReturns(nil)
+				})
+
+				It("returns an error", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).To(MatchError(ContainSubstring("could not get channel config for channel 'my-channel'")))
+				})
+			})
+		})
+
+		Context("when the collections are malformed", func() {
+			BeforeEach(func() {
+				testDefinition.Collections = &pb.CollectionConfigPackage{
+					Config: []*pb.CollectionConfig{
+						{
+							Payload: &pb.CollectionConfig_StaticCollectionConfig{
+								StaticCollectionConfig: &pb.StaticCollectionConfig{Name: "bad-collection"},
+							},
+						},
+					},
+				}
+			})
+
+			It("returns an error without writing to the org state", func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("invalid collection configuration: collection member policy is not set for collection 'bad-collection'"))
+				Expect(fakeOrgState.PutStateCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the collections are not listed in name order", func() {
+			var collections *pb.CollectionConfigPackage
+
+			approvedCollectionNames := func() []string {
+				metadata, ok, err := resources.Serializer.DeserializeMetadata("namespaces", "cc-name#5", fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeTrue())
+				approvedParameters := &lifecycle.ChaincodeParameters{}
+				err = resources.Serializer.Deserialize("namespaces", "cc-name#5", metadata, approvedParameters, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+
+				var names []string
+				for _, collection := range approvedParameters.Collections.Config {
+					names = append(names, collection.GetStaticCollectionConfig().Name)
+				}
+				return names
+			}
+
+			BeforeEach(func() {
+				collections = collectionsNamed("coll-b", "coll-a")
+				testDefinition.Collections = collections
+			})
+
+			It("stores the collections in the order given", func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(approvedCollectionNames()).To(Equal([]string{"coll-b", "coll-a"}))
+			})
+
+			Context("when the channel stores collections in canonical order", func() {
+				BeforeEach(func() {
+					fakeCapabilities := &mock.ApplicationCapabilities{}
+					fakeCapabilities.LifecycleCanonicalCollectionsReturns(true)
+					fakeApplicationConfig.CapabilitiesReturns(fakeCapabilities)
+				})
+
+				It("stores the collections sorted by name", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(approvedCollectionNames()).To(Equal([]string{"coll-a", "coll-b"}))
+					Expect(collections.Config[0].GetStaticCollectionConfig().Name).To(Equal("coll-b"))
+				})
+
+				It("stores the bytes serialized for the definition on the channel", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).NotTo(HaveOccurred())
+
+					serialized, err := ef.SerializeParametersBytes("my-channel", testDefinition)
+					Expect(err).NotTo(HaveOccurred())
+					kvrwSet := &kvrwset.KVRWSet{}
+					err = proto.Unmarshal(serialized, kvrwSet)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(kvrwSet.Writes[0].Key).To(Equal("metadata"))
+					Expect(kvrwSet.Writes[0].Value).To(Equal(fakeOrgKVStore["namespaces/metadata/cc-name#5"]))
+					for _, write := range kvrwSet.Writes[1:] {
+						Expect(write.Value).To(Equal(fakeOrgKVStore["namespaces/fields/cc-name#5/"+write.Key]))
+					}
+
+					unsortedParameters := testDefinition.Parameters()
+					unsortedParameters.Collections = collections
+					unsorted, err := unsortedParameters.SerializeBytes()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(unsorted).NotTo(Equal(serialized))
+				})
+			})
+		})
+
+		Context("when the current sequence is undefined and the requested sequence is 0", func() {
+			BeforeEach(func() {
+				fakePublicKVStore = map[string][]byte{}
+			})
+
+			It("returns an error", func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "unknown-name", &lifecycle.ChaincodeDefinition{}, "hash", fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("requested sequence is 0, but first definable sequence number is 1"))
+			})
+		})
+
+		Context("when the sequence number already has a committed definition", func() {
+			BeforeEach(func() {
+				err := resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
+					Sequence: 5,
+					EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+						Version:           "version",
+						EndorsementPlugin: "my endorsement plugin",
+					},
+					ValidationInfo: &lb.ChaincodeValidationInfo{
+						ValidationPlugin:    "my validation plugin",
+						ValidationParameter: []byte("some awesome policy"),
+					},
+				}, fakePublicState)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("verifies that the definition matches before writing", func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			Context("when the current definition is not found", func() {
+				BeforeEach(func() {
+					delete(fakePublicKVStore, "namespaces/metadata/cc-name")
+				})
+
+				It("returns an error", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).To(MatchError("missing metadata for currently committed sequence number (5)"))
+				})
+			})
+
+			Context("when the current definition is corrupt", func() {
+				BeforeEach(func() {
+					fakePublicKVStore["namespaces/metadata/cc-name"] = []byte("garbage")
+				})
+
+				It("returns an error", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).To(MatchError("could not fetch metadata for current definition: could not unmarshal metadata for namespace namespaces/cc-name: proto: can't skip unknown wire type 7"))
+				})
+			})
+
+			Context("when the current definition is not a chaincode", func() {
+				BeforeEach(func() {
+					fakePublicKVStore = map[string][]byte{}
+					type OtherStruct struct {
+						Sequence int64
+					}
+					err := resources.Serializer.Serialize("namespaces", "cc-name", &OtherStruct{
+						Sequence: 5,
+					}, fakePublicState)
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				It("returns an error", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).To(MatchError("could not deserialize namespace cc-name as chaincode: type name mismatch 'ChaincodeDefinition' != 'OtherStruct'"))
+				})
+			})
+
+			Context("when the current definition lists the collections in a different order", func() {
+				BeforeEach(func() {
+					err := resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
+						Sequence: 5,
+						EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+							Version:           "version",
+							EndorsementPlugin: "my endorsement plugin",
+						},
+						ValidationInfo: &lb.ChaincodeValidationInfo{
+							ValidationPlugin:    "my validation plugin",
+							ValidationParameter: []byte("some awesome policy"),
+						},
+						Collections: collectionsNamed("coll-a", "coll-b"),
+					}, fakePublicState)
+					Expect(err).NotTo(HaveOccurred())
+					testDefinition.Collections = collectionsNamed("coll-b", "coll-a")
+				})
+
+				It("returns an error without writing", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).To(MatchError("attempted to redefine the current committed sequence (5) for namespace cc-name with different parameters: Collections do not match"))
+					Expect(fakeOrgState.PutStateCallCount()).To(Equal(0))
+				})
+
+				Context("when the channel stores collections in canonical order", func() {
+					BeforeEach(func() {
+						fakeCapabilities := &mock.ApplicationCapabilities{}
+						fakeCapabilities.LifecycleCanonicalCollectionsReturns(true)
+						fakeApplicationConfig.CapabilitiesReturns(fakeCapabilities)
+					})
+
+					It("sorts the collections before verifying that the definition matches", func() {
+						err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+						Expect(err).NotTo(HaveOccurred())
+					})
+				})
+			})
+
+			Context("when the Version in the new definition differs from the current definition", func() {
+				BeforeEach(func() {
+					fakePublicKVStore = map[string][]byte{}
+
+					err := resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
+						Sequence: 5,
+						EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+							Version: "other-version",
+						},
+					}, fakePublicState)
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				It("returns an error", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).To(MatchError("attempted to redefine the current committed sequence (5) for namespace cc-name with different parameters: expected Version 'other-version' does not match passed Version 'version'"))
+				})
+			})
+		})
+
+		Context("when the sequence number already has an uncommitted definition", func() {
+			BeforeEach(func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			Context("when uncommitted definition differs from update", func() {
+				It("succeeds", func() {
+					testDefinition.ValidationInfo.ValidationParameter = []byte("more awesome policy")
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).NotTo(HaveOccurred())
+				})
+			})
+
+			Context("when uncommitted definition is identical to update", func() {
+				It("returns error", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).To(MatchError("attempted to redefine uncommitted sequence (5) for namespace cc-name with unchanged content"))
+				})
+			})
+
+			Context("when the update only reorders the collections", func() {
+				BeforeEach(func() {
+					testDefinition.Collections = collectionsNamed("coll-a", "coll-b")
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).NotTo(HaveOccurred())
+					testDefinition.Collections = collectionsNamed("coll-b", "coll-a")
+				})
+
+				It("succeeds, as the stored order changes", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				Context("when the channel stores collections in canonical order", func() {
+					BeforeEach(func() {
+						fakeCapabilities := &mock.ApplicationCapabilities{}
+						fakeCapabilities.LifecycleCanonicalCollectionsReturns(true)
+						fakeApplicationConfig.CapabilitiesReturns(fakeCapabilities)
+					})
+
+					It("returns error", func() {
+						err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+						Expect(err).To(MatchError("attempted to redefine uncommitted sequence (5) for namespace cc-name with unchanged content"))
+					})
+				})
+			})
+
+			Context("when uncommitted definition has update of only package ID", func() {
+				It("succeeds", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash2", fakePublicState, fakeOrgState)
+					Expect(err).NotTo(HaveOccurred())
+				})
+			})
+
+			Context("when deserializing chaincode-source metadata fails", func() {
+				BeforeEach(func() {
+					fakeOrgKVStore["chaincode-sources/metadata/cc-name#5"] = []byte("garbage")
+				})
+
+				It("wraps and returns the error", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).To(MatchError("could not deserialize chaincode-source metadata for cc-name#5: could not unmarshal metadata for namespace chaincode-sources/cc-name#5: proto: can't skip unknown wire type 7"))
+				})
+			})
+
+			Context("when deserializing chaincode package fails", func() {
+				BeforeEach(func() {
+					fakeOrgKVStore["chaincode-sources/fields/cc-name#5/PackageID"] = []byte("garbage")
+				})
+
+				It("wraps and returns the error", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).To(MatchError("could not deserialize chaincode package for cc-name#5: could not unmarshal state for key chaincode-sources/fields/cc-name#5/PackageID: proto: can't skip unknown wire type 7"))
+				})
+			})
+		})
+
+		Context("when the definition is for an expired sequence number", func() {
+			BeforeEach(func() {
+				testDefinition.Sequence = 3
+			})
+
+			It("fails", func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("currently defined sequence 4 is larger than requested sequence 3"))
+			})
+		})
+
+		Context("when the definition is for a distant sequence number", func() {
+			BeforeEach(func() {
+				testDefinition.Sequence = 9
+			})
+
+			It("fails", func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("requested sequence 9 is larger than the next available sequence number 5"))
+			})
+		})
+
+		Context("when a plugin registry is configured", func() {
+			var fakePluginRegistry *mock.PluginRegistry
+
+			BeforeEach(func() {
+				fakePluginRegistry = &mock.PluginRegistry{}
+				fakePluginRegistry.EndorsementPluginExistsReturns(true)
+				fakePluginRegistry.ValidationPluginExistsReturns(true)
+				resources.PluginRegistry = fakePluginRegistry
+			})
+
+			It("checks the plugins exist", func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakePluginRegistry.EndorsementPluginExistsCallCount()).To(Equal(1))
+				Expect(fakePluginRegistry.EndorsementPluginExistsArgsForCall(0)).To(Equal("my endorsement plugin"))
+				Expect(fakePluginRegistry.ValidationPluginExistsCallCount()).To(Equal(1))
+				Expect(fakePluginRegistry.ValidationPluginExistsArgsForCall(0)).To(Equal("my validation plugin"))
+			})
+
+			Context("when the plugins are the builtin plugins", func() {
+				BeforeEach(func() {
+					testDefinition.EndorsementInfo.EndorsementPlugin = ""
+					testDefinition.ValidationInfo.ValidationPlugin = ""
+				})
+
+				It("does not consult the registry", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(fakePluginRegistry.EndorsementPluginExistsCallCount()).To(Equal(0))
+					Expect(fakePluginRegistry.ValidationPluginExistsCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when the endorsement plugin is unknown", func() {
+				BeforeEach(func() {
+					fakePluginRegistry.EndorsementPluginExistsReturns(false)
+				})
+
+				It("returns an error", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).To(MatchError("endorsement plugin 'my endorsement plugin' is not registered"))
+				})
+			})
+
+			Context("when the validation plugin is unknown", func() {
+				BeforeEach(func() {
+					fakePluginRegistry.ValidationPluginExistsReturns(false)
+				})
+
+				It("returns an error", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).To(MatchError("validation plugin 'my validation plugin' is not registered"))
+				})
+			})
+		})
+
+		Context("when a custom sequence policy is configured", func() {
+			var fakeSequencePolicy *mock.SequencePolicy
+
+			BeforeEach(func() {
+				fakeSequencePolicy = &mock.SequencePolicy{}
+				resources.SequencePolicy = fakeSequencePolicy
+				testDefinition.Sequence = 2
+			})
+
+			It("consults the policy with the current and requested sequences", func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeSequencePolicy.ValidateApprovalSequenceCallCount()).To(Equal(1))
+				current, requested := fakeSequencePolicy.ValidateApprovalSequenceArgsForCall(0)
+				Expect(current).To(Equal(int64(4)))
+				Expect(requested).To(Equal(int64(2)))
+
+				_, ok, err := resources.Serializer.DeserializeMetadata("namespaces", "cc-name#2", fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeTrue())
+			})
+
+			Context("when the policy rejects the sequence", func() {
+				BeforeEach(func() {
+					fakeSequencePolicy.ValidateApprovalSequenceReturns(fmt.Errorf("sequence-policy-error"))
+				})
+
+				It("returns the error", func() {
+					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+					Expect(err).To(MatchError("sequence-policy-error"))
+				})
+			})
+		})
+
+		Context("when the sequence exceeds the maximum sequence", func() {
+			BeforeEach(func() {
+				resources.MaxSequence = 4
+			})
+
+			It("fails", func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("requested sequence 5 exceeds the maximum allowed sequence 4"))
+			})
+		})
+
+		Context("when the chaincode name is invalid", func() {
+			It("fails before touching state", func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc#name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("invalid chaincode name 'cc#name'. Names can only consist of alphanumerics, '_', and '-' and can only begin with alphanumerics"))
+				Expect(fakePublicState.GetStateCallCount()).To(Equal(0))
+				Expect(fakeOrgState.PutStateCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the sequence is at the maximum sequence", func() {
+			BeforeEach(func() {
+				resources.MaxSequence = 5
+			})
+
+			It("succeeds", func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when querying the public state fails", func() {
+			BeforeEach(func() {
+				fakePublicState.GetStateReturns(nil, fmt.Errorf("get-state-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("could not get current sequence: could not get state for key namespaces/fields/cc-name/Sequence: get-state-error"))
+			})
+		})
+
+		Context("when writing to the org state fails for the parameters", func() {
+			BeforeEach(func() {
+				fakeOrgState.PutStateReturns(fmt.Errorf("put-state-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("could not serialize chaincode parameters to state: could not write key into state: put-state-error"))
+			})
+		})
+
+		Context("when writing to the org state fails for the package", func() {
+			BeforeEach(func() {
+				fakeOrgState.PutStateReturnsOnCall(4, fmt.Errorf("put-state-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("could not serialize chaincode package info to state: could not write key into state: put-state-error"))
+			})
+		})
+	})
+
+	Describe("BatchApproveChaincodeDefinitionsForOrg", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+			fakeOrgState    *mock.ReadWritableState
+
+			fakeOrgKVStore MapLedgerShim
+
+			defs map[string]*lifecycle.ChaincodeDefinition
+		)
+
+		definition := func(sequence int64) *lifecycle.ChaincodeDefinition {
+			return &lifecycle.ChaincodeDefinition{
+				Sequence: sequence,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "my endorsement plugin",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "my validation plugin",
+					ValidationParameter: []byte("some awesome policy"),
+				},
+				Collections: &pb.CollectionConfigPackage{},
+			}
+		}
+
+		BeforeEach(func() {
+			fakePublicKVStore := MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateStub = fakePublicKVStore.GetState
+
+			fakeOrgKVStore = MapLedgerShim(map[string][]byte{})
+			fakeOrgState = &mock.ReadWritableState{}
+			fakeOrgState.PutStateStub = fakeOrgKVStore.PutState
+			fakeOrgState.GetStateStub = fakeOrgKVStore.GetState
+
+			resources.Serializer.Serialize("namespaces", "cc-one", &lifecycle.ChaincodeDefinition{Sequence: 1}, fakePublicKVStore)
+			resources.Serializer.Serialize("namespaces", "cc-two", &lifecycle.ChaincodeDefinition{Sequence: 3}, fakePublicKVStore)
+
+			defs = map[string]*lifecycle.ChaincodeDefinition{
+				"cc-one":   definition(2),
+				"cc-two":   definition(9),
+				"cc-three": definition(1),
+			}
+		})
+
+		It("approves each definition and collects the failures", func() {
+			results, err := ef.BatchApproveChaincodeDefinitionsForOrg("my-channel", defs, map[string]string{
+				"cc-one":   "package-one",
+				"cc-three": "package-three",
+			}, fakePublicState, fakeOrgState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results).To(HaveLen(1))
+			Expect(results["cc-two"]).To(MatchError("requested sequence 9 is larger than the next available sequence number 4"))
+
+			Expect(fakeOrgKVStore).To(HaveKey("namespaces/metadata/cc-one#2"))
+			Expect(fakeOrgKVStore).To(HaveKey("namespaces/metadata/cc-three#1"))
+			Expect(fakeOrgKVStore).NotTo(HaveKey("namespaces/metadata/cc-two#9"))
+
+			ccLocalPackage := &lifecycle.ChaincodeLocalPackage{}
+			err = resources.Serializer.Deserialize("chaincode-sources", "cc-three#1", &lb.StateMetadata{
+				Datatype: "ChaincodeLocalPackage",
+				Fields:   []string{"PackageID"},
+			}, ccLocalPackage, fakeOrgKVStore)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ccLocalPackage.PackageID).To(Equal("package-three"))
+		})
+
+		Context("when the public state is unreadable", func() {
+			BeforeEach(func() {
+				fakePublicState.GetStateReturns(nil, fmt.Errorf("get-state-error"))
+			})
+
+			It("returns an error without approving anything", func() {
+				_, err := ef.BatchApproveChaincodeDefinitionsForOrg("my-channel", defs, nil, fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError(ContainSubstring("get-state-error")))
+				Expect(fakeOrgState.PutStateCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("ValidateChaincodeDefinition", func() {
+		var (
+			testDefinition *lifecycle.ChaincodeDefinition
+		)
+
+		BeforeEach(func() {
+			testDefinition = &lifecycle.ChaincodeDefinition{
+				Sequence: 1,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version: "version",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationParameter: protoutil.MarshalOrPanic(&pb.ApplicationPolicy{
+						Type: &pb.ApplicationPolicy_ChannelConfigPolicyReference{
+							ChannelConfigPolicyReference: "/Channel/Application/Endorsement",
+						},
+					}),
+				},
+				Collections: &pb.CollectionConfigPackage{},
+			}
+		})
+
+		It("accepts a well formed definition", func() {
+			Expect(ef.ValidateChaincodeDefinition("cc-name", testDefinition)).To(Succeed())
+		})
+
+		Context("when the name is invalid", func() {
+			It("returns an error", func() {
+				Expect(ef.ValidateChaincodeDefinition("_lifecycle", testDefinition)).To(MatchError("chaincode name '_lifecycle' is reserved"))
+			})
+		})
+
+		Context("when the validation info is missing", func() {
+			BeforeEach(func() {
+				testDefinition.ValidationInfo = nil
+			})
+
+			It("returns an error", func() {
+				Expect(ef.ValidateChaincodeDefinition("cc-name", testDefinition)).To(MatchError("chaincode definition must specify validation info"))
+			})
+		})
+
+		Context("when the sequence is not positive", func() {
+			BeforeEach(func() {
+				testDefinition.Sequence = 0
+			})
+
+			It("returns an error", func() {
+				Expect(ef.ValidateChaincodeDefinition("cc-name", testDefinition)).To(MatchError("chaincode definition sequence 0 must be greater than zero"))
+			})
+		})
+
+		Context("when a collection is malformed", func() {
+			BeforeEach(func() {
+				testDefinition.Collections.Config = []*pb.CollectionConfig{
+					{
+						Payload: &pb.CollectionConfig_StaticCollectionConfig{
+							StaticCollectionConfig: &pb.StaticCollectionConfig{},
+						},
+					},
+				}
+			})
+
+			It("returns an error", func() {
+				Expect(ef.ValidateChaincodeDefinition("cc-name", testDefinition)).To(MatchError(HavePrefix("invalid collection configuration: ")))
+			})
+		})
+
+		Context("when the validation parameter of the builtin plugin does not parse", func() {
+			BeforeEach(func() {
+				testDefinition.ValidationInfo.ValidationParameter = []byte("garbage")
+			})
+
+			It("returns an error", func() {
+				Expect(ef.ValidateChaincodeDefinition("cc-name", testDefinition)).To(MatchError(HavePrefix("validation parameter for the builtin validation plugin is not an application policy: ")))
+			})
+
+			It("accepts it for a custom validation plugin", func() {
+				testDefinition.ValidationInfo.ValidationPlugin = "custom-vscc"
+				Expect(ef.ValidateChaincodeDefinition("cc-name", testDefinition)).To(Succeed())
+			})
+		})
+	})
+
+	Describe("SimulateApproveChaincodeDefinitionForOrg", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+			fakeOrgState    *mock.ReadWritableState
+
+			fakeOrgKVStore    MapLedgerShim
+			fakePublicKVStore MapLedgerShim
+
+			testDefinition *lifecycle.ChaincodeDefinition
+		)
+
+		BeforeEach(func() {
+			testDefinition = &lifecycle.ChaincodeDefinition{
+				Sequence: 5,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "my endorsement plugin",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "my validation plugin",
+					ValidationParameter: []byte("some awesome policy"),
+				},
+				Collections: &pb.CollectionConfigPackage{},
+			}
+
+			fakePublicKVStore = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateStub = fakePublicKVStore.GetState
+
+			fakeOrgKVStore = MapLedgerShim(map[string][]byte{})
+			fakeOrgState = &mock.ReadWritableState{}
+			fakeOrgState.PutStateStub = fakeOrgKVStore.PutState
+			fakeOrgState.GetStateStub = fakeOrgKVStore.GetState
+
+			err := resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
+				Sequence: 4,
+			}, fakePublicKVStore)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("validates the definition without writing to the org state", func() {
+			err := ef.SimulateApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeOrgState.PutStateCallCount()).To(Equal(0))
+			Expect(fakeOrgKVStore).To(BeEmpty())
+		})
+
+		Context("when the definition is for a distant sequence number", func() {
+			BeforeEach(func() {
+				testDefinition.Sequence = 9
+			})
+
+			It("fails", func() {
+				err := ef.SimulateApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("requested sequence 9 is larger than the next available sequence number 5"))
+			})
+		})
+
+		Context("when the definition is for the current sequence with different parameters", func() {
+			BeforeEach(func() {
+				testDefinition.Sequence = 4
+			})
+
+			It("fails", func() {
+				err := ef.SimulateApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("attempted to redefine the current committed sequence (4) for namespace cc-name with different parameters: expected Version '' does not match passed Version 'version'"))
+			})
+		})
+
+		Context("when the uncommitted definition is unchanged", func() {
+			BeforeEach(func() {
+				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("fails", func() {
+				err := ef.SimulateApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("attempted to redefine uncommitted sequence (5) for namespace cc-name with unchanged content"))
+			})
+		})
+	})
+
+	Describe("CheckCommitReadiness", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+			fakeOrgStates   []*mock.ReadWritableState
+
+			testDefinition *lifecycle.ChaincodeDefinition
+
+			publicKVS, org0KVS, org1KVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			testDefinition = &lifecycle.ChaincodeDefinition{
+				Sequence: 5,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "endorsement-plugin",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: []byte("validation-parameter"),
+				},
+			}
+
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateStub = publicKVS.GetState
+			fakePublicState.PutStateStub = publicKVS.PutState
+
+			resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
+				Sequence: 4,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "endorsement-plugin",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: []byte("validation-parameter"),
+				},
+			}, publicKVS)
+
+			org0KVS = MapLedgerShim(map[string][]byte{})
+			org1KVS = MapLedgerShim(map[string][]byte{})
+			fakeOrg0State := &mock.ReadWritableState{}
+			fakeOrg0State.CollectionNameReturns("_implicit_org_org0")
+			fakeOrg1State := &mock.ReadWritableState{}
+			fakeOrg1State.CollectionNameReturns("_implicit_org_org1")
+			fakeOrgStates = []*mock.ReadWritableState{
+				fakeOrg0State,
+				fakeOrg1State,
+			}
+			for i, kvs := range []MapLedgerShim{org0KVS, org1KVS} {
+				kvs := kvs
+				fakeOrgStates[i].GetStateStub = kvs.GetState
+				fakeOrgStates[i].GetStateHashStub = kvs.GetStateHash
+				fakeOrgStates[i].PutStateStub = kvs.PutState
+			}
+
+			resources.Serializer.Serialize("namespaces", "cc-name#5", testDefinition.Parameters(), fakeOrgStates[0])
+			resources.Serializer.Serialize("namespaces", "cc-name#5", &lifecycle.ChaincodeParameters{}, fakeOrgStates[1])
+		})
+
+		It("checks the commit readiness of a chaincode definition and returns the approvals", func() {
+			approvals, err := ef.CheckCommitReadiness("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(approvals).To(Equal(map[string]bool{
+				"org0": true,
+				"org1": false,
+			}))
+		})
+
+		Context("when IsSerialized fails", func() {
+			BeforeEach(func() {
+				fakeOrgStates[0].GetStateHashReturns(nil, errors.New("bad bad failure"))
+			})
+
+			It("wraps and returns an error", func() {
+				_, err := ef.CheckCommitReadiness("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).To(MatchError(ContainSubstring("serialization check failed for key cc-name#5: could not get value for key namespaces/metadata/cc-name#5: bad bad failure")))
+			})
+		})
+
+		Context("when the peer sets defaults", func() {
+			BeforeEach(func() {
+				testDefinition.EndorsementInfo.EndorsementPlugin = "escc"
+				testDefinition.ValidationInfo.ValidationPlugin = "vscc"
+				testDefinition.ValidationInfo.ValidationParameter = protoutil.MarshalOrPanic(
+					&pb.ApplicationPolicy{
+						Type: &pb.ApplicationPolicy_ChannelConfigPolicyReference{
+							ChannelConfigPolicyReference: "/Channel/Application/Endorsement",
+						},
+					})
+
+				resources.Serializer.Serialize("namespaces", "cc-name#5", testDefinition.Parameters(), fakeOrgStates[0])
+
+				testDefinition.EndorsementInfo.EndorsementPlugin = ""
+				testDefinition.ValidationInfo.ValidationPlugin = ""
+				testDefinition.ValidationInfo.ValidationParameter = nil
+
+				resources.Serializer.Serialize("namespaces", "cc-name#5", testDefinition.Parameters(), fakeOrgStates[1])
+			})
+
+			It("applies the chaincode definition and returns the approvals", func() {
+				approvals, err := ef.CheckCommitReadiness("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(approvals).To(Equal(map[string]bool{
+					"org0": true,
+					"org1": false,
+				}))
+			})
+
+			Context("when no default endorsement policy is defined on the channel", func() {
+				BeforeEach(func() {
+					fakePolicyManager.GetPolicyReturns(nil, false)
+				})
+
+				It("returns an error", func() {
+					_, err := ef.CheckCommitReadiness("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+					Expect(err).To(MatchError(ContainSubstring("could not set defaults for chaincode definition in " +
+						"channel my-channel: policy '/Channel/Application/Endorsement' must be defined " +
+						"for channel 'my-channel' before chaincode operations can be attempted")))
+				})
+			})
+
+			Context("when obtaining a stable channel config fails", func() {
+				BeforeEach(func() {
+					fakeChannelConfigSource.GetStableChannelConfigReturns(nil)
+				})
+
+				It("returns an error", func() {
+					_, err := ef.CheckCommitReadiness("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+					Expect(err).To(MatchError(ContainSubstring("could not get channel config for channel 'my-channel'")))
+
+				})
+			})
+
+			Context("when the public state is not readable", func() {
+				BeforeEach(func() {
+					fakePublicState.GetStateReturns(nil, fmt.Errorf("getstate-error"))
+				})
+
+				It("wraps and returns the error", func() {
+					_, err := ef.CheckCommitReadiness("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+					Expect(err).To(MatchError("could not get current sequence: could not get state for key namespaces/fields/cc-name/Sequence: getstate-error"))
+				})
+			})
+
+			Context("when the current sequence is not immediately prior to the new", func() {
+				BeforeEach(func() {
+					resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
+						Sequence: 3,
+						EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+							Version:           "version",
+							EndorsementPlugin: "endorsement-plugin",
+						},
+						ValidationInfo: &lb.ChaincodeValidationInfo{
+							ValidationPlugin:    "validation-plugin",
+							ValidationParameter: []byte("validation-parameter"),
+						},
+					}, fakePublicState)
+				})
+
+				It("returns an error", func() {
+					_, err := ef.CheckCommitReadiness("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+					Expect(err).To(MatchError("requested sequence is 5, but new definition must be sequence 4"))
+				})
+			})
+		})
+	})
+
+	Describe("QueryApprovedChaincodeDefinition", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+			fakeOrgStates   []*mock.ReadWritableState
+
+			testDefinition            *lifecycle.ChaincodeDefinition
+			testChaincodeLocalPackage *lifecycle.ChaincodeLocalPackage
+
+			publicKVS, org0KVS, org1KVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			testDefinition = &lifecycle.ChaincodeDefinition{
+				Sequence: 4,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "endorsement-plugin",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: []byte("validation-parameter"),
+				},
+			}
+
+			testChaincodeLocalPackage = &lifecycle.ChaincodeLocalPackage{
+				PackageID: "hash",
+			}
+
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateStub = publicKVS.GetState
+			fakePublicState.PutStateStub = publicKVS.PutState
+
+			resources.Serializer.Serialize("namespaces", "cc-name", testDefinition, publicKVS)
+
+			org0KVS = MapLedgerShim(map[string][]byte{})
+			org1KVS = MapLedgerShim(map[string][]byte{})
+			fakeOrg0State := &mock.ReadWritableState{}
+			fakeOrg0State.CollectionNameReturns("_implicit_org_org0")
+			fakeOrg1State := &mock.ReadWritableState{}
+			fakeOrg1State.CollectionNameReturns("_implicit_org_org1")
+			fakeOrgStates = []*mock.ReadWritableState{
+				fakeOrg0State,
+				fakeOrg1State,
+			}
+			for i, kvs := range []MapLedgerShim{org0KVS, org1KVS} {
+				kvs := kvs
+				fakeOrgStates[i].GetStateStub = kvs.GetState
+				fakeOrgStates[i].GetStateHashStub = kvs.GetStateHash
+				fakeOrgStates[i].PutStateStub = kvs.PutState
+			}
+
+			resources.Serializer.Serialize("namespaces", "cc-name#3", testDefinition.Parameters(), fakeOrgStates[0])
+			resources.Serializer.Serialize("chaincode-sources", "cc-name#3", testChaincodeLocalPackage, fakeOrgStates[0])
+			resources.Serializer.Serialize("namespaces", "cc-name#3", testDefinition.Parameters(), fakeOrgStates[1])
+			resources.Serializer.Serialize("chaincode-sources", "cc-name#3", testChaincodeLocalPackage, fakeOrgStates[1])
+
+			resources.Serializer.Serialize("namespaces", "cc-name#4", testDefinition.Parameters(), fakeOrgStates[0])
+			resources.Serializer.Serialize("chaincode-sources", "cc-name#4", testChaincodeLocalPackage, fakeOrgStates[0])
+			resources.Serializer.Serialize("namespaces", "cc-name#4", testDefinition.Parameters(), fakeOrgStates[1])
+			resources.Serializer.Serialize("chaincode-sources", "cc-name#4", testChaincodeLocalPackage, fakeOrgStates[1])
+		})
+
+		It("returns the approved chaincode", func() {
+			cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 3, fakePublicState, fakeOrgStates[0])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cc.Sequence).To(Equal(int64(3)))
+			Expect(proto.Equal(cc.EndorsementInfo, &lb.ChaincodeEndorsementInfo{
+				Version:           "version",
+				EndorsementPlugin: "endorsement-plugin",
+			})).To(BeTrue())
+			Expect(proto.Equal(cc.ValidationInfo, &lb.ChaincodeValidationInfo{
+				ValidationPlugin:    "validation-plugin",
+				ValidationParameter: []byte("validation-parameter"),
+			})).To(BeTrue())
+			Expect(proto.Equal(cc.Collections, &pb.CollectionConfigPackage{})).To(BeTrue())
+			Expect(proto.Equal(cc.Source, &lb.ChaincodeSource{
+				Type: &lb.ChaincodeSource_LocalPackage{
+					LocalPackage: &lb.ChaincodeSource_Local{
+						PackageId: "hash",
+					},
+				},
+			})).To(BeTrue())
+		})
+
+		Context("when the next sequence is not defined and the sequence argument is not provided", func() {
+
+			It("returns the approved chaincode for the current sequence", func() {
+				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 0, fakePublicState, fakeOrgStates[0])
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cc.Sequence).To(Equal(int64(4)))
+				Expect(proto.Equal(cc.EndorsementInfo, &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "endorsement-plugin",
+				})).To(BeTrue())
+				Expect(proto.Equal(cc.ValidationInfo, &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: []byte("validation-parameter"),
+				})).To(BeTrue())
+				Expect(proto.Equal(cc.Collections, &pb.CollectionConfigPackage{})).To(BeTrue())
+				Expect(proto.Equal(cc.Source, &lb.ChaincodeSource{
+					Type: &lb.ChaincodeSource_LocalPackage{
+						LocalPackage: &lb.ChaincodeSource_Local{
+							PackageId: "hash",
+						},
+					},
+				})).To(BeTrue())
+			})
+		})
+
+		Context("when the next sequence is defined and the sequence argument is not provided", func() {
+			BeforeEach(func() {
+				testChaincodeLocalPackage.PackageID = ""
+				resources.Serializer.Serialize("namespaces", "cc-name#5", testDefinition.Parameters(), fakeOrgStates[0])
+				resources.Serializer.Serialize("chaincode-sources", "cc-name#5", testChaincodeLocalPackage, fakeOrgStates[0])
+			})
+
+			It("returns the approved chaincode for the next sequence", func() {
+				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 0, fakePublicState, fakeOrgStates[0])
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cc.Sequence).To(Equal(int64(5)))
+				Expect(proto.Equal(cc.EndorsementInfo, &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "endorsement-plugin",
+				})).To(BeTrue())
+				Expect(proto.Equal(cc.ValidationInfo, &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: []byte("validation-parameter"),
+				})).To(BeTrue())
+				Expect(proto.Equal(cc.Collections, &pb.CollectionConfigPackage{})).To(BeTrue())
+				Expect(proto.Equal(cc.Source, &lb.ChaincodeSource{
+					Type: &lb.ChaincodeSource_Unavailable_{
+						Unavailable: &lb.ChaincodeSource_Unavailable{},
+					},
+				})).To(BeTrue())
+			})
+
+			Context("and deserializing namespace metadata for next sequence fails", func() {
+				BeforeEach(func() {
+					org0KVS["namespaces/metadata/cc-name#5"] = []byte("garbage")
+				})
+
+				It("wraps and returns the error", func() {
+					cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 0, fakePublicState, fakeOrgStates[0])
+					Expect(err).To(MatchError("could not deserialize namespace metadata for next sequence 5: could not unmarshal metadata for namespace namespaces/cc-name#5: proto: can't skip unknown wire type 7"))
+					Expect(cc).To(BeNil())
+				})
+			})
+		})
+
+		Context("when the next sequence is defined and the sequence argument is provided", func() {
+			BeforeEach(func() {
+				testChaincodeLocalPackage.PackageID = "hash"
+				resources.Serializer.Serialize("namespaces", "cc-name#5", testDefinition.Parameters(), fakeOrgStates[0])
+				resources.Serializer.Serialize("chaincode-sources", "cc-name#5", testChaincodeLocalPackage, fakeOrgStates[0])
+			})
+
+			It("returns the approved chaincode for the next sequence", func() {
+				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 5, fakePublicState, fakeOrgStates[0])
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cc.Sequence).To(Equal(int64(5)))
+				Expect(proto.Equal(cc.EndorsementInfo, &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "endorsement-plugin",
+				})).To(BeTrue())
+				Expect(proto.Equal(cc.ValidationInfo, &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: []byte("validation-parameter"),
+				})).To(BeTrue())
+				Expect(proto.Equal(cc.Collections, &pb.CollectionConfigPackage{})).To(BeTrue())
+				Expect(proto.Equal(cc.Source, &lb.ChaincodeSource{
+					Type: &lb.ChaincodeSource_LocalPackage{
+						LocalPackage: &lb.ChaincodeSource_Local{
+							PackageId: "hash",
+						},
+					},
+				})).To(BeTrue())
+			})
+		})
+
+		Context("when the requested sequence number is out of range", func() {
+
+			It("wraps and returns the error", func() {
+				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 5, fakePublicState, fakeOrgStates[0])
+				Expect(err).To(MatchError("could not fetch approved chaincode definition (name: 'cc-name', sequence: '5') on channel 'my-channel'"))
+				Expect(cc).To(BeNil())
+			})
+		})
+
+		Context("when the sequence argument is not provided and querying the public state fails", func() {
+			BeforeEach(func() {
+				fakePublicState.GetStateReturns(nil, fmt.Errorf("get-state-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 0, fakePublicState, fakeOrgStates[0])
+				Expect(err).To(MatchError("could not get current sequence: could not get state for key namespaces/fields/cc-name/Sequence: get-state-error"))
+				Expect(cc).To(BeNil())
+			})
+		})
+
+		Context("when deserializing namespace metadata for requested sequence fails", func() {
+			BeforeEach(func() {
+				org0KVS["namespaces/metadata/cc-name#4"] = []byte("garbage")
+			})
+
+			It("wraps and returns the error", func() {
+				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 4, fakePublicState, fakeOrgStates[0])
+				Expect(err).To(MatchError("could not deserialize namespace metadata for cc-name#4: could not unmarshal metadata for namespace namespaces/cc-name#4: proto: can't skip unknown wire type 7"))
+				Expect(cc).To(BeNil())
+			})
+		})
+
+		Context("when deserializing chaincode parameters fails", func() {
+			BeforeEach(func() {
+				org0KVS["namespaces/fields/cc-name#4/EndorsementInfo"] = []byte("garbage")
+			})
+
+			It("wraps and returns the error", func() {
+				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 4, fakePublicState, fakeOrgStates[0])
+				Expect(err).To(MatchError("could not deserialize chaincode parameters for cc-name#4: could not unmarshal state for key namespaces/fields/cc-name#4/EndorsementInfo: proto: can't skip unknown wire type 7"))
+				Expect(cc).To(BeNil())
+			})
+		})
+
+		Context("when deserializing chaincode-source metadata fails", func() {
+			BeforeEach(func() {
+				org0KVS["chaincode-sources/metadata/cc-name#4"] = []byte("garbage")
+			})
+
+			It("wraps and returns the error", func() {
+				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 4, fakePublicState, fakeOrgStates[0])
+				Expect(err).To(MatchError("could not deserialize chaincode-source metadata for cc-name#4: could not unmarshal metadata for namespace chaincode-sources/cc-name#4: proto: can't skip unknown wire type 7"))
+				Expect(cc).To(BeNil())
+			})
+		})
+
+		Context("when the requested cc parameters are found but the cc source is not found due to some inconsistency", func() {
+
+			BeforeEach(func() {
+				resources.Serializer.Serialize("namespaces", "cc-name#5", testDefinition.Parameters(), fakeOrgStates[0])
+			})
+
+			It("wraps and returns the error", func() {
+				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 5, fakePublicState, fakeOrgStates[0])
+				Expect(err).To(MatchError("could not fetch approved chaincode definition (name: 'cc-name', sequence: '5') on channel 'my-channel'"))
+				Expect(cc).To(BeNil())
+			})
+		})
+
+		Context("when deserializing chaincode package fails", func() {
+			BeforeEach(func() {
+				org0KVS["chaincode-sources/fields/cc-name#4/PackageID"] = []byte("garbage")
+			})
+
+			It("wraps and returns the error", func() {
+				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 4, fakePublicState, fakeOrgStates[0])
+				Expect(err).To(MatchError("could not deserialize chaincode package for cc-name#4: could not unmarshal state for key chaincode-sources/fields/cc-name#4/PackageID: proto: can't skip unknown wire type 7"))
+				Expect(cc).To(BeNil())
+			})
+		})
+
+		Context("when the metadata is not for chaincode parameters", func() {
+			BeforeEach(func() {
+				type badStruct struct{}
+				resources.Serializer.Serialize("namespaces", "cc-name#4", &badStruct{}, fakeOrgStates[0])
+			})
+
+			It("wraps and returns the error", func() {
+				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 4, fakePublicState, fakeOrgStates[0])
+				Expect(err).To(MatchError("not a chaincode parameters type: badStruct"))
+				Expect(cc).To(BeNil())
+			})
+		})
+
+		Context("when the metadata is not for a chaincode local package", func() {
+			BeforeEach(func() {
+				type badStruct struct{}
+				resources.Serializer.Serialize("chaincode-sources", "cc-name#4", &badStruct{}, fakeOrgStates[0])
+			})
+
+			It("wraps and returns the error", func() {
+				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 4, fakePublicState, fakeOrgStates[0])
+				Expect(err).To(MatchError("not a chaincode local package type: badStruct"))
+				Expect(cc).To(BeNil())
+			})
+		})
+	})
+
+	Describe("Events", func() {
+		var (
+			publicKVS, orgKVS MapLedgerShim
+			fakePublicState   *mock.ReadWritableState
+			fakeOrgState      *mock.ReadWritableState
+			fakeClock         *mock.Clock
+
+			testDefinition *lifecycle.ChaincodeDefinition
+		)
+
+		BeforeEach(func() {
+			fakeClock = &mock.Clock{}
+			fakeClock.NowReturns(1596585600000000000)
+			ef.Clock = fakeClock
+
+			fakeParser.ParseReturns(&persistence.ChaincodePackage{
+				Metadata: &persistence.ChaincodePackageMetadata{
+					Type:  "cc-type",
+					Path:  "cc-path",
+					Label: "cc-label",
+				},
+			}, nil)
+			fakeCCStore.SaveWithContextReturns("fake-hash", nil)
+
+			testDefinition = &lifecycle.ChaincodeDefinition{
+				Sequence: 1,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "endorsement-plugin",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: []byte("validation-parameter"),
+				},
+			}
+
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateStub = publicKVS.GetState
+			fakePublicState.PutStateStub = publicKVS.PutState
+
+			orgKVS = MapLedgerShim(map[string][]byte{})
+			fakeOrgState = &mock.ReadWritableState{}
+			fakeOrgState.CollectionNameReturns("_implicit_org_org0")
+			fakeOrgState.GetStateStub = orgKVS.GetState
+			fakeOrgState.GetStateHashStub = orgKVS.GetStateHash
+			fakeOrgState.PutStateStub = orgKVS.PutState
+		})
+
+		It("returns the same channel on every call", func() {
+			Expect(ef.Events()).To(Equal(ef.Events()))
+		})
+
+		It("emits an event for each of install, approve, and commit", func() {
+			events := ef.Events()
+
+			_, err := ef.InstallChaincode([]byte("cc-package"))
+			Expect(err).NotTo(HaveOccurred())
+			err = ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "fake-hash", fakePublicState, fakeOrgState)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgState})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(events).To(Receive(Equal(lifecycle.LifecycleEvent{
+				Type:      lifecycle.ChaincodeInstalledEvent,
+				Timestamp: 1596585600000000000,
+				Name:      "cc-label",
+				PackageID: "fake-hash",
+			})))
+			Expect(events).To(Receive(Equal(lifecycle.LifecycleEvent{
+				Type:       lifecycle.ChaincodeApprovalEndorsedEvent,
+				Timestamp:  1596585600000000000,
+				ChannelID:  "my-channel",
+				Name:       "cc-name",
+				PackageID:  "fake-hash",
+				Definition: testDefinition,
+			})))
+			Expect(events).To(Receive(Equal(lifecycle.LifecycleEvent{
+				Type:       lifecycle.ChaincodeCommitEndorsedEvent,
+				Timestamp:  1596585600000000000,
+				ChannelID:  "my-channel",
+				Name:       "cc-name",
+				Definition: testDefinition,
+			})))
+			Expect(events).NotTo(Receive())
+		})
+
+		Context("when no clock is configured", func() {
+			BeforeEach(func() {
+				ef.Clock = nil
+			})
+
+			It("timestamps events with the wall clock", func() {
+				events := ef.Events()
+				before := time.Now().UnixNano()
+				_, err := ef.InstallChaincode([]byte("cc-package"))
+				Expect(err).NotTo(HaveOccurred())
+				after := time.Now().UnixNano()
+
+				var event lifecycle.LifecycleEvent
+				Expect(events).To(Receive(&event))
+				Expect(event.Timestamp).To(BeNumerically(">=", before))
+				Expect(event.Timestamp).To(BeNumerically("<=", after))
+			})
+		})
+
+		It("does not buffer events before the channel is requested", func() {
+			_, err := ef.InstallChaincode([]byte("cc-package"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeClock.NowCallCount()).To(Equal(0))
+
+			events := ef.Events()
+			Expect(events).NotTo(Receive())
+
+			err = ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "fake-hash", fakePublicState, fakeOrgState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(Receive())
+		})
+
+		It("does not emit events for failed operations", func() {
+			events := ef.Events()
+
+			testDefinition.Sequence = 3
+			err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "fake-hash", fakePublicState, fakeOrgState)
+			Expect(err).To(HaveOccurred())
+			Expect(events).NotTo(Receive())
+		})
+
+		Context("when the consumer does not keep up", func() {
+			It("drops events rather than blocking", func() {
+				fakeCCStore.SaveWithContextStub = func(ctx context.Context, label string, pkg []byte) (string, error) {
+					return fmt.Sprintf("fake-hash-%d", fakeCCStore.SaveWithContextCallCount()), nil
+				}
+
+				events := ef.Events()
+				for i := 0; i < cap(events)+1; i++ {
+					_, err := ef.InstallChaincode([]byte("cc-package"))
+					Expect(err).NotTo(HaveOccurred())
+				}
+				Expect(events).To(HaveLen(cap(events)))
+			})
+		})
+	})
+
+	Describe("Metrics", func() {
+		var (
+			fakeInstallTotal, fakeApproveTotal, fakeCommitTotal          *metricsfakes.Counter
+			fakeInstallDuration, fakeApproveDuration, fakeCommitDuration *metricsfakes.Histogram
+		)
+
+		BeforeEach(func() {
+			fakeInstallTotal = &metricsfakes.Counter{}
+			fakeInstallTotal.WithReturns(fakeInstallTotal)
+			fakeApproveTotal = &metricsfakes.Counter{}
+			fakeApproveTotal.WithReturns(fakeApproveTotal)
+			fakeCommitTotal = &metricsfakes.Counter{}
+			fakeCommitTotal.WithReturns(fakeCommitTotal)
+			fakeInstallDuration = &metricsfakes.Histogram{}
+			fakeInstallDuration.WithReturns(fakeInstallDuration)
+			fakeApproveDuration = &metricsfakes.Histogram{}
+			fakeApproveDuration.WithReturns(fakeApproveDuration)
+			fakeCommitDuration = &metricsfakes.Histogram{}
+			fakeCommitDuration.WithReturns(fakeCommitDuration)
+
+			ef.Metrics = &lifecycle.Metrics{
+				InstallTotal:    fakeInstallTotal,
+				ApproveTotal:    fakeApproveTotal,
+				CommitTotal:     fakeCommitTotal,
+				InstallDuration: fakeInstallDuration,
+				ApproveDuration: fakeApproveDuration,
+				CommitDuration:  fakeCommitDuration,
+			}
+
+			fakeParser.ParseReturns(&persistence.ChaincodePackage{
+				Metadata: &persistence.ChaincodePackageMetadata{
+					Label: "cc-label",
+				},
+			}, nil)
+			fakeCCStore.SaveWithContextReturns("fake-hash", nil)
+		})
+
+		It("records successful installs", func() {
+			_, err := ef.InstallChaincode([]byte("cc-package"))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeInstallTotal.WithCallCount()).To(Equal(1))
+			Expect(fakeInstallTotal.WithArgsForCall(0)).To(Equal([]string{"success", "true"}))
+			Expect(fakeInstallTotal.AddCallCount()).To(Equal(1))
+			Expect(fakeInstallTotal.AddArgsForCall(0)).To(Equal(float64(1)))
+			Expect(fakeInstallDuration.WithCallCount()).To(Equal(1))
+			Expect(fakeInstallDuration.WithArgsForCall(0)).To(Equal([]string{"success", "true"}))
+			Expect(fakeInstallDuration.ObserveCallCount()).To(Equal(1))
+		})
+
+		It("times operations with the configured clock", func() {
+			fakeClock := &mock.Clock{}
+			fakeClock.NowReturnsOnCall(0, 1000000000)
+			fakeClock.NowReturnsOnCall(1, 3500000000)
+			ef.Clock = fakeClock
+
+			_, err := ef.InstallChaincode([]byte("cc-package"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeClock.NowCallCount()).To(Equal(2))
+			Expect(fakeInstallDuration.ObserveArgsForCall(0)).To(Equal(2.5))
+		})
+
+		It("records failed installs", func() {
+			fakeParser.ParseReturns(nil, fmt.Errorf("parse-error"))
+			_, err := ef.InstallChaincode([]byte("cc-package"))
+			Expect(err).To(HaveOccurred())
+
+			Expect(fakeInstallTotal.WithCallCount()).To(Equal(1))
+			Expect(fakeInstallTotal.WithArgsForCall(0)).To(Equal([]string{"success", "false"}))
+			Expect(fakeInstallTotal.AddCallCount()).To(Equal(1))
+			Expect(fakeInstallDuration.ObserveCallCount()).To(Equal(1))
+		})
+
+		It("records approvals and commits", func() {
+			publicKVS := MapLedgerShim(map[string][]byte{})
+			fakePublicState := &mock.ReadWritableState{}
+			fakePublicState.GetStateStub = publicKVS.GetState
+			fakePublicState.PutStateStub = publicKVS.PutState
+
+			orgKVS := MapLedgerShim(map[string][]byte{})
+			fakeOrgState := &mock.ReadWritableState{}
+			fakeOrgState.CollectionNameReturns("_implicit_org_org0")
+			fakeOrgState.GetStateStub = orgKVS.GetState
+			fakeOrgState.GetStateHashStub = orgKVS.GetStateHash
+			fakeOrgState.PutStateStub = orgKVS.PutState
+
+			testDefinition := &lifecycle.ChaincodeDefinition{
+				Sequence: 1,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "endorsement-plugin",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: []byte("validation-parameter"),
+				},
+			}
+
+			err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "fake-hash", fakePublicState, fakeOrgState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeApproveTotal.WithArgsForCall(0)).To(Equal([]string{"success", "true"}))
+			Expect(fakeApproveDuration.ObserveCallCount()).To(Equal(1))
+
+			_, err = ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgState})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeCommitTotal.WithArgsForCall(0)).To(Equal([]string{"success", "true"}))
+			Expect(fakeCommitDuration.ObserveCallCount()).To(Equal(1))
+
+			testDefinition.EndorsementInfo.Version = "other-version"
+			_, err = ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgState})
+			Expect(err).To(HaveOccurred())
+			Expect(fakeCommitTotal.WithArgsForCall(1)).To(Equal([]string{"success", "false"}))
+			Expect(fakeCommitDuration.ObserveCallCount()).To(Equal(2))
+		})
+	})
+
+	Describe("PreviewCommitWrites", func() {
+		var testDefinition *lifecycle.ChaincodeDefinition
+
+		BeforeEach(func() {
+			testDefinition = &lifecycle.ChaincodeDefinition{
+				Sequence: 5,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "endorsement-plugin",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: []byte("validation-parameter"),
+				},
+				Collections: &pb.CollectionConfigPackage{},
+			}
+		})
+
+		It("returns the writes the definition serializes to", func() {
+			writes, err := ef.PreviewCommitWrites("cc-name", testDefinition)
+			Expect(err).NotTo(HaveOccurred())
+
+			publicKVS := MapLedgerShim(map[string][]byte{})
+			err = resources.Serializer.Serialize("namespaces", "cc-name", testDefinition, publicKVS)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(writes).To(Equal(map[string][]byte(publicKVS)))
+			Expect(writes).To(HaveKey("namespaces/metadata/cc-name"))
+			Expect(writes).To(HaveKey("namespaces/fields/cc-name/Sequence"))
+		})
+
+		Context("when marshaling fails", func() {
+			BeforeEach(func() {
+				resources.Serializer.Marshaler = func(proto.Message) ([]byte, error) {
+					return nil, fmt.Errorf("marshal-error")
+				}
+			})
+
+			It("wraps and returns the error", func() {
+				_, err := ef.PreviewCommitWrites("cc-name", testDefinition)
+				Expect(err).To(MatchError(HavePrefix("could not serialize chaincode definition: ")))
+			})
+		})
+	})
+
+	Describe("SerializeParametersBytes", func() {
+		var testDefinition *lifecycle.ChaincodeDefinition
+
+		BeforeEach(func() {
+			testDefinition = &lifecycle.ChaincodeDefinition{
+				Sequence: 5,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version: "version",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationParameter: []byte("validation-parameter"),
+				},
+				Collections: collectionsNamed("coll-b", "coll-a"),
+			}
+		})
+
+		It("serializes the parameters with the channel defaults applied", func() {
+			serialized, err := ef.SerializeParametersBytes("my-channel", testDefinition)
+			Expect(err).NotTo(HaveOccurred())
+
+			defaulted := testDefinition.DeepCopy()
+			defaulted.EndorsementInfo.EndorsementPlugin = "escc"
+			defaulted.ValidationInfo.ValidationPlugin = "vscc"
+			expected, err := defaulted.Parameters().SerializeBytes()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(serialized).To(Equal(expected))
+		})
+
+		It("does not modify the definition", func() {
+			_, err := ef.SerializeParametersBytes("my-channel", testDefinition)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(testDefinition.EndorsementInfo.EndorsementPlugin).To(BeEmpty())
+			Expect(testDefinition.ValidationInfo.ValidationPlugin).To(BeEmpty())
+			Expect(testDefinition.Collections.Config[0].GetStaticCollectionConfig().Name).To(Equal("coll-b"))
+		})
+	})
+
+	Describe("CommitChaincodeDefinition", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+			fakeOrgStates   []*mock.ReadWritableState
+
+			testDefinition *lifecycle.ChaincodeDefinition
+
+			publicKVS, org0KVS, org1KVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			testDefinition = &lifecycle.ChaincodeDefinition{
+				Sequence: 5,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "endorsement-plugin",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: []byte("validation-parameter"),
+				},
+			}
+
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateStub = publicKVS.GetState
+			fakePublicState.PutStateStub = publicKVS.PutState
+
+			resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
+				Sequence: 4,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "endorsement-plugin",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: []byte("validation-parameter"),
+				},
+			}, publicKVS)
+
+			org0KVS = MapLedgerShim(map[string][]byte{})
+			org1KVS = MapLedgerShim(map[string][]byte{})
+			fakeOrg0State := &mock.ReadWritableState{}
+			fakeOrg0State.CollectionNameReturns("_implicit_org_org0")
+			fakeOrg1State := &mock.ReadWritableState{}
+			fakeOrg1State.CollectionNameReturns("_implicit_org_org1")
+			fakeOrgStates = []*mock.ReadWritableState{
+				fakeOrg0State,
+				fakeOrg1State,
+			}
+			for i, kvs := range []MapLedgerShim{org0KVS, org1KVS} {
+				kvs := kvs
+				fakeOrgStates[i].GetStateStub = kvs.GetState
+				fakeOrgStates[i].GetStateHashStub = kvs.GetStateHash
+				fakeOrgStates[i].PutStateStub = kvs.PutState
+			}
+
+			resources.Serializer.Serialize("namespaces", "cc-name#5", testDefinition.Parameters(), fakeOrgStates[0])
+			resources.Serializer.Serialize("namespaces", "cc-name#5", &lifecycle.ChaincodeParameters{}, fakeOrgStates[1])
+		})
+
+		It("applies the chaincode definition and returns the approvals", func() {
+			approvals, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(approvals).To(Equal(map[string]bool{
+				"org0": true,
+				"org1": false,
+			}))
+		})
+
+		Context("when the previous sequence has annotations", func() {
+			BeforeEach(func() {
+				fakePublicState.DelStateStub = publicKVS.DelState
+				err := ef.SetAnnotations("cc-name", map[string]string{"ticket": "FAB-1234"}, fakePublicState)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("clears them", func() {
+				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(publicKVS).NotTo(HaveKey("namespaces/fields/cc-name/Annotations"))
+
+				annotations, err := ef.GetAnnotations("cc-name", fakePublicState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(annotations).To(BeEmpty())
+			})
+		})
+
+		Context("when the previous sequence has a validation fallback", func() {
+			BeforeEach(func() {
+				fakePublicState.DelStateStub = publicKVS.DelState
+				err := ef.SetValidationFallback("cc-name", "fallback-plugin", fakePublicState)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("clears it", func() {
+				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).NotTo(HaveOccurred())
+
+				plugin, err := ef.QueryValidationFallback("cc-name", fakePublicState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(plugin).To(BeEmpty())
+			})
+		})
+
+		Context("when the definition has no auxiliary fields", func() {
+			It("does not delete any state", func() {
+				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakePublicState.DelStateCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the caller supplies its own org index", func() {
+			It("reports whether its own org agreed", func() {
+				approvals, myOrgAgreed, err := ef.CommitChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]}, 0)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(approvals).To(Equal(map[string]bool{
+					"org0": true,
+					"org1": false,
+				}))
+				Expect(myOrgAgreed).To(BeTrue())
+			})
+
+			It("reports when its own org did not agree", func() {
+				_, myOrgAgreed, err := ef.CommitChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]}, 1)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(myOrgAgreed).To(BeFalse())
+			})
+
+			It("fails when the index is out of range", func() {
+				_, _, err := ef.CommitChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]}, 2)
+				Expect(err).To(MatchError("org index 2 is out of range for 2 org states"))
+				Expect(fakePublicState.PutStateCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when disagreements are collected", func() {
+			It("describes how each disagreeing org's approval differs", func() {
+				fakeOrg2State := &mock.ReadWritableState{}
+				fakeOrg2State.CollectionNameReturns("_implicit_org_org2")
+				fakeOrg2State.GetStateHashStub = MapLedgerShim(map[string][]byte{}).GetStateHash
+
+				approvals, disagreements, err := ef.CommitChaincodeDefinitionWithDisagreements("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1], fakeOrg2State}, true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(approvals).To(Equal(map[string]bool{
+					"org0": true,
+					"org1": false,
+					"org2": false,
+				}))
+				Expect(disagreements).To(Equal(map[string][]string{
+					"org1": {
+						"Version 'version' -> ''",
+						"EndorsementPlugin 'endorsement-plugin' -> ''",
+						"ValidationPlugin 'validation-plugin' -> ''",
+						"ValidationParameter '76616c69646174696f6e2d706172616d65746572' -> ''",
+					},
+					"org2": {"no approval for sequence 5"},
+				}))
+			})
+
+			It("names the differing fields of opaque org states", func() {
+				opaqueOrg1State := &mock.OpaqueState{}
+				opaqueOrg1State.CollectionNameReturns("_implicit_org_org1")
+				opaqueOrg1State.GetStateHashStub = org1KVS.GetStateHash
+
+				_, disagreements, err := ef.CommitChaincodeDefinitionWithDisagreements("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], opaqueOrg1State}, true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(disagreements).To(Equal(map[string][]string{
+					"org1": {"EndorsementInfo changed", "ValidationInfo changed"},
+				}))
+			})
+
+			It("collects nothing when not requested", func() {
+				_, disagreements, err := ef.CommitChaincodeDefinitionWithDisagreements("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]}, false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(disagreements).To(BeNil())
+			})
+		})
+
+		Context("when a namespace reservation is configured", func() {
+			var fakeNamespaceReservation *mock.NamespaceReservation
+
+			BeforeEach(func() {
+				fakeNamespaceReservation = &mock.NamespaceReservation{}
+				resources.NamespaceReservation = fakeNamespaceReservation
+			})
+
+			It("consults it with the orgs which agreed", func() {
+				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeNamespaceReservation.CanDefineCallCount()).To(Equal(1))
+				name, committingOrgs := fakeNamespaceReservation.CanDefineArgsForCall(0)
+				Expect(name).To(Equal("cc-name"))
+				Expect(committingOrgs).To(Equal([]string{"org0"}))
+			})
+
+			It("fails without writing when the name is reserved", func() {
+				fakeNamespaceReservation.CanDefineReturns(fmt.Errorf("reserved-error"))
+				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).To(MatchError("chaincode name 'cc-name' may not be defined by orgs [org0]: reserved-error"))
+				Expect(fakePublicState.PutStateCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when collection members are required to be in the channel", func() {
+			var fakeMSPManager *mock.MSPManager
+
+			BeforeEach(func() {
+				resources.RequireCollectionMembersInChannel = true
+				fakeMSPManager = &mock.MSPManager{}
+				fakeMSPManager.GetMSPsReturns(map[string]msp.MSP{"org0": &mock.MSP{}}, nil)
+				fakeChannelConfig.MSPManagerReturns(fakeMSPManager)
+				testDefinition.Collections = &pb.CollectionConfigPackage{
+					Config: []*pb.CollectionConfig{
+						{
+							Payload: &pb.CollectionConfig_StaticCollectionConfig{
+								StaticCollectionConfig: &pb.StaticCollectionConfig{
+									Name: "collection-name",
+									MemberOrgsPolicy: &pb.CollectionPolicyConfig{
+										Payload: &pb.CollectionPolicyConfig_SignaturePolicy{
+											SignaturePolicy: policydsl.SignedByAnyMember([]string{"org0"}),
+										},
+									},
+								},
+							},
+						},
+					},
+				}
+			})
+
+			It("accepts collections whose members are in the channel", func() {
+				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			Context("when a collection member is not in the channel", func() {
+				BeforeEach(func() {
+					fakeMSPManager.GetMSPsReturns(map[string]msp.MSP{"org1": &mock.MSP{}}, nil)
+				})
+
+				It("names the collection and the member", func() {
+					_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+					Expect(err).To(MatchError("invalid collection configuration: collection-name: collection-name -- collection member 'org0' is not part of the channel"))
+					Expect(fakePublicState.PutStateCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when the MSPs cannot be retrieved", func() {
+				BeforeEach(func() {
+					fakeMSPManager.GetMSPsReturns(nil, fmt.Errorf("msp-error"))
+				})
+
+				It("wraps and returns the error", func() {
+					_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+					Expect(err).To(MatchError("invalid collection configuration: could not get MSPs: msp-error"))
+				})
+			})
+
+			Context("when the channel has no MSP manager", func() {
+				BeforeEach(func() {
+					fakeChannelConfig.MSPManagerReturns(nil)
+				})
+
+				It("returns an error", func() {
+					_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+					Expect(err).To(MatchError("invalid collection configuration: could not get MSP manager for channel 'my-channel'"))
+				})
+			})
+		})
+
+		Context("when agreement is required to commit", func() {
+			BeforeEach(func() {
+				resources.RequireAgreementToCommit = true
+			})
+
+			It("commits when some org agrees", func() {
+				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("fails without writing when no org agrees", func() {
+				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[1]})
+				Expect(err).To(MatchError("no org agrees with the chaincode definition for cc-name at sequence 5"))
+				Expect(fakePublicState.PutStateCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the sequence exceeds the maximum sequence", func() {
+			BeforeEach(func() {
+				resources.MaxSequence = 4
+			})
+
+			It("fails", func() {
+				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).To(MatchError("requested sequence 5 exceeds the maximum allowed sequence 4"))
+			})
+		})
+
+		Context("when the chaincode name is reserved", func() {
+			It("fails", func() {
+				_, err := ef.CommitChaincodeDefinition("my-channel", "_lifecycle", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).To(MatchError("chaincode name '_lifecycle' is reserved"))
+				Expect(fakePublicState.PutStateCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the validation plugin is not registered", func() {
+			BeforeEach(func() {
+				fakePluginRegistry := &mock.PluginRegistry{}
+				fakePluginRegistry.EndorsementPluginExistsReturns(true)
+				resources.PluginRegistry = fakePluginRegistry
+			})
+
+			It("returns an error", func() {
+				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).To(MatchError("validation plugin 'validation-plugin' is not registered"))
+			})
+		})
+
+		Context("when the validation parameter references a channel policy", func() {
+			BeforeEach(func() {
+				testDefinition.ValidationInfo.ValidationParameter = protoutil.MarshalOrPanic(&pb.ApplicationPolicy{
+					Type: &pb.ApplicationPolicy_ChannelConfigPolicyReference{
+						ChannelConfigPolicyReference: "/Channel/Application/Endorsement",
+					},
+				})
+				fakePolicyManager.GetPolicyStub = func(name string) (policies.Policy, bool) {
+					return nil, name == "/Channel/Application/Endorsement"
+				}
+			})
+
+			It("accepts an existing policy", func() {
+				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakePolicyManager.GetPolicyArgsForCall(0)).To(Equal("/Channel/Application/Endorsement"))
+			})
+
+			Context("when the referenced policy does not exist", func() {
+				BeforeEach(func() {
+					testDefinition.ValidationInfo.ValidationParameter = protoutil.MarshalOrPanic(&pb.ApplicationPolicy{
+						Type: &pb.ApplicationPolicy_ChannelConfigPolicyReference{
+							ChannelConfigPolicyReference: "/Channel/Application/Missing",
+						},
+					})
+				})
+
+				It("returns an error", func() {
+					_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+					Expect(err).To(MatchError("channel config policy '/Channel/Application/Missing' referenced by the validation parameter does not exist"))
+				})
+			})
+
+			Context("when there is no channel config source", func() {
+				BeforeEach(func() {
+					resources.ChannelConfigSource = nil
+					testDefinition.ValidationInfo.ValidationParameter = protoutil.MarshalOrPanic(&pb.ApplicationPolicy{
+						Type: &pb.ApplicationPolicy_ChannelConfigPolicyReference{
+							ChannelConfigPolicyReference: "/Channel/Application/Missing",
+						},
+					})
+				})
+
+				It("skips the check", func() {
+					_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+					Expect(err).NotTo(HaveOccurred())
+				})
+			})
+		})
+
+		Context("when uniform packages are required", func() {
+			BeforeEach(func() {
+				ef.RequireUniformPackage = true
+				resources.Serializer.Serialize("namespaces", "cc-name#5", testDefinition.Parameters(), fakeOrgStates[1])
+				resources.Serializer.Serialize("chaincode-sources", "cc-name#5", &lifecycle.ChaincodeLocalPackage{PackageID: "package-id"}, fakeOrgStates[0])
+				resources.Serializer.Serialize("chaincode-sources", "cc-name#5", &lifecycle.ChaincodeLocalPackage{PackageID: "package-id"}, fakeOrgStates[1])
+			})
+
+			It("commits when the agreeing orgs approved the same package", func() {
+				approvals, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(approvals).To(Equal(map[string]bool{
+					"org0": true,
+					"org1": true,
+				}))
+			})
+
+			Context("when the agreeing orgs approved different packages", func() {
+				BeforeEach(func() {
+					resources.Serializer.Serialize("chaincode-sources", "cc-name#5", &lifecycle.ChaincodeLocalPackage{PackageID: "other-package-id"}, fakeOrgStates[1])
+				})
+
+				It("returns an error", func() {
+					_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+					Expect(err).To(MatchError("agreeing orgs 'org0' and 'org1' approved different packages"))
+				})
+			})
+
+			Context("when a non-agreeing org approved a different package", func() {
+				BeforeEach(func() {
+					resources.Serializer.Serialize("namespaces", "cc-name#5", &lifecycle.ChaincodeParameters{}, fakeOrgStates[1])
+					resources.Serializer.Serialize("chaincode-sources", "cc-name#5", &lifecycle.ChaincodeLocalPackage{PackageID: "other-package-id"}, fakeOrgStates[1])
+				})
+
+				It("commits the definition", func() {
+					_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+					Expect(err).NotTo(HaveOccurred())
+				})
+			})
+
+			Context("when the package hash cannot be retrieved", func() {
+				BeforeEach(func() {
+					fakeOrgStates[1].GetStateHashStub = func(key string) ([]byte, error) {
+						if key == "chaincode-sources/fields/cc-name#5/PackageID" {
+							return nil, fmt.Errorf("state-hash-error")
+						}
+						return org1KVS.GetStateHash(key)
+					}
+				})
+
+				It("wraps and returns the error", func() {
+					_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+					Expect(err).To(MatchError("could not get state hash for key chaincode-sources/fields/cc-name#5/PackageID: state-hash-error"))
+				})
+			})
+		})
+
+		Context("when IsSerialized fails", func() {
+			BeforeEach(func() {
+				fakeOrgStates[0].GetStateHashReturns(nil, errors.New("bad bad failure"))
+			})
+
+			It("wraps and returns an error", func() {
+				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).To(MatchError(ContainSubstring("serialization check failed for key cc-name#5: could not get value for key namespaces/metadata/cc-name#5: bad bad failure")))
+			})
+
+			It("reports the error per org when org errors are tolerated", func() {
+				approvals, orgErrs, err := ef.CommitChaincodeDefinitionWithOrgErrors("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(approvals).To(Equal(map[string]bool{
+					"org0": false,
+					"org1": false,
+				}))
+				Expect(orgErrs).To(HaveLen(1))
+				Expect(orgErrs[0]).To(MatchError("serialization check failed for key cc-name#5: could not get value for key namespaces/metadata/cc-name#5: bad bad failure"))
+			})
+		})
+
+		Context("when the peer sets defaults", func() {
+			BeforeEach(func() {
+				testDefinition.EndorsementInfo.EndorsementPlugin = "escc"
+				testDefinition.ValidationInfo.ValidationPlugin = "vscc"
+				testDefinition.ValidationInfo.ValidationParameter = protoutil.MarshalOrPanic(
+					&pb.ApplicationPolicy{
+						Type: &pb.ApplicationPolicy_ChannelConfigPolicyReference{
+							ChannelConfigPolicyReference: "/Channel/Application/Endorsement",
+						},
+					})
+
+				resources.Serializer.Serialize("namespaces", "cc-name#5", testDefinition.Parameters(), fakeOrgStates[0])
+
+				testDefinition.EndorsementInfo.EndorsementPlugin = ""
+				testDefinition.ValidationInfo.ValidationPlugin = ""
+				testDefinition.ValidationInfo.ValidationParameter = nil
+
+				resources.Serializer.Serialize("namespaces", "cc-name#5", testDefinition.Parameters(), fakeOrgStates[1])
+			})
+
+			It("applies the chaincode definition and returns the approvals", func() {
+				approvals, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(approvals).To(Equal(map[string]bool{
+					"org0": true,
+					"org1": false,
+				}))
+			})
+
+			Context("when no default endorsement policy is defined on thc channel", func() {
+				BeforeEach(func() {
+					fakePolicyManager.GetPolicyReturns(nil, false)
+				})
+
+				It("returns an error", func() {
+					_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+					Expect(err).To(MatchError(ContainSubstring("could not set defaults for chaincode definition in " +
+						"channel my-channel: policy '/Channel/Application/Endorsement' must be defined " +
+						"for channel 'my-channel' before chaincode operations can be attempted")))
+				})
+			})
+
+			Context("when obtaining a stable channel config fails", func() {
+				BeforeEach(func() {
+					fakeChannelConfigSource.GetStableChannelConfigReturns(nil)
+				})
+
+				It("returns an error", func() {
+					_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+					Expect(err).To(MatchError(ContainSubstring("could not get channel config for channel 'my-channel'")))
+				})
+			})
+		})
+
+		Context("when the public state is not readable", func() {
+			BeforeEach(func() {
+				fakePublicState.GetStateReturns(nil, fmt.Errorf("getstate-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).To(MatchError("could not get current sequence: could not get state for key namespaces/fields/cc-name/Sequence: getstate-error"))
+			})
+		})
+
+		Context("when the public state is not writable", func() {
+			BeforeEach(func() {
+				fakePublicState.PutStateReturns(fmt.Errorf("putstate-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).To(MatchError("could not serialize chaincode definition: could not write key into state: putstate-error"))
+			})
+		})
+
+		Context("when the current sequence is not immediately prior to the new", func() {
+			BeforeEach(func() {
+				resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
+					Sequence: 3,
+					EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+						Version:           "version",
+						EndorsementPlugin: "endorsement-plugin",
+					},
+					ValidationInfo: &lb.ChaincodeValidationInfo{
+						ValidationPlugin:    "validation-plugin",
+						ValidationParameter: []byte("validation-parameter"),
+					},
+				}, fakePublicState)
+			})
+
+			It("returns an error", func() {
+				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).To(MatchError("requested sequence is 5, but new definition must be sequence 4"))
+				Expect(err).To(Equal(lifecycle.ErrWrongSequence{Requested: 5, Expected: 4}))
+			})
+		})
+
+		Context("when the current sequence is the new sequence", func() {
+			BeforeEach(func() {
+				resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
+					Sequence: 5,
+					EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+						Version:           "other-version",
+						EndorsementPlugin: "endorsement-plugin",
+					},
+					ValidationInfo: &lb.ChaincodeValidationInfo{
+						ValidationPlugin:    "validation-plugin",
+						ValidationParameter: []byte("validation-parameter"),
+					},
+				}, fakePublicState)
+			})
+
+			It("returns an error", func() {
+				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).To(MatchError("requested sequence is 5, but new definition must be sequence 6"))
+			})
+		})
+
+		Context("when the definition is already committed", func() {
+			BeforeEach(func() {
+				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).NotTo(HaveOccurred())
+				fakePublicState.PutStateReturns(fmt.Errorf("unexpected-write"))
+				fakePublicState.DelStateReturns(fmt.Errorf("unexpected-delete"))
+			})
+
+			It("succeeds without writing and returns the approvals", func() {
+				approvals, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition.DeepCopy(), fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(approvals).To(Equal(map[string]bool{
+					"org0": true,
+					"org1": false,
+				}))
+			})
+
+			Context("when the definition relies on the defaults", func() {
+				BeforeEach(func() {
+					testDefinition = &lifecycle.ChaincodeDefinition{
+						Sequence: 6,
+						EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+							Version: "version",
+						},
+						ValidationInfo: &lb.ChaincodeValidationInfo{
+							ValidationParameter: []byte("validation-parameter"),
+						},
+					}
+					fakePublicState.PutStateStub = publicKVS.PutState
+					fakePublicState.DelStateReturns(nil)
+					_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition.DeepCopy(), fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+					Expect(err).NotTo(HaveOccurred())
+					fakePublicState.PutStateReturns(fmt.Errorf("unexpected-write"))
+				})
+
+				It("still treats the commit as a no-op", func() {
+					_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition.DeepCopy(), fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+					Expect(err).NotTo(HaveOccurred())
+				})
+			})
+
+			Context("when the definition differs from the committed one", func() {
+				BeforeEach(func() {
+					testDefinition.EndorsementInfo.Version = "other-version"
+				})
+
+				It("returns the sequence error", func() {
+					_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+					Expect(err).To(MatchError("requested sequence is 5, but new definition must be sequence 6"))
+				})
+			})
+		})
+
+		Context("when the collections are malformed", func() {
+			BeforeEach(func() {
+				testDefinition.Collections = &pb.CollectionConfigPackage{
+					Config: []*pb.CollectionConfig{
+						{
+							Payload: &pb.CollectionConfig_StaticCollectionConfig{
+								StaticCollectionConfig: &pb.StaticCollectionConfig{Name: "bad-collection"},
+							},
+						},
+					},
+				}
+			})
+
+			It("returns an error without writing the definition", func() {
+				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).To(MatchError("invalid collection configuration: collection member policy is not set for collection 'bad-collection'"))
+				Expect(fakePublicState.PutStateCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the plugin combination is allowed", func() {
+			BeforeEach(func() {
+				ef.AllowedPluginCombos = [][2]string{
+					{"other-endorsement-plugin", "other-validation-plugin"},
+					{"endorsement-plugin", "validation-plugin"},
+				}
+			})
+
+			It("applies the chaincode definition", func() {
+				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakePublicState.PutStateCallCount()).NotTo(Equal(0))
+			})
+		})
+
+		Context("when the plugin combination is not allowed", func() {
+			BeforeEach(func() {
+				ef.AllowedPluginCombos = [][2]string{
+					{"endorsement-plugin", "other-validation-plugin"},
+				}
+			})
+
+			It("returns an error without writing the definition", func() {
+				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).To(MatchError("endorsement plugin 'endorsement-plugin' and validation plugin 'validation-plugin' are not an allowed combination"))
+				Expect(fakePublicState.PutStateCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("CurrentSequence", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+
+			publicKVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateStub = publicKVS.GetState
+
+			resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
+				Sequence: 7,
+			}, publicKVS)
+		})
+
+		It("returns the committed sequence", func() {
+			sequence, err := ef.CurrentSequence("cc-name", fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sequence).To(Equal(int64(7)))
+		})
+
+		Context("when the chaincode is not defined", func() {
+			It("returns 0", func() {
+				sequence, err := ef.CurrentSequence("other-name", fakePublicState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(sequence).To(Equal(int64(0)))
+			})
+		})
+
+		Context("when the state cannot be read", func() {
+			BeforeEach(func() {
+				fakePublicState.GetStateReturns(nil, fmt.Errorf("get-state-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				_, err := ef.CurrentSequence("cc-name", fakePublicState)
+				Expect(err).To(MatchError("could not get current sequence: could not get state for key namespaces/fields/cc-name/Sequence: get-state-error"))
+			})
+		})
+	})
+
+	Describe("SequenceIfDefined", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+
+			publicKVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateStub = publicKVS.GetState
+
+			resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
+				Sequence: 7,
+			}, publicKVS)
+			resources.Serializer.Serialize("namespaces", "other-type", &lifecycle.ChaincodeParameters{}, publicKVS)
+		})
+
+		It("returns the committed sequence", func() {
+			sequence, defined, err := ef.SequenceIfDefined("cc-name", fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(defined).To(BeTrue())
+			Expect(sequence).To(Equal(int64(7)))
+		})
+
+		Context("when the chaincode is not defined", func() {
+			It("reports that it is not defined", func() {
+				sequence, defined, err := ef.SequenceIfDefined("other-name", fakePublicState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(defined).To(BeFalse())
+				Expect(sequence).To(Equal(int64(0)))
+			})
+		})
+
+		Context("when the namespace is not a chaincode", func() {
+			It("returns an error", func() {
+				_, _, err := ef.SequenceIfDefined("other-type", fakePublicState)
+				Expect(err).To(Equal(lifecycle.ErrNotChaincodeType{Datatype: "ChaincodeParameters"}))
+			})
+		})
+
+		Context("when the metadata cannot be read", func() {
+			BeforeEach(func() {
+				fakePublicState.GetStateReturns(nil, fmt.Errorf("get-state-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				_, _, err := ef.SequenceIfDefined("cc-name", fakePublicState)
+				Expect(err).To(MatchError("could not deserialize metadata for chaincode cc-name: could not query metadata for namespace namespaces/cc-name: get-state-error"))
+			})
+		})
+	})
+
+	Describe("Annotations", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+
+			publicKVS MapLedgerShim
+
+			testDefinition *lifecycle.ChaincodeDefinition
+		)
+
+		BeforeEach(func() {
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateStub = publicKVS.GetState
+			fakePublicState.PutStateStub = publicKVS.PutState
+			fakePublicState.DelStateStub = publicKVS.DelState
+
+			testDefinition = &lifecycle.ChaincodeDefinition{
+				Sequence: 1,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version: "version",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{},
+				Collections:    &pb.CollectionConfigPackage{},
+			}
+			resources.Serializer.Serialize("namespaces", "cc-name", testDefinition, publicKVS)
+		})
+
+		It("stores and returns the annotations", func() {
+			err := ef.SetAnnotations("cc-name", map[string]string{"ticket": "FAB-1234", "owner": "team-a"}, fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(publicKVS).To(HaveKey("namespaces/fields/cc-name/Annotations"))
+
+			annotations, err := ef.GetAnnotations("cc-name", fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(annotations).To(Equal(map[string]string{"ticket": "FAB-1234", "owner": "team-a"}))
+		})
+
+		It("does not affect the chaincode definition", func() {
+			err := ef.SetAnnotations("cc-name", map[string]string{"ticket": "FAB-1234"}, fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+
+			cd, err := ef.QueryChaincodeDefinition("cc-name", fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cd.Parameters().Equal(testDefinition.Parameters())).To(Succeed())
+
+			testDefinition.Sequence = 2
+			err = resources.Serializer.Serialize("namespaces", "cc-name", testDefinition, publicKVS)
+			Expect(err).NotTo(HaveOccurred())
+			annotations, err := ef.GetAnnotations("cc-name", fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(annotations).To(Equal(map[string]string{"ticket": "FAB-1234"}))
+		})
+
+		It("does not rewrite unchanged annotations", func() {
+			err := ef.SetAnnotations("cc-name", map[string]string{"ticket": "FAB-1234"}, fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			err = ef.SetAnnotations("cc-name", map[string]string{"ticket": "FAB-1234"}, fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakePublicState.PutStateCallCount()).To(Equal(1))
+		})
+
+		It("removes the annotations when they are empty", func() {
+			err := ef.SetAnnotations("cc-name", map[string]string{"ticket": "FAB-1234"}, fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			err = ef.SetAnnotations("cc-name", nil, fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(publicKVS).NotTo(HaveKey("namespaces/fields/cc-name/Annotations"))
+
+			annotations, err := ef.GetAnnotations("cc-name", fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(annotations).To(BeEmpty())
+		})
+
+		Context("when the chaincode is not defined", func() {
+			It("returns an error", func() {
+				err := ef.SetAnnotations("other-name", map[string]string{"ticket": "FAB-1234"}, fakePublicState)
+				Expect(err).To(MatchError("namespace other-name is not defined"))
+			})
+		})
+
+		Context("when the annotations cannot be read", func() {
+			BeforeEach(func() {
+				fakePublicState.GetStateReturns(nil, fmt.Errorf("get-state-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				_, err := ef.GetAnnotations("cc-name", fakePublicState)
+				Expect(err).To(MatchError("could not get annotations for chaincode cc-name: could not get state for key namespaces/fields/cc-name/Annotations: get-state-error"))
+			})
+		})
+	})
+
+	Describe("ValidationFallback", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+
+			publicKVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateStub = publicKVS.GetState
+			fakePublicState.PutStateStub = publicKVS.PutState
+			fakePublicState.DelStateStub = publicKVS.DelState
+
+			resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
+				Sequence: 1,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version: "version",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin: "vscc",
+				},
+				Collections: &pb.CollectionConfigPackage{},
+			}, publicKVS)
+		})
+
+		It("stores and returns the fallback plugin", func() {
+			err := ef.SetValidationFallback("cc-name", "fallback-vscc", fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(publicKVS).To(HaveKey("namespaces/fields/cc-name/ValidationFallback"))
+
+			plugin, err := ef.QueryValidationFallback("cc-name", fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plugin).To(Equal("fallback-vscc"))
+		})
+
+		It("does not affect the chaincode definition", func() {
+			err := ef.SetValidationFallback("cc-name", "fallback-vscc", fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ef.VerifyRoundTrip("cc-name", fakePublicState)).To(Succeed())
+			cd, err := ef.QueryChaincodeDefinition("cc-name", fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cd.ValidationInfo.ValidationPlugin).To(Equal("vscc"))
+		})
+
+		It("removes the fallback when set to the empty string", func() {
+			Expect(ef.SetValidationFallback("cc-name", "fallback-vscc", fakePublicState)).To(Succeed())
+			Expect(ef.SetValidationFallback("cc-name", "", fakePublicState)).To(Succeed())
+			Expect(publicKVS).NotTo(HaveKey("namespaces/fields/cc-name/ValidationFallback"))
+
+			plugin, err := ef.QueryValidationFallback("cc-name", fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plugin).To(BeEmpty())
+		})
+
+		Context("when the fallback is the validation plugin itself", func() {
+			It("returns an error", func() {
+				err := ef.SetValidationFallback("cc-name", "vscc", fakePublicState)
+				Expect(err).To(MatchError("validation fallback for chaincode cc-name must differ from its validation plugin 'vscc'"))
+			})
+		})
+
+		Context("when the fallback plugin is not registered", func() {
+			BeforeEach(func() {
+				resources.PluginRegistry = &mock.PluginRegistry{}
+			})
+
+			It("returns an error", func() {
+				err := ef.SetValidationFallback("cc-name", "fallback-vscc", fakePublicState)
+				Expect(err).To(MatchError("validation plugin 'fallback-vscc' is not registered"))
+				Expect(publicKVS).NotTo(HaveKey("namespaces/fields/cc-name/ValidationFallback"))
+			})
+		})
+
+		Context("when the chaincode is not defined", func() {
+			It("returns an error", func() {
+				err := ef.SetValidationFallback("other-name", "fallback-vscc", fakePublicState)
+				Expect(err).To(MatchError("namespace other-name is not defined"))
+			})
+		})
+	})
+
+	Describe("VerifyRoundTrip", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+
+			publicKVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateStub = publicKVS.GetState
+
+			resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
+				Sequence: 3,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version: "version",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin: "validation-plugin",
+				},
+				Collections: &pb.CollectionConfigPackage{},
+			}, publicKVS)
+		})
+
+		It("succeeds for a faithfully serialized definition", func() {
+			err := ef.VerifyRoundTrip("cc-name", fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when a field has been mutated", func() {
+			BeforeEach(func() {
+				// Repeat the version field, which deserializes to the same
+				// value but is not how the serializer would encode it
+				endorsementInfo := publicKVS["namespaces/fields/cc-name/EndorsementInfo"]
+				stateData := &lb.StateData{}
+				Expect(proto.Unmarshal(endorsementInfo, stateData)).To(Succeed())
+				stateData.Type = &lb.StateData_Bytes{Bytes: append(stateData.GetBytes(), stateData.GetBytes()...)}
+				publicKVS["namespaces/fields/cc-name/EndorsementInfo"] = protoutil.MarshalOrPanic(stateData)
+			})
+
+			It("reports the mismatched key", func() {
+				err := ef.VerifyRoundTrip("cc-name", fakePublicState)
+				Expect(err).To(MatchError(ContainSubstring("round trip mismatch for key namespaces/fields/cc-name/EndorsementInfo")))
+			})
+		})
+
+		Context("when the chaincode is not defined", func() {
+			It("returns an error", func() {
+				err := ef.VerifyRoundTrip("other-name", fakePublicState)
+				Expect(err).To(MatchError("namespace other-name is not defined"))
+			})
+		})
+	})
+
+	Describe("CollectionDiff", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+
+			publicKVS MapLedgerShim
+
+			proposed *lifecycle.ChaincodeDefinition
+		)
+
+		collection := func(name string, btl uint64) *pb.CollectionConfig {
+			return &pb.CollectionConfig{
+				Payload: &pb.CollectionConfig_StaticCollectionConfig{
+					StaticCollectionConfig: &pb.StaticCollectionConfig{
+						Name:        name,
+						BlockToLive: btl,
+					},
+				},
+			}
+		}
+
+		BeforeEach(func() {
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateStub = publicKVS.GetState
+
+			resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
+				Sequence:        1,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{},
+				ValidationInfo:  &lb.ChaincodeValidationInfo{},
+				Collections: &pb.CollectionConfigPackage{
+					Config: []*pb.CollectionConfig{
+						collection("unchanged", 1),
+						collection("removed", 1),
+						collection("modified", 1),
+					},
+				},
+			}, publicKVS)
+
+			proposed = &lifecycle.ChaincodeDefinition{
+				Sequence: 2,
+				Collections: &pb.CollectionConfigPackage{
+					Config: []*pb.CollectionConfig{
+						collection("added", 1),
+						collection("modified", 2),
+						collection("unchanged", 1),
+					},
+				},
+			}
+		})
+
+		It("reports the added, removed, and modified collections", func() {
+			added, removed, modified, err := ef.CollectionDiff("cc-name", proposed, fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(added).To(Equal([]string{"added"}))
+			Expect(removed).To(Equal([]string{"removed"}))
+			Expect(modified).To(Equal([]string{"modified"}))
+		})
+
+		Context("when the chaincode is not defined", func() {
+			It("reports every proposed collection as added", func() {
+				added, removed, modified, err := ef.CollectionDiff("other-name", proposed, fakePublicState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(added).To(Equal([]string{"added", "modified", "unchanged"}))
+				Expect(removed).To(BeEmpty())
+				Expect(modified).To(BeEmpty())
+			})
+		})
+
+		Context("when the committed definition cannot be read", func() {
+			BeforeEach(func() {
+				fakePublicState.GetStateReturns(nil, fmt.Errorf("get-state-error"))
+			})
+
+			It("returns the error", func() {
+				_, _, _, err := ef.CollectionDiff("cc-name", proposed, fakePublicState)
+				Expect(err).To(MatchError("could not fetch metadata for namespace cc-name: could not query metadata for namespace namespaces/cc-name: get-state-error"))
+			})
+		})
+	})
+
+	Describe("QueryChaincodeDefinition", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+			fakeOrgStates   []*mock.ReadWritableState
+
+			testDefinition *lifecycle.ChaincodeDefinition
+
+			publicKVS, org0KVS, org1KVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateStub = publicKVS.GetState
+			fakePublicState.PutStateStub = publicKVS.PutState
+
+			testDefinition = &lifecycle.ChaincodeDefinition{
+				Sequence: 4,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "endorsement-plugin",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: []byte("validation-parameter"),
+				},
+				Collections: &pb.CollectionConfigPackage{},
+			}
+
+			resources.Serializer.Serialize("namespaces", "cc-name", testDefinition, publicKVS)
+
+			org0KVS = MapLedgerShim(map[string][]byte{})
+			org1KVS = MapLedgerShim(map[string][]byte{})
+			fakeOrg0State := &mock.ReadWritableState{}
+			fakeOrg1State := &mock.ReadWritableState{}
+			fakeOrgStates = []*mock.ReadWritableState{
+				fakeOrg0State,
+				fakeOrg1State,
+			}
+			for i, kvs := range []MapLedgerShim{org0KVS, org1KVS} {
+				kvs := kvs
+				fakeOrgStates[i].GetStateStub = kvs.GetState
+				fakeOrgStates[i].GetStateHashStub = kvs.GetStateHash
+				fakeOrgStates[i].PutStateStub = kvs.PutState
+			}
+
+			resources.Serializer.Serialize("namespaces", "cc-name#4", testDefinition.Parameters(), fakeOrgStates[0])
+			resources.Serializer.Serialize("namespaces", "cc-name#4", &lifecycle.ChaincodeParameters{}, fakeOrgStates[1])
+		})
+
+		It("returns the defined chaincode", func() {
+			cc, err := ef.QueryChaincodeDefinition("cc-name", fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cc.Sequence).To(Equal(int64(4)))
+			Expect(proto.Equal(cc.EndorsementInfo, &lb.ChaincodeEndorsementInfo{
+				Version:           "version",
+				EndorsementPlugin: "endorsement-plugin",
+			})).To(BeTrue())
+			Expect(proto.Equal(cc.ValidationInfo, &lb.ChaincodeValidationInfo{
+				ValidationPlugin:    "validation-plugin",
+				ValidationParameter: []byte("validation-parameter"),
+			})).To(BeTrue())
+			Expect(proto.Equal(cc.Collections, &pb.CollectionConfigPackage{})).To(BeTrue())
+		})
+
+		Context("when the chaincode is not defined", func() {
+			BeforeEach(func() {
+				fakePublicState.GetStateReturns(nil, nil)
+			})
+
+			It("returns an error", func() {
+				cc, err := ef.QueryChaincodeDefinition("cc-name", fakePublicState)
+				Expect(err).To(MatchError("namespace cc-name is not defined"))
+				Expect(err).To(Equal(lifecycle.ErrNamespaceNotDefined{Namespace: "cc-name"}))
+				Expect(cc).To(BeNil())
+			})
+		})
+
+		Context("when the namespace is not a chaincode", func() {
+			BeforeEach(func() {
+				resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeParameters{}, publicKVS)
+			})
+
+			It("returns an error", func() {
+				cc, err := ef.QueryChaincodeDefinition("cc-name", fakePublicState)
+				Expect(err).To(MatchError("not a chaincode type: ChaincodeParameters"))
+				Expect(err).To(Equal(lifecycle.ErrNotChaincodeType{Datatype: "ChaincodeParameters"}))
+				Expect(cc).To(BeNil())
+			})
+		})
+
+		Context("when getting the metadata fails", func() {
+			BeforeEach(func() {
+				fakePublicState.GetStateReturns(nil, fmt.Errorf("metadata-error"))
+			})
+
+			It("returns an error", func() {
+				cc, err := ef.QueryChaincodeDefinition("cc-name", fakePublicState) //, nil)
+				Expect(err).To(MatchError("could not fetch metadata for namespace cc-name: could not query metadata for namespace namespaces/cc-name: metadata-error"))
+				Expect(cc).To(BeNil())
+			})
+		})
+
+		Context("when deserializing the definition fails", func() {
+			BeforeEach(func() {
+				publicKVS["namespaces/fields/cc-name/EndorsementInfo"] = []byte("garbage")
+			})
+
+			It("returns an error", func() {
+				cc, err := ef.QueryChaincodeDefinition("cc-name", fakePublicState)
+				Expect(err).To(MatchError("could not deserialize namespace cc-name as chaincode: could not unmarshal state for key namespaces/fields/cc-name/EndorsementInfo: proto: can't skip unknown wire type 7"))
+				Expect(cc).To(BeNil())
+			})
+		})
+	})
+
+	Describe("QueryValidationParameter", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+
+			publicKVS MapLedgerShim
+
+			rawParameter []byte
+		)
+
+		BeforeEach(func() {
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateStub = publicKVS.GetState
+
+			// not a valid application policy, so any re-encoding would alter it
+			rawParameter = []byte{0x00, 0xff, 0x0a, 0x01}
+			resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
+				Sequence:        4,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: rawParameter,
+				},
+				Collections: &pb.CollectionConfigPackage{},
+			}, publicKVS)
+		})
+
+		It("returns the validation parameter as stored, reading only the validation info", func() {
+			validationParameter, err := ef.QueryValidationParameter("cc-name", fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(validationParameter).To(Equal(rawParameter))
+			Expect(fakePublicState.GetStateCallCount()).To(Equal(2))
+			Expect(fakePublicState.GetStateArgsForCall(1)).To(Equal("namespaces/fields/cc-name/ValidationInfo"))
+		})
+
+		It("matches the validation parameter of the queried definition", func() {
+			cc, err := ef.QueryChaincodeDefinition("cc-name", fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cc.ValidationInfo.ValidationParameter).To(Equal(rawParameter))
+		})
+
+		Context("when the chaincode is not defined", func() {
+			It("returns an error", func() {
+				_, err := ef.QueryValidationParameter("other-name", fakePublicState)
+				Expect(err).To(Equal(lifecycle.ErrNamespaceNotDefined{Namespace: "other-name"}))
+			})
+		})
+
+		Context("when the namespace is not a chaincode", func() {
+			BeforeEach(func() {
+				resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeParameters{}, publicKVS)
+			})
+
+			It("returns an error", func() {
+				_, err := ef.QueryValidationParameter("cc-name", fakePublicState)
+				Expect(err).To(Equal(lifecycle.ErrNotChaincodeType{Datatype: "ChaincodeParameters"}))
+			})
+		})
+
+		Context("when the validation info cannot be deserialized", func() {
+			BeforeEach(func() {
+				publicKVS["namespaces/fields/cc-name/ValidationInfo"] = []byte("garbage")
+			})
+
+			It("wraps and returns the error", func() {
+				_, err := ef.QueryValidationParameter("cc-name", fakePublicState)
+				Expect(err).To(MatchError(ContainSubstring("could not deserialize validation info for chaincode cc-name")))
+			})
+		})
+	})
+
+	Describe("QueryChaincodeDefinitionWithApprovals", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+			fakeOrgStates   []*mock.ReadWritableState
+
+			testDefinition *lifecycle.ChaincodeDefinition
+
+			publicKVS, org0KVS, org1KVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateStub = publicKVS.GetState
+
+			testDefinition = &lifecycle.ChaincodeDefinition{
+				Sequence: 4,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "endorsement-plugin",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: []byte("validation-parameter"),
+				},
+				Collections: &pb.CollectionConfigPackage{},
+			}
+
+			resources.Serializer.Serialize("namespaces", "cc-name", testDefinition, publicKVS)
+
+			org0KVS = MapLedgerShim(map[string][]byte{})
+			org1KVS = MapLedgerShim(map[string][]byte{})
+			fakeOrg0State := &mock.ReadWritableState{}
+			fakeOrg0State.CollectionNameReturns("_implicit_org_org0")
+			fakeOrg1State := &mock.ReadWritableState{}
+			fakeOrg1State.CollectionNameReturns("_implicit_org_org1")
+			fakeOrgStates = []*mock.ReadWritableState{
+				fakeOrg0State,
+				fakeOrg1State,
+			}
+			for i, kvs := range []MapLedgerShim{org0KVS, org1KVS} {
+				kvs := kvs
+				fakeOrgStates[i].GetStateStub = kvs.GetState
+				fakeOrgStates[i].GetStateHashStub = kvs.GetStateHash
+				fakeOrgStates[i].PutStateStub = kvs.PutState
+			}
+
+			resources.Serializer.Serialize("namespaces", "cc-name#4", testDefinition.Parameters(), fakeOrgStates[0])
+			resources.Serializer.Serialize("namespaces", "cc-name#4", &lifecycle.ChaincodeParameters{}, fakeOrgStates[1])
+		})
+
+		It("returns the defined chaincode and the approvals for its sequence", func() {
+			cc, approvals, err := ef.QueryChaincodeDefinitionWithApprovals("cc-name", fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cc.Sequence).To(Equal(int64(4)))
+			Expect(proto.Equal(cc.EndorsementInfo, testDefinition.EndorsementInfo)).To(BeTrue())
+			Expect(approvals).To(Equal(map[string]bool{
+				"org0": true,
+				"org1": false,
+			}))
+		})
+
+		Context("when the chaincode is not defined", func() {
+			It("returns an error", func() {
+				cc, approvals, err := ef.QueryChaincodeDefinitionWithApprovals("missing-name", fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).To(Equal(lifecycle.ErrNamespaceNotDefined{Namespace: "missing-name"}))
+				Expect(cc).To(BeNil())
+				Expect(approvals).To(BeNil())
+			})
+		})
+
+		Context("when an org state cannot be read", func() {
+			BeforeEach(func() {
+				fakeOrgStates[1].GetStateHashReturns(nil, errors.New("state-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				_, _, err := ef.QueryChaincodeDefinitionWithApprovals("cc-name", fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).To(MatchError("serialization check failed for key cc-name#4: could not get value for key namespaces/metadata/cc-name#4: state-error"))
+			})
+		})
+	})
+
+	Describe("QueryOrgApprovals", func() {
+		var (
+			fakeOrgStates []*mock.ReadWritableState
+
+			testDefinition *lifecycle.ChaincodeDefinition
+
+			org0KVS, org1KVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			testDefinition = &lifecycle.ChaincodeDefinition{
+				Sequence: 4,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "endorsement-plugin",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: []byte("validation-parameter"),
+				},
+				Collections: &pb.CollectionConfigPackage{},
+			}
+
+			org0KVS = MapLedgerShim(map[string][]byte{})
+			org1KVS = MapLedgerShim(map[string][]byte{})
+			fakeOrg0State := &mock.ReadWritableState{}
+			fakeOrg0State.CollectionNameReturns("_implicit_org_org0")
+			fakeOrg1State := &mock.ReadWritableState{}
+			fakeOrg1State.CollectionNameReturns("_implicit_org_org1")
+			fakeOrgStates = []*mock.ReadWritableState{
+				fakeOrg0State,
+				fakeOrg1State,
+			}
+			for i, kvs := range []MapLedgerShim{org0KVS, org1KVS} {
+				kvs := kvs
+				fakeOrgStates[i].GetStateStub = kvs.GetState
+				fakeOrgStates[i].GetStateHashStub = kvs.GetStateHash
+				fakeOrgStates[i].PutStateStub = kvs.PutState
+			}
+
+			resources.Serializer.Serialize("namespaces", "cc-name#4", testDefinition.Parameters(), fakeOrgStates[0])
+			resources.Serializer.Serialize("namespaces", "cc-name#4", &lifecycle.ChaincodeParameters{}, fakeOrgStates[1])
+		})
+
+		It("returns the org approvals", func() {
+			approvals, err := ef.QueryOrgApprovals("cc-name", testDefinition, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(approvals).To(Equal(map[string]bool{
+				"org0": true,
+				"org1": false,
+			}))
+		})
+
+		Context("when the org state cannot be deserialized", func() {
+			BeforeEach(func() {
+				fakeOrgStates[0].GetStateHashReturns(nil, errors.New("owww that hurt"))
+			})
+
+			It("wraps and returns an error", func() {
+				approvals, err := ef.QueryOrgApprovals("cc-name", testDefinition, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+				Expect(err).To(MatchError("serialization check failed for key cc-name#4: could not get value for key namespaces/metadata/cc-name#4: owww that hurt"))
+				Expect(approvals).To(BeNil())
+			})
+		})
+	})
+
+	Describe("RecomputeAgreement", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+			fakeOrgStates   []*mock.ReadWritableState
+
+			testDefinition *lifecycle.ChaincodeDefinition
+
+			publicKVS, org0KVS, org1KVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			testDefinition = &lifecycle.ChaincodeDefinition{
+				Sequence: 4,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "endorsement-plugin",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: []byte("validation-parameter"),
+				},
+				Collections: &pb.CollectionConfigPackage{},
+			}
+
+			publicKVS = MapLedgerShim(map[string][]byte{})
 			fakePublicState = &mock.ReadWritableState{}
-			fakePublicKVStore = MapLedgerShim(map[string][]byte{})
+			fakePublicState.GetStateStub = publicKVS.GetState
+			resources.Serializer.Serialize("namespaces", "cc-name", testDefinition, publicKVS)
+
+			org0KVS = MapLedgerShim(map[string][]byte{})
+			org1KVS = MapLedgerShim(map[string][]byte{})
+			fakeOrgStates = []*mock.ReadWritableState{{}, {}}
+			for i, kvs := range []MapLedgerShim{org0KVS, org1KVS} {
+				kvs := kvs
+				fakeOrgStates[i].GetStateStub = kvs.GetState
+				fakeOrgStates[i].GetStateHashStub = kvs.GetStateHash
+			}
+
+			resources.Serializer.Serialize("namespaces", "cc-name#4", testDefinition.Parameters(), org0KVS)
+			resources.Serializer.Serialize("namespaces", "cc-name#4", &lifecycle.ChaincodeParameters{}, org1KVS)
+		})
+
+		It("recomputes each org's agreement with the committed definition", func() {
+			agreement, err := ef.RecomputeAgreement("cc-name", 4, fakePublicState, map[string]lifecycle.OpaqueState{
+				"org0": fakeOrgStates[0],
+				"org1": fakeOrgStates[1],
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(agreement).To(Equal(map[string]bool{
+				"org0": true,
+				"org1": false,
+			}))
+		})
+
+		Context("when an org's state has been repaired", func() {
+			BeforeEach(func() {
+				resources.Serializer.Serialize("namespaces", "cc-name#4", testDefinition.Parameters(), org1KVS)
+			})
+
+			It("reports that the org agrees", func() {
+				agreement, err := ef.RecomputeAgreement("cc-name", 4, fakePublicState, map[string]lifecycle.OpaqueState{
+					"org0": fakeOrgStates[0],
+					"org1": fakeOrgStates[1],
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(agreement).To(Equal(map[string]bool{
+					"org0": true,
+					"org1": true,
+				}))
+			})
+		})
+
+		Context("when the sequence is not the committed sequence", func() {
+			It("returns an error", func() {
+				_, err := ef.RecomputeAgreement("cc-name", 3, fakePublicState, nil)
+				Expect(err).To(MatchError("requested sequence 3 does not match the committed sequence 4 for chaincode cc-name"))
+			})
+		})
+
+		Context("when the chaincode is not defined", func() {
+			It("returns an error", func() {
+				_, err := ef.RecomputeAgreement("other-name", 4, fakePublicState, nil)
+				Expect(err).To(MatchError("namespace other-name is not defined"))
+			})
+		})
+
+		Context("when the serialization check fails", func() {
+			BeforeEach(func() {
+				fakeOrgStates[1].GetStateHashReturns(nil, fmt.Errorf("state-hash-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				_, err := ef.RecomputeAgreement("cc-name", 4, fakePublicState, map[string]lifecycle.OpaqueState{
+					"org1": fakeOrgStates[1],
+				})
+				Expect(err).To(MatchError("serialization check failed for key cc-name#4: could not get value for key namespaces/metadata/cc-name#4: state-hash-error"))
+			})
+		})
+	})
+
+	Describe("ReadinessPercent", func() {
+		var (
+			orgStates map[string]lifecycle.OpaqueState
+
+			testDefinition *lifecycle.ChaincodeDefinition
+		)
+
+		BeforeEach(func() {
+			testDefinition = &lifecycle.ChaincodeDefinition{
+				Sequence: 4,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "endorsement-plugin",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: []byte("validation-parameter"),
+				},
+				Collections: &pb.CollectionConfigPackage{},
+			}
+
+			fakeOrgConfigs = []*mock.ApplicationOrgConfig{{}, {}, {}, {}}
+			orgStates = map[string]lifecycle.OpaqueState{}
+			channelOrgs := map[string]channelconfig.ApplicationOrg{}
+			for i, fakeOrgConfig := range fakeOrgConfigs {
+				mspID := fmt.Sprintf("org%d", i)
+				fakeOrgConfig.MSPIDReturns(mspID)
+				channelOrgs[mspID] = fakeOrgConfig
+
+				kvs := MapLedgerShim(map[string][]byte{})
+				if i < 2 {
+					resources.Serializer.Serialize("namespaces", "cc-name#4", testDefinition.Parameters(), kvs)
+				} else {
+					resources.Serializer.Serialize("namespaces", "cc-name#4", &lifecycle.ChaincodeParameters{}, kvs)
+				}
+				fakeOrgState := &mock.ReadWritableState{}
+				fakeOrgState.GetStateHashStub = kvs.GetStateHash
+				orgStates[mspID] = fakeOrgState
+			}
+			fakeApplicationConfig.OrganizationsReturns(channelOrgs)
+		})
+
+		It("returns the fraction of channel orgs which agree", func() {
+			percent, err := ef.ReadinessPercent("cc-name", testDefinition, "my-channel", orgStates)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(percent).To(Equal(0.5))
+		})
+
+		Context("when an org state is not supplied", func() {
+			BeforeEach(func() {
+				delete(orgStates, "org0")
+			})
+
+			It("counts the org as not agreeing", func() {
+				percent, err := ef.ReadinessPercent("cc-name", testDefinition, "my-channel", orgStates)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(percent).To(Equal(0.25))
+			})
+		})
+
+		Context("when the org state cannot be checked", func() {
+			BeforeEach(func() {
+				fakeOrgState := &mock.ReadWritableState{}
+				fakeOrgState.GetStateHashReturns(nil, errors.New("state-error"))
+				orgStates["org0"] = fakeOrgState
+			})
+
+			It("wraps and returns an error", func() {
+				_, err := ef.ReadinessPercent("cc-name", testDefinition, "my-channel", orgStates)
+				Expect(err).To(MatchError("serialization check failed for key cc-name#4: could not get value for key namespaces/metadata/cc-name#4: state-error"))
+			})
+		})
+
+		Context("when the channel has no application orgs", func() {
+			BeforeEach(func() {
+				fakeApplicationConfig.OrganizationsReturns(nil)
+			})
+
+			It("returns an error", func() {
+				_, err := ef.ReadinessPercent("cc-name", testDefinition, "my-channel", orgStates)
+				Expect(err).To(MatchError("no application orgs defined for channel 'my-channel'"))
+			})
+		})
+
+		Context("when the application config cannot be retrieved", func() {
+			BeforeEach(func() {
+				fakeChannelConfig.ApplicationConfigReturns(nil, false)
+			})
+
+			It("returns an error", func() {
+				_, err := ef.ReadinessPercent("cc-name", testDefinition, "my-channel", orgStates)
+				Expect(err).To(MatchError("could not get application config for channel 'my-channel'"))
+			})
+		})
+
+		Context("when the channel config cannot be retrieved", func() {
+			BeforeEach(func() {
+				fakeChannelConfigSource.GetStableChannelConfigReturns(nil)
+			})
+
+			It("returns an error", func() {
+				_, err := ef.ReadinessPercent("cc-name", testDefinition, "my-channel", orgStates)
+				Expect(err).To(MatchError("could not get channel config for channel 'my-channel'"))
+			})
+		})
+	})
+
+	Describe("CommitPreflight", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+			orgKVSs         map[string]MapLedgerShim
+			orgStates       map[string]lifecycle.OpaqueState
+
+			testDefinition *lifecycle.ChaincodeDefinition
+		)
+
+		BeforeEach(func() {
+			testDefinition = &lifecycle.ChaincodeDefinition{
+				Sequence: 4,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "endorsement-plugin",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: []byte("validation-parameter"),
+				},
+				Collections: &pb.CollectionConfigPackage{},
+			}
+
+			publicKVS := MapLedgerShim(map[string][]byte{})
 			fakePublicState = &mock.ReadWritableState{}
-			fakePublicState.PutStateStub = fakePublicKVStore.PutState
-			fakePublicState.GetStateStub = fakePublicKVStore.GetState
+			fakePublicState.GetStateStub = publicKVS.GetState
+			resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
+				Sequence: 3,
+			}, publicKVS)
+
+			fakeOrgConfigs = []*mock.ApplicationOrgConfig{{}, {}, {}}
+			orgKVSs = map[string]MapLedgerShim{}
+			orgStates = map[string]lifecycle.OpaqueState{}
+			channelOrgs := map[string]channelconfig.ApplicationOrg{}
+			for i, fakeOrgConfig := range fakeOrgConfigs {
+				mspID := fmt.Sprintf("org%d", i)
+				fakeOrgConfig.MSPIDReturns(mspID)
+				channelOrgs[mspID] = fakeOrgConfig
+
+				kvs := MapLedgerShim(map[string][]byte{})
+				orgKVSs[mspID] = kvs
+				fakeOrgState := &mock.ReadWritableState{}
+				fakeOrgState.GetStateHashStub = kvs.GetStateHash
+				fakeOrgState.CollectionNameReturns(lifecycle.ImplicitCollectionNameForOrg(mspID))
+				orgStates[mspID] = fakeOrgState
+			}
+			fakeApplicationConfig.OrganizationsReturns(channelOrgs)
+			fakePolicyManager.GetPolicyReturns(nil, false)
+
+			resources.Serializer.Serialize("namespaces", "cc-name#4", testDefinition.Parameters(), orgKVSs["org0"])
+			resources.Serializer.Serialize("namespaces", "cc-name#4", testDefinition.Parameters(), orgKVSs["org1"])
+		})
+
+		It("reports that the definition is ready to commit", func() {
+			result, err := ef.CommitPreflight("cc-name", testDefinition, "my-channel", fakePublicState, orgStates)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Ready()).To(BeTrue())
+			Expect(result.Issues).To(BeEmpty())
+			Expect(result.Approvals).To(Equal(map[string]bool{
+				"org0": true,
+				"org1": true,
+				"org2": false,
+			}))
+			Expect(fakePublicState.PutStateCallCount()).To(Equal(0))
+		})
+
+		Context("when the definition has several problems", func() {
+			BeforeEach(func() {
+				testDefinition.Sequence = 6
+				testDefinition.Collections = &pb.CollectionConfigPackage{
+					Config: []*pb.CollectionConfig{
+						{
+							Payload: &pb.CollectionConfig_StaticCollectionConfig{
+								StaticCollectionConfig: &pb.StaticCollectionConfig{
+									Name:              "collection-name",
+									RequiredPeerCount: 2,
+									MaximumPeerCount:  1,
+								},
+							},
+						},
+					},
+				}
+				testDefinition.ValidationInfo.ValidationParameter = protoutil.MarshalOrPanic(&pb.ApplicationPolicy{
+					Type: &pb.ApplicationPolicy_SignaturePolicy{
+						SignaturePolicy: policydsl.SignedByMspMember("unknown-org"),
+					},
+				})
+			})
+
+			It("reports every issue", func() {
+				result, err := ef.CommitPreflight("cc-name", testDefinition, "my-channel", fakePublicState, orgStates)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Ready()).To(BeFalse())
+				Expect(result.Issues).To(Equal([]string{
+					"invalid collection configuration: collection-name: collection-name -- maximum peer count (1) cannot be less than the required peer count (2)",
+					"requested sequence is 6, but new definition must be sequence 4",
+					"endorsement policy cannot be satisfied by the orgs of the channel",
+					"the 0 approving orgs of the 3 channel orgs cannot satisfy the lifecycle endorsement policy",
+				}))
+			})
+		})
+
+		Context("when the lifecycle endorsement policy is a channel config policy", func() {
+			var fakePolicy *mock.ConvertiblePolicy
+
+			BeforeEach(func() {
+				fakePolicy = &mock.ConvertiblePolicy{}
+				fakePolicy.ConvertReturns(policydsl.SignedByNOutOfGivenRole(3, mb.MSPRole_PEER, []string{"org0", "org1", "org2"}), nil)
+				fakePolicyManager.GetPolicyReturns(fakePolicy, true)
+			})
+
+			It("evaluates the policy against the approving orgs", func() {
+				result, err := ef.CommitPreflight("cc-name", testDefinition, "my-channel", fakePublicState, orgStates)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Issues).To(Equal([]string{
+					"the 2 approving orgs of the 3 channel orgs cannot satisfy the lifecycle endorsement policy",
+				}))
+				Expect(fakePolicyManager.GetPolicyArgsForCall(fakePolicyManager.GetPolicyCallCount() - 1)).To(Equal("/Channel/Application/LifecycleEndorsement"))
+			})
+
+			Context("when every org has approved", func() {
+				BeforeEach(func() {
+					resources.Serializer.Serialize("namespaces", "cc-name#4", testDefinition.Parameters(), orgKVSs["org2"])
+				})
+
+				It("reports that the definition is ready to commit", func() {
+					result, err := ef.CommitPreflight("cc-name", testDefinition, "my-channel", fakePublicState, orgStates)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(result.Ready()).To(BeTrue())
+				})
+			})
+
+			Context("when the policy is not convertible", func() {
+				BeforeEach(func() {
+					fakePolicyManager.GetPolicyReturns(&mock.InconvertiblePolicy{}, true)
+				})
+
+				It("returns an error", func() {
+					_, err := ef.CommitPreflight("cc-name", testDefinition, "my-channel", fakePublicState, orgStates)
+					Expect(err).To(MatchError("policy '/Channel/Application/LifecycleEndorsement' on channel 'my-channel' is not convertible to SignaturePolicyEnvelope"))
+				})
+			})
+		})
+
+		Context("when the chaincode name is invalid", func() {
+			It("reports the issue", func() {
+				result, err := ef.CommitPreflight("cc-name!", testDefinition, "my-channel", fakePublicState, orgStates)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Issues).To(ContainElement(ContainSubstring("invalid chaincode name 'cc-name!'")))
+			})
+		})
+
+		Context("when agreement is required to commit", func() {
+			BeforeEach(func() {
+				resources.RequireAgreementToCommit = true
+				testDefinition.EndorsementInfo.Version = "other-version"
+			})
+
+			It("reports the issue", func() {
+				result, err := ef.CommitPreflight("cc-name", testDefinition, "my-channel", fakePublicState, orgStates)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Issues).To(ContainElement("no org agrees with the chaincode definition for cc-name at sequence 4"))
+			})
+		})
+
+		Context("when agreeing orgs approved different packages", func() {
+			BeforeEach(func() {
+				ef.RequireUniformPackage = true
+				resources.Serializer.Serialize("chaincode-sources", "cc-name#4", &lifecycle.ChaincodeLocalPackage{PackageID: "package-id"}, orgKVSs["org0"])
+				resources.Serializer.Serialize("chaincode-sources", "cc-name#4", &lifecycle.ChaincodeLocalPackage{PackageID: "other-package-id"}, orgKVSs["org1"])
+			})
+
+			It("reports the issue", func() {
+				result, err := ef.CommitPreflight("cc-name", testDefinition, "my-channel", fakePublicState, orgStates)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Issues).To(Equal([]string{
+					"agreeing orgs 'org0' and 'org1' approved different packages",
+				}))
+			})
+		})
+
+		Context("when the definition is already committed", func() {
+			BeforeEach(func() {
+				publicKVS := MapLedgerShim(map[string][]byte{})
+				fakePublicState.GetStateStub = publicKVS.GetState
+				testDefinition.Sequence = 3
+				err := resources.Serializer.Serialize("namespaces", "cc-name", testDefinition, publicKVS)
+				Expect(err).NotTo(HaveOccurred())
+				resources.Serializer.Serialize("namespaces", "cc-name#3", testDefinition.Parameters(), orgKVSs["org0"])
+			})
+
+			It("reports that the definition is ready to commit", func() {
+				result, err := ef.CommitPreflight("cc-name", testDefinition, "my-channel", fakePublicState, orgStates)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Ready()).To(BeTrue())
+				Expect(result.Approvals).To(Equal(map[string]bool{
+					"org0": true,
+					"org1": false,
+					"org2": false,
+				}))
+			})
+		})
+
+		Context("when the endorsement info is missing", func() {
+			BeforeEach(func() {
+				testDefinition.EndorsementInfo = nil
+			})
+
+			It("reports the issue without panicking", func() {
+				result, err := ef.CommitPreflight("cc-name", testDefinition, "my-channel", fakePublicState, orgStates)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Issues).To(ContainElement("chaincode definition must specify endorsement info and validation info"))
+			})
+		})
+
+		Context("when the current sequence cannot be read", func() {
+			BeforeEach(func() {
+				fakePublicState.GetStateReturns(nil, fmt.Errorf("get-state-error"))
+			})
+
+			It("returns an error", func() {
+				_, err := ef.CommitPreflight("cc-name", testDefinition, "my-channel", fakePublicState, orgStates)
+				Expect(err).To(MatchError("could not get current sequence: could not get state for key namespaces/fields/cc-name/Sequence: get-state-error"))
+			})
+		})
+
+		Context("when the channel config cannot be retrieved", func() {
+			BeforeEach(func() {
+				fakeChannelConfigSource.GetStableChannelConfigReturns(nil)
+			})
+
+			It("returns an error", func() {
+				_, err := ef.CommitPreflight("cc-name", testDefinition, "my-channel", fakePublicState, orgStates)
+				Expect(err).To(MatchError("could not get channel config for channel 'my-channel'"))
+			})
+		})
+	})
+
+	Describe("QueryApprovedChaincode", func() {
+		var (
+			fakeOrgState *mock.ReadWritableState
+
+			testParameters *lifecycle.ChaincodeParameters
+
+			orgKVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			testParameters = &lifecycle.ChaincodeParameters{
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "endorsement-plugin",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: []byte("validation-parameter"),
+				},
+				Collections: &pb.CollectionConfigPackage{},
+			}
+
+			orgKVS = MapLedgerShim(map[string][]byte{})
+			fakeOrgState = &mock.ReadWritableState{}
+			fakeOrgState.GetStateStub = orgKVS.GetState
+
+			resources.Serializer.Serialize("namespaces", "cc-name#4", testParameters, orgKVS)
+		})
+
+		It("returns the approved parameters", func() {
+			cp, ok, err := ef.QueryApprovedChaincode("cc-name", 4, fakeOrgState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(proto.Equal(cp.EndorsementInfo, testParameters.EndorsementInfo)).To(BeTrue())
+			Expect(proto.Equal(cp.ValidationInfo, testParameters.ValidationInfo)).To(BeTrue())
+			Expect(proto.Equal(cp.Collections, testParameters.Collections)).To(BeTrue())
+		})
+
+		Context("when nothing is approved for the sequence", func() {
+			It("returns not ok without an error", func() {
+				cp, ok, err := ef.QueryApprovedChaincode("cc-name", 5, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeFalse())
+				Expect(cp).To(BeNil())
+			})
+		})
+
+		Context("when the metadata is not for chaincode parameters", func() {
+			BeforeEach(func() {
+				resources.Serializer.Serialize("namespaces", "cc-name#4", &lifecycle.ChaincodeDefinition{}, orgKVS)
+			})
+
+			It("returns an error", func() {
+				_, _, err := ef.QueryApprovedChaincode("cc-name", 4, fakeOrgState)
+				Expect(err).To(MatchError("not a chaincode parameters type: ChaincodeDefinition"))
+			})
+		})
+
+		Context("when getting the metadata fails", func() {
+			BeforeEach(func() {
+				fakeOrgState.GetStateReturns(nil, fmt.Errorf("metadata-error"))
+			})
+
+			It("wraps and returns an error", func() {
+				_, _, err := ef.QueryApprovedChaincode("cc-name", 4, fakeOrgState)
+				Expect(err).To(MatchError("could not deserialize namespace metadata for cc-name#4: could not query metadata for namespace namespaces/cc-name#4: metadata-error"))
+			})
+		})
+
+		Context("when deserializing the parameters fails", func() {
+			BeforeEach(func() {
+				orgKVS["namespaces/fields/cc-name#4/EndorsementInfo"] = []byte("garbage")
+			})
+
+			It("wraps and returns an error", func() {
+				_, _, err := ef.QueryApprovedChaincode("cc-name", 4, fakeOrgState)
+				Expect(err).To(MatchError("could not deserialize chaincode parameters for cc-name#4: could not unmarshal state for key namespaces/fields/cc-name#4/EndorsementInfo: proto: can't skip unknown wire type 7"))
+			})
+		})
+	})
+
+	Describe("QueryHistoricalDefinition", func() {
+		var (
+			testParameters *lifecycle.ChaincodeParameters
+
+			fakeOrgStates []*mock.ReadWritableState
+			orgKVSs       []MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			testParameters = &lifecycle.ChaincodeParameters{
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version:           "version",
+					EndorsementPlugin: "endorsement-plugin",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationPlugin:    "validation-plugin",
+					ValidationParameter: []byte("validation-parameter"),
+				},
+				Collections: &pb.CollectionConfigPackage{},
+			}
+
+			orgKVSs = []MapLedgerShim{{}, {}, {}}
+			resources.Serializer.Serialize("namespaces", "cc-name#2", testParameters, orgKVSs[1])
+			resources.Serializer.Serialize("namespaces", "cc-name#2", testParameters, orgKVSs[2])
+
+			fakeOrgStates = []*mock.ReadWritableState{{}, {}, {}}
+			for i, fakeOrgState := range fakeOrgStates {
+				fakeOrgState.GetStateStub = orgKVSs[i].GetState
+			}
+		})
+
+		orgStates := func() []lifecycle.ReadableState {
+			return []lifecycle.ReadableState{fakeOrgStates[0], fakeOrgStates[1], fakeOrgStates[2]}
+		}
+
+		It("reconstructs the definition from the orgs which approved the sequence", func() {
+			cd, err := ef.QueryHistoricalDefinition("cc-name", 2, orgStates())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cd.Sequence).To(Equal(int64(2)))
+			Expect(proto.Equal(cd.EndorsementInfo, testParameters.EndorsementInfo)).To(BeTrue())
+			Expect(proto.Equal(cd.ValidationInfo, testParameters.ValidationInfo)).To(BeTrue())
+			Expect(proto.Equal(cd.Collections, testParameters.Collections)).To(BeTrue())
+		})
+
+		Context("when the first org approved different parameters", func() {
+			BeforeEach(func() {
+				resources.Serializer.Serialize("namespaces", "cc-name#2", &lifecycle.ChaincodeParameters{
+					EndorsementInfo: &lb.ChaincodeEndorsementInfo{Version: "other-version"},
+					ValidationInfo:  &lb.ChaincodeValidationInfo{},
+					Collections:     &pb.CollectionConfigPackage{},
+				}, orgKVSs[0])
+			})
+
+			It("returns the parameters most orgs approved", func() {
+				cd, err := ef.QueryHistoricalDefinition("cc-name", 2, orgStates())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cd.EndorsementInfo.Version).To(Equal("version"))
+			})
+
+			Context("and the orgs are evenly split", func() {
+				BeforeEach(func() {
+					delete(orgKVSs[2], "namespaces/metadata/cc-name#2")
+				})
+
+				It("returns an error", func() {
+					_, err := ef.QueryHistoricalDefinition("cc-name", 2, orgStates())
+					Expect(err).To(MatchError("orgs are evenly split between different parameters for chaincode cc-name at sequence 2"))
+				})
+			})
+		})
+
+		Context("when no org approved the sequence", func() {
+			It("returns an error", func() {
+				_, err := ef.QueryHistoricalDefinition("cc-name", 3, orgStates())
+				Expect(err).To(MatchError("no org has approved chaincode cc-name at sequence 3"))
+			})
+		})
+
+		Context("when reading an org state fails", func() {
+			BeforeEach(func() {
+				fakeOrgStates[0].GetStateStub = nil
+				fakeOrgStates[0].GetStateReturns(nil, fmt.Errorf("state-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				_, err := ef.QueryHistoricalDefinition("cc-name", 2, orgStates())
+				Expect(err).To(MatchError("could not query approval in org state 0: could not deserialize namespace metadata for cc-name#2: could not query metadata for namespace namespaces/cc-name#2: state-error"))
+			})
+		})
+	})
+
+	Describe("QueryChaincodeSourcePackageID", func() {
+		var (
+			fakeOrgState *mock.ReadWritableState
+
+			orgKVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			orgKVS = MapLedgerShim(map[string][]byte{})
+			fakeOrgState = &mock.ReadWritableState{}
+			fakeOrgState.GetStateStub = orgKVS.GetState
+
+			resources.Serializer.Serialize("chaincode-sources", "cc-name#4", &lifecycle.ChaincodeLocalPackage{PackageID: "label:hash"}, orgKVS)
+			resources.Serializer.Serialize("chaincode-sources", "cc-name#5", &lifecycle.ChaincodeLocalPackage{}, orgKVS)
+		})
+
+		It("returns the approved package ID", func() {
+			packageID, ok, err := ef.QueryChaincodeSourcePackageID("cc-name", 4, fakeOrgState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(packageID).To(Equal("label:hash"))
+		})
+
+		Context("when the org approved without a package", func() {
+			It("returns not ok without an error", func() {
+				_, ok, err := ef.QueryChaincodeSourcePackageID("cc-name", 5, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeFalse())
+			})
+		})
+
+		Context("when nothing is approved for the sequence", func() {
+			It("returns not ok without an error", func() {
+				_, ok, err := ef.QueryChaincodeSourcePackageID("cc-name", 6, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeFalse())
+			})
+		})
+
+		Context("when the metadata is not for a local package", func() {
+			BeforeEach(func() {
+				resources.Serializer.Serialize("chaincode-sources", "cc-name#4", &lifecycle.ChaincodeParameters{}, orgKVS)
+			})
+
+			It("returns an error", func() {
+				_, _, err := ef.QueryChaincodeSourcePackageID("cc-name", 4, fakeOrgState)
+				Expect(err).To(MatchError("not a chaincode local package type: ChaincodeParameters"))
+			})
+		})
 
-			fakeOrgKVStore = MapLedgerShim(map[string][]byte{})
-			fakeOrgState = &mock.ReadWritableState{}
-			fakeOrgState.PutStateStub = fakeOrgKVStore.PutState
-			fakeOrgState.GetStateStub = fakeOrgKVStore.GetState
+		Context("when the org state cannot be read", func() {
+			BeforeEach(func() {
+				fakeOrgState.GetStateReturns(nil, fmt.Errorf("get-state-error"))
+			})
 
-			err := resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
-				Sequence: 4,
-			}, fakePublicKVStore)
-			Expect(err).NotTo(HaveOccurred())
+			It("wraps and returns the error", func() {
+				_, _, err := ef.QueryChaincodeSourcePackageID("cc-name", 4, fakeOrgState)
+				Expect(err).To(MatchError("could not deserialize chaincode-source metadata for cc-name#4: could not query metadata for namespace chaincode-sources/cc-name#4: get-state-error"))
+			})
 		})
+	})
 
-		It("serializes the chaincode parameters to the org scoped collection", func() {
-			err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
-			Expect(err).NotTo(HaveOccurred())
+	Describe("QueryChaincodeSourceHash", func() {
+		var (
+			fakeOrgState *mock.ReadWritableState
 
-			metadata, ok, err := resources.Serializer.DeserializeMetadata("namespaces", "cc-name#5", fakeOrgState)
-			Expect(err).NotTo(HaveOccurred())
-			Expect(ok).To(BeTrue())
-			committedDefinition := &lifecycle.ChaincodeParameters{}
-			err = resources.Serializer.Deserialize("namespaces", "cc-name#5", metadata, committedDefinition, fakeOrgState)
-			Expect(err).NotTo(HaveOccurred())
-			Expect(committedDefinition.EndorsementInfo.Version).To(Equal("version"))
-			Expect(committedDefinition.EndorsementInfo.EndorsementPlugin).To(Equal("my endorsement plugin"))
-			Expect(proto.Equal(committedDefinition.ValidationInfo, &lb.ChaincodeValidationInfo{
-				ValidationPlugin:    "my validation plugin",
-				ValidationParameter: []byte("some awesome policy"),
-			})).To(BeTrue())
-			Expect(proto.Equal(committedDefinition.Collections, &pb.CollectionConfigPackage{})).To(BeTrue())
+			orgKVS MapLedgerShim
+		)
 
-			metadata, ok, err = resources.Serializer.DeserializeMetadata("chaincode-sources", "cc-name#5", fakeOrgState)
+		BeforeEach(func() {
+			orgKVS = MapLedgerShim(map[string][]byte{})
+			fakeOrgState = &mock.ReadWritableState{}
+			fakeOrgState.GetStateStub = orgKVS.GetState
+
+			resources.Serializer.Serialize("chaincode-sources", "cc-name#4", &lifecycle.ChaincodeLocalPackage{PackageID: "label:0a0b"}, orgKVS)
+			resources.Serializer.Serialize("chaincode-sources", "cc-name#5", &lifecycle.ChaincodeLocalPackage{}, orgKVS)
+		})
+
+		It("returns the hash of the approved package", func() {
+			hash, ok, err := ef.QueryChaincodeSourceHash("cc-name", 4, fakeOrgState)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(ok).To(BeTrue())
-			localPackage := &lifecycle.ChaincodeLocalPackage{}
-			err = resources.Serializer.Deserialize("chaincode-sources", "cc-name#5", metadata, localPackage, fakeOrgState)
+			Expect(hash).To(Equal([]byte{0x0a, 0x0b}))
+		})
+
+		Context("when the org approved without a package", func() {
+			It("returns not ok without an error", func() {
+				hash, ok, err := ef.QueryChaincodeSourceHash("cc-name", 5, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeFalse())
+				Expect(hash).To(BeNil())
+			})
+		})
+
+		Context("when the package ID does not end in a hex hash", func() {
+			BeforeEach(func() {
+				resources.Serializer.Serialize("chaincode-sources", "cc-name#4", &lifecycle.ChaincodeLocalPackage{PackageID: "label:not-hex"}, orgKVS)
+			})
+
+			It("returns an error", func() {
+				_, _, err := ef.QueryChaincodeSourceHash("cc-name", 4, fakeOrgState)
+				Expect(err).To(MatchError(ContainSubstring("could not decode hash of package ID 'label:not-hex' for cc-name#4")))
+			})
+		})
+
+		Context("when the org state cannot be read", func() {
+			BeforeEach(func() {
+				fakeOrgState.GetStateReturns(nil, fmt.Errorf("get-state-error"))
+			})
+
+			It("returns the error", func() {
+				_, _, err := ef.QueryChaincodeSourceHash("cc-name", 4, fakeOrgState)
+				Expect(err).To(MatchError("could not deserialize chaincode-source metadata for cc-name#4: could not query metadata for namespace chaincode-sources/cc-name#4: get-state-error"))
+			})
+		})
+	})
+
+	Describe("CheckDefinitionReadiness", func() {
+		var (
+			fakeOrgState *mock.ReadWritableState
+
+			orgKVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			orgKVS = MapLedgerShim(map[string][]byte{})
+			fakeOrgState = &mock.ReadWritableState{}
+			fakeOrgState.GetStateStub = orgKVS.GetState
+
+			resources.Serializer.Serialize("chaincode-sources", "cc-name#4", &lifecycle.ChaincodeLocalPackage{PackageID: "label:hash"}, orgKVS)
+			resources.Serializer.Serialize("chaincode-sources", "cc-name#5", &lifecycle.ChaincodeLocalPackage{}, orgKVS)
+
+			fakeCCStore.RetrieveHashByPackageIDReturns([]byte("hash"), nil)
+		})
+
+		It("reports that the approved package is installed", func() {
+			installed, err := ef.CheckDefinitionReadiness("cc-name", &lifecycle.ChaincodeDefinition{Sequence: 4}, fakeOrgState)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(localPackage).To(Equal(&lifecycle.ChaincodeLocalPackage{
-				PackageID: "hash",
-			}))
+			Expect(installed).To(BeTrue())
+
+			Expect(fakeCCStore.RetrieveHashByPackageIDCallCount()).To(Equal(1))
+			Expect(fakeCCStore.RetrieveHashByPackageIDArgsForCall(0)).To(Equal("label:hash"))
 		})
 
-		Context("when the peer sets defaults", func() {
+		Context("when the approved package is not installed", func() {
 			BeforeEach(func() {
-				testDefinition.EndorsementInfo.EndorsementPlugin = ""
-				testDefinition.ValidationInfo.ValidationPlugin = ""
-				testDefinition.ValidationInfo.ValidationParameter = nil
+				fakeCCStore.RetrieveHashByPackageIDReturns(nil, &persistence.CodePackageNotFoundErr{PackageID: "label:hash"})
 			})
 
-			It("uses appropriate defaults", func() {
-				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
+			It("reports that the package is not installed", func() {
+				installed, err := ef.CheckDefinitionReadiness("cc-name", &lifecycle.ChaincodeDefinition{Sequence: 4}, fakeOrgState)
 				Expect(err).NotTo(HaveOccurred())
+				Expect(installed).To(BeFalse())
+			})
+		})
 
-				metadata, ok, err := resources.Serializer.DeserializeMetadata("namespaces", "cc-name#5", fakeOrgState)
-				Expect(err).NotTo(HaveOccurred())
-				Expect(ok).To(BeTrue())
-				committedDefinition := &lifecycle.ChaincodeParameters{}
-				err = resources.Serializer.Deserialize("namespaces", "cc-name#5", metadata, committedDefinition, fakeOrgState)
+		Context("when the org approved without a package", func() {
+			It("reports that the package is not installed", func() {
+				installed, err := ef.CheckDefinitionReadiness("cc-name", &lifecycle.ChaincodeDefinition{Sequence: 5}, fakeOrgState)
 				Expect(err).NotTo(HaveOccurred())
-				Expect(committedDefinition.EndorsementInfo.Version).To(Equal("version"))
-				Expect(committedDefinition.EndorsementInfo.EndorsementPlugin).To(Equal("escc"))
-				Expect(proto.Equal(committedDefinition.ValidationInfo, &lb.ChaincodeValidationInfo{
-					ValidationPlugin: "vscc",
-					ValidationParameter: protoutil.MarshalOrPanic(
-						&pb.ApplicationPolicy{
-							Type: &pb.ApplicationPolicy_ChannelConfigPolicyReference{
-								ChannelConfigPolicyReference: "/Channel/Application/Endorsement",
-							},
-						}),
-				})).To(BeTrue())
-				Expect(proto.Equal(committedDefinition.Collections, &pb.CollectionConfigPackage{})).To(BeTrue())
+				Expect(installed).To(BeFalse())
+				Expect(fakeCCStore.RetrieveHashByPackageIDCallCount()).To(Equal(0))
 			})
+		})
 
-			Context("when no default endorsement policy is defined on thc channel", func() {
-				BeforeEach(func() {
-					fakePolicyManager.GetPolicyReturns(nil, false)
-				})
+		Context("when the chaincode store fails", func() {
+			BeforeEach(func() {
+				fakeCCStore.RetrieveHashByPackageIDReturns(nil, fmt.Errorf("store-error"))
+			})
 
-				It("returns an error", func() {
-					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
-					Expect(err).To(MatchError(ContainSubstring("could not set defaults for chaincode definition in channel my-channel: policy '/Channel/Application/Endorsement' must be defined for channel 'my-channel' before chaincode operations can be attempted")))
-				})
+			It("wraps and returns the error", func() {
+				_, err := ef.CheckDefinitionReadiness("cc-name", &lifecycle.ChaincodeDefinition{Sequence: 4}, fakeOrgState)
+				Expect(err).To(MatchError("could not retrieve hash for package 'label:hash': store-error"))
 			})
+		})
+	})
 
-			Context("when obtaining a stable channel config fails", func() {
-				BeforeEach(func() {
-					fakeChannelConfigSource.GetStableChannelConfigReturns(nil)
-				})
+	Describe("QueryApprovalHistory", func() {
+		var (
+			fakeOrgState *mock.ReadWritableState
 
-				It("returns an error", func() {
-					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
-					Expect(err).To(MatchError(ContainSubstring("could not get channel config for channel 'my-channel'")))
-				})
-			})
+			orgKVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			orgKVS = MapLedgerShim(map[string][]byte{})
+			fakeOrgState = &mock.ReadWritableState{}
+			fakeOrgState.GetStateRangeStub = orgKVS.GetStateRange
+
+			for _, privateName := range []string{"cc-name#10", "cc-name#2", "cc-name#3", "cc-name-other#4"} {
+				resources.Serializer.Serialize("namespaces", privateName, &lifecycle.ChaincodeParameters{}, orgKVS)
+			}
+			resources.Serializer.Serialize("namespaces", "cc-name#bad", &lifecycle.ChaincodeParameters{}, orgKVS)
+			resources.Serializer.Serialize("namespaces", "cc-name#5", &lifecycle.ChaincodeLocalPackage{}, orgKVS)
 		})
 
-		Context("when the current sequence is undefined and the requested sequence is 0", func() {
+		It("returns the sorted sequences the org approved", func() {
+			sequences, err := ef.QueryApprovalHistory("cc-name", fakeOrgState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sequences).To(Equal([]int64{2, 3, 10}))
+
+			Expect(fakeOrgState.GetStateRangeArgsForCall(0)).To(Equal("namespaces/metadata/cc-name#"))
+		})
+
+		Context("when the range cannot be retrieved", func() {
 			BeforeEach(func() {
-				fakePublicKVStore = map[string][]byte{}
+				fakeOrgState.GetStateRangeReturns(nil, fmt.Errorf("state-range-error"))
 			})
 
-			It("returns an error", func() {
-				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "unknown-name", &lifecycle.ChaincodeDefinition{}, "hash", fakePublicState, fakeOrgState)
-				Expect(err).To(MatchError("requested sequence is 0, but first definable sequence number is 1"))
+			It("wraps and returns the error", func() {
+				_, err := ef.QueryApprovalHistory("cc-name", fakeOrgState)
+				Expect(err).To(MatchError("could not get state range for approvals of chaincode cc-name: state-range-error"))
 			})
 		})
+	})
 
-		Context("when the sequence number already has a committed definition", func() {
+	Describe("QueryPendingApprovals", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+			fakeOrgState    *mock.ReadWritableState
+
+			publicKVS, orgKVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateRangeStub = publicKVS.GetStateRange
+
+			orgKVS = MapLedgerShim(map[string][]byte{})
+			fakeOrgState = &mock.ReadWritableState{}
+			fakeOrgState.GetStateRangeStub = orgKVS.GetStateRange
+
+			resources.Serializer.Serialize("namespaces", "cc-pending", &lifecycle.ChaincodeDefinition{Sequence: 2}, publicKVS)
+			resources.Serializer.Serialize("namespaces", "cc-committed", &lifecycle.ChaincodeDefinition{Sequence: 3}, publicKVS)
+
+			for _, privateName := range []string{"cc-pending#2", "cc-pending#3", "cc-committed#3", "cc-new#1", "cc-ahead#2"} {
+				resources.Serializer.Serialize("namespaces", privateName, &lifecycle.ChaincodeParameters{}, orgKVS)
+			}
+			resources.Serializer.Serialize("namespaces", "cc-committed#4", &lifecycle.ChaincodeLocalPackage{}, orgKVS)
+		})
+
+		It("returns the approvals at the next sequence of each chaincode", func() {
+			pending, err := ef.QueryPendingApprovals(fakePublicState, fakeOrgState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pending).To(Equal(map[string]int64{
+				"cc-pending": 3,
+				"cc-new":     1,
+			}))
+		})
+
+		Context("when the public state cannot be ranged over", func() {
 			BeforeEach(func() {
-				err := resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
-					Sequence: 5,
-					EndorsementInfo: &lb.ChaincodeEndorsementInfo{
-						Version:           "version",
-						EndorsementPlugin: "my endorsement plugin",
-					},
-					ValidationInfo: &lb.ChaincodeValidationInfo{
-						ValidationPlugin:    "my validation plugin",
-						ValidationParameter: []byte("some awesome policy"),
-					},
-				}, fakePublicState)
-				Expect(err).NotTo(HaveOccurred())
+				fakePublicState.GetStateRangeReturns(nil, fmt.Errorf("range-error"))
 			})
 
-			It("verifies that the definition matches before writing", func() {
-				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
-				Expect(err).NotTo(HaveOccurred())
+			It("wraps and returns the error", func() {
+				_, err := ef.QueryPendingApprovals(fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("could not query committed namespace fields: range-error"))
 			})
+		})
 
-			Context("when the current definition is not found", func() {
-				BeforeEach(func() {
-					delete(fakePublicKVStore, "namespaces/metadata/cc-name")
-				})
+		Context("when the org state cannot be ranged over", func() {
+			BeforeEach(func() {
+				fakeOrgState.GetStateRangeReturns(nil, fmt.Errorf("range-error"))
+			})
 
-				It("returns an error", func() {
-					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
-					Expect(err).To(MatchError("missing metadata for currently committed sequence number (5)"))
-				})
+			It("wraps and returns the error", func() {
+				_, err := ef.QueryPendingApprovals(fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("could not query approved namespace metadata: could not get state range for namespace namespaces: range-error"))
 			})
+		})
+	})
 
-			Context("when the current definition is corrupt", func() {
-				BeforeEach(func() {
-					fakePublicKVStore["namespaces/metadata/cc-name"] = []byte("garbage")
-				})
+	Describe("CompareApprovalToCommitted", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+			fakeOrgState    *mock.ReadWritableState
 
-				It("returns an error", func() {
-					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
-					Expect(err).To(MatchError("could not fetch metadata for current definition: could not unmarshal metadata for namespace namespaces/cc-name: proto: can't skip unknown wire type 7"))
-				})
-			})
+			publicKVS, orgKVS MapLedgerShim
+		)
 
-			Context("when the current definition is not a chaincode", func() {
-				BeforeEach(func() {
-					fakePublicKVStore = map[string][]byte{}
-					type OtherStruct struct {
-						Sequence int64
-					}
-					err := resources.Serializer.Serialize("namespaces", "cc-name", &OtherStruct{
-						Sequence: 5,
-					}, fakePublicState)
-					Expect(err).NotTo(HaveOccurred())
-				})
+		BeforeEach(func() {
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateStub = publicKVS.GetState
 
-				It("returns an error", func() {
-					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
-					Expect(err).To(MatchError("could not deserialize namespace cc-name as chaincode: type name mismatch 'ChaincodeDefinition' != 'OtherStruct'"))
-				})
-			})
+			orgKVS = MapLedgerShim(map[string][]byte{})
+			fakeOrgState = &mock.ReadWritableState{}
+			fakeOrgState.GetStateRangeStub = orgKVS.GetStateRange
 
-			Context("when the Version in the new definition differs from the current definition", func() {
-				BeforeEach(func() {
-					fakePublicKVStore = map[string][]byte{}
+			resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{Sequence: 3}, publicKVS)
+			for _, privateName := range []string{"cc-name#2", "cc-name#3", "cc-name#4"} {
+				resources.Serializer.Serialize("namespaces", privateName, &lifecycle.ChaincodeParameters{}, orgKVS)
+			}
+		})
 
-					err := resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
-						Sequence: 5,
-						EndorsementInfo: &lb.ChaincodeEndorsementInfo{
-							Version: "other-version",
-						},
-					}, fakePublicState)
-					Expect(err).NotTo(HaveOccurred())
-				})
+		It("reports that the org approved the committed sequence", func() {
+			committedSeq, approvedSeqs, matches, err := ef.CompareApprovalToCommitted("cc-name", fakePublicState, fakeOrgState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(committedSeq).To(Equal(int64(3)))
+			Expect(approvedSeqs).To(Equal([]int64{2, 3, 4}))
+			Expect(matches).To(BeTrue())
+		})
 
-				It("returns an error", func() {
-					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
-					Expect(err).To(MatchError("attempted to redefine the current committed sequence (5) for namespace cc-name with different parameters: expected Version 'other-version' does not match passed Version 'version'"))
-				})
+		Context("when the org did not approve the committed sequence", func() {
+			BeforeEach(func() {
+				resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{Sequence: 5}, publicKVS)
+			})
+
+			It("reports that the approval does not match", func() {
+				committedSeq, approvedSeqs, matches, err := ef.CompareApprovalToCommitted("cc-name", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(committedSeq).To(Equal(int64(5)))
+				Expect(approvedSeqs).To(Equal([]int64{2, 3, 4}))
+				Expect(matches).To(BeFalse())
+			})
+		})
+
+		Context("when the chaincode is not committed", func() {
+			It("reports a committed sequence of zero", func() {
+				committedSeq, approvedSeqs, matches, err := ef.CompareApprovalToCommitted("other-name", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(committedSeq).To(Equal(int64(0)))
+				Expect(approvedSeqs).To(BeEmpty())
+				Expect(matches).To(BeFalse())
 			})
 		})
 
-		Context("when the sequence number already has an uncommitted definition", func() {
+		Context("when the org state cannot be ranged over", func() {
 			BeforeEach(func() {
-				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
-				Expect(err).NotTo(HaveOccurred())
+				fakeOrgState.GetStateRangeReturns(nil, fmt.Errorf("range-error"))
 			})
 
-			Context("when uncommitted definition differs from update", func() {
-				It("succeeds", func() {
-					testDefinition.ValidationInfo.ValidationParameter = []byte("more awesome policy")
-					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
-					Expect(err).NotTo(HaveOccurred())
-				})
+			It("wraps and returns the error", func() {
+				_, _, _, err := ef.CompareApprovalToCommitted("cc-name", fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("could not get state range for approvals of chaincode cc-name: range-error"))
 			})
+		})
+	})
 
-			Context("when uncommitted definition is identical to update", func() {
-				It("returns error", func() {
-					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
-					Expect(err).To(MatchError("attempted to redefine uncommitted sequence (5) for namespace cc-name with unchanged content"))
-				})
-			})
+	Describe("QueryApprovalGaps", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+			fakeOrgState    *mock.ReadWritableState
 
-			Context("when uncommitted definition has update of only package ID", func() {
-				It("succeeds", func() {
-					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash2", fakePublicState, fakeOrgState)
-					Expect(err).NotTo(HaveOccurred())
-				})
-			})
+			publicKVS, orgKVS MapLedgerShim
+		)
 
-			Context("when deserializing chaincode-source metadata fails", func() {
-				BeforeEach(func() {
-					fakeOrgKVStore["chaincode-sources/metadata/cc-name#5"] = []byte("garbage")
-				})
+		BeforeEach(func() {
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateStub = publicKVS.GetState
 
-				It("wraps and returns the error", func() {
-					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
-					Expect(err).To(MatchError("could not deserialize chaincode-source metadata for cc-name#5: could not unmarshal metadata for namespace chaincode-sources/cc-name#5: proto: can't skip unknown wire type 7"))
-				})
-			})
+			orgKVS = MapLedgerShim(map[string][]byte{})
+			fakeOrgState = &mock.ReadWritableState{}
+			fakeOrgState.GetStateRangeStub = orgKVS.GetStateRange
 
-			Context("when deserializing chaincode package fails", func() {
-				BeforeEach(func() {
-					fakeOrgKVStore["chaincode-sources/fields/cc-name#5/PackageID"] = []byte("garbage")
-				})
+			resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{Sequence: 5}, publicKVS)
+			for _, privateName := range []string{"cc-name#1", "cc-name#3", "cc-name#5", "cc-name#6", "other-name#2"} {
+				resources.Serializer.Serialize("namespaces", privateName, &lifecycle.ChaincodeParameters{}, orgKVS)
+			}
+		})
 
-				It("wraps and returns the error", func() {
-					err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
-					Expect(err).To(MatchError("could not deserialize chaincode package for cc-name#5: could not unmarshal state for key chaincode-sources/fields/cc-name#5/PackageID: proto: can't skip unknown wire type 7"))
-				})
-			})
+		It("returns the committed sequences the org did not approve", func() {
+			gaps, err := ef.QueryApprovalGaps("cc-name", fakePublicState, fakeOrgState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(gaps).To(Equal([]int64{2, 4}))
 		})
 
-		Context("when the definition is for an expired sequence number", func() {
+		Context("when the org approved every committed sequence", func() {
 			BeforeEach(func() {
-				testDefinition.Sequence = 3
+				resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{Sequence: 1}, publicKVS)
 			})
 
-			It("fails", func() {
-				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
-				Expect(err).To(MatchError("currently defined sequence 4 is larger than requested sequence 3"))
+			It("returns no gaps", func() {
+				gaps, err := ef.QueryApprovalGaps("cc-name", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(gaps).To(BeEmpty())
 			})
 		})
 
-		Context("when the definition is for a distant sequence number", func() {
+		Context("when the chaincode is not committed", func() {
+			It("returns no gaps", func() {
+				gaps, err := ef.QueryApprovalGaps("other-name", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(gaps).To(BeEmpty())
+			})
+		})
+
+		Context("when the current sequence cannot be read", func() {
 			BeforeEach(func() {
-				testDefinition.Sequence = 9
+				fakePublicState.GetStateReturns(nil, fmt.Errorf("state-error"))
 			})
 
-			It("fails", func() {
-				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
-				Expect(err).To(MatchError("requested sequence 9 is larger than the next available sequence number 5"))
+			It("wraps and returns the error", func() {
+				_, err := ef.QueryApprovalGaps("cc-name", fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("could not get current sequence: could not get state for key namespaces/fields/cc-name/Sequence: state-error"))
 			})
 		})
 
-		Context("when querying the public state fails", func() {
+		Context("when the org state cannot be ranged over", func() {
 			BeforeEach(func() {
-				fakePublicState.GetStateReturns(nil, fmt.Errorf("get-state-error"))
+				fakeOrgState.GetStateRangeReturns(nil, fmt.Errorf("range-error"))
 			})
 
 			It("wraps and returns the error", func() {
-				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
-				Expect(err).To(MatchError("could not get current sequence: could not get state for key namespaces/fields/cc-name/Sequence: get-state-error"))
+				_, err := ef.QueryApprovalGaps("cc-name", fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("could not get state range for approvals of chaincode cc-name: range-error"))
 			})
 		})
+	})
 
-		Context("when writing to the org state fails for the parameters", func() {
+	Describe("FindOrphanedSources", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+			fakeOrgState    *mock.ReadWritableState
+
+			publicKVS, orgKVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateStub = publicKVS.GetState
+
+			orgKVS = MapLedgerShim(map[string][]byte{})
+			fakeOrgState = &mock.ReadWritableState{}
+			fakeOrgState.GetStateStub = orgKVS.GetState
+			fakeOrgState.DelStateStub = orgKVS.DelState
+			fakeOrgState.GetStateRangeStub = orgKVS.GetStateRange
+
+			resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{Sequence: 3}, publicKVS)
+			for _, privateName := range []string{"cc-name#1", "cc-name#2", "cc-name#3", "cc-name#4", "other-name#1"} {
+				resources.Serializer.Serialize("chaincode-sources", privateName, &lifecycle.ChaincodeLocalPackage{PackageID: "package-id"}, orgKVS)
+			}
+		})
+
+		It("returns the sequences of sources below the committed sequence", func() {
+			sequences, err := ef.FindOrphanedSources("cc-name", fakePublicState, fakeOrgState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sequences).To(Equal([]int64{1, 2}))
+		})
+
+		Context("when the chaincode is not committed", func() {
+			It("returns no sequences", func() {
+				sequences, err := ef.FindOrphanedSources("other-name", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(sequences).To(BeEmpty())
+			})
+		})
+
+		Context("when the org state cannot be ranged over", func() {
 			BeforeEach(func() {
-				fakeOrgState.PutStateReturns(fmt.Errorf("put-state-error"))
+				fakeOrgState.GetStateRangeReturns(nil, fmt.Errorf("range-error"))
 			})
 
 			It("wraps and returns the error", func() {
-				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
-				Expect(err).To(MatchError("could not serialize chaincode parameters to state: could not write key into state: put-state-error"))
+				_, err := ef.FindOrphanedSources("cc-name", fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("could not get state range for chaincode sources of chaincode cc-name: range-error"))
 			})
 		})
 
-		Context("when writing to the org state fails for the package", func() {
-			BeforeEach(func() {
-				fakeOrgState.PutStateReturnsOnCall(4, fmt.Errorf("put-state-error"))
+		Describe("PruneOrphanedSources", func() {
+			It("deletes only the orphaned sources", func() {
+				sequences, err := ef.PruneOrphanedSources("cc-name", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(sequences).To(Equal([]int64{1, 2}))
+
+				for _, privateName := range []string{"cc-name#1", "cc-name#2"} {
+					for key := range orgKVS {
+						Expect(key).NotTo(ContainSubstring(privateName))
+					}
+				}
+				for _, privateName := range []string{"cc-name#3", "cc-name#4", "other-name#1"} {
+					_, ok, err := resources.Serializer.DeserializeMetadata("chaincode-sources", privateName, orgKVS)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(ok).To(BeTrue())
+				}
+
+				sequences, err = ef.FindOrphanedSources("cc-name", fakePublicState, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(sequences).To(BeEmpty())
 			})
 
-			It("wraps and returns the error", func() {
-				err := ef.ApproveChaincodeDefinitionForOrg("my-channel", "cc-name", testDefinition, "hash", fakePublicState, fakeOrgState)
-				Expect(err).To(MatchError("could not serialize chaincode package info to state: could not write key into state: put-state-error"))
+			Context("when deleting from the org state fails", func() {
+				BeforeEach(func() {
+					fakeOrgState.DelStateReturns(fmt.Errorf("del-error"))
+				})
+
+				It("wraps and returns the error", func() {
+					_, err := ef.PruneOrphanedSources("cc-name", fakePublicState, fakeOrgState)
+					Expect(err).To(MatchError("could not delete chaincode source for cc-name#1: del-error"))
+				})
 			})
 		})
 	})
 
-	Describe("CheckCommitReadiness", func() {
+	Describe("ChannelChaincodeReadiness", func() {
 		var (
 			fakePublicState *mock.ReadWritableState
-			fakeOrgStates   []*mock.ReadWritableState
-
-			testDefinition *lifecycle.ChaincodeDefinition
+			fakeOrgState    *mock.ReadWritableState
 
-			publicKVS, org0KVS, org1KVS MapLedgerShim
+			publicKVS, orgKVS MapLedgerShim
 		)
 
 		BeforeEach(func() {
-			testDefinition = &lifecycle.ChaincodeDefinition{
-				Sequence: 5,
-				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
-					Version:           "version",
-					EndorsementPlugin: "endorsement-plugin",
-				},
-				ValidationInfo: &lb.ChaincodeValidationInfo{
-					ValidationPlugin:    "validation-plugin",
-					ValidationParameter: []byte("validation-parameter"),
-				},
-			}
-
 			publicKVS = MapLedgerShim(map[string][]byte{})
 			fakePublicState = &mock.ReadWritableState{}
-			fakePublicState.GetStateStub = publicKVS.GetState
-			fakePublicState.PutStateStub = publicKVS.PutState
+			fakePublicState.GetStateRangeStub = publicKVS.GetStateRange
 
-			resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
-				Sequence: 4,
+			orgKVS = MapLedgerShim(map[string][]byte{})
+			fakeOrgState = &mock.ReadWritableState{}
+			fakeOrgState.GetStateRangeStub = orgKVS.GetStateRange
+
+			for _, name := range []string{"approved", "mismatched", "unapproved"} {
+				resources.Serializer.Serialize("namespaces", name, &lifecycle.ChaincodeDefinition{
+					Sequence: 2,
+					EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+						Version: "version",
+					},
+					ValidationInfo: &lb.ChaincodeValidationInfo{},
+					Collections:    &pb.CollectionConfigPackage{},
+				}, publicKVS)
+			}
+			resources.Serializer.Serialize("namespaces", "reordered", &lifecycle.ChaincodeDefinition{
+				Sequence: 2,
 				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
-					Version:           "version",
-					EndorsementPlugin: "endorsement-plugin",
-				},
-				ValidationInfo: &lb.ChaincodeValidationInfo{
-					ValidationPlugin:    "validation-plugin",
-					ValidationParameter: []byte("validation-parameter"),
+					Version: "version",
 				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{},
+				Collections:    collectionsNamed("coll-b", "coll-a"),
 			}, publicKVS)
 
-			org0KVS = MapLedgerShim(map[string][]byte{})
-			org1KVS = MapLedgerShim(map[string][]byte{})
-			fakeOrg0State := &mock.ReadWritableState{}
-			fakeOrg0State.CollectionNameReturns("_implicit_org_org0")
-			fakeOrg1State := &mock.ReadWritableState{}
-			fakeOrg1State.CollectionNameReturns("_implicit_org_org1")
-			fakeOrgStates = []*mock.ReadWritableState{
-				fakeOrg0State,
-				fakeOrg1State,
-			}
-			for i, kvs := range []MapLedgerShim{org0KVS, org1KVS} {
-				kvs := kvs
-				fakeOrgStates[i].GetStateStub = kvs.GetState
-				fakeOrgStates[i].GetStateHashStub = kvs.GetStateHash
-				fakeOrgStates[i].PutStateStub = kvs.PutState
-			}
-
-			resources.Serializer.Serialize("namespaces", "cc-name#5", testDefinition.Parameters(), fakeOrgStates[0])
-			resources.Serializer.Serialize("namespaces", "cc-name#5", &lifecycle.ChaincodeParameters{}, fakeOrgStates[1])
+			resources.Serializer.Serialize("namespaces", "approved#2", &lifecycle.ChaincodeParameters{
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version: "version",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{},
+				Collections:    &pb.CollectionConfigPackage{},
+			}, orgKVS)
+			resources.Serializer.Serialize("namespaces", "mismatched#2", &lifecycle.ChaincodeParameters{
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version: "other-version",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{},
+				Collections:    &pb.CollectionConfigPackage{},
+			}, orgKVS)
+			resources.Serializer.Serialize("namespaces", "reordered#2", &lifecycle.ChaincodeParameters{
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version: "version",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{},
+				Collections:    collectionsNamed("coll-a", "coll-b"),
+			}, orgKVS)
+			resources.Serializer.Serialize("namespaces", "unapproved#1", &lifecycle.ChaincodeParameters{}, orgKVS)
 		})
 
-		It("checks the commit readiness of a chaincode definition and returns the approvals", func() {
-			approvals, err := ef.CheckCommitReadiness("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+		It("reports whether the org approved each committed definition", func() {
+			readiness, err := ef.ChannelChaincodeReadiness(fakePublicState, fakeOrgState)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(approvals).To(Equal(map[string]bool{
-				"org0": true,
-				"org1": false,
+			Expect(readiness).To(Equal(map[string]bool{
+				"approved":   true,
+				"mismatched": false,
+				"reordered":  false,
+				"unapproved": false,
 			}))
 		})
 
-		Context("when IsSerialized fails", func() {
+		Context("when the org state cannot be ranged over", func() {
 			BeforeEach(func() {
-				fakeOrgStates[0].GetStateHashReturns(nil, errors.New("bad bad failure"))
+				fakeOrgState.GetStateRangeReturns(nil, fmt.Errorf("range-error"))
 			})
 
-			It("wraps and returns an error", func() {
-				_, err := ef.CheckCommitReadiness("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
-				Expect(err).To(MatchError(ContainSubstring("serialization check failed for key cc-name#5: could not get value for key namespaces/metadata/cc-name#5: bad bad failure")))
+			It("wraps and returns the error", func() {
+				_, err := ef.ChannelChaincodeReadiness(fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("could not query approved namespaces: range-error"))
 			})
 		})
 
-		Context("when the peer sets defaults", func() {
+		Context("when the public state cannot be ranged over", func() {
 			BeforeEach(func() {
-				testDefinition.EndorsementInfo.EndorsementPlugin = "escc"
-				testDefinition.ValidationInfo.ValidationPlugin = "vscc"
-				testDefinition.ValidationInfo.ValidationParameter = protoutil.MarshalOrPanic(
-					&pb.ApplicationPolicy{
-						Type: &pb.ApplicationPolicy_ChannelConfigPolicyReference{
-							ChannelConfigPolicyReference: "/Channel/Application/Endorsement",
-						},
-					})
-
-				resources.Serializer.Serialize("namespaces", "cc-name#5", testDefinition.Parameters(), fakeOrgStates[0])
-
-				testDefinition.EndorsementInfo.EndorsementPlugin = ""
-				testDefinition.ValidationInfo.ValidationPlugin = ""
-				testDefinition.ValidationInfo.ValidationParameter = nil
-
-				resources.Serializer.Serialize("namespaces", "cc-name#5", testDefinition.Parameters(), fakeOrgStates[1])
+				fakePublicState.GetStateRangeReturns(nil, fmt.Errorf("range-error"))
 			})
 
-			It("applies the chaincode definition and returns the approvals", func() {
-				approvals, err := ef.CheckCommitReadiness("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
-				Expect(err).NotTo(HaveOccurred())
-				Expect(approvals).To(Equal(map[string]bool{
-					"org0": true,
-					"org1": false,
-				}))
+			It("wraps and returns the error", func() {
+				_, err := ef.ChannelChaincodeReadiness(fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("could not query chaincode definitions: could not get state range for namespace namespaces: range-error"))
 			})
+		})
+	})
 
-			Context("when no default endorsement policy is defined on the channel", func() {
-				BeforeEach(func() {
-					fakePolicyManager.GetPolicyReturns(nil, false)
-				})
+	Describe("PrepareRollbackDefinition", func() {
+		var (
+			fakePublicState, fakeOrgState *mock.ReadWritableState
 
-				It("returns an error", func() {
-					_, err := ef.CheckCommitReadiness("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
-					Expect(err).To(MatchError(ContainSubstring("could not set defaults for chaincode definition in " +
-						"channel my-channel: policy '/Channel/Application/Endorsement' must be defined " +
-						"for channel 'my-channel' before chaincode operations can be attempted")))
-				})
-			})
+			oldParameters *lifecycle.ChaincodeParameters
+		)
 
-			Context("when obtaining a stable channel config fails", func() {
-				BeforeEach(func() {
-					fakeChannelConfigSource.GetStableChannelConfigReturns(nil)
-				})
+		BeforeEach(func() {
+			oldParameters = &lifecycle.ChaincodeParameters{
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version: "old-version",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{
+					ValidationParameter: []byte("old-validation-parameter"),
+				},
+				Collections: &pb.CollectionConfigPackage{},
+			}
 
-				It("returns an error", func() {
-					_, err := ef.CheckCommitReadiness("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
-					Expect(err).To(MatchError(ContainSubstring("could not get channel config for channel 'my-channel'")))
+			publicKVS := MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateStub = publicKVS.GetState
+			resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
+				Sequence: 3,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version: "defective-version",
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{},
+				Collections:    &pb.CollectionConfigPackage{},
+			}, publicKVS)
+
+			orgKVS := MapLedgerShim(map[string][]byte{})
+			fakeOrgState = &mock.ReadWritableState{}
+			fakeOrgState.GetStateStub = orgKVS.GetState
+			resources.Serializer.Serialize("namespaces", "cc-name#2", oldParameters, orgKVS)
+		})
+
+		It("returns the target parameters at the next sequence", func() {
+			cd, err := ef.PrepareRollbackDefinition("cc-name", 2, fakePublicState, fakeOrgState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cd.Sequence).To(Equal(int64(4)))
+			Expect(cd.Parameters().Equal(oldParameters)).To(Succeed())
+		})
 
-				})
+		Context("when the target sequence is not a previous sequence", func() {
+			It("returns an error", func() {
+				_, err := ef.PrepareRollbackDefinition("cc-name", 3, fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("target sequence 3 must be a previous sequence of chaincode cc-name, whose committed sequence is 3"))
 			})
+		})
 
-			Context("when the public state is not readable", func() {
-				BeforeEach(func() {
-					fakePublicState.GetStateReturns(nil, fmt.Errorf("getstate-error"))
-				})
-
-				It("wraps and returns the error", func() {
-					_, err := ef.CheckCommitReadiness("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
-					Expect(err).To(MatchError("could not get current sequence: could not get state for key namespaces/fields/cc-name/Sequence: getstate-error"))
-				})
+		Context("when the org did not approve the target sequence", func() {
+			It("returns an error", func() {
+				_, err := ef.PrepareRollbackDefinition("cc-name", 1, fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("no approved parameters found for chaincode cc-name at sequence 1"))
 			})
+		})
 
-			Context("when the current sequence is not immediately prior to the new", func() {
-				BeforeEach(func() {
-					resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
-						Sequence: 3,
-						EndorsementInfo: &lb.ChaincodeEndorsementInfo{
-							Version:           "version",
-							EndorsementPlugin: "endorsement-plugin",
-						},
-						ValidationInfo: &lb.ChaincodeValidationInfo{
-							ValidationPlugin:    "validation-plugin",
-							ValidationParameter: []byte("validation-parameter"),
-						},
-					}, fakePublicState)
-				})
+		Context("when the org state cannot be read", func() {
+			BeforeEach(func() {
+				fakeOrgState.GetStateReturns(nil, fmt.Errorf("get-state-error"))
+			})
 
-				It("returns an error", func() {
-					_, err := ef.CheckCommitReadiness("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
-					Expect(err).To(MatchError("requested sequence is 5, but new definition must be sequence 4"))
-				})
+			It("wraps and returns the error", func() {
+				_, err := ef.PrepareRollbackDefinition("cc-name", 2, fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("could not query approved parameters for chaincode cc-name at sequence 2: could not deserialize namespace metadata for cc-name#2: could not query metadata for namespace namespaces/cc-name#2: get-state-error"))
 			})
 		})
 	})
 
-	Describe("QueryApprovedChaincodeDefinition", func() {
+	Describe("SelfApprovalForCommit", func() {
 		var (
-			fakePublicState *mock.ReadWritableState
-			fakeOrgStates   []*mock.ReadWritableState
+			fakeOrgState *mock.ReadWritableState
 
-			testDefinition            *lifecycle.ChaincodeDefinition
-			testChaincodeLocalPackage *lifecycle.ChaincodeLocalPackage
+			testDefinition *lifecycle.ChaincodeDefinition
 
-			publicKVS, org0KVS, org1KVS MapLedgerShim
+			orgKVS MapLedgerShim
 		)
 
 		BeforeEach(func() {
@@ -1130,674 +7081,832 @@ var _ = Describe("ExternalFunctions", func() {
 					ValidationPlugin:    "validation-plugin",
 					ValidationParameter: []byte("validation-parameter"),
 				},
+				Collections: &pb.CollectionConfigPackage{},
 			}
 
-			testChaincodeLocalPackage = &lifecycle.ChaincodeLocalPackage{
-				PackageID: "hash",
-			}
+			orgKVS = MapLedgerShim(map[string][]byte{})
+			fakeOrgState = &mock.ReadWritableState{}
+			fakeOrgState.GetStateStub = orgKVS.GetState
 
-			publicKVS = MapLedgerShim(map[string][]byte{})
-			fakePublicState = &mock.ReadWritableState{}
-			fakePublicState.GetStateStub = publicKVS.GetState
-			fakePublicState.PutStateStub = publicKVS.PutState
+			resources.Serializer.Serialize("namespaces", "cc-name#4", testDefinition.Parameters(), orgKVS)
+		})
 
-			resources.Serializer.Serialize("namespaces", "cc-name", testDefinition, publicKVS)
+		It("reports a match and returns the approved parameters", func() {
+			matched, approved, err := ef.SelfApprovalForCommit("cc-name", testDefinition, fakeOrgState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(matched).To(BeTrue())
+			Expect(proto.Equal(approved.EndorsementInfo, testDefinition.EndorsementInfo)).To(BeTrue())
+			Expect(proto.Equal(approved.ValidationInfo, testDefinition.ValidationInfo)).To(BeTrue())
+		})
 
-			org0KVS = MapLedgerShim(map[string][]byte{})
-			org1KVS = MapLedgerShim(map[string][]byte{})
-			fakeOrg0State := &mock.ReadWritableState{}
-			fakeOrg0State.CollectionNameReturns("_implicit_org_org0")
-			fakeOrg1State := &mock.ReadWritableState{}
-			fakeOrg1State.CollectionNameReturns("_implicit_org_org1")
-			fakeOrgStates = []*mock.ReadWritableState{
-				fakeOrg0State,
-				fakeOrg1State,
-			}
-			for i, kvs := range []MapLedgerShim{org0KVS, org1KVS} {
-				kvs := kvs
-				fakeOrgStates[i].GetStateStub = kvs.GetState
-				fakeOrgStates[i].GetStateHashStub = kvs.GetStateHash
-				fakeOrgStates[i].PutStateStub = kvs.PutState
-			}
+		Context("when the approval does not match the definition", func() {
+			BeforeEach(func() {
+				testDefinition.EndorsementInfo.Version = "other-version"
+			})
 
-			resources.Serializer.Serialize("namespaces", "cc-name#3", testDefinition.Parameters(), fakeOrgStates[0])
-			resources.Serializer.Serialize("chaincode-sources", "cc-name#3", testChaincodeLocalPackage, fakeOrgStates[0])
-			resources.Serializer.Serialize("namespaces", "cc-name#3", testDefinition.Parameters(), fakeOrgStates[1])
-			resources.Serializer.Serialize("chaincode-sources", "cc-name#3", testChaincodeLocalPackage, fakeOrgStates[1])
+			It("reports a mismatch and returns the approved parameters", func() {
+				matched, approved, err := ef.SelfApprovalForCommit("cc-name", testDefinition, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(matched).To(BeFalse())
+				Expect(approved.EndorsementInfo.Version).To(Equal("version"))
+			})
+		})
 
-			resources.Serializer.Serialize("namespaces", "cc-name#4", testDefinition.Parameters(), fakeOrgStates[0])
-			resources.Serializer.Serialize("chaincode-sources", "cc-name#4", testChaincodeLocalPackage, fakeOrgStates[0])
-			resources.Serializer.Serialize("namespaces", "cc-name#4", testDefinition.Parameters(), fakeOrgStates[1])
-			resources.Serializer.Serialize("chaincode-sources", "cc-name#4", testChaincodeLocalPackage, fakeOrgStates[1])
+		Context("when the definition lists the approved collections in a different order", func() {
+			BeforeEach(func() {
+				testDefinition.Collections = collectionsNamed("coll-a", "coll-b")
+				resources.Serializer.Serialize("namespaces", "cc-name#4", testDefinition.Parameters(), orgKVS)
+				testDefinition.Collections = collectionsNamed("coll-b", "coll-a")
+			})
+
+			It("reports a mismatch, as the commit would", func() {
+				matched, approved, err := ef.SelfApprovalForCommit("cc-name", testDefinition, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(matched).To(BeFalse())
+				Expect(approved.Collections.Config).To(HaveLen(2))
+			})
 		})
 
-		It("returns the approved chaincode", func() {
-			cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 3, fakePublicState, fakeOrgStates[0])
+		Context("when nothing is approved for the sequence", func() {
+			BeforeEach(func() {
+				testDefinition.Sequence = 5
+			})
+
+			It("reports a mismatch without parameters", func() {
+				matched, approved, err := ef.SelfApprovalForCommit("cc-name", testDefinition, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(matched).To(BeFalse())
+				Expect(approved).To(BeNil())
+			})
+		})
+
+		Context("when reading the approval fails", func() {
+			BeforeEach(func() {
+				fakeOrgState.GetStateReturns(nil, fmt.Errorf("state-error"))
+			})
+
+			It("returns the error", func() {
+				_, _, err := ef.SelfApprovalForCommit("cc-name", testDefinition, fakeOrgState)
+				Expect(err).To(MatchError("could not deserialize namespace metadata for cc-name#4: could not query metadata for namespace namespaces/cc-name#4: state-error"))
+			})
+		})
+	})
+
+	Describe("QueryNamespaceDefinitions", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+
+			publicKVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateStub = publicKVS.GetState
+			fakePublicState.GetStateRangeStub = publicKVS.GetStateRange
+			resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{}, publicKVS)
+			resources.Serializer.Serialize("namespaces", "other-name", &lifecycle.ChaincodeParameters{}, publicKVS)
+		})
+
+		It("returns the defined namespaces", func() {
+			result, err := ef.QueryNamespaceDefinitions(fakePublicState)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(cc.Sequence).To(Equal(int64(3)))
-			Expect(proto.Equal(cc.EndorsementInfo, &lb.ChaincodeEndorsementInfo{
-				Version:           "version",
-				EndorsementPlugin: "endorsement-plugin",
-			})).To(BeTrue())
-			Expect(proto.Equal(cc.ValidationInfo, &lb.ChaincodeValidationInfo{
-				ValidationPlugin:    "validation-plugin",
-				ValidationParameter: []byte("validation-parameter"),
-			})).To(BeTrue())
-			Expect(proto.Equal(cc.Collections, &pb.CollectionConfigPackage{})).To(BeTrue())
-			Expect(proto.Equal(cc.Source, &lb.ChaincodeSource{
-				Type: &lb.ChaincodeSource_LocalPackage{
-					LocalPackage: &lb.ChaincodeSource_Local{
-						PackageId: "hash",
-					},
-				},
-			})).To(BeTrue())
+			Expect(result).To(Equal(map[string]string{
+				"cc-name":    "Chaincode",
+				"other-name": "ChaincodeParameters",
+			}))
 		})
 
-		Context("when the next sequence is not defined and the sequence argument is not provided", func() {
+		Context("when a datatype is registered", func() {
+			BeforeEach(func() {
+				resources.DatatypeRegistry = lifecycle.DefaultDatatypeRegistry()
+				resources.DatatypeRegistry.Register(&lifecycle.ChaincodeParameters{}, "Parameters")
+			})
 
-			It("returns the approved chaincode for the current sequence", func() {
-				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 0, fakePublicState, fakeOrgStates[0])
+			It("returns its friendly name", func() {
+				result, err := ef.QueryNamespaceDefinitions(fakePublicState)
 				Expect(err).NotTo(HaveOccurred())
-				Expect(cc.Sequence).To(Equal(int64(4)))
-				Expect(proto.Equal(cc.EndorsementInfo, &lb.ChaincodeEndorsementInfo{
-					Version:           "version",
-					EndorsementPlugin: "endorsement-plugin",
-				})).To(BeTrue())
-				Expect(proto.Equal(cc.ValidationInfo, &lb.ChaincodeValidationInfo{
-					ValidationPlugin:    "validation-plugin",
-					ValidationParameter: []byte("validation-parameter"),
-				})).To(BeTrue())
-				Expect(proto.Equal(cc.Collections, &pb.CollectionConfigPackage{})).To(BeTrue())
-				Expect(proto.Equal(cc.Source, &lb.ChaincodeSource{
-					Type: &lb.ChaincodeSource_LocalPackage{
-						LocalPackage: &lb.ChaincodeSource_Local{
-							PackageId: "hash",
-						},
-					},
-				})).To(BeTrue())
+				Expect(result).To(Equal(map[string]string{
+					"cc-name":    "Chaincode",
+					"other-name": "Parameters",
+				}))
 			})
 		})
 
-		Context("when the next sequence is defined and the sequence argument is not provided", func() {
+		Context("when the range cannot be retrieved", func() {
 			BeforeEach(func() {
-				testChaincodeLocalPackage.PackageID = ""
-				resources.Serializer.Serialize("namespaces", "cc-name#5", testDefinition.Parameters(), fakeOrgStates[0])
-				resources.Serializer.Serialize("chaincode-sources", "cc-name#5", testChaincodeLocalPackage, fakeOrgStates[0])
+				fakePublicState.GetStateRangeReturns(nil, fmt.Errorf("state-range-error"))
 			})
 
-			It("returns the approved chaincode for the next sequence", func() {
-				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 0, fakePublicState, fakeOrgStates[0])
-				Expect(err).NotTo(HaveOccurred())
-				Expect(cc.Sequence).To(Equal(int64(5)))
-				Expect(proto.Equal(cc.EndorsementInfo, &lb.ChaincodeEndorsementInfo{
-					Version:           "version",
-					EndorsementPlugin: "endorsement-plugin",
-				})).To(BeTrue())
-				Expect(proto.Equal(cc.ValidationInfo, &lb.ChaincodeValidationInfo{
-					ValidationPlugin:    "validation-plugin",
-					ValidationParameter: []byte("validation-parameter"),
-				})).To(BeTrue())
-				Expect(proto.Equal(cc.Collections, &pb.CollectionConfigPackage{})).To(BeTrue())
-				Expect(proto.Equal(cc.Source, &lb.ChaincodeSource{
-					Type: &lb.ChaincodeSource_Unavailable_{
-						Unavailable: &lb.ChaincodeSource_Unavailable{},
-					},
-				})).To(BeTrue())
+			It("returns an error", func() {
+				_, err := ef.QueryNamespaceDefinitions(fakePublicState)
+				Expect(err).To(MatchError("could not query namespace metadata: could not get state range for namespace namespaces: state-range-error"))
 			})
+		})
+	})
 
-			Context("and deserializing namespace metadata for next sequence fails", func() {
-				BeforeEach(func() {
-					org0KVS["namespaces/metadata/cc-name#5"] = []byte("garbage")
-				})
+	Describe("QueryChaincodeSources", func() {
+		var (
+			fakeOrgState *mock.ReadWritableState
 
-				It("wraps and returns the error", func() {
-					cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 0, fakePublicState, fakeOrgStates[0])
-					Expect(err).To(MatchError("could not deserialize namespace metadata for next sequence 5: could not unmarshal metadata for namespace namespaces/cc-name#5: proto: can't skip unknown wire type 7"))
-					Expect(cc).To(BeNil())
-				})
+			orgKVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			orgKVS = MapLedgerShim(map[string][]byte{})
+			fakeOrgState = &mock.ReadWritableState{}
+			fakeOrgState.GetStateRangeStub = orgKVS.GetStateRange
+			resources.Serializer.Serialize("chaincode-sources", "cc-name#1", &lifecycle.ChaincodeLocalPackage{PackageID: "label:0a0b"}, orgKVS)
+			resources.Serializer.Serialize("chaincode-sources", "cc-name#2", &lifecycle.ChaincodeLocalPackage{}, orgKVS)
+		})
+
+		It("returns the package hash of each approval", func() {
+			sources, err := ef.QueryChaincodeSources(fakeOrgState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sources).To(Equal(map[string][]byte{
+				"cc-name#1": {0x0a, 0x0b},
+				"cc-name#2": nil,
+			}))
+		})
+
+		Context("when a package ID does not contain a hex hash", func() {
+			BeforeEach(func() {
+				resources.Serializer.Serialize("chaincode-sources", "cc-name#3", &lifecycle.ChaincodeLocalPackage{PackageID: "label:not-hex"}, orgKVS)
+			})
+
+			It("returns an error", func() {
+				_, err := ef.QueryChaincodeSources(fakeOrgState)
+				Expect(err).To(MatchError(ContainSubstring("could not decode hash of package ID 'label:not-hex' for cc-name#3")))
 			})
 		})
 
-		Context("when the next sequence is defined and the sequence argument is provided", func() {
+		Context("when the state cannot be ranged over", func() {
 			BeforeEach(func() {
-				testChaincodeLocalPackage.PackageID = "hash"
-				resources.Serializer.Serialize("namespaces", "cc-name#5", testDefinition.Parameters(), fakeOrgStates[0])
-				resources.Serializer.Serialize("chaincode-sources", "cc-name#5", testChaincodeLocalPackage, fakeOrgStates[0])
+				fakeOrgState.GetStateRangeReturns(nil, fmt.Errorf("range-error"))
 			})
 
-			It("returns the approved chaincode for the next sequence", func() {
-				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 5, fakePublicState, fakeOrgStates[0])
-				Expect(err).NotTo(HaveOccurred())
-				Expect(cc.Sequence).To(Equal(int64(5)))
-				Expect(proto.Equal(cc.EndorsementInfo, &lb.ChaincodeEndorsementInfo{
-					Version:           "version",
-					EndorsementPlugin: "endorsement-plugin",
-				})).To(BeTrue())
-				Expect(proto.Equal(cc.ValidationInfo, &lb.ChaincodeValidationInfo{
-					ValidationPlugin:    "validation-plugin",
-					ValidationParameter: []byte("validation-parameter"),
-				})).To(BeTrue())
-				Expect(proto.Equal(cc.Collections, &pb.CollectionConfigPackage{})).To(BeTrue())
-				Expect(proto.Equal(cc.Source, &lb.ChaincodeSource{
-					Type: &lb.ChaincodeSource_LocalPackage{
-						LocalPackage: &lb.ChaincodeSource_Local{
-							PackageId: "hash",
-						},
-					},
-				})).To(BeTrue())
+			It("wraps and returns the error", func() {
+				_, err := ef.QueryChaincodeSources(fakeOrgState)
+				Expect(err).To(MatchError("could not query chaincode-source metadata: could not get state range for namespace chaincode-sources: range-error"))
 			})
 		})
+	})
+
+	Describe("QueryOrgsWithPackage", func() {
+		var (
+			fakeOrgStates []*mock.ReadWritableState
+
+			org0KVS, org1KVS, org2KVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			org0KVS = MapLedgerShim(map[string][]byte{})
+			org1KVS = MapLedgerShim(map[string][]byte{})
+			org2KVS = MapLedgerShim(map[string][]byte{})
+			fakeOrgStates = nil
+			for _, kvs := range []MapLedgerShim{org0KVS, org1KVS, org2KVS} {
+				fakeOrgState := &mock.ReadWritableState{}
+				fakeOrgState.GetStateRangeStub = kvs.GetStateRange
+				fakeOrgStates = append(fakeOrgStates, fakeOrgState)
+			}
+
+			resources.Serializer.Serialize("chaincode-sources", "cc-name#1", &lifecycle.ChaincodeLocalPackage{PackageID: "label:0a0b"}, org0KVS)
+			resources.Serializer.Serialize("chaincode-sources", "cc-name#1", &lifecycle.ChaincodeLocalPackage{PackageID: "label:0c0d"}, org1KVS)
+			resources.Serializer.Serialize("chaincode-sources", "cc-name#2", &lifecycle.ChaincodeLocalPackage{}, org1KVS)
+			resources.Serializer.Serialize("chaincode-sources", "other-name#1", &lifecycle.ChaincodeLocalPackage{PackageID: "label:0a0b"}, org1KVS)
+			resources.Serializer.Serialize("chaincode-sources", "cc-name#1", &lifecycle.ChaincodeLocalPackage{PackageID: "label:0c0d"}, org2KVS)
+			resources.Serializer.Serialize("chaincode-sources", "cc-name#2", &lifecycle.ChaincodeLocalPackage{PackageID: "other-label:0a0b"}, org2KVS)
+		})
 
-		Context("when the requested sequence number is out of range", func() {
+		It("returns the orgs whose approvals of the chaincode reference the package", func() {
+			orgs, err := ef.QueryOrgsWithPackage("cc-name", []byte{0x0a, 0x0b}, []lifecycle.RangeableState{fakeOrgStates[0], fakeOrgStates[1], fakeOrgStates[2]})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(orgs).To(Equal([]int{0, 2}))
+		})
 
-			It("wraps and returns the error", func() {
-				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 5, fakePublicState, fakeOrgStates[0])
-				Expect(err).To(MatchError("could not fetch approved chaincode definition (name: 'cc-name', sequence: '5') on channel 'my-channel'"))
-				Expect(cc).To(BeNil())
+		Context("when no org references the package", func() {
+			It("returns no orgs", func() {
+				orgs, err := ef.QueryOrgsWithPackage("cc-name", []byte{0x0e}, []lifecycle.RangeableState{fakeOrgStates[0], fakeOrgStates[1], fakeOrgStates[2]})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(orgs).To(BeEmpty())
 			})
 		})
 
-		Context("when the sequence argument is not provided and querying the public state fails", func() {
+		Context("when an org state cannot be ranged over", func() {
 			BeforeEach(func() {
-				fakePublicState.GetStateReturns(nil, fmt.Errorf("get-state-error"))
+				fakeOrgStates[1].GetStateRangeReturns(nil, fmt.Errorf("range-error"))
 			})
 
 			It("wraps and returns the error", func() {
-				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 0, fakePublicState, fakeOrgStates[0])
-				Expect(err).To(MatchError("could not get current sequence: could not get state for key namespaces/fields/cc-name/Sequence: get-state-error"))
-				Expect(cc).To(BeNil())
+				_, err := ef.QueryOrgsWithPackage("cc-name", []byte{0x0a, 0x0b}, []lifecycle.RangeableState{fakeOrgStates[0], fakeOrgStates[1], fakeOrgStates[2]})
+				Expect(err).To(MatchError("could not query chaincode sources for org 1: could not query chaincode-source metadata: could not get state range for namespace chaincode-sources: range-error"))
 			})
 		})
+	})
 
-		Context("when deserializing namespace metadata for requested sequence fails", func() {
-			BeforeEach(func() {
-				org0KVS["namespaces/metadata/cc-name#4"] = []byte("garbage")
-			})
+	Describe("QueryNamespaceDefinitionsByType", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
 
-			It("wraps and returns the error", func() {
-				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 4, fakePublicState, fakeOrgStates[0])
-				Expect(err).To(MatchError("could not deserialize namespace metadata for cc-name#4: could not unmarshal metadata for namespace namespaces/cc-name#4: proto: can't skip unknown wire type 7"))
-				Expect(cc).To(BeNil())
-			})
+			publicKVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateRangeStub = publicKVS.GetStateRange
+			resources.Serializer.Serialize("namespaces", "cc-b", &lifecycle.ChaincodeDefinition{}, publicKVS)
+			resources.Serializer.Serialize("namespaces", "cc-a", &lifecycle.ChaincodeDefinition{}, publicKVS)
+			resources.Serializer.Serialize("namespaces", "cc-c", &lifecycle.ChaincodeParameters{}, publicKVS)
 		})
 
-		Context("when deserializing chaincode parameters fails", func() {
-			BeforeEach(func() {
-				org0KVS["namespaces/fields/cc-name#4/EndorsementInfo"] = []byte("garbage")
-			})
+		It("returns the sorted namespaces of the internal datatype", func() {
+			names, err := ef.QueryNamespaceDefinitionsByType(fakePublicState, "ChaincodeDefinition")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names).To(Equal([]string{"cc-a", "cc-b"}))
+		})
 
-			It("wraps and returns the error", func() {
-				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 4, fakePublicState, fakeOrgStates[0])
-				Expect(err).To(MatchError("could not deserialize chaincode parameters for cc-name#4: could not unmarshal state for key namespaces/fields/cc-name#4/EndorsementInfo: proto: can't skip unknown wire type 7"))
-				Expect(cc).To(BeNil())
+		It("matches the friendly datatype name case-insensitively", func() {
+			names, err := ef.QueryNamespaceDefinitionsByType(fakePublicState, "chaincode")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names).To(Equal([]string{"cc-a", "cc-b"}))
+
+			names, err = ef.QueryNamespaceDefinitionsByType(fakePublicState, "chaincodeparameters")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names).To(Equal([]string{"cc-c"}))
+		})
+
+		Context("when no namespace has the datatype", func() {
+			It("returns an empty list", func() {
+				names, err := ef.QueryNamespaceDefinitionsByType(fakePublicState, "TokenManagementSystem")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(names).To(BeEmpty())
 			})
 		})
 
-		Context("when deserializing chaincode-source metadata fails", func() {
+		Context("when the state cannot be ranged over", func() {
 			BeforeEach(func() {
-				org0KVS["chaincode-sources/metadata/cc-name#4"] = []byte("garbage")
+				fakePublicState.GetStateRangeReturns(nil, fmt.Errorf("range-error"))
 			})
 
 			It("wraps and returns the error", func() {
-				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 4, fakePublicState, fakeOrgStates[0])
-				Expect(err).To(MatchError("could not deserialize chaincode-source metadata for cc-name#4: could not unmarshal metadata for namespace chaincode-sources/cc-name#4: proto: can't skip unknown wire type 7"))
-				Expect(cc).To(BeNil())
+				_, err := ef.QueryNamespaceDefinitionsByType(fakePublicState, "Chaincode")
+				Expect(err).To(MatchError("could not query namespace metadata: could not get state range for namespace namespaces: range-error"))
 			})
 		})
+	})
 
-		Context("when the requested cc parameters are found but the cc source is not found due to some inconsistency", func() {
+	Describe("NamespaceType", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+
+			publicKVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateStub = publicKVS.GetState
+			resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{}, publicKVS)
+			resources.Serializer.Serialize("namespaces", "other-name", &lifecycle.ChaincodeParameters{}, publicKVS)
+		})
+
+		It("returns the friendly type of the namespace", func() {
+			friendlyType, ok, err := ef.NamespaceType("cc-name", fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(friendlyType).To(Equal("Chaincode"))
+			Expect(fakePublicState.GetStateCallCount()).To(Equal(1))
+			Expect(fakePublicState.GetStateArgsForCall(0)).To(Equal("namespaces/metadata/cc-name"))
+
+			friendlyType, ok, err = ef.NamespaceType("other-name", fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(friendlyType).To(Equal("ChaincodeParameters"))
+		})
 
+		Context("when a datatype is registered", func() {
 			BeforeEach(func() {
-				resources.Serializer.Serialize("namespaces", "cc-name#5", testDefinition.Parameters(), fakeOrgStates[0])
+				resources.DatatypeRegistry = lifecycle.DefaultDatatypeRegistry()
+				resources.DatatypeRegistry.Register(&lifecycle.ChaincodeParameters{}, "Parameters")
 			})
 
-			It("wraps and returns the error", func() {
-				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 5, fakePublicState, fakeOrgStates[0])
-				Expect(err).To(MatchError("could not fetch approved chaincode definition (name: 'cc-name', sequence: '5') on channel 'my-channel'"))
-				Expect(cc).To(BeNil())
+			It("returns its friendly name", func() {
+				friendlyType, ok, err := ef.NamespaceType("other-name", fakePublicState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeTrue())
+				Expect(friendlyType).To(Equal("Parameters"))
 			})
 		})
 
-		Context("when deserializing chaincode package fails", func() {
+		Context("when the namespace is not defined", func() {
+			It("returns not ok", func() {
+				friendlyType, ok, err := ef.NamespaceType("missing-name", fakePublicState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ok).To(BeFalse())
+				Expect(friendlyType).To(BeEmpty())
+			})
+		})
+
+		Context("when the metadata cannot be retrieved", func() {
 			BeforeEach(func() {
-				org0KVS["chaincode-sources/fields/cc-name#4/PackageID"] = []byte("garbage")
+				fakePublicState.GetStateReturns(nil, fmt.Errorf("state-error"))
 			})
 
 			It("wraps and returns the error", func() {
-				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 4, fakePublicState, fakeOrgStates[0])
-				Expect(err).To(MatchError("could not deserialize chaincode package for cc-name#4: could not unmarshal state for key chaincode-sources/fields/cc-name#4/PackageID: proto: can't skip unknown wire type 7"))
-				Expect(cc).To(BeNil())
+				_, _, err := ef.NamespaceType("cc-name", fakePublicState)
+				Expect(err).To(MatchError("could not query metadata for namespace cc-name: could not query metadata for namespace namespaces/cc-name: state-error"))
 			})
 		})
+	})
+
+	Describe("QueryNamespaceDefinitionsPaginated", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+
+			publicKVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateRangeWithPaginationStub = publicKVS.GetStateRangeWithPagination
+			resources.Serializer.Serialize("namespaces", "cc-a", &lifecycle.ChaincodeDefinition{}, publicKVS)
+			resources.Serializer.Serialize("namespaces", "cc-b", &lifecycle.ChaincodeDefinition{}, publicKVS)
+			resources.Serializer.Serialize("namespaces", "cc-c", &lifecycle.ChaincodeParameters{}, publicKVS)
+		})
 
-		Context("when the metadata is not for chaincode parameters", func() {
+		It("returns the defined namespaces a page at a time", func() {
+			result, bookmark, err := ef.QueryNamespaceDefinitionsPaginated(fakePublicState, "", 2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(map[string]string{
+				"cc-a": "Chaincode",
+				"cc-b": "Chaincode",
+			}))
+			Expect(bookmark).NotTo(BeEmpty())
+
+			result, bookmark, err = ef.QueryNamespaceDefinitionsPaginated(fakePublicState, bookmark, 2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(map[string]string{
+				"cc-c": "ChaincodeParameters",
+			}))
+			Expect(bookmark).To(BeEmpty())
+
+			prefix, _, pageSize := fakePublicState.GetStateRangeWithPaginationArgsForCall(0)
+			Expect(prefix).To(Equal("namespaces/metadata/"))
+			Expect(pageSize).To(Equal(int32(2)))
+		})
+
+		Context("when a datatype is registered", func() {
 			BeforeEach(func() {
-				type badStruct struct{}
-				resources.Serializer.Serialize("namespaces", "cc-name#4", &badStruct{}, fakeOrgStates[0])
+				resources.DatatypeRegistry = lifecycle.DefaultDatatypeRegistry()
+				resources.DatatypeRegistry.Register(&lifecycle.ChaincodeParameters{}, "Parameters")
 			})
 
-			It("wraps and returns the error", func() {
-				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 4, fakePublicState, fakeOrgStates[0])
-				Expect(err).To(MatchError("not a chaincode parameters type: badStruct"))
-				Expect(cc).To(BeNil())
+			It("returns its friendly name", func() {
+				result, _, err := ef.QueryNamespaceDefinitionsPaginated(fakePublicState, "", 3)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result).To(Equal(map[string]string{
+					"cc-a": "Chaincode",
+					"cc-b": "Chaincode",
+					"cc-c": "Parameters",
+				}))
 			})
 		})
 
-		Context("when the metadata is not for a chaincode local package", func() {
+		Context("when the page size is not positive", func() {
+			It("returns an error", func() {
+				_, _, err := ef.QueryNamespaceDefinitionsPaginated(fakePublicState, "", 0)
+				Expect(err).To(MatchError("page size must be positive, but was 0"))
+			})
+		})
+
+		Context("when the range cannot be retrieved", func() {
 			BeforeEach(func() {
-				type badStruct struct{}
-				resources.Serializer.Serialize("chaincode-sources", "cc-name#4", &badStruct{}, fakeOrgStates[0])
+				fakePublicState.GetStateRangeWithPaginationReturns(nil, "", fmt.Errorf("state-range-error"))
 			})
 
-			It("wraps and returns the error", func() {
-				cc, err := ef.QueryApprovedChaincodeDefinition("my-channel", "cc-name", 4, fakePublicState, fakeOrgStates[0])
-				Expect(err).To(MatchError("not a chaincode local package type: badStruct"))
-				Expect(cc).To(BeNil())
+			It("returns an error", func() {
+				_, _, err := ef.QueryNamespaceDefinitionsPaginated(fakePublicState, "", 2)
+				Expect(err).To(MatchError("could not query namespace metadata: could not get state range for namespace namespaces: state-range-error"))
 			})
 		})
 	})
 
-	Describe("CommitChaincodeDefinition", func() {
+	Describe("QueryChaincodeDefinitions", func() {
 		var (
 			fakePublicState *mock.ReadWritableState
-			fakeOrgStates   []*mock.ReadWritableState
-
-			testDefinition *lifecycle.ChaincodeDefinition
 
-			publicKVS, org0KVS, org1KVS MapLedgerShim
+			publicKVS MapLedgerShim
 		)
 
 		BeforeEach(func() {
-			testDefinition = &lifecycle.ChaincodeDefinition{
-				Sequence: 5,
-				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
-					Version:           "version",
-					EndorsementPlugin: "endorsement-plugin",
-				},
-				ValidationInfo: &lb.ChaincodeValidationInfo{
-					ValidationPlugin:    "validation-plugin",
-					ValidationParameter: []byte("validation-parameter"),
-				},
-			}
-
 			publicKVS = MapLedgerShim(map[string][]byte{})
 			fakePublicState = &mock.ReadWritableState{}
-			fakePublicState.GetStateStub = publicKVS.GetState
-			fakePublicState.PutStateStub = publicKVS.PutState
-
+			fakePublicState.GetStateRangeStub = publicKVS.GetStateRange
 			resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
-				Sequence: 4,
+				Sequence: 3,
 				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
-					Version:           "version",
-					EndorsementPlugin: "endorsement-plugin",
+					Version: "version",
 				},
-				ValidationInfo: &lb.ChaincodeValidationInfo{
-					ValidationPlugin:    "validation-plugin",
-					ValidationParameter: []byte("validation-parameter"),
+				ValidationInfo: &lb.ChaincodeValidationInfo{},
+				Collections:    &pb.CollectionConfigPackage{},
+			}, publicKVS)
+			resources.Serializer.Serialize("namespaces", "cc-name2", &lifecycle.ChaincodeDefinition{
+				Sequence: 7,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version: "version2",
 				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{},
+				Collections:    &pb.CollectionConfigPackage{},
 			}, publicKVS)
-
-			org0KVS = MapLedgerShim(map[string][]byte{})
-			org1KVS = MapLedgerShim(map[string][]byte{})
-			fakeOrg0State := &mock.ReadWritableState{}
-			fakeOrg0State.CollectionNameReturns("_implicit_org_org0")
-			fakeOrg1State := &mock.ReadWritableState{}
-			fakeOrg1State.CollectionNameReturns("_implicit_org_org1")
-			fakeOrgStates = []*mock.ReadWritableState{
-				fakeOrg0State,
-				fakeOrg1State,
-			}
-			for i, kvs := range []MapLedgerShim{org0KVS, org1KVS} {
-				kvs := kvs
-				fakeOrgStates[i].GetStateStub = kvs.GetState
-				fakeOrgStates[i].GetStateHashStub = kvs.GetStateHash
-				fakeOrgStates[i].PutStateStub = kvs.PutState
-			}
-
-			resources.Serializer.Serialize("namespaces", "cc-name#5", testDefinition.Parameters(), fakeOrgStates[0])
-			resources.Serializer.Serialize("namespaces", "cc-name#5", &lifecycle.ChaincodeParameters{}, fakeOrgStates[1])
+			resources.Serializer.Serialize("namespaces", "other-name", &lifecycle.ChaincodeParameters{}, publicKVS)
 		})
 
-		It("applies the chaincode definition and returns the approvals", func() {
-			approvals, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+		It("returns the chaincode definitions using a single range query", func() {
+			result, err := ef.QueryChaincodeDefinitions(fakePublicState)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(approvals).To(Equal(map[string]bool{
-				"org0": true,
-				"org1": false,
-			}))
+			Expect(result).To(HaveLen(2))
+			Expect(result["cc-name"].Sequence).To(Equal(int64(3)))
+			Expect(result["cc-name"].EndorsementInfo.Version).To(Equal("version"))
+			Expect(result["cc-name2"].Sequence).To(Equal(int64(7)))
+			Expect(result["cc-name2"].EndorsementInfo.Version).To(Equal("version2"))
+
+			Expect(fakePublicState.GetStateRangeCallCount()).To(Equal(1))
+			Expect(fakePublicState.GetStateRangeArgsForCall(0)).To(Equal("namespaces/"))
+			Expect(fakePublicState.GetStateCallCount()).To(Equal(0))
 		})
 
-		Context("when IsSerialized fails", func() {
+		Context("when the range cannot be retrieved", func() {
 			BeforeEach(func() {
-				fakeOrgStates[0].GetStateHashReturns(nil, errors.New("bad bad failure"))
+				fakePublicState.GetStateRangeReturns(nil, fmt.Errorf("state-range-error"))
 			})
 
-			It("wraps and returns an error", func() {
-				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
-				Expect(err).To(MatchError(ContainSubstring("serialization check failed for key cc-name#5: could not get value for key namespaces/metadata/cc-name#5: bad bad failure")))
+			It("returns an error", func() {
+				_, err := ef.QueryChaincodeDefinitions(fakePublicState)
+				Expect(err).To(MatchError("could not get state range for namespace namespaces: state-range-error"))
 			})
 		})
 
-		Context("when the peer sets defaults", func() {
+		Context("when the metadata is corrupt", func() {
 			BeforeEach(func() {
-				testDefinition.EndorsementInfo.EndorsementPlugin = "escc"
-				testDefinition.ValidationInfo.ValidationPlugin = "vscc"
-				testDefinition.ValidationInfo.ValidationParameter = protoutil.MarshalOrPanic(
-					&pb.ApplicationPolicy{
-						Type: &pb.ApplicationPolicy_ChannelConfigPolicyReference{
-							ChannelConfigPolicyReference: "/Channel/Application/Endorsement",
-						},
-					})
-
-				resources.Serializer.Serialize("namespaces", "cc-name#5", testDefinition.Parameters(), fakeOrgStates[0])
+				publicKVS["namespaces/metadata/cc-name"] = []byte("garbage")
+			})
 
-				testDefinition.EndorsementInfo.EndorsementPlugin = ""
-				testDefinition.ValidationInfo.ValidationPlugin = ""
-				testDefinition.ValidationInfo.ValidationParameter = nil
+			It("returns an error", func() {
+				_, err := ef.QueryChaincodeDefinitions(fakePublicState)
+				Expect(err).To(MatchError(ContainSubstring("error unmarshaling metadata for key namespaces/metadata/cc-name")))
+			})
+		})
 
-				resources.Serializer.Serialize("namespaces", "cc-name#5", testDefinition.Parameters(), fakeOrgStates[1])
+		Context("when a definition field is corrupt", func() {
+			BeforeEach(func() {
+				publicKVS["namespaces/fields/cc-name/EndorsementInfo"] = []byte("garbage")
 			})
 
-			It("applies the chaincode definition and returns the approvals", func() {
-				approvals, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
-				Expect(err).NotTo(HaveOccurred())
-				Expect(approvals).To(Equal(map[string]bool{
-					"org0": true,
-					"org1": false,
-				}))
+			It("returns an error", func() {
+				_, err := ef.QueryChaincodeDefinitions(fakePublicState)
+				Expect(err).To(MatchError("could not deserialize namespace cc-name as chaincode: could not unmarshal state for key namespaces/fields/cc-name/EndorsementInfo: proto: can't skip unknown wire type 7"))
 			})
+		})
+	})
 
-			Context("when no default endorsement policy is defined on thc channel", func() {
-				BeforeEach(func() {
-					fakePolicyManager.GetPolicyReturns(nil, false)
-				})
+	Describe("QueryChaincodeDefinitionsByPlugin", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
 
-				It("returns an error", func() {
-					_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
-					Expect(err).To(MatchError(ContainSubstring("could not set defaults for chaincode definition in " +
-						"channel my-channel: policy '/Channel/Application/Endorsement' must be defined " +
-						"for channel 'my-channel' before chaincode operations can be attempted")))
-				})
-			})
+			publicKVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateRangeStub = publicKVS.GetStateRange
+			for name, plugin := range map[string]string{
+				"cc-a": "custom-escc",
+				"cc-b": "escc",
+				"cc-c": "",
+				"cc-d": "custom-escc",
+			} {
+				resources.Serializer.Serialize("namespaces", name, &lifecycle.ChaincodeDefinition{
+					Sequence: 1,
+					EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+						EndorsementPlugin: plugin,
+					},
+					ValidationInfo: &lb.ChaincodeValidationInfo{},
+					Collections:    &pb.CollectionConfigPackage{},
+				}, publicKVS)
+			}
+		})
 
-			Context("when obtaining a stable channel config fails", func() {
-				BeforeEach(func() {
-					fakeChannelConfigSource.GetStableChannelConfigReturns(nil)
-				})
+		It("returns the sorted names of the chaincodes using the plugin", func() {
+			result, err := ef.QueryChaincodeDefinitionsByPlugin(fakePublicState, "custom-escc")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal([]string{"cc-a", "cc-d"}))
+		})
 
-				It("returns an error", func() {
-					_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
-					Expect(err).To(MatchError(ContainSubstring("could not get channel config for channel 'my-channel'")))
-				})
-			})
+		It("matches the builtin plugin for an empty plugin name", func() {
+			result, err := ef.QueryChaincodeDefinitionsByPlugin(fakePublicState, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal([]string{"cc-b", "cc-c"}))
 		})
 
-		Context("when the public state is not readable", func() {
+		Context("when querying the definitions fails", func() {
 			BeforeEach(func() {
-				fakePublicState.GetStateReturns(nil, fmt.Errorf("getstate-error"))
+				fakePublicState.GetStateRangeReturns(nil, fmt.Errorf("state-range-error"))
 			})
 
 			It("wraps and returns the error", func() {
-				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
-				Expect(err).To(MatchError("could not get current sequence: could not get state for key namespaces/fields/cc-name/Sequence: getstate-error"))
+				_, err := ef.QueryChaincodeDefinitionsByPlugin(fakePublicState, "escc")
+				Expect(err).To(MatchError("could not query chaincode definitions: could not get state range for namespace namespaces: state-range-error"))
 			})
 		})
+	})
 
-		Context("when the public state is not writable", func() {
+	Describe("QueryInitRequiredChaincodes", func() {
+		var (
+			fakePublicState *mock.ReadWritableState
+
+			publicKVS MapLedgerShim
+		)
+
+		BeforeEach(func() {
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			fakePublicState = &mock.ReadWritableState{}
+			fakePublicState.GetStateRangeStub = publicKVS.GetStateRange
+			for name, initRequired := range map[string]bool{
+				"cc-b": true,
+				"cc-a": true,
+				"cc-c": false,
+			} {
+				resources.Serializer.Serialize("namespaces", name, &lifecycle.ChaincodeDefinition{
+					Sequence: 1,
+					EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+						InitRequired: initRequired,
+					},
+					ValidationInfo: &lb.ChaincodeValidationInfo{},
+					Collections:    &pb.CollectionConfigPackage{},
+				}, publicKVS)
+			}
+			resources.Serializer.Serialize("namespaces", "other-name", &lifecycle.ChaincodeParameters{}, publicKVS)
+		})
+
+		It("returns the sorted names of the chaincodes requiring init", func() {
+			result, err := ef.QueryInitRequiredChaincodes(fakePublicState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal([]string{"cc-a", "cc-b"}))
+		})
+
+		Context("when querying the definitions fails", func() {
 			BeforeEach(func() {
-				fakePublicState.PutStateReturns(fmt.Errorf("putstate-error"))
+				fakePublicState.GetStateRangeReturns(nil, fmt.Errorf("state-range-error"))
 			})
 
 			It("wraps and returns the error", func() {
-				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
-				Expect(err).To(MatchError("could not serialize chaincode definition: could not write key into state: putstate-error"))
+				_, err := ef.QueryInitRequiredChaincodes(fakePublicState)
+				Expect(err).To(MatchError("could not query chaincode definitions: could not get state range for namespace namespaces: state-range-error"))
 			})
 		})
+	})
 
-		Context("when the current sequence is not immediately prior to the new", func() {
-			BeforeEach(func() {
-				resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
-					Sequence: 3,
-					EndorsementInfo: &lb.ChaincodeEndorsementInfo{
-						Version:           "version",
-						EndorsementPlugin: "endorsement-plugin",
-					},
-					ValidationInfo: &lb.ChaincodeValidationInfo{
-						ValidationPlugin:    "validation-plugin",
-						ValidationParameter: []byte("validation-parameter"),
-					},
-				}, fakePublicState)
+	Describe("ValidateVersionConsistency", func() {
+		newDefinition := func(version string) *lifecycle.ChaincodeDefinition {
+			return &lifecycle.ChaincodeDefinition{
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
+					Version: version,
+				},
+				ValidationInfo: &lb.ChaincodeValidationInfo{},
+			}
+		}
+
+		It("accepts a family of semver versions", func() {
+			err := ef.ValidateVersionConsistency(map[string]*lifecycle.ChaincodeDefinition{
+				"cc1": newDefinition("1.0.0"),
+				"cc2": newDefinition("v2.3.4"),
+				"cc3": newDefinition("1.2.0-rc1+build5"),
 			})
+			Expect(err).NotTo(HaveOccurred())
+		})
 
-			It("returns an error", func() {
-				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
-				Expect(err).To(MatchError("requested sequence is 5, but new definition must be sequence 4"))
+		It("accepts a family of date-based versions", func() {
+			err := ef.ValidateVersionConsistency(map[string]*lifecycle.ChaincodeDefinition{
+				"cc1": newDefinition("2020-08-05"),
+				"cc2": newDefinition("20200805.1"),
 			})
+			Expect(err).NotTo(HaveOccurred())
 		})
 
-		Context("when the current sequence is the new sequence", func() {
-			BeforeEach(func() {
-				resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
-					Sequence: 5,
-					EndorsementInfo: &lb.ChaincodeEndorsementInfo{
-						Version:           "version",
-						EndorsementPlugin: "endorsement-plugin",
-					},
-					ValidationInfo: &lb.ChaincodeValidationInfo{
-						ValidationPlugin:    "validation-plugin",
-						ValidationParameter: []byte("validation-parameter"),
-					},
-				}, fakePublicState)
+		It("accepts an empty family", func() {
+			Expect(ef.ValidateVersionConsistency(nil)).To(Succeed())
+		})
+
+		It("reports the outliers of a mixed family", func() {
+			err := ef.ValidateVersionConsistency(map[string]*lifecycle.ChaincodeDefinition{
+				"cc1": newDefinition("1.0.0"),
+				"cc2": newDefinition("1.1.0"),
+				"cc3": newDefinition("2020-08-05"),
+				"cc4": newDefinition("latest"),
 			})
+			Expect(err).To(MatchError("chaincode versions do not follow the semver version scheme: cc3 ('2020-08-05'), cc4 ('latest')"))
+		})
 
-			It("returns an error", func() {
-				_, err := ef.CommitChaincodeDefinition("my-channel", "cc-name", testDefinition, fakePublicState, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
-				Expect(err).To(MatchError("requested sequence is 5, but new definition must be sequence 6"))
+		It("expects the scheme shared by the most definitions", func() {
+			err := ef.ValidateVersionConsistency(map[string]*lifecycle.ChaincodeDefinition{
+				"cc1": newDefinition("1.0.0"),
+				"cc2": newDefinition("2020-08-05"),
+				"cc3": newDefinition("2020-08-06"),
 			})
+			Expect(err).To(MatchError("chaincode versions do not follow the date version scheme: cc1 ('1.0.0')"))
 		})
 	})
 
-	Describe("QueryChaincodeDefinition", func() {
+	Describe("MissingPackages", func() {
 		var (
-			fakePublicState *mock.ReadWritableState
-			fakeOrgStates   []*mock.ReadWritableState
-
-			testDefinition *lifecycle.ChaincodeDefinition
+			fakeOrgState *mock.ReadWritableState
 
-			publicKVS, org0KVS, org1KVS MapLedgerShim
+			publicKVS, orgKVS MapLedgerShim
 		)
 
 		BeforeEach(func() {
 			publicKVS = MapLedgerShim(map[string][]byte{})
-			fakePublicState = &mock.ReadWritableState{}
-			fakePublicState.GetStateStub = publicKVS.GetState
-			fakePublicState.PutStateStub = publicKVS.PutState
-
-			testDefinition = &lifecycle.ChaincodeDefinition{
-				Sequence: 4,
-				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
-					Version:           "version",
-					EndorsementPlugin: "endorsement-plugin",
-				},
-				ValidationInfo: &lb.ChaincodeValidationInfo{
-					ValidationPlugin:    "validation-plugin",
-					ValidationParameter: []byte("validation-parameter"),
-				},
-				Collections: &pb.CollectionConfigPackage{},
-			}
-
-			resources.Serializer.Serialize("namespaces", "cc-name", testDefinition, publicKVS)
-
-			org0KVS = MapLedgerShim(map[string][]byte{})
-			org1KVS = MapLedgerShim(map[string][]byte{})
-			fakeOrg0State := &mock.ReadWritableState{}
-			fakeOrg1State := &mock.ReadWritableState{}
-			fakeOrgStates = []*mock.ReadWritableState{
-				fakeOrg0State,
-				fakeOrg1State,
-			}
-			for i, kvs := range []MapLedgerShim{org0KVS, org1KVS} {
-				kvs := kvs
-				fakeOrgStates[i].GetStateStub = kvs.GetState
-				fakeOrgStates[i].GetStateHashStub = kvs.GetStateHash
-				fakeOrgStates[i].PutStateStub = kvs.PutState
-			}
+			resources.Serializer.Serialize("namespaces", "cc1", &lifecycle.ChaincodeDefinition{Sequence: 2}, publicKVS)
+			resources.Serializer.Serialize("namespaces", "cc2", &lifecycle.ChaincodeDefinition{Sequence: 1}, publicKVS)
 
-			resources.Serializer.Serialize("namespaces", "cc-name#4", testDefinition.Parameters(), fakeOrgStates[0])
-			resources.Serializer.Serialize("namespaces", "cc-name#4", &lifecycle.ChaincodeParameters{}, fakeOrgStates[1])
+			orgKVS = MapLedgerShim(map[string][]byte{})
+			fakeOrgState = &mock.ReadWritableState{}
+			fakeOrgState.GetStateRangeStub = orgKVS.GetStateRange
+			resources.Serializer.Serialize("chaincode-sources", "cc1#1", &lifecycle.ChaincodeLocalPackage{PackageID: "superseded-package-id"}, orgKVS)
+			resources.Serializer.Serialize("chaincode-sources", "cc1#2", &lifecycle.ChaincodeLocalPackage{PackageID: "installed-package-id"}, orgKVS)
+			resources.Serializer.Serialize("chaincode-sources", "cc2#1", &lifecycle.ChaincodeLocalPackage{PackageID: "missing-package-id"}, orgKVS)
+			resources.Serializer.Serialize("chaincode-sources", "cc3#1", &lifecycle.ChaincodeLocalPackage{PackageID: "uncommitted-package-id"}, orgKVS)
+
+			fakeCCStore.ListInstalledChaincodesReturns([]chaincode.InstalledChaincode{
+				{PackageID: "installed-package-id"},
+			}, nil)
 		})
 
-		It("returns the defined chaincode", func() {
-			cc, err := ef.QueryChaincodeDefinition("cc-name", fakePublicState)
+		It("returns the packages referenced by committed definitions which are not installed", func() {
+			missing, err := ef.MissingPackages(publicKVS, fakeOrgState)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(cc.Sequence).To(Equal(int64(4)))
-			Expect(proto.Equal(cc.EndorsementInfo, &lb.ChaincodeEndorsementInfo{
-				Version:           "version",
-				EndorsementPlugin: "endorsement-plugin",
-			})).To(BeTrue())
-			Expect(proto.Equal(cc.ValidationInfo, &lb.ChaincodeValidationInfo{
-				ValidationPlugin:    "validation-plugin",
-				ValidationParameter: []byte("validation-parameter"),
-			})).To(BeTrue())
-			Expect(proto.Equal(cc.Collections, &pb.CollectionConfigPackage{})).To(BeTrue())
+			Expect(missing).To(Equal([]string{"missing-package-id"}))
 		})
 
-		Context("when the chaincode is not defined", func() {
+		Context("when the org approved a definition without a package", func() {
 			BeforeEach(func() {
-				fakePublicState.GetStateReturns(nil, nil)
+				resources.Serializer.Serialize("chaincode-sources", "cc2#1", &lifecycle.ChaincodeLocalPackage{}, orgKVS)
+			})
+
+			It("does not report a missing package", func() {
+				missing, err := ef.MissingPackages(publicKVS, fakeOrgState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(missing).To(BeEmpty())
+			})
+		})
+
+		Context("when the chaincode-source range cannot be retrieved", func() {
+			BeforeEach(func() {
+				fakeOrgState.GetStateRangeReturns(nil, fmt.Errorf("state-range-error"))
 			})
 
 			It("returns an error", func() {
-				cc, err := ef.QueryChaincodeDefinition("cc-name", fakePublicState)
-				Expect(err).To(MatchError("namespace cc-name is not defined"))
-				Expect(cc).To(BeNil())
+				_, err := ef.MissingPackages(publicKVS, fakeOrgState)
+				Expect(err).To(MatchError("could not query chaincode-source metadata: could not get state range for namespace chaincode-sources: state-range-error"))
 			})
 		})
 
-		Context("when getting the metadata fails", func() {
+		Context("when a chaincode package cannot be deserialized", func() {
 			BeforeEach(func() {
-				fakePublicState.GetStateReturns(nil, fmt.Errorf("metadata-error"))
+				orgKVS["chaincode-sources/fields/cc2#1/PackageID"] = []byte("garbage")
 			})
 
 			It("returns an error", func() {
-				cc, err := ef.QueryChaincodeDefinition("cc-name", fakePublicState) //, nil)
-				Expect(err).To(MatchError("could not fetch metadata for namespace cc-name: could not query metadata for namespace namespaces/cc-name: metadata-error"))
-				Expect(cc).To(BeNil())
+				_, err := ef.MissingPackages(publicKVS, fakeOrgState)
+				Expect(err).To(MatchError("could not deserialize chaincode package for cc2#1: could not unmarshal state for key chaincode-sources/fields/cc2#1/PackageID: proto: can't skip unknown wire type 7"))
 			})
 		})
 
-		Context("when deserializing the definition fails", func() {
+		Context("when the installed chaincodes cannot be listed", func() {
 			BeforeEach(func() {
-				publicKVS["namespaces/fields/cc-name/EndorsementInfo"] = []byte("garbage")
+				fakeCCStore.ListInstalledChaincodesReturns(nil, fmt.Errorf("list-error"))
 			})
 
 			It("returns an error", func() {
-				cc, err := ef.QueryChaincodeDefinition("cc-name", fakePublicState)
-				Expect(err).To(MatchError("could not deserialize namespace cc-name as chaincode: could not unmarshal state for key namespaces/fields/cc-name/EndorsementInfo: proto: can't skip unknown wire type 7"))
-				Expect(cc).To(BeNil())
+				_, err := ef.MissingPackages(publicKVS, fakeOrgState)
+				Expect(err).To(MatchError("could not list installed chaincodes: list-error"))
 			})
 		})
 	})
 
-	Describe("QueryOrgApprovals", func() {
+	Describe("GroupChaincodesByPackage", func() {
 		var (
-			fakeOrgStates []*mock.ReadWritableState
-
-			testDefinition *lifecycle.ChaincodeDefinition
+			fakeOrgState *mock.ReadWritableState
 
-			org0KVS, org1KVS MapLedgerShim
+			publicKVS, orgKVS MapLedgerShim
 		)
 
 		BeforeEach(func() {
-			testDefinition = &lifecycle.ChaincodeDefinition{
-				Sequence: 4,
-				EndorsementInfo: &lb.ChaincodeEndorsementInfo{
-					Version:           "version",
-					EndorsementPlugin: "endorsement-plugin",
-				},
-				ValidationInfo: &lb.ChaincodeValidationInfo{
-					ValidationPlugin:    "validation-plugin",
-					ValidationParameter: []byte("validation-parameter"),
-				},
-				Collections: &pb.CollectionConfigPackage{},
-			}
-
-			org0KVS = MapLedgerShim(map[string][]byte{})
-			org1KVS = MapLedgerShim(map[string][]byte{})
-			fakeOrg0State := &mock.ReadWritableState{}
-			fakeOrg0State.CollectionNameReturns("_implicit_org_org0")
-			fakeOrg1State := &mock.ReadWritableState{}
-			fakeOrg1State.CollectionNameReturns("_implicit_org_org1")
-			fakeOrgStates = []*mock.ReadWritableState{
-				fakeOrg0State,
-				fakeOrg1State,
-			}
-			for i, kvs := range []MapLedgerShim{org0KVS, org1KVS} {
-				kvs := kvs
-				fakeOrgStates[i].GetStateStub = kvs.GetState
-				fakeOrgStates[i].GetStateHashStub = kvs.GetStateHash
-				fakeOrgStates[i].PutStateStub = kvs.PutState
+			publicKVS = MapLedgerShim(map[string][]byte{})
+			for name, sequence := range map[string]int64{"cc1": 2, "cc2": 1, "cc3": 1, "cc4": 1} {
+				resources.Serializer.Serialize("namespaces", name, &lifecycle.ChaincodeDefinition{
+					Sequence:        sequence,
+					EndorsementInfo: &lb.ChaincodeEndorsementInfo{},
+					ValidationInfo:  &lb.ChaincodeValidationInfo{},
+					Collections:     &pb.CollectionConfigPackage{},
+				}, publicKVS)
 			}
 
-			resources.Serializer.Serialize("namespaces", "cc-name#4", testDefinition.Parameters(), fakeOrgStates[0])
-			resources.Serializer.Serialize("namespaces", "cc-name#4", &lifecycle.ChaincodeParameters{}, fakeOrgStates[1])
+			orgKVS = MapLedgerShim(map[string][]byte{})
+			fakeOrgState = &mock.ReadWritableState{}
+			fakeOrgState.GetStateRangeStub = orgKVS.GetStateRange
+			resources.Serializer.Serialize("chaincode-sources", "cc1#1", &lifecycle.ChaincodeLocalPackage{PackageID: "superseded:aaaa"}, orgKVS)
+			resources.Serializer.Serialize("chaincode-sources", "cc1#2", &lifecycle.ChaincodeLocalPackage{PackageID: "shared:abcd"}, orgKVS)
+			resources.Serializer.Serialize("chaincode-sources", "cc2#1", &lifecycle.ChaincodeLocalPackage{PackageID: "shared:abcd"}, orgKVS)
+			resources.Serializer.Serialize("chaincode-sources", "cc3#1", &lifecycle.ChaincodeLocalPackage{PackageID: "unique:1234"}, orgKVS)
+			resources.Serializer.Serialize("chaincode-sources", "cc4#1", &lifecycle.ChaincodeLocalPackage{}, orgKVS)
 		})
 
-		It("returns the org approvals", func() {
-			approvals, err := ef.QueryOrgApprovals("cc-name", testDefinition, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
+		It("groups the committed chaincodes by the hash of their approved package", func() {
+			groups, err := ef.GroupChaincodesByPackage(publicKVS, fakeOrgState)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(approvals).To(Equal(map[string]bool{
-				"org0": true,
-				"org1": false,
+			Expect(groups).To(Equal(map[string][]string{
+				"abcd": {"cc1", "cc2"},
+				"1234": {"cc3"},
 			}))
 		})
 
-		Context("when the org state cannot be deserialized", func() {
+		Context("when the chaincode definitions cannot be retrieved", func() {
+			It("returns an error", func() {
+				fakePublicState := &mock.ReadWritableState{}
+				fakePublicState.GetStateRangeReturns(nil, fmt.Errorf("state-range-error"))
+				_, err := ef.GroupChaincodesByPackage(fakePublicState, fakeOrgState)
+				Expect(err).To(MatchError("could not query chaincode definitions: could not get state range for namespace namespaces: state-range-error"))
+			})
+		})
+
+		Context("when the chaincode-source range cannot be retrieved", func() {
 			BeforeEach(func() {
-				fakeOrgStates[0].GetStateHashReturns(nil, errors.New("owww that hurt"))
+				fakeOrgState.GetStateRangeReturns(nil, fmt.Errorf("state-range-error"))
 			})
 
-			It("wraps and returns an error", func() {
-				approvals, err := ef.QueryOrgApprovals("cc-name", testDefinition, []lifecycle.OpaqueState{fakeOrgStates[0], fakeOrgStates[1]})
-				Expect(err).To(MatchError("serialization check failed for key cc-name#4: could not get value for key namespaces/metadata/cc-name#4: owww that hurt"))
-				Expect(approvals).To(BeNil())
+			It("returns an error", func() {
+				_, err := ef.GroupChaincodesByPackage(publicKVS, fakeOrgState)
+				Expect(err).To(MatchError("could not query chaincode-source metadata: could not get state range for namespace chaincode-sources: state-range-error"))
 			})
 		})
 	})
 
-	Describe("QueryNamespaceDefinitions", func() {
-		var (
-			fakePublicState *mock.ReadWritableState
-
-			publicKVS MapLedgerShim
-		)
+	Describe("QueryUnreferencedInstalledChaincodes", func() {
+		var publicKVS, orgKVS MapLedgerShim
 
 		BeforeEach(func() {
 			publicKVS = MapLedgerShim(map[string][]byte{})
-			fakePublicState = &mock.ReadWritableState{}
-			fakePublicState.GetStateStub = publicKVS.GetState
-			fakePublicState.GetStateRangeStub = publicKVS.GetStateRange
-			resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{}, publicKVS)
-			resources.Serializer.Serialize("namespaces", "other-name", &lifecycle.ChaincodeParameters{}, publicKVS)
+			resources.Serializer.Serialize("namespaces", "cc-name", &lifecycle.ChaincodeDefinition{
+				Sequence:        2,
+				EndorsementInfo: &lb.ChaincodeEndorsementInfo{},
+				ValidationInfo:  &lb.ChaincodeValidationInfo{},
+				Collections:     &pb.CollectionConfigPackage{},
+			}, publicKVS)
+
+			orgKVS = MapLedgerShim(map[string][]byte{})
+			resources.Serializer.Serialize("chaincode-sources", "cc-name#1", &lifecycle.ChaincodeLocalPackage{PackageID: "old:aaaa"}, orgKVS)
+			resources.Serializer.Serialize("chaincode-sources", "cc-name#2", &lifecycle.ChaincodeLocalPackage{PackageID: "current:abcd"}, orgKVS)
+
+			fakeCCStore.ListInstalledChaincodesReturns([]chaincode.InstalledChaincode{
+				{PackageID: "current:abcd", Label: "current"},
+				{PackageID: "old:aaaa", Label: "old"},
+			}, nil)
 		})
 
-		It("returns the defined namespaces", func() {
-			result, err := ef.QueryNamespaceDefinitions(fakePublicState)
+		It("returns the installed packages which no committed definition references", func() {
+			result, err := ef.QueryUnreferencedInstalledChaincodes(
+				map[string]lifecycle.RangeableState{"channel-id": publicKVS},
+				map[string]lifecycle.RangeableState{"channel-id": orgKVS},
+			)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(result).To(Equal(map[string]string{
-				"cc-name":    "Chaincode",
-				"other-name": "ChaincodeParameters",
-			}))
+			Expect(result).To(Equal([]string{"old:aaaa"}))
 		})
 
-		Context("when the range cannot be retrieved", func() {
+		Context("when no org state is supplied for a channel", func() {
+			It("returns an error", func() {
+				_, err := ef.QueryUnreferencedInstalledChaincodes(
+					map[string]lifecycle.RangeableState{"channel-id": publicKVS},
+					map[string]lifecycle.RangeableState{},
+				)
+				Expect(err).To(MatchError("no org state supplied for channel 'channel-id'"))
+			})
+		})
+
+		Context("when the installed chaincodes cannot be listed", func() {
 			BeforeEach(func() {
-				fakePublicState.GetStateRangeReturns(nil, fmt.Errorf("state-range-error"))
+				fakeCCStore.ListInstalledChaincodesReturns(nil, fmt.Errorf("fake-list-error"))
 			})
 
-			It("returns an error", func() {
-				_, err := ef.QueryNamespaceDefinitions(fakePublicState)
-				Expect(err).To(MatchError("could not query namespace metadata: could not get state range for namespace namespaces: state-range-error"))
+			It("wraps and returns the error", func() {
+				_, err := ef.QueryUnreferencedInstalledChaincodes(
+					map[string]lifecycle.RangeableState{"channel-id": publicKVS},
+					map[string]lifecycle.RangeableState{"channel-id": orgKVS},
+				)
+				Expect(err).To(MatchError("could not list installed chaincodes: fake-list-error"))
 			})
 		})
 	})
@@ -0,0 +1,113 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lifecycle
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/common/metrics"
+)
+
+var (
+	installTotal = metrics.CounterOpts{
+		Namespace:    "chaincode_lifecycle",
+		Name:         "install_total",
+		Help:         "The number of chaincode installs.",
+		LabelNames:   []string{"success"},
+		StatsdFormat: "%{#fqname}.%{success}",
+	}
+	approveTotal = metrics.CounterOpts{
+		Namespace:    "chaincode_lifecycle",
+		Name:         "approve_total",
+		Help:         "The number of chaincode definition approvals.",
+		LabelNames:   []string{"success"},
+		StatsdFormat: "%{#fqname}.%{success}",
+	}
+	commitTotal = metrics.CounterOpts{
+		Namespace:    "chaincode_lifecycle",
+		Name:         "commit_total",
+		Help:         "The number of chaincode definition commits.",
+		LabelNames:   []string{"success"},
+		StatsdFormat: "%{#fqname}.%{success}",
+	}
+
+	installDuration = metrics.HistogramOpts{
+		Namespace:    "chaincode_lifecycle",
+		Name:         "install_duration",
+		Help:         "The time to install a chaincode.",
+		LabelNames:   []string{"success"},
+		StatsdFormat: "%{#fqname}.%{success}",
+	}
+	approveDuration = metrics.HistogramOpts{
+		Namespace:    "chaincode_lifecycle",
+		Name:         "approve_duration",
+		Help:         "The time to approve a chaincode definition.",
+		LabelNames:   []string{"success"},
+		StatsdFormat: "%{#fqname}.%{success}",
+	}
+	commitDuration = metrics.HistogramOpts{
+		Namespace:    "chaincode_lifecycle",
+		Name:         "commit_duration",
+		Help:         "The time to commit a chaincode definition.",
+		LabelNames:   []string{"success"},
+		StatsdFormat: "%{#fqname}.%{success}",
+	}
+)
+
+type Metrics struct {
+	InstallTotal    metrics.Counter
+	ApproveTotal    metrics.Counter
+	CommitTotal     metrics.Counter
+	InstallDuration metrics.Histogram
+	ApproveDuration metrics.Histogram
+	CommitDuration  metrics.Histogram
+}
+
+func NewMetrics(p metrics.Provider) *Metrics {
+	return &Metrics{
+		InstallTotal:    p.NewCounter(installTotal),
+		ApproveTotal:    p.NewCounter(approveTotal),
+		CommitTotal:     p.NewCounter(commitTotal),
+		InstallDuration: p.NewHistogram(installDuration),
+		ApproveDuration: p.NewHistogram(approveDuration),
+		CommitDuration:  p.NewHistogram(commitDuration),
+	}
+}
+
+// observe records the outcome and duration of an operation which started
+// at startTime and ended at endTime, both in nanoseconds since the Unix epoch.
+func (m *Metrics) observe(total metrics.Counter, duration metrics.Histogram, startTime, endTime int64, err error) {
+	success := strconv.FormatBool(err == nil)
+	total.With("success", success).Add(1)
+	duration.With("success", success).Observe(time.Duration(endTime - startTime).Seconds())
+}
+
+// The observe functions are no-ops for nil metrics, so that
+// ExternalFunctions may be used without metrics.  The end time of the
+// operation is read from now, which is not called for nil metrics.
+
+func (m *Metrics) observeInstall(startTime int64, now func() int64, err error) {
+	if m == nil {
+		return
+	}
+	m.observe(m.InstallTotal, m.InstallDuration, startTime, now(), err)
+}
+
+func (m *Metrics) observeApprove(startTime int64, now func() int64, err error) {
+	if m == nil {
+		return
+	}
+	m.observe(m.ApproveTotal, m.ApproveDuration, startTime, now(), err)
+}
+
+func (m *Metrics) observeCommit(startTime int64, now func() int64, err error) {
+	if m == nil {
+		return
+	}
+	m.observe(m.CommitTotal, m.CommitDuration, startTime, now(), err)
+}
@@ -0,0 +1,79 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package mock
+
+import (
+	"sync"
+
+	"github.com/hyperledger/fabric/core/chaincode/lifecycle"
+)
+
+type ApprovalEndorsementListener struct {
+	HandleChaincodeApprovalEndorsedStub        func(string, *lifecycle.ChaincodeDefinition, string)
+	handleChaincodeApprovalEndorsedMutex       sync.RWMutex
+	handleChaincodeApprovalEndorsedArgsForCall []struct {
+		arg1 string
+		arg2 *lifecycle.ChaincodeDefinition
+		arg3 string
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *ApprovalEndorsementListener) HandleChaincodeApprovalEndorsed(arg1 string, arg2 *lifecycle.ChaincodeDefinition, arg3 string) {
+	fake.handleChaincodeApprovalEndorsedMutex.Lock()
+	fake.handleChaincodeApprovalEndorsedArgsForCall = append(fake.handleChaincodeApprovalEndorsedArgsForCall, struct {
+		arg1 string
+		arg2 *lifecycle.ChaincodeDefinition
+		arg3 string
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("HandleChaincodeApprovalEndorsed", []interface{}{arg1, arg2, arg3})
+	fake.handleChaincodeApprovalEndorsedMutex.Unlock()
+	if fake.HandleChaincodeApprovalEndorsedStub != nil {
+		fake.HandleChaincodeApprovalEndorsedStub(arg1, arg2, arg3)
+	}
+}
+
+func (fake *ApprovalEndorsementListener) HandleChaincodeApprovalEndorsedCallCount() int {
+	fake.handleChaincodeApprovalEndorsedMutex.RLock()
+	defer fake.handleChaincodeApprovalEndorsedMutex.RUnlock()
+	return len(fake.handleChaincodeApprovalEndorsedArgsForCall)
+}
+
+func (fake *ApprovalEndorsementListener) HandleChaincodeApprovalEndorsedCalls(stub func(string, *lifecycle.ChaincodeDefinition, string)) {
+	fake.handleChaincodeApprovalEndorsedMutex.Lock()
+	defer fake.handleChaincodeApprovalEndorsedMutex.Unlock()
+	fake.HandleChaincodeApprovalEndorsedStub = stub
+}
+
+func (fake *ApprovalEndorsementListener) HandleChaincodeApprovalEndorsedArgsForCall(i int) (string, *lifecycle.ChaincodeDefinition, string) {
+	fake.handleChaincodeApprovalEndorsedMutex.RLock()
+	defer fake.handleChaincodeApprovalEndorsedMutex.RUnlock()
+	argsForCall := fake.handleChaincodeApprovalEndorsedArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *ApprovalEndorsementListener) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.handleChaincodeApprovalEndorsedMutex.RLock()
+	defer fake.handleChaincodeApprovalEndorsedMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *ApprovalEndorsementListener) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ lifecycle.ApprovalEndorsementListener = new(ApprovalEndorsementListener)
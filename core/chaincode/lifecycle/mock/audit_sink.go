@@ -0,0 +1,86 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package mock
+
+import (
+	"sync"
+
+	"github.com/hyperledger/fabric/core/chaincode/lifecycle"
+)
+
+type AuditSink struct {
+	RecordCommitStub        func(string, string, int64, []byte)
+	recordCommitMutex       sync.RWMutex
+	recordCommitArgsForCall []struct {
+		arg1 string
+		arg2 string
+		arg3 int64
+		arg4 []byte
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *AuditSink) RecordCommit(arg1 string, arg2 string, arg3 int64, arg4 []byte) {
+	var arg4Copy []byte
+	if arg4 != nil {
+		arg4Copy = make([]byte, len(arg4))
+		copy(arg4Copy, arg4)
+	}
+	fake.recordCommitMutex.Lock()
+	fake.recordCommitArgsForCall = append(fake.recordCommitArgsForCall, struct {
+		arg1 string
+		arg2 string
+		arg3 int64
+		arg4 []byte
+	}{arg1, arg2, arg3, arg4Copy})
+	fake.recordInvocation("RecordCommit", []interface{}{arg1, arg2, arg3, arg4Copy})
+	fake.recordCommitMutex.Unlock()
+	if fake.RecordCommitStub != nil {
+		fake.RecordCommitStub(arg1, arg2, arg3, arg4)
+	}
+}
+
+func (fake *AuditSink) RecordCommitCallCount() int {
+	fake.recordCommitMutex.RLock()
+	defer fake.recordCommitMutex.RUnlock()
+	return len(fake.recordCommitArgsForCall)
+}
+
+func (fake *AuditSink) RecordCommitCalls(stub func(string, string, int64, []byte)) {
+	fake.recordCommitMutex.Lock()
+	defer fake.recordCommitMutex.Unlock()
+	fake.RecordCommitStub = stub
+}
+
+func (fake *AuditSink) RecordCommitArgsForCall(i int) (string, string, int64, []byte) {
+	fake.recordCommitMutex.RLock()
+	defer fake.recordCommitMutex.RUnlock()
+	argsForCall := fake.recordCommitArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *AuditSink) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.recordCommitMutex.RLock()
+	defer fake.recordCommitMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *AuditSink) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ lifecycle.AuditSink = new(AuditSink)
@@ -2,12 +2,27 @@
 package mock
 
 import (
+	"context"
+	"io"
 	"sync"
 
 	"github.com/hyperledger/fabric/common/chaincode"
+	"github.com/hyperledger/fabric/core/chaincode/persistence"
 )
 
 type ChaincodeStore struct {
+	CountStub        func() (int, error)
+	countMutex       sync.RWMutex
+	countArgsForCall []struct {
+	}
+	countReturns struct {
+		result1 int
+		result2 error
+	}
+	countReturnsOnCall map[int]struct {
+		result1 int
+		result2 error
+	}
 	DeleteStub        func(string) error
 	deleteMutex       sync.RWMutex
 	deleteArgsForCall []struct {
@@ -44,6 +59,19 @@ type ChaincodeStore struct {
 		result1 []byte
 		result2 error
 	}
+	RetrieveHashByPackageIDStub        func(string) ([]byte, error)
+	retrieveHashByPackageIDMutex       sync.RWMutex
+	retrieveHashByPackageIDArgsForCall []struct {
+		arg1 string
+	}
+	retrieveHashByPackageIDReturns struct {
+		result1 []byte
+		result2 error
+	}
+	retrieveHashByPackageIDReturnsOnCall map[int]struct {
+		result1 []byte
+		result2 error
+	}
 	SaveStub        func(string, []byte) (string, error)
 	saveMutex       sync.RWMutex
 	saveArgsForCall []struct {
@@ -58,10 +86,96 @@ type ChaincodeStore struct {
 		result1 string
 		result2 error
 	}
+	SaveStreamStub        func(io.Reader, int64) (string, *persistence.ChaincodePackageMetadata, error)
+	saveStreamMutex       sync.RWMutex
+	saveStreamArgsForCall []struct {
+		arg1 io.Reader
+		arg2 int64
+	}
+	saveStreamReturns struct {
+		result1 string
+		result2 *persistence.ChaincodePackageMetadata
+		result3 error
+	}
+	saveStreamReturnsOnCall map[int]struct {
+		result1 string
+		result2 *persistence.ChaincodePackageMetadata
+		result3 error
+	}
+	SaveWithContextStub        func(context.Context, string, []byte) (string, error)
+	saveWithContextMutex       sync.RWMutex
+	saveWithContextArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 []byte
+	}
+	saveWithContextReturns struct {
+		result1 string
+		result2 error
+	}
+	saveWithContextReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
+func (fake *ChaincodeStore) Count() (int, error) {
+	fake.countMutex.Lock()
+	ret, specificReturn := fake.countReturnsOnCall[len(fake.countArgsForCall)]
+	fake.countArgsForCall = append(fake.countArgsForCall, struct {
+	}{})
+	fake.recordInvocation("Count", []interface{}{})
+	fake.countMutex.Unlock()
+	if fake.CountStub != nil {
+		return fake.CountStub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.countReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *ChaincodeStore) CountCallCount() int {
+	fake.countMutex.RLock()
+	defer fake.countMutex.RUnlock()
+	return len(fake.countArgsForCall)
+}
+
+func (fake *ChaincodeStore) CountCalls(stub func() (int, error)) {
+	fake.countMutex.Lock()
+	defer fake.countMutex.Unlock()
+	fake.CountStub = stub
+}
+
+func (fake *ChaincodeStore) CountReturns(result1 int, result2 error) {
+	fake.countMutex.Lock()
+	defer fake.countMutex.Unlock()
+	fake.CountStub = nil
+	fake.countReturns = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ChaincodeStore) CountReturnsOnCall(i int, result1 int, result2 error) {
+	fake.countMutex.Lock()
+	defer fake.countMutex.Unlock()
+	fake.CountStub = nil
+	if fake.countReturnsOnCall == nil {
+		fake.countReturnsOnCall = make(map[int]struct {
+			result1 int
+			result2 error
+		})
+	}
+	fake.countReturnsOnCall[i] = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *ChaincodeStore) Delete(arg1 string) error {
 	fake.deleteMutex.Lock()
 	ret, specificReturn := fake.deleteReturnsOnCall[len(fake.deleteArgsForCall)]
@@ -240,6 +354,69 @@ func (fake *ChaincodeStore) LoadReturnsOnCall(i int, result1 []byte, result2 err
 	}{result1, result2}
 }
 
+func (fake *ChaincodeStore) RetrieveHashByPackageID(arg1 string) ([]byte, error) {
+	fake.retrieveHashByPackageIDMutex.Lock()
+	ret, specificReturn := fake.retrieveHashByPackageIDReturnsOnCall[len(fake.retrieveHashByPackageIDArgsForCall)]
+	fake.retrieveHashByPackageIDArgsForCall = append(fake.retrieveHashByPackageIDArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.recordInvocation("RetrieveHashByPackageID", []interface{}{arg1})
+	fake.retrieveHashByPackageIDMutex.Unlock()
+	if fake.RetrieveHashByPackageIDStub != nil {
+		return fake.RetrieveHashByPackageIDStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.retrieveHashByPackageIDReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *ChaincodeStore) RetrieveHashByPackageIDCallCount() int {
+	fake.retrieveHashByPackageIDMutex.RLock()
+	defer fake.retrieveHashByPackageIDMutex.RUnlock()
+	return len(fake.retrieveHashByPackageIDArgsForCall)
+}
+
+func (fake *ChaincodeStore) RetrieveHashByPackageIDCalls(stub func(string) ([]byte, error)) {
+	fake.retrieveHashByPackageIDMutex.Lock()
+	defer fake.retrieveHashByPackageIDMutex.Unlock()
+	fake.RetrieveHashByPackageIDStub = stub
+}
+
+func (fake *ChaincodeStore) RetrieveHashByPackageIDArgsForCall(i int) string {
+	fake.retrieveHashByPackageIDMutex.RLock()
+	defer fake.retrieveHashByPackageIDMutex.RUnlock()
+	argsForCall := fake.retrieveHashByPackageIDArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *ChaincodeStore) RetrieveHashByPackageIDReturns(result1 []byte, result2 error) {
+	fake.retrieveHashByPackageIDMutex.Lock()
+	defer fake.retrieveHashByPackageIDMutex.Unlock()
+	fake.RetrieveHashByPackageIDStub = nil
+	fake.retrieveHashByPackageIDReturns = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ChaincodeStore) RetrieveHashByPackageIDReturnsOnCall(i int, result1 []byte, result2 error) {
+	fake.retrieveHashByPackageIDMutex.Lock()
+	defer fake.retrieveHashByPackageIDMutex.Unlock()
+	fake.RetrieveHashByPackageIDStub = nil
+	if fake.retrieveHashByPackageIDReturnsOnCall == nil {
+		fake.retrieveHashByPackageIDReturnsOnCall = make(map[int]struct {
+			result1 []byte
+			result2 error
+		})
+	}
+	fake.retrieveHashByPackageIDReturnsOnCall[i] = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *ChaincodeStore) Save(arg1 string, arg2 []byte) (string, error) {
 	var arg2Copy []byte
 	if arg2 != nil {
@@ -309,17 +486,162 @@ func (fake *ChaincodeStore) SaveReturnsOnCall(i int, result1 string, result2 err
 	}{result1, result2}
 }
 
+func (fake *ChaincodeStore) SaveStream(arg1 io.Reader, arg2 int64) (string, *persistence.ChaincodePackageMetadata, error) {
+	fake.saveStreamMutex.Lock()
+	ret, specificReturn := fake.saveStreamReturnsOnCall[len(fake.saveStreamArgsForCall)]
+	fake.saveStreamArgsForCall = append(fake.saveStreamArgsForCall, struct {
+		arg1 io.Reader
+		arg2 int64
+	}{arg1, arg2})
+	fake.recordInvocation("SaveStream", []interface{}{arg1, arg2})
+	fake.saveStreamMutex.Unlock()
+	if fake.SaveStreamStub != nil {
+		return fake.SaveStreamStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	fakeReturns := fake.saveStreamReturns
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
+}
+
+func (fake *ChaincodeStore) SaveStreamCallCount() int {
+	fake.saveStreamMutex.RLock()
+	defer fake.saveStreamMutex.RUnlock()
+	return len(fake.saveStreamArgsForCall)
+}
+
+func (fake *ChaincodeStore) SaveStreamCalls(stub func(io.Reader, int64) (string, *persistence.ChaincodePackageMetadata, error)) {
+	fake.saveStreamMutex.Lock()
+	defer fake.saveStreamMutex.Unlock()
+	fake.SaveStreamStub = stub
+}
+
+func (fake *ChaincodeStore) SaveStreamArgsForCall(i int) (io.Reader, int64) {
+	fake.saveStreamMutex.RLock()
+	defer fake.saveStreamMutex.RUnlock()
+	argsForCall := fake.saveStreamArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *ChaincodeStore) SaveStreamReturns(result1 string, result2 *persistence.ChaincodePackageMetadata, result3 error) {
+	fake.saveStreamMutex.Lock()
+	defer fake.saveStreamMutex.Unlock()
+	fake.SaveStreamStub = nil
+	fake.saveStreamReturns = struct {
+		result1 string
+		result2 *persistence.ChaincodePackageMetadata
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *ChaincodeStore) SaveStreamReturnsOnCall(i int, result1 string, result2 *persistence.ChaincodePackageMetadata, result3 error) {
+	fake.saveStreamMutex.Lock()
+	defer fake.saveStreamMutex.Unlock()
+	fake.SaveStreamStub = nil
+	if fake.saveStreamReturnsOnCall == nil {
+		fake.saveStreamReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 *persistence.ChaincodePackageMetadata
+			result3 error
+		})
+	}
+	fake.saveStreamReturnsOnCall[i] = struct {
+		result1 string
+		result2 *persistence.ChaincodePackageMetadata
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *ChaincodeStore) SaveWithContext(arg1 context.Context, arg2 string, arg3 []byte) (string, error) {
+	var arg3Copy []byte
+	if arg3 != nil {
+		arg3Copy = make([]byte, len(arg3))
+		copy(arg3Copy, arg3)
+	}
+	fake.saveWithContextMutex.Lock()
+	ret, specificReturn := fake.saveWithContextReturnsOnCall[len(fake.saveWithContextArgsForCall)]
+	fake.saveWithContextArgsForCall = append(fake.saveWithContextArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 []byte
+	}{arg1, arg2, arg3Copy})
+	fake.recordInvocation("SaveWithContext", []interface{}{arg1, arg2, arg3Copy})
+	fake.saveWithContextMutex.Unlock()
+	if fake.SaveWithContextStub != nil {
+		return fake.SaveWithContextStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.saveWithContextReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *ChaincodeStore) SaveWithContextCallCount() int {
+	fake.saveWithContextMutex.RLock()
+	defer fake.saveWithContextMutex.RUnlock()
+	return len(fake.saveWithContextArgsForCall)
+}
+
+func (fake *ChaincodeStore) SaveWithContextCalls(stub func(context.Context, string, []byte) (string, error)) {
+	fake.saveWithContextMutex.Lock()
+	defer fake.saveWithContextMutex.Unlock()
+	fake.SaveWithContextStub = stub
+}
+
+func (fake *ChaincodeStore) SaveWithContextArgsForCall(i int) (context.Context, string, []byte) {
+	fake.saveWithContextMutex.RLock()
+	defer fake.saveWithContextMutex.RUnlock()
+	argsForCall := fake.saveWithContextArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *ChaincodeStore) SaveWithContextReturns(result1 string, result2 error) {
+	fake.saveWithContextMutex.Lock()
+	defer fake.saveWithContextMutex.Unlock()
+	fake.SaveWithContextStub = nil
+	fake.saveWithContextReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *ChaincodeStore) SaveWithContextReturnsOnCall(i int, result1 string, result2 error) {
+	fake.saveWithContextMutex.Lock()
+	defer fake.saveWithContextMutex.Unlock()
+	fake.SaveWithContextStub = nil
+	if fake.saveWithContextReturnsOnCall == nil {
+		fake.saveWithContextReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.saveWithContextReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *ChaincodeStore) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.countMutex.RLock()
+	defer fake.countMutex.RUnlock()
 	fake.deleteMutex.RLock()
 	defer fake.deleteMutex.RUnlock()
 	fake.listInstalledChaincodesMutex.RLock()
 	defer fake.listInstalledChaincodesMutex.RUnlock()
 	fake.loadMutex.RLock()
 	defer fake.loadMutex.RUnlock()
+	fake.retrieveHashByPackageIDMutex.RLock()
+	defer fake.retrieveHashByPackageIDMutex.RUnlock()
 	fake.saveMutex.RLock()
 	defer fake.saveMutex.RUnlock()
+	fake.saveStreamMutex.RLock()
+	defer fake.saveStreamMutex.RUnlock()
+	fake.saveWithContextMutex.RLock()
+	defer fake.saveWithContextMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
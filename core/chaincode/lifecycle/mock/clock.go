@@ -0,0 +1,101 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package mock
+
+import (
+	"sync"
+
+	"github.com/hyperledger/fabric/core/chaincode/lifecycle"
+)
+
+type Clock struct {
+	NowStub        func() int64
+	nowMutex       sync.RWMutex
+	nowArgsForCall []struct {
+	}
+	nowReturns struct {
+		result1 int64
+	}
+	nowReturnsOnCall map[int]struct {
+		result1 int64
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *Clock) Now() int64 {
+	fake.nowMutex.Lock()
+	ret, specificReturn := fake.nowReturnsOnCall[len(fake.nowArgsForCall)]
+	fake.nowArgsForCall = append(fake.nowArgsForCall, struct {
+	}{})
+	fake.recordInvocation("Now", []interface{}{})
+	fake.nowMutex.Unlock()
+	if fake.NowStub != nil {
+		return fake.NowStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.nowReturns
+	return fakeReturns.result1
+}
+
+func (fake *Clock) NowCallCount() int {
+	fake.nowMutex.RLock()
+	defer fake.nowMutex.RUnlock()
+	return len(fake.nowArgsForCall)
+}
+
+func (fake *Clock) NowCalls(stub func() int64) {
+	fake.nowMutex.Lock()
+	defer fake.nowMutex.Unlock()
+	fake.NowStub = stub
+}
+
+func (fake *Clock) NowReturns(result1 int64) {
+	fake.nowMutex.Lock()
+	defer fake.nowMutex.Unlock()
+	fake.NowStub = nil
+	fake.nowReturns = struct {
+		result1 int64
+	}{result1}
+}
+
+func (fake *Clock) NowReturnsOnCall(i int, result1 int64) {
+	fake.nowMutex.Lock()
+	defer fake.nowMutex.Unlock()
+	fake.NowStub = nil
+	if fake.nowReturnsOnCall == nil {
+		fake.nowReturnsOnCall = make(map[int]struct {
+			result1 int64
+		})
+	}
+	fake.nowReturnsOnCall[i] = struct {
+		result1 int64
+	}{result1}
+}
+
+func (fake *Clock) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.nowMutex.RLock()
+	defer fake.nowMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *Clock) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ lifecycle.Clock = new(Clock)
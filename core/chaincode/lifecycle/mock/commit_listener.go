@@ -0,0 +1,79 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package mock
+
+import (
+	"sync"
+
+	"github.com/hyperledger/fabric/core/chaincode/lifecycle"
+)
+
+type CommitListener struct {
+	HandleChaincodeCommittedStub        func(string, string, *lifecycle.ChaincodeDefinition)
+	handleChaincodeCommittedMutex       sync.RWMutex
+	handleChaincodeCommittedArgsForCall []struct {
+		arg1 string
+		arg2 string
+		arg3 *lifecycle.ChaincodeDefinition
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *CommitListener) HandleChaincodeCommitted(arg1 string, arg2 string, arg3 *lifecycle.ChaincodeDefinition) {
+	fake.handleChaincodeCommittedMutex.Lock()
+	fake.handleChaincodeCommittedArgsForCall = append(fake.handleChaincodeCommittedArgsForCall, struct {
+		arg1 string
+		arg2 string
+		arg3 *lifecycle.ChaincodeDefinition
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("HandleChaincodeCommitted", []interface{}{arg1, arg2, arg3})
+	fake.handleChaincodeCommittedMutex.Unlock()
+	if fake.HandleChaincodeCommittedStub != nil {
+		fake.HandleChaincodeCommittedStub(arg1, arg2, arg3)
+	}
+}
+
+func (fake *CommitListener) HandleChaincodeCommittedCallCount() int {
+	fake.handleChaincodeCommittedMutex.RLock()
+	defer fake.handleChaincodeCommittedMutex.RUnlock()
+	return len(fake.handleChaincodeCommittedArgsForCall)
+}
+
+func (fake *CommitListener) HandleChaincodeCommittedCalls(stub func(string, string, *lifecycle.ChaincodeDefinition)) {
+	fake.handleChaincodeCommittedMutex.Lock()
+	defer fake.handleChaincodeCommittedMutex.Unlock()
+	fake.HandleChaincodeCommittedStub = stub
+}
+
+func (fake *CommitListener) HandleChaincodeCommittedArgsForCall(i int) (string, string, *lifecycle.ChaincodeDefinition) {
+	fake.handleChaincodeCommittedMutex.RLock()
+	defer fake.handleChaincodeCommittedMutex.RUnlock()
+	argsForCall := fake.handleChaincodeCommittedArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *CommitListener) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.handleChaincodeCommittedMutex.RLock()
+	defer fake.handleChaincodeCommittedMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *CommitListener) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ lifecycle.CommitListener = new(CommitListener)
@@ -0,0 +1,185 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package mock
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	"github.com/hyperledger/fabric/common/chaincode"
+	"github.com/hyperledger/fabric/common/util"
+	"github.com/hyperledger/fabric/core/chaincode/persistence"
+	"github.com/pkg/errors"
+)
+
+// MemoryChaincodeStore is a ChaincodeStore which holds chaincode install
+// packages in memory rather than on the filesystem.  Unlike the generated
+// ChaincodeStore fake, it computes real package hashes and package IDs, so
+// that installs, queries and loads behave as they would against a real store.
+// The zero value is ready to use.
+type MemoryChaincodeStore struct {
+	mutex    sync.Mutex
+	packages map[string]*memoryChaincodePackage
+}
+
+type memoryChaincodePackage struct {
+	label string
+	hash  []byte
+	bytes []byte
+}
+
+// Save stores the chaincode install package bytes and returns the package ID.
+func (m *MemoryChaincodeStore) Save(label string, ccInstallPkg []byte) (string, error) {
+	return m.SaveWithContext(context.Background(), label, ccInstallPkg)
+}
+
+// SaveWithContext stores the chaincode install package bytes like Save, but
+// fails if the supplied context is done.
+func (m *MemoryChaincodeStore) SaveWithContext(ctx context.Context, label string, ccInstallPkg []byte) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", errors.Wrap(err, "chaincode install package save aborted")
+	}
+
+	return m.save(label, ccInstallPkg), nil
+}
+
+// SaveStream reads a chaincode install package from the supplied reader and
+// stores it, taking the label from the package metadata.  If maxSize is
+// positive, packages larger than maxSize bytes are rejected.
+func (m *MemoryChaincodeStore) SaveStream(r io.Reader, maxSize int64) (string, *persistence.ChaincodePackageMetadata, error) {
+	if maxSize > 0 {
+		r = io.LimitReader(r, maxSize+1)
+	}
+
+	ccInstallPkg, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "error reading chaincode install package")
+	}
+
+	if maxSize > 0 && int64(len(ccInstallPkg)) > maxSize {
+		return "", nil, errors.Errorf("chaincode install package exceeds the maximum size of %d bytes", maxSize)
+	}
+
+	parser := &persistence.ChaincodePackageParser{
+		MetadataProvider: noDBArtifacts{},
+	}
+	pkg, err := parser.Parse(ccInstallPkg)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return m.save(pkg.Metadata.Label, ccInstallPkg), pkg.Metadata, nil
+}
+
+func (m *MemoryChaincodeStore) save(label string, ccInstallPkg []byte) string {
+	hash := util.ComputeSHA256(ccInstallPkg)
+	packageID := fmt.Sprintf("%s:%x", label, hash)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.packages == nil {
+		m.packages = map[string]*memoryChaincodePackage{}
+	}
+
+	if _, ok := m.packages[packageID]; !ok {
+		m.packages[packageID] = &memoryChaincodePackage{
+			label: label,
+			hash:  hash,
+			bytes: append([]byte(nil), ccInstallPkg...),
+		}
+	}
+
+	return packageID
+}
+
+// ListInstalledChaincodes returns the stored chaincodes, ordered by package ID.
+func (m *MemoryChaincodeStore) ListInstalledChaincodes() ([]chaincode.InstalledChaincode, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	installedChaincodes := []chaincode.InstalledChaincode{}
+	for packageID, pkg := range m.packages {
+		installedChaincodes = append(installedChaincodes, chaincode.InstalledChaincode{
+			PackageID: packageID,
+			Label:     pkg.label,
+			Hash:      pkg.hash,
+		})
+	}
+
+	sort.Slice(installedChaincodes, func(i, j int) bool {
+		return installedChaincodes[i].PackageID < installedChaincodes[j].PackageID
+	})
+
+	return installedChaincodes, nil
+}
+
+// Count returns the number of stored chaincodes.
+func (m *MemoryChaincodeStore) Count() (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return len(m.packages), nil
+}
+
+// Load returns the bytes of the stored chaincode install package with the
+// given package ID.
+func (m *MemoryChaincodeStore) Load(packageID string) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	pkg, ok := m.packages[packageID]
+	if !ok {
+		return nil, &persistence.CodePackageNotFoundErr{
+			PackageID: packageID,
+		}
+	}
+
+	return append([]byte(nil), pkg.bytes...), nil
+}
+
+// RetrieveHashByPackageID returns the hash of the stored chaincode install
+// package with the given package ID.
+func (m *MemoryChaincodeStore) RetrieveHashByPackageID(packageID string) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	pkg, ok := m.packages[packageID]
+	if !ok {
+		return nil, &persistence.CodePackageNotFoundErr{
+			PackageID: packageID,
+		}
+	}
+
+	return pkg.hash, nil
+}
+
+// Delete removes the stored chaincode install package with the given
+// package ID, if any.
+func (m *MemoryChaincodeStore) Delete(packageID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.packages, packageID)
+	return nil
+}
+
+// noDBArtifacts is a persistence.MetadataProvider for packages whose DB
+// artifacts are not of interest.
+type noDBArtifacts struct{}
+
+func (noDBArtifacts) GetDBArtifacts(codePackage []byte) ([]byte, error) {
+	return nil, nil
+}
+
+func (noDBArtifacts) GetDBArtifactsFromStream(codePackage io.Reader) ([]byte, error) {
+	return nil, nil
+}
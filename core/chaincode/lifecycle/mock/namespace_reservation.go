@@ -0,0 +1,117 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package mock
+
+import (
+	"sync"
+
+	"github.com/hyperledger/fabric/core/chaincode/lifecycle"
+)
+
+type NamespaceReservation struct {
+	CanDefineStub        func(string, []string) error
+	canDefineMutex       sync.RWMutex
+	canDefineArgsForCall []struct {
+		arg1 string
+		arg2 []string
+	}
+	canDefineReturns struct {
+		result1 error
+	}
+	canDefineReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *NamespaceReservation) CanDefine(arg1 string, arg2 []string) error {
+	var arg2Copy []string
+	if arg2 != nil {
+		arg2Copy = make([]string, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	fake.canDefineMutex.Lock()
+	ret, specificReturn := fake.canDefineReturnsOnCall[len(fake.canDefineArgsForCall)]
+	fake.canDefineArgsForCall = append(fake.canDefineArgsForCall, struct {
+		arg1 string
+		arg2 []string
+	}{arg1, arg2Copy})
+	fake.recordInvocation("CanDefine", []interface{}{arg1, arg2Copy})
+	fake.canDefineMutex.Unlock()
+	if fake.CanDefineStub != nil {
+		return fake.CanDefineStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.canDefineReturns
+	return fakeReturns.result1
+}
+
+func (fake *NamespaceReservation) CanDefineCallCount() int {
+	fake.canDefineMutex.RLock()
+	defer fake.canDefineMutex.RUnlock()
+	return len(fake.canDefineArgsForCall)
+}
+
+func (fake *NamespaceReservation) CanDefineCalls(stub func(string, []string) error) {
+	fake.canDefineMutex.Lock()
+	defer fake.canDefineMutex.Unlock()
+	fake.CanDefineStub = stub
+}
+
+func (fake *NamespaceReservation) CanDefineArgsForCall(i int) (string, []string) {
+	fake.canDefineMutex.RLock()
+	defer fake.canDefineMutex.RUnlock()
+	argsForCall := fake.canDefineArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *NamespaceReservation) CanDefineReturns(result1 error) {
+	fake.canDefineMutex.Lock()
+	defer fake.canDefineMutex.Unlock()
+	fake.CanDefineStub = nil
+	fake.canDefineReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *NamespaceReservation) CanDefineReturnsOnCall(i int, result1 error) {
+	fake.canDefineMutex.Lock()
+	defer fake.canDefineMutex.Unlock()
+	fake.CanDefineStub = nil
+	if fake.canDefineReturnsOnCall == nil {
+		fake.canDefineReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.canDefineReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *NamespaceReservation) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.canDefineMutex.RLock()
+	defer fake.canDefineMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *NamespaceReservation) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ lifecycle.NamespaceReservation = new(NamespaceReservation)
@@ -0,0 +1,111 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package mock
+
+import (
+	"sync"
+
+	"github.com/hyperledger/fabric/core/chaincode/lifecycle"
+	"github.com/hyperledger/fabric/core/chaincode/persistence"
+)
+
+type PackageCapabilityChecker struct {
+	SupportsInitStub        func(*persistence.ChaincodePackageMetadata) bool
+	supportsInitMutex       sync.RWMutex
+	supportsInitArgsForCall []struct {
+		arg1 *persistence.ChaincodePackageMetadata
+	}
+	supportsInitReturns struct {
+		result1 bool
+	}
+	supportsInitReturnsOnCall map[int]struct {
+		result1 bool
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *PackageCapabilityChecker) SupportsInit(arg1 *persistence.ChaincodePackageMetadata) bool {
+	fake.supportsInitMutex.Lock()
+	ret, specificReturn := fake.supportsInitReturnsOnCall[len(fake.supportsInitArgsForCall)]
+	fake.supportsInitArgsForCall = append(fake.supportsInitArgsForCall, struct {
+		arg1 *persistence.ChaincodePackageMetadata
+	}{arg1})
+	fake.recordInvocation("SupportsInit", []interface{}{arg1})
+	fake.supportsInitMutex.Unlock()
+	if fake.SupportsInitStub != nil {
+		return fake.SupportsInitStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.supportsInitReturns
+	return fakeReturns.result1
+}
+
+func (fake *PackageCapabilityChecker) SupportsInitCallCount() int {
+	fake.supportsInitMutex.RLock()
+	defer fake.supportsInitMutex.RUnlock()
+	return len(fake.supportsInitArgsForCall)
+}
+
+func (fake *PackageCapabilityChecker) SupportsInitCalls(stub func(*persistence.ChaincodePackageMetadata) bool) {
+	fake.supportsInitMutex.Lock()
+	defer fake.supportsInitMutex.Unlock()
+	fake.SupportsInitStub = stub
+}
+
+func (fake *PackageCapabilityChecker) SupportsInitArgsForCall(i int) *persistence.ChaincodePackageMetadata {
+	fake.supportsInitMutex.RLock()
+	defer fake.supportsInitMutex.RUnlock()
+	argsForCall := fake.supportsInitArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *PackageCapabilityChecker) SupportsInitReturns(result1 bool) {
+	fake.supportsInitMutex.Lock()
+	defer fake.supportsInitMutex.Unlock()
+	fake.SupportsInitStub = nil
+	fake.supportsInitReturns = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *PackageCapabilityChecker) SupportsInitReturnsOnCall(i int, result1 bool) {
+	fake.supportsInitMutex.Lock()
+	defer fake.supportsInitMutex.Unlock()
+	fake.SupportsInitStub = nil
+	if fake.supportsInitReturnsOnCall == nil {
+		fake.supportsInitReturnsOnCall = make(map[int]struct {
+			result1 bool
+		})
+	}
+	fake.supportsInitReturnsOnCall[i] = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *PackageCapabilityChecker) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.supportsInitMutex.RLock()
+	defer fake.supportsInitMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *PackageCapabilityChecker) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ lifecycle.PackageCapabilityChecker = new(PackageCapabilityChecker)
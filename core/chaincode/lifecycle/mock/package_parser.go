@@ -2,6 +2,7 @@
 package mock
 
 import (
+	"io"
 	"sync"
 
 	"github.com/hyperledger/fabric/core/chaincode/persistence"
@@ -21,6 +22,19 @@ type PackageParser struct {
 		result1 *persistence.ChaincodePackage
 		result2 error
 	}
+	ParseStreamStub        func(io.Reader) (*persistence.ChaincodePackageMetadata, error)
+	parseStreamMutex       sync.RWMutex
+	parseStreamArgsForCall []struct {
+		arg1 io.Reader
+	}
+	parseStreamReturns struct {
+		result1 *persistence.ChaincodePackageMetadata
+		result2 error
+	}
+	parseStreamReturnsOnCall map[int]struct {
+		result1 *persistence.ChaincodePackageMetadata
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -93,11 +107,76 @@ func (fake *PackageParser) ParseReturnsOnCall(i int, result1 *persistence.Chainc
 	}{result1, result2}
 }
 
+func (fake *PackageParser) ParseStream(arg1 io.Reader) (*persistence.ChaincodePackageMetadata, error) {
+	fake.parseStreamMutex.Lock()
+	ret, specificReturn := fake.parseStreamReturnsOnCall[len(fake.parseStreamArgsForCall)]
+	fake.parseStreamArgsForCall = append(fake.parseStreamArgsForCall, struct {
+		arg1 io.Reader
+	}{arg1})
+	fake.recordInvocation("ParseStream", []interface{}{arg1})
+	fake.parseStreamMutex.Unlock()
+	if fake.ParseStreamStub != nil {
+		return fake.ParseStreamStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.parseStreamReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *PackageParser) ParseStreamCallCount() int {
+	fake.parseStreamMutex.RLock()
+	defer fake.parseStreamMutex.RUnlock()
+	return len(fake.parseStreamArgsForCall)
+}
+
+func (fake *PackageParser) ParseStreamCalls(stub func(io.Reader) (*persistence.ChaincodePackageMetadata, error)) {
+	fake.parseStreamMutex.Lock()
+	defer fake.parseStreamMutex.Unlock()
+	fake.ParseStreamStub = stub
+}
+
+func (fake *PackageParser) ParseStreamArgsForCall(i int) io.Reader {
+	fake.parseStreamMutex.RLock()
+	defer fake.parseStreamMutex.RUnlock()
+	argsForCall := fake.parseStreamArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *PackageParser) ParseStreamReturns(result1 *persistence.ChaincodePackageMetadata, result2 error) {
+	fake.parseStreamMutex.Lock()
+	defer fake.parseStreamMutex.Unlock()
+	fake.ParseStreamStub = nil
+	fake.parseStreamReturns = struct {
+		result1 *persistence.ChaincodePackageMetadata
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *PackageParser) ParseStreamReturnsOnCall(i int, result1 *persistence.ChaincodePackageMetadata, result2 error) {
+	fake.parseStreamMutex.Lock()
+	defer fake.parseStreamMutex.Unlock()
+	fake.ParseStreamStub = nil
+	if fake.parseStreamReturnsOnCall == nil {
+		fake.parseStreamReturnsOnCall = make(map[int]struct {
+			result1 *persistence.ChaincodePackageMetadata
+			result2 error
+		})
+	}
+	fake.parseStreamReturnsOnCall[i] = struct {
+		result1 *persistence.ChaincodePackageMetadata
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *PackageParser) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
 	fake.parseMutex.RLock()
 	defer fake.parseMutex.RUnlock()
+	fake.parseStreamMutex.RLock()
+	defer fake.parseStreamMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
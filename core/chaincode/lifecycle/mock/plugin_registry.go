@@ -0,0 +1,183 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package mock
+
+import (
+	"sync"
+
+	"github.com/hyperledger/fabric/core/chaincode/lifecycle"
+)
+
+type PluginRegistry struct {
+	EndorsementPluginExistsStub        func(string) bool
+	endorsementPluginExistsMutex       sync.RWMutex
+	endorsementPluginExistsArgsForCall []struct {
+		arg1 string
+	}
+	endorsementPluginExistsReturns struct {
+		result1 bool
+	}
+	endorsementPluginExistsReturnsOnCall map[int]struct {
+		result1 bool
+	}
+	ValidationPluginExistsStub        func(string) bool
+	validationPluginExistsMutex       sync.RWMutex
+	validationPluginExistsArgsForCall []struct {
+		arg1 string
+	}
+	validationPluginExistsReturns struct {
+		result1 bool
+	}
+	validationPluginExistsReturnsOnCall map[int]struct {
+		result1 bool
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *PluginRegistry) EndorsementPluginExists(arg1 string) bool {
+	fake.endorsementPluginExistsMutex.Lock()
+	ret, specificReturn := fake.endorsementPluginExistsReturnsOnCall[len(fake.endorsementPluginExistsArgsForCall)]
+	fake.endorsementPluginExistsArgsForCall = append(fake.endorsementPluginExistsArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.recordInvocation("EndorsementPluginExists", []interface{}{arg1})
+	fake.endorsementPluginExistsMutex.Unlock()
+	if fake.EndorsementPluginExistsStub != nil {
+		return fake.EndorsementPluginExistsStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.endorsementPluginExistsReturns
+	return fakeReturns.result1
+}
+
+func (fake *PluginRegistry) EndorsementPluginExistsCallCount() int {
+	fake.endorsementPluginExistsMutex.RLock()
+	defer fake.endorsementPluginExistsMutex.RUnlock()
+	return len(fake.endorsementPluginExistsArgsForCall)
+}
+
+func (fake *PluginRegistry) EndorsementPluginExistsCalls(stub func(string) bool) {
+	fake.endorsementPluginExistsMutex.Lock()
+	defer fake.endorsementPluginExistsMutex.Unlock()
+	fake.EndorsementPluginExistsStub = stub
+}
+
+func (fake *PluginRegistry) EndorsementPluginExistsArgsForCall(i int) string {
+	fake.endorsementPluginExistsMutex.RLock()
+	defer fake.endorsementPluginExistsMutex.RUnlock()
+	argsForCall := fake.endorsementPluginExistsArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *PluginRegistry) EndorsementPluginExistsReturns(result1 bool) {
+	fake.endorsementPluginExistsMutex.Lock()
+	defer fake.endorsementPluginExistsMutex.Unlock()
+	fake.EndorsementPluginExistsStub = nil
+	fake.endorsementPluginExistsReturns = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *PluginRegistry) EndorsementPluginExistsReturnsOnCall(i int, result1 bool) {
+	fake.endorsementPluginExistsMutex.Lock()
+	defer fake.endorsementPluginExistsMutex.Unlock()
+	fake.EndorsementPluginExistsStub = nil
+	if fake.endorsementPluginExistsReturnsOnCall == nil {
+		fake.endorsementPluginExistsReturnsOnCall = make(map[int]struct {
+			result1 bool
+		})
+	}
+	fake.endorsementPluginExistsReturnsOnCall[i] = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *PluginRegistry) ValidationPluginExists(arg1 string) bool {
+	fake.validationPluginExistsMutex.Lock()
+	ret, specificReturn := fake.validationPluginExistsReturnsOnCall[len(fake.validationPluginExistsArgsForCall)]
+	fake.validationPluginExistsArgsForCall = append(fake.validationPluginExistsArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.recordInvocation("ValidationPluginExists", []interface{}{arg1})
+	fake.validationPluginExistsMutex.Unlock()
+	if fake.ValidationPluginExistsStub != nil {
+		return fake.ValidationPluginExistsStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.validationPluginExistsReturns
+	return fakeReturns.result1
+}
+
+func (fake *PluginRegistry) ValidationPluginExistsCallCount() int {
+	fake.validationPluginExistsMutex.RLock()
+	defer fake.validationPluginExistsMutex.RUnlock()
+	return len(fake.validationPluginExistsArgsForCall)
+}
+
+func (fake *PluginRegistry) ValidationPluginExistsCalls(stub func(string) bool) {
+	fake.validationPluginExistsMutex.Lock()
+	defer fake.validationPluginExistsMutex.Unlock()
+	fake.ValidationPluginExistsStub = stub
+}
+
+func (fake *PluginRegistry) ValidationPluginExistsArgsForCall(i int) string {
+	fake.validationPluginExistsMutex.RLock()
+	defer fake.validationPluginExistsMutex.RUnlock()
+	argsForCall := fake.validationPluginExistsArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *PluginRegistry) ValidationPluginExistsReturns(result1 bool) {
+	fake.validationPluginExistsMutex.Lock()
+	defer fake.validationPluginExistsMutex.Unlock()
+	fake.ValidationPluginExistsStub = nil
+	fake.validationPluginExistsReturns = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *PluginRegistry) ValidationPluginExistsReturnsOnCall(i int, result1 bool) {
+	fake.validationPluginExistsMutex.Lock()
+	defer fake.validationPluginExistsMutex.Unlock()
+	fake.ValidationPluginExistsStub = nil
+	if fake.validationPluginExistsReturnsOnCall == nil {
+		fake.validationPluginExistsReturnsOnCall = make(map[int]struct {
+			result1 bool
+		})
+	}
+	fake.validationPluginExistsReturnsOnCall[i] = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *PluginRegistry) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.endorsementPluginExistsMutex.RLock()
+	defer fake.endorsementPluginExistsMutex.RUnlock()
+	fake.validationPluginExistsMutex.RLock()
+	defer fake.validationPluginExistsMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *PluginRegistry) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ lifecycle.PluginRegistry = new(PluginRegistry)
@@ -66,6 +66,23 @@ type ReadWritableState struct {
 		result1 map[string][]byte
 		result2 error
 	}
+	GetStateRangeWithPaginationStub        func(string, string, int32) (map[string][]byte, string, error)
+	getStateRangeWithPaginationMutex       sync.RWMutex
+	getStateRangeWithPaginationArgsForCall []struct {
+		arg1 string
+		arg2 string
+		arg3 int32
+	}
+	getStateRangeWithPaginationReturns struct {
+		result1 map[string][]byte
+		result2 string
+		result3 error
+	}
+	getStateRangeWithPaginationReturnsOnCall map[int]struct {
+		result1 map[string][]byte
+		result2 string
+		result3 error
+	}
 	PutStateStub        func(string, []byte) error
 	putStateMutex       sync.RWMutex
 	putStateArgsForCall []struct {
@@ -383,6 +400,74 @@ func (fake *ReadWritableState) GetStateRangeReturnsOnCall(i int, result1 map[str
 	}{result1, result2}
 }
 
+func (fake *ReadWritableState) GetStateRangeWithPagination(arg1 string, arg2 string, arg3 int32) (map[string][]byte, string, error) {
+	fake.getStateRangeWithPaginationMutex.Lock()
+	ret, specificReturn := fake.getStateRangeWithPaginationReturnsOnCall[len(fake.getStateRangeWithPaginationArgsForCall)]
+	fake.getStateRangeWithPaginationArgsForCall = append(fake.getStateRangeWithPaginationArgsForCall, struct {
+		arg1 string
+		arg2 string
+		arg3 int32
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("GetStateRangeWithPagination", []interface{}{arg1, arg2, arg3})
+	fake.getStateRangeWithPaginationMutex.Unlock()
+	if fake.GetStateRangeWithPaginationStub != nil {
+		return fake.GetStateRangeWithPaginationStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	fakeReturns := fake.getStateRangeWithPaginationReturns
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
+}
+
+func (fake *ReadWritableState) GetStateRangeWithPaginationCallCount() int {
+	fake.getStateRangeWithPaginationMutex.RLock()
+	defer fake.getStateRangeWithPaginationMutex.RUnlock()
+	return len(fake.getStateRangeWithPaginationArgsForCall)
+}
+
+func (fake *ReadWritableState) GetStateRangeWithPaginationCalls(stub func(string, string, int32) (map[string][]byte, string, error)) {
+	fake.getStateRangeWithPaginationMutex.Lock()
+	defer fake.getStateRangeWithPaginationMutex.Unlock()
+	fake.GetStateRangeWithPaginationStub = stub
+}
+
+func (fake *ReadWritableState) GetStateRangeWithPaginationArgsForCall(i int) (string, string, int32) {
+	fake.getStateRangeWithPaginationMutex.RLock()
+	defer fake.getStateRangeWithPaginationMutex.RUnlock()
+	argsForCall := fake.getStateRangeWithPaginationArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *ReadWritableState) GetStateRangeWithPaginationReturns(result1 map[string][]byte, result2 string, result3 error) {
+	fake.getStateRangeWithPaginationMutex.Lock()
+	defer fake.getStateRangeWithPaginationMutex.Unlock()
+	fake.GetStateRangeWithPaginationStub = nil
+	fake.getStateRangeWithPaginationReturns = struct {
+		result1 map[string][]byte
+		result2 string
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *ReadWritableState) GetStateRangeWithPaginationReturnsOnCall(i int, result1 map[string][]byte, result2 string, result3 error) {
+	fake.getStateRangeWithPaginationMutex.Lock()
+	defer fake.getStateRangeWithPaginationMutex.Unlock()
+	fake.GetStateRangeWithPaginationStub = nil
+	if fake.getStateRangeWithPaginationReturnsOnCall == nil {
+		fake.getStateRangeWithPaginationReturnsOnCall = make(map[int]struct {
+			result1 map[string][]byte
+			result2 string
+			result3 error
+		})
+	}
+	fake.getStateRangeWithPaginationReturnsOnCall[i] = struct {
+		result1 map[string][]byte
+		result2 string
+		result3 error
+	}{result1, result2, result3}
+}
+
 func (fake *ReadWritableState) PutState(arg1 string, arg2 []byte) error {
 	var arg2Copy []byte
 	if arg2 != nil {
@@ -462,6 +547,8 @@ func (fake *ReadWritableState) Invocations() map[string][][]interface{} {
 	defer fake.getStateHashMutex.RUnlock()
 	fake.getStateRangeMutex.RLock()
 	defer fake.getStateRangeMutex.RUnlock()
+	fake.getStateRangeWithPaginationMutex.RLock()
+	defer fake.getStateRangeWithPaginationMutex.RUnlock()
 	fake.putStateMutex.RLock()
 	defer fake.putStateMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
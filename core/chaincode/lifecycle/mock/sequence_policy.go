@@ -0,0 +1,112 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package mock
+
+import (
+	"sync"
+
+	"github.com/hyperledger/fabric/core/chaincode/lifecycle"
+)
+
+type SequencePolicy struct {
+	ValidateApprovalSequenceStub        func(int64, int64) error
+	validateApprovalSequenceMutex       sync.RWMutex
+	validateApprovalSequenceArgsForCall []struct {
+		arg1 int64
+		arg2 int64
+	}
+	validateApprovalSequenceReturns struct {
+		result1 error
+	}
+	validateApprovalSequenceReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *SequencePolicy) ValidateApprovalSequence(arg1 int64, arg2 int64) error {
+	fake.validateApprovalSequenceMutex.Lock()
+	ret, specificReturn := fake.validateApprovalSequenceReturnsOnCall[len(fake.validateApprovalSequenceArgsForCall)]
+	fake.validateApprovalSequenceArgsForCall = append(fake.validateApprovalSequenceArgsForCall, struct {
+		arg1 int64
+		arg2 int64
+	}{arg1, arg2})
+	fake.recordInvocation("ValidateApprovalSequence", []interface{}{arg1, arg2})
+	fake.validateApprovalSequenceMutex.Unlock()
+	if fake.ValidateApprovalSequenceStub != nil {
+		return fake.ValidateApprovalSequenceStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.validateApprovalSequenceReturns
+	return fakeReturns.result1
+}
+
+func (fake *SequencePolicy) ValidateApprovalSequenceCallCount() int {
+	fake.validateApprovalSequenceMutex.RLock()
+	defer fake.validateApprovalSequenceMutex.RUnlock()
+	return len(fake.validateApprovalSequenceArgsForCall)
+}
+
+func (fake *SequencePolicy) ValidateApprovalSequenceCalls(stub func(int64, int64) error) {
+	fake.validateApprovalSequenceMutex.Lock()
+	defer fake.validateApprovalSequenceMutex.Unlock()
+	fake.ValidateApprovalSequenceStub = stub
+}
+
+func (fake *SequencePolicy) ValidateApprovalSequenceArgsForCall(i int) (int64, int64) {
+	fake.validateApprovalSequenceMutex.RLock()
+	defer fake.validateApprovalSequenceMutex.RUnlock()
+	argsForCall := fake.validateApprovalSequenceArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *SequencePolicy) ValidateApprovalSequenceReturns(result1 error) {
+	fake.validateApprovalSequenceMutex.Lock()
+	defer fake.validateApprovalSequenceMutex.Unlock()
+	fake.ValidateApprovalSequenceStub = nil
+	fake.validateApprovalSequenceReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *SequencePolicy) ValidateApprovalSequenceReturnsOnCall(i int, result1 error) {
+	fake.validateApprovalSequenceMutex.Lock()
+	defer fake.validateApprovalSequenceMutex.Unlock()
+	fake.ValidateApprovalSequenceStub = nil
+	if fake.validateApprovalSequenceReturnsOnCall == nil {
+		fake.validateApprovalSequenceReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.validateApprovalSequenceReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *SequencePolicy) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.validateApprovalSequenceMutex.RLock()
+	defer fake.validateApprovalSequenceMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *SequencePolicy) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ lifecycle.SequencePolicy = new(SequencePolicy)
@@ -0,0 +1,111 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lifecycle
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/hyperledger/fabric/core/chaincode/persistence"
+	"github.com/pkg/errors"
+)
+
+// GzipMagic is the header of the gzipped tar install packages which are
+// parsed by the persistence.ChaincodePackageParser.
+var GzipMagic = []byte{0x1f, 0x8b}
+
+// PackageParserRegistry is a PackageParser which dispatches each install
+// package to the parser registered for its format, as identified by the
+// magic header at the start of the package.
+type PackageParserRegistry struct {
+	mutex   sync.RWMutex
+	parsers map[string]PackageParser
+}
+
+// NewPackageParserRegistry returns a registry which parses gzipped tar
+// install packages with the supplied parser.
+func NewPackageParserRegistry(tarParser PackageParser) *PackageParserRegistry {
+	ppr := &PackageParserRegistry{
+		parsers: map[string]PackageParser{},
+	}
+	ppr.Register(GzipMagic, tarParser)
+	return ppr
+}
+
+// Register associates the parser with install packages beginning with the
+// supplied magic header, replacing any parser registered for that header.
+func (ppr *PackageParserRegistry) Register(magic []byte, parser PackageParser) {
+	ppr.mutex.Lock()
+	defer ppr.mutex.Unlock()
+	ppr.parsers[string(magic)] = parser
+}
+
+// Parse parses the install package with the parser registered for the
+// longest magic header which the package begins with.
+func (ppr *PackageParserRegistry) Parse(data []byte) (*persistence.ChaincodePackage, error) {
+	parser, err := ppr.parserFor(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return parser.Parse(data)
+}
+
+// ParseStream parses the install package read from the supplied reader
+// with the parser registered for the longest magic header which the
+// package begins with.  Only the magic header is read ahead of the parser.
+func (ppr *PackageParserRegistry) ParseStream(r io.Reader) (*persistence.ChaincodePackageMetadata, error) {
+	ppr.mutex.RLock()
+	var longest int
+	for magic := range ppr.parsers {
+		if len(magic) > longest {
+			longest = len(magic)
+		}
+	}
+	ppr.mutex.RUnlock()
+
+	br := bufio.NewReaderSize(r, longest)
+	header, err := br.Peek(longest)
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "could not read chaincode package header")
+	}
+
+	parser, err := ppr.parserFor(header)
+	if err != nil {
+		return nil, err
+	}
+
+	return parser.ParseStream(br)
+}
+
+// parserFor returns the parser registered for the longest magic header
+// which the package beginning with data begins with.
+func (ppr *PackageParserRegistry) parserFor(data []byte) (PackageParser, error) {
+	ppr.mutex.RLock()
+	defer ppr.mutex.RUnlock()
+
+	var parser PackageParser
+	var matched int
+	for magic, candidate := range ppr.parsers {
+		if len(magic) > matched && bytes.HasPrefix(data, []byte(magic)) {
+			parser = candidate
+			matched = len(magic)
+		}
+	}
+
+	if parser == nil {
+		header := data
+		if len(header) > 4 {
+			header = header[:4]
+		}
+		return nil, errors.Errorf("unknown chaincode package format with header '%x'", header)
+	}
+
+	return parser, nil
+}
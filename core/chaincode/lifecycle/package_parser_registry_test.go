@@ -0,0 +1,121 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lifecycle_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/hyperledger/fabric/core/chaincode/lifecycle"
+	"github.com/hyperledger/fabric/core/chaincode/lifecycle/mock"
+	"github.com/hyperledger/fabric/core/chaincode/persistence"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PackageParserRegistry", func() {
+	var (
+		fakeTarParser   *mock.PackageParser
+		fakeOtherParser *mock.PackageParser
+		ppr             *lifecycle.PackageParserRegistry
+	)
+
+	BeforeEach(func() {
+		fakeTarParser = &mock.PackageParser{}
+		fakeTarParser.ParseReturns(&persistence.ChaincodePackage{
+			Metadata: &persistence.ChaincodePackageMetadata{Label: "tar-package"},
+		}, nil)
+
+		fakeOtherParser = &mock.PackageParser{}
+		fakeOtherParser.ParseReturns(&persistence.ChaincodePackage{
+			Metadata: &persistence.ChaincodePackageMetadata{Label: "other-package"},
+		}, nil)
+
+		ppr = lifecycle.NewPackageParserRegistry(fakeTarParser)
+		ppr.Register([]byte("OTHR"), fakeOtherParser)
+	})
+
+	It("parses gzipped tar packages with the tar parser", func() {
+		pkg, err := ppr.Parse([]byte("\x1f\x8bpackage"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pkg.Metadata.Label).To(Equal("tar-package"))
+		Expect(fakeTarParser.ParseCallCount()).To(Equal(1))
+		Expect(fakeTarParser.ParseArgsForCall(0)).To(Equal([]byte("\x1f\x8bpackage")))
+		Expect(fakeOtherParser.ParseCallCount()).To(Equal(0))
+	})
+
+	It("dispatches other formats by their magic header", func() {
+		pkg, err := ppr.Parse([]byte("OTHRpackage"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pkg.Metadata.Label).To(Equal("other-package"))
+		Expect(fakeTarParser.ParseCallCount()).To(Equal(0))
+	})
+
+	Context("when a longer magic header also matches", func() {
+		var fakeLongerParser *mock.PackageParser
+
+		BeforeEach(func() {
+			fakeLongerParser = &mock.PackageParser{}
+			ppr.Register([]byte("OTHR2"), fakeLongerParser)
+		})
+
+		It("uses the parser for the longest match", func() {
+			ppr.Parse([]byte("OTHR2package"))
+			Expect(fakeLongerParser.ParseCallCount()).To(Equal(1))
+			Expect(fakeOtherParser.ParseCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the parser fails", func() {
+		BeforeEach(func() {
+			fakeOtherParser.ParseReturns(nil, fmt.Errorf("parse-error"))
+		})
+
+		It("returns the error", func() {
+			_, err := ppr.Parse([]byte("OTHRpackage"))
+			Expect(err).To(MatchError("parse-error"))
+		})
+	})
+
+	Context("when the format is unknown", func() {
+		It("returns an error", func() {
+			_, err := ppr.Parse([]byte("unknown-package"))
+			Expect(err).To(MatchError("unknown chaincode package format with header '756e6b6e'"))
+		})
+	})
+
+	Describe("ParseStream", func() {
+		BeforeEach(func() {
+			fakeTarParser.ParseStreamReturns(&persistence.ChaincodePackageMetadata{Label: "tar-package"}, nil)
+			fakeOtherParser.ParseStreamReturns(&persistence.ChaincodePackageMetadata{Label: "other-package"}, nil)
+		})
+
+		It("streams the whole package to the parser for its magic header", func() {
+			md, err := ppr.ParseStream(bytes.NewReader([]byte("OTHRpackage")))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(md.Label).To(Equal("other-package"))
+			Expect(fakeTarParser.ParseStreamCallCount()).To(Equal(0))
+			Expect(fakeOtherParser.ParseStreamCallCount()).To(Equal(1))
+			Expect(ioutil.ReadAll(fakeOtherParser.ParseStreamArgsForCall(0))).To(Equal([]byte("OTHRpackage")))
+		})
+
+		It("parses gzipped tar packages with the tar parser", func() {
+			md, err := ppr.ParseStream(bytes.NewReader([]byte("\x1f\x8bpackage")))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(md.Label).To(Equal("tar-package"))
+		})
+
+		Context("when the format is unknown", func() {
+			It("returns an error", func() {
+				_, err := ppr.ParseStream(bytes.NewReader([]byte("unk")))
+				Expect(err).To(MatchError("unknown chaincode package format with header '756e6b'"))
+			})
+		})
+	})
+})
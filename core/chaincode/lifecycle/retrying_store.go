@@ -0,0 +1,207 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hyperledger/fabric/common/chaincode"
+	"github.com/hyperledger/fabric/core/chaincode/persistence"
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxRetryBackoff is the longest delay between the attempts of a
+// RetryingChaincodeStore operation when MaxBackoff is not set.
+const DefaultMaxRetryBackoff = 10 * time.Second
+
+// DefaultRetryTimeout is the longest time spent on all attempts of a
+// RetryingChaincodeStore operation when Timeout is not set.
+const DefaultRetryTimeout = time.Minute
+
+// RetryingChaincodeStore is a ChaincodeStore which wraps another store and
+// retries the operations which save, load, and retrieve the hash of chaincode
+// install packages when they fail with a retryable error.  The delay between
+// attempts starts at Backoff and doubles after each failed attempt, up to
+// MaxBackoff, and all attempts of an operation must complete within Timeout.
+// Callers such as InstallChaincode save while holding the build lock of the
+// package, so Timeout and MaxBackoff also bound how long a retried save keeps
+// other installs of the same package waiting.  The remaining operations are passed through to the wrapped
+// store unchanged; in particular, SaveStream is not retried, as its reader
+// cannot be replayed.
+type RetryingChaincodeStore struct {
+	ChaincodeStore ChaincodeStore
+
+	// MaxAttempts is the number of times an operation is attempted before
+	// its error is returned.  Values less than one are treated as one.
+	MaxAttempts int
+
+	// Backoff is the delay before the first retry.
+	Backoff time.Duration
+
+	// MaxBackoff caps the delay between attempts.  When zero,
+	// DefaultMaxRetryBackoff is used.
+	MaxBackoff time.Duration
+
+	// Timeout bounds the total time spent on all attempts of an operation,
+	// including the delays between them.  The context passed to the
+	// wrapped store expires with it.  When zero, DefaultRetryTimeout is
+	// used.
+	Timeout time.Duration
+
+	// IsRetryable reports whether an operation which failed with an error
+	// having the given cause, as returned by errors.Cause, should be
+	// retried.  When nil, no error is retried, as the wrapped store cannot
+	// tell transient failures apart.  An error reporting that the package
+	// does not exist is never retried.
+	IsRetryable func(error) bool
+
+	// Sleep, when set, is used to wait between attempts instead of a timer.
+	// Can be overridden for test.
+	Sleep func(time.Duration)
+}
+
+// Save saves the chaincode install package, retrying on retryable errors.
+func (r *RetryingChaincodeStore) Save(label string, ccInstallPkg []byte) (string, error) {
+	return r.SaveWithContext(context.Background(), label, ccInstallPkg)
+}
+
+// SaveWithContext saves the chaincode install package like Save, but stops
+// retrying once the supplied context is done.
+func (r *RetryingChaincodeStore) SaveWithContext(ctx context.Context, label string, ccInstallPkg []byte) (string, error) {
+	var packageID string
+	err := r.retry(ctx, "save of chaincode install package", func(ctx context.Context) error {
+		var err error
+		packageID, err = r.ChaincodeStore.SaveWithContext(ctx, label, ccInstallPkg)
+		return err
+	})
+	return packageID, err
+}
+
+// SaveStream saves the chaincode install package read from the reader.  It
+// is not retried.
+func (r *RetryingChaincodeStore) SaveStream(reader io.Reader, maxSize int64) (string, *persistence.ChaincodePackageMetadata, error) {
+	return r.ChaincodeStore.SaveStream(reader, maxSize)
+}
+
+// ListInstalledChaincodes lists the installed chaincodes.  It is not retried.
+func (r *RetryingChaincodeStore) ListInstalledChaincodes() ([]chaincode.InstalledChaincode, error) {
+	return r.ChaincodeStore.ListInstalledChaincodes()
+}
+
+// Count returns the number of installed chaincodes.  It is not retried.
+func (r *RetryingChaincodeStore) Count() (int, error) {
+	return r.ChaincodeStore.Count()
+}
+
+// Load loads the chaincode install package, retrying on retryable errors.
+func (r *RetryingChaincodeStore) Load(packageID string) ([]byte, error) {
+	var ccInstallPkg []byte
+	err := r.retry(context.Background(), fmt.Sprintf("load of chaincode install package '%s'", packageID), func(context.Context) error {
+		var err error
+		ccInstallPkg, err = r.ChaincodeStore.Load(packageID)
+		return err
+	})
+	return ccInstallPkg, err
+}
+
+// RetrieveHashByPackageID retrieves the hash of the chaincode install package,
+// retrying on retryable errors.
+func (r *RetryingChaincodeStore) RetrieveHashByPackageID(packageID string) ([]byte, error) {
+	var hash []byte
+	err := r.retry(context.Background(), fmt.Sprintf("retrieval of hash for chaincode install package '%s'", packageID), func(context.Context) error {
+		var err error
+		hash, err = r.ChaincodeStore.RetrieveHashByPackageID(packageID)
+		return err
+	})
+	return hash, err
+}
+
+// Delete deletes the chaincode install package.  It is not retried.
+func (r *RetryingChaincodeStore) Delete(packageID string) error {
+	return r.ChaincodeStore.Delete(packageID)
+}
+
+// retry invokes the operation until it succeeds, fails with an error which
+// is not retryable, has been attempted MaxAttempts times, or runs out of
+// time.  The operation is passed a context which expires with Timeout.  Errors which
+// are not retryable are returned unwrapped, so that callers may still
+// inspect their type.
+func (r *RetryingChaincodeStore) retry(ctx context.Context, operation string, op func(context.Context) error) error {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultRetryTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	maxAttempts := r.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	maxBackoff := r.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxRetryBackoff
+	}
+
+	backoff := r.Backoff
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := op(ctx)
+		if err == nil || !r.isRetryable(err) {
+			return err
+		}
+
+		if attempt >= maxAttempts {
+			return errors.WithMessagef(err, "%s failed after %d attempt(s)", operation, attempt)
+		}
+
+		logger.Warningf("Attempt %d of %d of %s failed, retrying in %s: %s", attempt, maxAttempts, operation, backoff, err)
+		if err := r.sleep(ctx, backoff); err != nil {
+			return errors.Wrapf(err, "%s aborted after %d attempt(s)", operation, attempt)
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func (r *RetryingChaincodeStore) isRetryable(err error) bool {
+	cause := errors.Cause(err)
+	switch cause.(type) {
+	case persistence.CodePackageNotFoundErr, *persistence.CodePackageNotFoundErr:
+		return false
+	}
+
+	return r.IsRetryable != nil && r.IsRetryable(cause)
+}
+
+// sleep waits for the given duration, returning early with the context's
+// error if the context is done first.
+func (r *RetryingChaincodeStore) sleep(ctx context.Context, d time.Duration) error {
+	if r.Sleep != nil {
+		r.Sleep(d)
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
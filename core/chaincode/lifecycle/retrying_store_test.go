@@ -0,0 +1,222 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lifecycle_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/lifecycle"
+	"github.com/hyperledger/fabric/core/chaincode/lifecycle/mock"
+	"github.com/hyperledger/fabric/core/chaincode/persistence"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+var _ = Describe("RetryingChaincodeStore", func() {
+	var (
+		fakeCCStore *mock.ChaincodeStore
+		sleeps      []time.Duration
+		store       *lifecycle.RetryingChaincodeStore
+	)
+
+	BeforeEach(func() {
+		fakeCCStore = &mock.ChaincodeStore{}
+		sleeps = nil
+		store = &lifecycle.RetryingChaincodeStore{
+			ChaincodeStore: fakeCCStore,
+			MaxAttempts:    4,
+			Backoff:        10 * time.Millisecond,
+			IsRetryable: func(err error) bool {
+				return strings.HasPrefix(err.Error(), "transient-error")
+			},
+			Sleep: func(d time.Duration) {
+				sleeps = append(sleeps, d)
+			},
+		}
+	})
+
+	Describe("Save", func() {
+		BeforeEach(func() {
+			for i := 0; i < 3; i++ {
+				fakeCCStore.SaveWithContextReturnsOnCall(i, "", fmt.Errorf("transient-error-%d", i))
+			}
+			fakeCCStore.SaveWithContextReturnsOnCall(3, "package-id", nil)
+		})
+
+		It("retries transient failures with a doubling backoff", func() {
+			packageID, err := store.Save("label", []byte("cc-package"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(packageID).To(Equal("package-id"))
+			Expect(fakeCCStore.SaveWithContextCallCount()).To(Equal(4))
+			Expect(sleeps).To(Equal([]time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond}))
+
+			for i := 0; i < 4; i++ {
+				_, label, ccInstallPkg := fakeCCStore.SaveWithContextArgsForCall(i)
+				Expect(label).To(Equal("label"))
+				Expect(ccInstallPkg).To(Equal([]byte("cc-package")))
+			}
+		})
+
+		Context("when the failures outlast the attempts", func() {
+			BeforeEach(func() {
+				store.MaxAttempts = 3
+			})
+
+			It("returns the last error", func() {
+				_, err := store.Save("label", []byte("cc-package"))
+				Expect(err).To(MatchError("save of chaincode install package failed after 3 attempt(s): transient-error-2"))
+				Expect(fakeCCStore.SaveWithContextCallCount()).To(Equal(3))
+			})
+		})
+
+		Context("when the backoff reaches its maximum", func() {
+			BeforeEach(func() {
+				store.MaxBackoff = 25 * time.Millisecond
+			})
+
+			It("caps the delay between attempts", func() {
+				_, err := store.Save("label", []byte("cc-package"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(sleeps).To(Equal([]time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 25 * time.Millisecond}))
+			})
+		})
+
+		Context("when the error is wrapped", func() {
+			BeforeEach(func() {
+				fakeCCStore.SaveWithContextReturnsOnCall(0, "", errors.WithMessage(fmt.Errorf("transient-error"), "wrapped"))
+			})
+
+			It("decides whether to retry by its cause", func() {
+				_, err := store.Save("label", []byte("cc-package"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeCCStore.SaveWithContextCallCount()).To(Equal(4))
+			})
+		})
+
+		Context("when no retryable errors are configured", func() {
+			BeforeEach(func() {
+				store.IsRetryable = nil
+			})
+
+			It("returns the error without retrying", func() {
+				_, err := store.Save("label", []byte("cc-package"))
+				Expect(err).To(MatchError("transient-error-0"))
+				Expect(fakeCCStore.SaveWithContextCallCount()).To(Equal(1))
+				Expect(sleeps).To(BeEmpty())
+			})
+		})
+
+		Context("when the error is not retryable", func() {
+			BeforeEach(func() {
+				store.IsRetryable = func(error) bool { return false }
+			})
+
+			It("returns the error without retrying", func() {
+				_, err := store.Save("label", []byte("cc-package"))
+				Expect(err).To(MatchError("transient-error-0"))
+				Expect(fakeCCStore.SaveWithContextCallCount()).To(Equal(1))
+				Expect(sleeps).To(BeEmpty())
+			})
+		})
+
+		It("bounds the attempts with the timeout", func() {
+			store.Timeout = time.Hour
+
+			_, err := store.Save("label", []byte("cc-package"))
+			Expect(err).NotTo(HaveOccurred())
+			ctx, _, _ := fakeCCStore.SaveWithContextArgsForCall(0)
+			deadline, ok := ctx.Deadline()
+			Expect(ok).To(BeTrue())
+			Expect(deadline).To(BeTemporally("~", time.Now().Add(time.Hour), time.Minute))
+		})
+
+		Context("when the timeout expires", func() {
+			BeforeEach(func() {
+				store.Timeout = time.Nanosecond
+			})
+
+			It("stops retrying", func() {
+				_, err := store.Save("label", []byte("cc-package"))
+				Expect(err).To(MatchError("save of chaincode install package aborted after 1 attempt(s): context deadline exceeded"))
+				Expect(fakeCCStore.SaveWithContextCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the context is done", func() {
+			It("stops retrying", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				store.Sleep = func(time.Duration) { cancel() }
+
+				_, err := store.SaveWithContext(ctx, "label", []byte("cc-package"))
+				Expect(err).To(MatchError("save of chaincode install package aborted after 1 attempt(s): context canceled"))
+				Expect(fakeCCStore.SaveWithContextCallCount()).To(Equal(1))
+			})
+		})
+	})
+
+	Describe("Load", func() {
+		It("retries transient failures", func() {
+			fakeCCStore.LoadReturnsOnCall(0, nil, fmt.Errorf("transient-error"))
+			fakeCCStore.LoadReturnsOnCall(1, []byte("cc-package"), nil)
+
+			ccInstallPkg, err := store.Load("package-id")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ccInstallPkg).To(Equal([]byte("cc-package")))
+			Expect(fakeCCStore.LoadCallCount()).To(Equal(2))
+		})
+
+		Context("when the package does not exist", func() {
+			BeforeEach(func() {
+				store.IsRetryable = func(error) bool { return true }
+				fakeCCStore.LoadReturns(nil, &persistence.CodePackageNotFoundErr{PackageID: "package-id"})
+			})
+
+			It("returns the error unwrapped without retrying", func() {
+				_, err := store.Load("package-id")
+				Expect(err).To(Equal(&persistence.CodePackageNotFoundErr{PackageID: "package-id"}))
+				Expect(fakeCCStore.LoadCallCount()).To(Equal(1))
+			})
+		})
+	})
+
+	Describe("RetrieveHashByPackageID", func() {
+		It("retries transient failures", func() {
+			fakeCCStore.RetrieveHashByPackageIDReturnsOnCall(0, nil, fmt.Errorf("transient-error"))
+			fakeCCStore.RetrieveHashByPackageIDReturnsOnCall(1, []byte("hash"), nil)
+
+			hash, err := store.RetrieveHashByPackageID("package-id")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hash).To(Equal([]byte("hash")))
+			Expect(fakeCCStore.RetrieveHashByPackageIDCallCount()).To(Equal(2))
+		})
+
+		Context("when the failures outlast the attempts", func() {
+			BeforeEach(func() {
+				fakeCCStore.RetrieveHashByPackageIDReturns(nil, fmt.Errorf("transient-error"))
+			})
+
+			It("returns the last error", func() {
+				_, err := store.RetrieveHashByPackageID("package-id")
+				Expect(err).To(MatchError("retrieval of hash for chaincode install package 'package-id' failed after 4 attempt(s): transient-error"))
+			})
+		})
+	})
+
+	Describe("ListInstalledChaincodes", func() {
+		It("does not retry", func() {
+			fakeCCStore.ListInstalledChaincodesReturns(nil, fmt.Errorf("list-error"))
+			_, err := store.ListInstalledChaincodes()
+			Expect(err).To(MatchError("list-error"))
+			Expect(fakeCCStore.ListInstalledChaincodesCallCount()).To(Equal(1))
+		})
+	})
+})
@@ -653,6 +653,28 @@ var (
 	}
 )
 
+// MaxChaincodeNameLength is the maximum length of a chaincode name.
+const MaxChaincodeNameLength = 255
+
+// ValidateChaincodeName returns an error if the name may not be used for a
+// user defined chaincode, either because it contains characters which would
+// corrupt the lifecycle key layout, is too long, or is reserved.
+func ValidateChaincodeName(name string) error {
+	if name == LifecycleNamespace {
+		return errors.Errorf("chaincode name '%s' is reserved", name)
+	}
+	if !ChaincodeNameRegExp.MatchString(name) {
+		return errors.Errorf("invalid chaincode name '%s'. Names can only consist of alphanumerics, '_', and '-' and can only begin with alphanumerics", name)
+	}
+	if len(name) > MaxChaincodeNameLength {
+		return errors.Errorf("chaincode name '%s' exceeds the maximum length of %d", name, MaxChaincodeNameLength)
+	}
+	if _, ok := systemChaincodeNames[name]; ok {
+		return errors.Errorf("chaincode name '%s' is the name of a system chaincode", name)
+	}
+	return nil
+}
+
 func (i *Invocation) validateInput(name, version string, collections *pb.CollectionConfigPackage) error {
 	if !ChaincodeNameRegExp.MatchString(name) {
 		return errors.Errorf("invalid chaincode name '%s'. Names can only consist of alphanumerics, '_', and '-' and can only begin with alphanumerics", name)
@@ -726,31 +748,11 @@ func extractStaticCollectionConfigs(collConfigPkg *pb.CollectionConfigPackage) (
 }
 
 func validateCollectionConfigs(collConfigs []*pb.StaticCollectionConfig, mspMgr msp.MSPManager) error {
-	if len(collConfigs) == 0 {
-		return nil
+	if err := validateStaticCollectionConfigs(collConfigs); err != nil {
+		return err
 	}
-	collNamesMap := map[string]struct{}{}
-	// Process each collection config from a set of collection configs
+
 	for _, c := range collConfigs {
-		if !collectionNameRegExp.MatchString(c.Name) {
-			return errors.Errorf("invalid collection name '%s'. Names can only consist of alphanumerics, '_', and '-' and cannot begin with '_'",
-				c.Name)
-		}
-		// Ensure that there are no duplicate collection names
-		if _, ok := collNamesMap[c.Name]; ok {
-			return errors.Errorf("collection-name: %s -- found duplicate in collection configuration",
-				c.Name)
-		}
-		collNamesMap[c.Name] = struct{}{}
-		// Validate gossip related parameters present in the collection config
-		if c.MaximumPeerCount < c.RequiredPeerCount {
-			return errors.Errorf("collection-name: %s -- maximum peer count (%d) cannot be less than the required peer count (%d)",
-				c.Name, c.MaximumPeerCount, c.RequiredPeerCount)
-		}
-		if c.RequiredPeerCount < 0 {
-			return errors.Errorf("collection-name: %s -- requiredPeerCount (%d) cannot be less than zero",
-				c.Name, c.RequiredPeerCount)
-		}
 		if err := validateCollectionConfigMemberOrgsPolicy(c, mspMgr); err != nil {
 			return err
 		}
@@ -10,6 +10,7 @@ import (
 	"bytes"
 	"encoding/gob"
 	"fmt"
+	"strings"
 
 	"github.com/hyperledger/fabric/core/ledger"
 
@@ -32,6 +33,28 @@ import (
 	. "github.com/onsi/gomega"
 )
 
+var _ = Describe("ValidateChaincodeName", func() {
+	It("accepts valid names", func() {
+		Expect(lifecycle.ValidateChaincodeName("cc-name_1")).To(Succeed())
+	})
+
+	It("rejects names which would corrupt the key layout", func() {
+		for _, name := range []string{"cc#name", "cc/name", "cc\x00name", "-cc", ""} {
+			Expect(lifecycle.ValidateChaincodeName(name)).To(MatchError(ContainSubstring("invalid chaincode name")))
+		}
+	})
+
+	It("rejects names which are too long", func() {
+		name := strings.Repeat("a", 256)
+		Expect(lifecycle.ValidateChaincodeName(name)).To(MatchError(fmt.Sprintf("chaincode name '%s' exceeds the maximum length of 255", name)))
+	})
+
+	It("rejects reserved names", func() {
+		Expect(lifecycle.ValidateChaincodeName("_lifecycle")).To(MatchError("chaincode name '_lifecycle' is reserved"))
+		Expect(lifecycle.ValidateChaincodeName("lscc")).To(MatchError("chaincode name 'lscc' is the name of a system chaincode"))
+	})
+})
+
 var _ = Describe("SCC", func() {
 	var (
 		scc                        *lifecycle.SCC
@@ -749,6 +772,22 @@ var _ = Describe("SCC", func() {
 				})
 			})
 
+			Context("when the collection endorsement policy references an org which is not a member", func() {
+				BeforeEach(func() {
+					collConfigs[0].EndorsementPolicy = &pb.ApplicationPolicy{
+						Type: &pb.ApplicationPolicy_SignaturePolicy{
+							SignaturePolicy: policydsl.SignedByMspMember("other-org"),
+						},
+					}
+				})
+
+				It("wraps and returns error", func() {
+					res := scc.Invoke(fakeStub)
+					Expect(res.Status).To(Equal(int32(500)))
+					Expect(res.Message).To(Equal("failed to invoke backing implementation of 'ApproveChaincodeDefinitionForMyOrg': error validating chaincode definition: collection-name: test-collection -- endorsement policy references org other-org which is not a member of the collection"))
+				})
+			})
+
 			Context("when committed definition and proposed definition both contains no collection config", func() {
 				BeforeEach(func() {
 					fakeDeployedCCInfoProvider.ChaincodeInfoReturns(&ledger.DeployedChaincodeInfo{}, nil)
@@ -1691,6 +1730,7 @@ type collectionConfig struct {
 	Identities              []*mspprotos.MSPPrincipal
 	UseGivenMemberOrgPolicy bool
 	MemberOrgPolicy         *pb.CollectionPolicyConfig
+	EndorsementPolicy       *pb.ApplicationPolicy
 }
 
 func (cc *collectionConfig) toCollectionConfigProto() *pb.CollectionConfig {
@@ -1713,6 +1753,7 @@ func (cc *collectionConfig) toCollectionConfigProto() *pb.CollectionConfig {
 				RequiredPeerCount: cc.RequiredPeerCount,
 				BlockToLive:       cc.BlockToLive,
 				MemberOrgsPolicy:  memberOrgPolicy,
+				EndorsementPolicy: cc.EndorsementPolicy,
 			},
 		},
 	}
@@ -40,6 +40,7 @@ type OpaqueState interface {
 
 type RangeableState interface {
 	GetStateRange(prefix string) (map[string][]byte, error)
+	GetStateRangeWithPagination(prefix, bookmark string, pageSize int32) (map[string][]byte, string, error)
 }
 
 type Marshaler func(proto.Message) ([]byte, error)
@@ -194,6 +195,71 @@ func (s *Serializer) Serialize(namespace, name string, structure interface{}, st
 	return nil
 }
 
+// SerializeField writes the given proto message as the bytes value of a single
+// auxiliary field of an already serialized structure, such as the annotations
+// of a chaincode definition, leaving the metadata and the structure's fields
+// untouched.  Fields recorded in the metadata may not be written this way, as
+// doing so would alter the structure without re-serializing it.  If the field
+// is already stored with a kind other than bytes, it is not overwritten.  As
+// with Serialize, if the stored value is unchanged, the key is _not_ written to.
+func (s *Serializer) SerializeField(namespace, name, field string, value proto.Message, state ReadWritableState) error {
+	metadata, ok, err := s.DeserializeMetadata(namespace, name, state)
+	if err != nil {
+		return errors.WithMessagef(err, "could not deserialize metadata for namespace %s/%s", namespace, name)
+	}
+	if !ok {
+		return errors.Errorf("no structure is serialized for namespace %s/%s", namespace, name)
+	}
+
+	for _, existingField := range metadata.Fields {
+		if existingField == field {
+			return errors.Errorf("field %s is part of the structure for namespace %s/%s and may not be written alone", field, namespace, name)
+		}
+	}
+
+	keyName := FieldKey(namespace, name, field)
+	existingValue, err := state.GetState(keyName)
+	if err != nil {
+		return errors.WithMessagef(err, "could not get value for key %s", keyName)
+	}
+
+	if len(existingValue) > 0 {
+		existingStateData := &lb.StateData{}
+		if err := proto.Unmarshal(existingValue, existingStateData); err != nil {
+			return errors.Wrapf(err, "could not unmarshal state for key %s", keyName)
+		}
+		if _, ok := existingStateData.Type.(*lb.StateData_Bytes); !ok {
+			return errors.Errorf("field %s for namespace %s/%s is stored as %T, not bytes", field, namespace, name, existingStateData.Type)
+		}
+	}
+
+	var bin []byte
+	if value != nil && !reflect.ValueOf(value).IsNil() {
+		bin, err = s.Marshaler.Marshal(value)
+		if err != nil {
+			return errors.Wrapf(err, "could not marshal field %s", field)
+		}
+	}
+
+	marshaledFieldValue, err := s.Marshaler.Marshal(&lb.StateData{
+		Type: &lb.StateData_Bytes{Bytes: bin},
+	})
+	if err != nil {
+		return errors.WithMessagef(err, "could not marshal value for key %s", keyName)
+	}
+
+	if bytes.Equal(existingValue, marshaledFieldValue) {
+		return nil
+	}
+
+	err = state.PutState(keyName, marshaledFieldValue)
+	if err != nil {
+		return errors.WithMessage(err, "could not write key into state")
+	}
+
+	return nil
+}
+
 func (s *Serializer) IsMetadataSerialized(namespace, name string, structure interface{}, state OpaqueState) (bool, error) {
 	value, allFields, err := s.SerializableChecks(structure)
 	if err != nil {
@@ -222,9 +288,22 @@ func (s *Serializer) IsMetadataSerialized(namespace, name string, structure inte
 // IsSerialized essentially checks if the hashes of a serialized version of a structure matches the hashes
 // of the pre-image of some struct serialized into the database.
 func (s *Serializer) IsSerialized(namespace, name string, structure interface{}, state OpaqueState) (bool, error) {
+	metadataMatches, unmatchedFields, err := s.UnmatchedFields(namespace, name, structure, state)
+	if err != nil {
+		return false, err
+	}
+
+	return metadataMatches && len(unmatchedFields) == 0, nil
+}
+
+// UnmatchedFields compares the hashes of a serialized version of a structure to the hashes of the
+// pre-image of some struct serialized into the database like IsSerialized, but rather than stopping
+// at the first mismatch, it reports whether the metadata matches and the names of every field
+// whose hash does not.  If the metadata does not match, the fields are not compared.
+func (s *Serializer) UnmatchedFields(namespace, name string, structure interface{}, state OpaqueState) (metadataMatches bool, unmatchedFields []string, err error) {
 	value, allFields, err := s.SerializableChecks(structure)
 	if err != nil {
-		return false, errors.WithMessagef(err, "structure for namespace %s/%s is not serializable", namespace, name)
+		return false, nil, errors.WithMessagef(err, "structure for namespace %s/%s is not serializable", namespace, name)
 	}
 
 	fqKeys := make([]string, 0, len(allFields)+1)
@@ -237,7 +316,7 @@ func (s *Serializer) IsSerialized(namespace, name string, structure interface{},
 	for _, fqKey := range fqKeys {
 		value, err := state.GetStateHash(fqKey)
 		if err != nil {
-			return false, errors.WithMessagef(err, "could not get value for key %s", fqKey)
+			return false, nil, errors.WithMessagef(err, "could not get value for key %s", fqKey)
 		}
 		existingKeys[fqKey] = value
 	}
@@ -248,12 +327,12 @@ func (s *Serializer) IsSerialized(namespace, name string, structure interface{},
 	}
 	metadataBin, err := s.Marshaler.Marshal(metadata)
 	if err != nil {
-		return false, errors.WithMessagef(err, "could not marshal metadata for namespace %s/%s", namespace, name)
+		return false, nil, errors.WithMessagef(err, "could not marshal metadata for namespace %s/%s", namespace, name)
 	}
 
 	metadataKeyName := MetadataKey(namespace, name)
 	if !bytes.Equal(util.ComputeSHA256(metadataBin), existingKeys[metadataKeyName]) {
-		return false, nil
+		return false, nil, nil
 	}
 
 	for i := 0; i < value.NumField(); i++ {
@@ -271,11 +350,13 @@ func (s *Serializer) IsSerialized(namespace, name string, structure interface{},
 		case reflect.Slice:
 			stateData.Type = &lb.StateData_Bytes{Bytes: fieldValue.Bytes()}
 		case reflect.Ptr:
+			// A nil message marshals to the same empty bytes as an empty
+			// message, so nil and empty collections match.
 			var bin []byte
 			if !fieldValue.IsNil() {
 				bin, err = s.Marshaler.Marshal(fieldValue.Interface().(proto.Message))
 				if err != nil {
-					return false, errors.Wrapf(err, "could not marshal field %s", fieldName)
+					return false, nil, errors.Wrapf(err, "could not marshal field %s", fieldName)
 				}
 			}
 			stateData.Type = &lb.StateData_Bytes{Bytes: bin}
@@ -284,15 +365,15 @@ func (s *Serializer) IsSerialized(namespace, name string, structure interface{},
 
 		marshaledFieldValue, err := s.Marshaler.Marshal(stateData)
 		if err != nil {
-			return false, errors.WithMessagef(err, "could not marshal value for key %s", keyName)
+			return false, nil, errors.WithMessagef(err, "could not marshal value for key %s", keyName)
 		}
 
 		if existingValue, ok := existingKeys[keyName]; !ok || !bytes.Equal(existingValue, util.ComputeSHA256(marshaledFieldValue)) {
-			return false, nil
+			unmatchedFields = append(unmatchedFields, fieldName)
 		}
 	}
 
-	return true, nil
+	return true, unmatchedFields, nil
 }
 
 // Deserialize accepts a struct (of a type previously serialized) and populates it with the values from the db.
@@ -454,11 +535,30 @@ func (s *Serializer) DeserializeAllMetadata(namespace string, state RangeableSta
 	if err != nil {
 		return nil, errors.WithMessagef(err, "could not get state range for namespace %s", namespace)
 	}
+	return deserializeMetadataRange(prefix, kvs)
+}
+
+// DeserializeMetadataPage returns a page of at most pageSize metadata entries for the
+// namespace, starting at the bookmark, along with the bookmark of the next page.
+func (s *Serializer) DeserializeMetadataPage(namespace string, state RangeableState, bookmark string, pageSize int32) (map[string]*lb.StateMetadata, string, error) {
+	prefix := fmt.Sprintf("%s/%s/", namespace, MetadataInfix)
+	kvs, nextBookmark, err := state.GetStateRangeWithPagination(prefix, bookmark, pageSize)
+	if err != nil {
+		return nil, "", errors.WithMessagef(err, "could not get state range for namespace %s", namespace)
+	}
+	result, err := deserializeMetadataRange(prefix, kvs)
+	if err != nil {
+		return nil, "", err
+	}
+	return result, nextBookmark, nil
+}
+
+func deserializeMetadataRange(prefix string, kvs map[string][]byte) (map[string]*lb.StateMetadata, error) {
 	result := map[string]*lb.StateMetadata{}
 	for key, value := range kvs {
 		name := key[len(prefix):]
 		metadata := &lb.StateMetadata{}
-		err = proto.Unmarshal(value, metadata)
+		err := proto.Unmarshal(value, metadata)
 		if err != nil {
 			return nil, errors.Wrapf(err, "error unmarshaling metadata for key %s", key)
 		}
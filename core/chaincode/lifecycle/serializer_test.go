@@ -13,6 +13,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	"github.com/golang/protobuf/proto"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
 	lb "github.com/hyperledger/fabric-protos-go/peer/lifecycle"
 	"github.com/hyperledger/fabric/common/util"
 	"github.com/hyperledger/fabric/core/chaincode/lifecycle"
@@ -361,6 +362,120 @@ var _ = Describe("Serializer", func() {
 		})
 	})
 
+	Describe("SerializeField", func() {
+		var kvs MapLedgerShim
+
+		BeforeEach(func() {
+			kvs = MapLedgerShim(map[string][]byte{})
+			err := s.Serialize("namespaces", "fake", testStruct, kvs)
+			Expect(err).NotTo(HaveOccurred())
+			fakeState.GetStateStub = kvs.GetState
+			fakeState.PutStateStub = kvs.PutState
+		})
+
+		It("writes only the given field", func() {
+			err := s.SerializeField("namespaces", "fake", "Extra", &lb.InstallChaincodeResult{PackageId: "new-hash"}, fakeState)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeState.PutStateCallCount()).To(Equal(1))
+			key, value := fakeState.PutStateArgsForCall(0)
+			Expect(key).To(Equal("namespaces/fields/fake/Extra"))
+			Expect(value).To(Equal(protoutil.MarshalOrPanic(&lb.StateData{
+				Type: &lb.StateData_Bytes{Bytes: protoutil.MarshalOrPanic(&lb.InstallChaincodeResult{PackageId: "new-hash"})},
+			})))
+			Expect(fakeState.DelStateCallCount()).To(Equal(0))
+
+			extra := &lb.InstallChaincodeResult{}
+			err = s.DeserializeFieldAsProto("namespaces", "fake", "Extra", kvs, extra)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(proto.Equal(extra, &lb.InstallChaincodeResult{PackageId: "new-hash"})).To(BeTrue())
+
+			deserialized := &TestStruct{}
+			err = s.Deserialize("namespaces", "fake", &lb.StateMetadata{
+				Datatype: "TestStruct",
+				Fields:   []string{"Int", "Bytes", "Proto", "String"},
+			}, deserialized, kvs)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deserialized.Int).To(Equal(int64(-3)))
+			Expect(proto.Equal(deserialized.Proto, testStruct.Proto)).To(BeTrue())
+		})
+
+		Context("when the value is unchanged", func() {
+			BeforeEach(func() {
+				err := s.SerializeField("namespaces", "fake", "Extra", &lb.InstallChaincodeResult{PackageId: "new-hash"}, kvs)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("does not perform writes", func() {
+				err := s.SerializeField("namespaces", "fake", "Extra", &lb.InstallChaincodeResult{PackageId: "new-hash"}, fakeState)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeState.PutStateCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the structure is not serialized", func() {
+			It("fails", func() {
+				err := s.SerializeField("namespaces", "missing", "Extra", testStruct.Proto, fakeState)
+				Expect(err).To(MatchError("no structure is serialized for namespace namespaces/missing"))
+			})
+		})
+
+		Context("when the field is part of the structure", func() {
+			It("fails without writing", func() {
+				err := s.SerializeField("namespaces", "fake", "Proto", testStruct.Proto, fakeState)
+				Expect(err).To(MatchError("field Proto is part of the structure for namespace namespaces/fake and may not be written alone"))
+				Expect(fakeState.PutStateCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the field is stored with a different kind", func() {
+			BeforeEach(func() {
+				kvs["namespaces/fields/fake/Extra"] = protoutil.MarshalOrPanic(&lb.StateData{
+					Type: &lb.StateData_Int64{Int64: 7},
+				})
+			})
+
+			It("fails without writing", func() {
+				err := s.SerializeField("namespaces", "fake", "Extra", testStruct.Proto, fakeState)
+				Expect(err).To(MatchError("field Extra for namespace namespaces/fake is stored as *lifecycle.StateData_Int64, not bytes"))
+				Expect(fakeState.PutStateCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the existing field value is corrupt", func() {
+			BeforeEach(func() {
+				kvs["namespaces/fields/fake/Extra"] = []byte("garbage")
+			})
+
+			It("wraps and returns the error", func() {
+				err := s.SerializeField("namespaces", "fake", "Extra", testStruct.Proto, fakeState)
+				Expect(err).To(MatchError(ContainSubstring("could not unmarshal state for key namespaces/fields/fake/Extra")))
+			})
+		})
+
+		Context("when the state metadata cannot be retrieved", func() {
+			BeforeEach(func() {
+				fakeState.GetStateReturns(nil, fmt.Errorf("state-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				err := s.SerializeField("namespaces", "fake", "Extra", testStruct.Proto, fakeState)
+				Expect(err).To(MatchError("could not deserialize metadata for namespace namespaces/fake: could not query metadata for namespace namespaces/fake: state-error"))
+			})
+		})
+
+		Context("when writing to the state fails", func() {
+			BeforeEach(func() {
+				fakeState.PutStateReturns(fmt.Errorf("put-error"))
+			})
+
+			It("wraps and returns the error", func() {
+				err := s.SerializeField("namespaces", "fake", "Extra", &lb.InstallChaincodeResult{}, fakeState)
+				Expect(err).To(MatchError("could not write key into state: put-error"))
+			})
+		})
+	})
+
 	Describe("Deserialize", func() {
 		var (
 			kvs      map[string][]byte
@@ -1076,4 +1191,41 @@ var _ = Describe("Serializer", func() {
 			})
 		})
 	})
+
+	Describe("empty collections", func() {
+		var (
+			kvs       MapLedgerShim
+			fakeState *mock.ReadWritableState
+		)
+
+		BeforeEach(func() {
+			kvs = MapLedgerShim(map[string][]byte{})
+			fakeState = &mock.ReadWritableState{}
+			fakeState.GetStateStub = kvs.GetState
+			fakeState.GetStateHashStub = kvs.GetStateHash
+			fakeState.PutStateStub = kvs.PutState
+		})
+
+		It("matches nil collections against an empty collection config package", func() {
+			err := s.Serialize("namespaces", "cc-name#2", &lifecycle.ChaincodeParameters{}, fakeState)
+			Expect(err).NotTo(HaveOccurred())
+
+			matched, err := s.IsSerialized("namespaces", "cc-name#2", &lifecycle.ChaincodeParameters{
+				Collections: &pb.CollectionConfigPackage{},
+			}, fakeState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(matched).To(BeTrue())
+		})
+
+		It("matches an empty collection config package against nil collections", func() {
+			err := s.Serialize("namespaces", "cc-name#2", &lifecycle.ChaincodeParameters{
+				Collections: &pb.CollectionConfigPackage{},
+			}, fakeState)
+			Expect(err).NotTo(HaveOccurred())
+
+			matched, err := s.IsSerialized("namespaces", "cc-name#2", &lifecycle.ChaincodeParameters{}, fakeState)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(matched).To(BeTrue())
+		})
+	})
 })
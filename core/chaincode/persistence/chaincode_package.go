@@ -236,6 +236,7 @@ type ChaincodePackageMetadata struct {
 // information (for instance the DB indexes) from a code package.
 type MetadataProvider interface {
 	GetDBArtifacts(codePackage []byte) ([]byte, error)
+	GetDBArtifactsFromStream(codePackage io.Reader) ([]byte, error)
 }
 
 // ChaincodePackageParser provides the ability to parse chaincode packages.
@@ -327,3 +328,70 @@ func (ccpp ChaincodePackageParser) Parse(source []byte) (*ChaincodePackage, erro
 		DBArtifacts: dbArtifacts,
 	}, nil
 }
+
+// ParseStream parses a chaincode package read from the supplied reader
+// without holding its code package in memory. It performs the same checks
+// as Parse, streaming the code package to the MetadataProvider to retrieve
+// its DB artifacts, and returns the package metadata.
+func (ccpp ChaincodePackageParser) ParseStream(r io.Reader) (*ChaincodePackageMetadata, error) {
+	gzReader, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading as gzip stream")
+	}
+
+	tarReader := tar.NewReader(gzReader)
+
+	var foundCodePackage bool
+	var ccPackageMetadata *ChaincodePackageMetadata
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "error inspecting next tar header")
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			return nil, errors.Errorf("tar entry %s is not a regular file, type %v", header.Name, header.Typeflag)
+		}
+
+		switch header.Name {
+
+		case MetadataFile:
+			fileBytes, err := ioutil.ReadAll(tarReader)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not read %s from tar", header.Name)
+			}
+			ccPackageMetadata = &ChaincodePackageMetadata{}
+			err = json.Unmarshal(fileBytes, ccPackageMetadata)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not unmarshal %s as json", MetadataFile)
+			}
+
+		case CodePackageFile:
+			_, err := ccpp.MetadataProvider.GetDBArtifactsFromStream(tarReader)
+			if err != nil {
+				return nil, errors.WithMessage(err, "error retrieving DB artifacts from code package")
+			}
+			foundCodePackage = true
+		default:
+			logger.Warningf("Encountered unexpected file '%s' in top level of chaincode package", header.Name)
+		}
+	}
+
+	if !foundCodePackage {
+		return nil, errors.Errorf("did not find a code package inside the package")
+	}
+
+	if ccPackageMetadata == nil {
+		return nil, errors.Errorf("did not find any package metadata (missing %s)", MetadataFile)
+	}
+
+	if err := ValidateLabel(ccPackageMetadata.Label); err != nil {
+		return nil, err
+	}
+
+	return ccPackageMetadata, nil
+}
@@ -7,7 +7,9 @@ SPDX-License-Identifier: Apache-2.0
 package persistence_test
 
 import (
+	"bytes"
 	"io/ioutil"
+	"os"
 
 	pb "github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/hyperledger/fabric/core/chaincode/persistence"
@@ -253,6 +255,87 @@ var _ = Describe("ChaincodePackageParser", func() {
 			})
 		})
 	})
+
+	Describe("ParseStream", func() {
+		BeforeEach(func() {
+			mockMetaProvider.On("GetDBArtifactsFromStream", tm.Anything).Return([]byte("DB artefacts"), nil)
+		})
+
+		It("parses a chaincode package stream", func() {
+			f, err := os.Open("testdata/good-package.tar.gz")
+			Expect(err).NotTo(HaveOccurred())
+			defer f.Close()
+
+			metadata, err := ccpp.ParseStream(f)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(metadata).To(Equal(&persistence.ChaincodePackageMetadata{
+				Type:  "Fake-Type",
+				Path:  "Fake-Path",
+				Label: "Real-Label",
+			}))
+			mockMetaProvider.AssertCalled(GinkgoT(), "GetDBArtifactsFromStream", tm.Anything)
+			mockMetaProvider.AssertNotCalled(GinkgoT(), "GetDBArtifacts", tm.Anything)
+		})
+
+		Context("when the data is not gzipped", func() {
+			It("fails", func() {
+				_, err := ccpp.ParseStream(bytes.NewReader([]byte("bad-data")))
+				Expect(err).To(MatchError("error reading as gzip stream: unexpected EOF"))
+			})
+		})
+
+		Context("when the retrieval of the DB metadata fails", func() {
+			BeforeEach(func() {
+				mockMetaProvider = &mock.MetadataProvider{}
+				mockMetaProvider.On("GetDBArtifactsFromStream", tm.Anything).Return(nil, errors.New("not good"))
+
+				ccpp.MetadataProvider = mockMetaProvider
+			})
+
+			It("fails", func() {
+				f, err := os.Open("testdata/good-package.tar.gz")
+				Expect(err).NotTo(HaveOccurred())
+				defer f.Close()
+
+				metadata, err := ccpp.ParseStream(f)
+				Expect(metadata).To(BeNil())
+				Expect(err).To(MatchError("error retrieving DB artifacts from code package: not good"))
+			})
+		})
+
+		Context("when the chaincode package metadata is missing", func() {
+			It("fails", func() {
+				f, err := os.Open("testdata/missing-metadata.tar.gz")
+				Expect(err).NotTo(HaveOccurred())
+				defer f.Close()
+
+				_, err = ccpp.ParseStream(f)
+				Expect(err).To(MatchError("did not find any package metadata (missing metadata.json)"))
+			})
+		})
+
+		Context("when the label contains forbidden characters", func() {
+			It("fails", func() {
+				f, err := os.Open("testdata/bad-label.tar.gz")
+				Expect(err).NotTo(HaveOccurred())
+				defer f.Close()
+
+				_, err = ccpp.ParseStream(f)
+				Expect(err).To(MatchError(ContainSubstring("invalid label 'Bad-Label!'")))
+			})
+		})
+
+		Context("when the tar is missing a code-package", func() {
+			It("fails", func() {
+				f, err := os.Open("testdata/missing-codepackage.tar.gz")
+				Expect(err).NotTo(HaveOccurred())
+				defer f.Close()
+
+				_, err = ccpp.ParseStream(f)
+				Expect(err).To(MatchError("did not find a code package inside the package"))
+			})
+		})
+	})
 })
 
 var _ = Describe("ChaincodePackageLocator", func() {
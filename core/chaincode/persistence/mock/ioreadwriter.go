@@ -2,6 +2,7 @@
 package mock
 
 import (
+	"io"
 	"os"
 	"sync"
 )
@@ -69,6 +70,18 @@ type IOReadWriter struct {
 	removeReturnsOnCall map[int]struct {
 		result1 error
 	}
+	RenameStub        func(string, string) error
+	renameMutex       sync.RWMutex
+	renameArgsForCall []struct {
+		arg1 string
+		arg2 string
+	}
+	renameReturns struct {
+		result1 error
+	}
+	renameReturnsOnCall map[int]struct {
+		result1 error
+	}
 	WriteFileStub        func(string, string, []byte) error
 	writeFileMutex       sync.RWMutex
 	writeFileArgsForCall []struct {
@@ -82,6 +95,21 @@ type IOReadWriter struct {
 	writeFileReturnsOnCall map[int]struct {
 		result1 error
 	}
+	WriteFileStreamStub        func(string, string, io.Reader) (int64, error)
+	writeFileStreamMutex       sync.RWMutex
+	writeFileStreamArgsForCall []struct {
+		arg1 string
+		arg2 string
+		arg3 io.Reader
+	}
+	writeFileStreamReturns struct {
+		result1 int64
+		result2 error
+	}
+	writeFileStreamReturnsOnCall map[int]struct {
+		result1 int64
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -396,6 +424,67 @@ func (fake *IOReadWriter) RemoveReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *IOReadWriter) Rename(arg1 string, arg2 string) error {
+	fake.renameMutex.Lock()
+	ret, specificReturn := fake.renameReturnsOnCall[len(fake.renameArgsForCall)]
+	fake.renameArgsForCall = append(fake.renameArgsForCall, struct {
+		arg1 string
+		arg2 string
+	}{arg1, arg2})
+	fake.recordInvocation("Rename", []interface{}{arg1, arg2})
+	fake.renameMutex.Unlock()
+	if fake.RenameStub != nil {
+		return fake.RenameStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.renameReturns
+	return fakeReturns.result1
+}
+
+func (fake *IOReadWriter) RenameCallCount() int {
+	fake.renameMutex.RLock()
+	defer fake.renameMutex.RUnlock()
+	return len(fake.renameArgsForCall)
+}
+
+func (fake *IOReadWriter) RenameCalls(stub func(string, string) error) {
+	fake.renameMutex.Lock()
+	defer fake.renameMutex.Unlock()
+	fake.RenameStub = stub
+}
+
+func (fake *IOReadWriter) RenameArgsForCall(i int) (string, string) {
+	fake.renameMutex.RLock()
+	defer fake.renameMutex.RUnlock()
+	argsForCall := fake.renameArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *IOReadWriter) RenameReturns(result1 error) {
+	fake.renameMutex.Lock()
+	defer fake.renameMutex.Unlock()
+	fake.RenameStub = nil
+	fake.renameReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *IOReadWriter) RenameReturnsOnCall(i int, result1 error) {
+	fake.renameMutex.Lock()
+	defer fake.renameMutex.Unlock()
+	fake.RenameStub = nil
+	if fake.renameReturnsOnCall == nil {
+		fake.renameReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.renameReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *IOReadWriter) WriteFile(arg1 string, arg2 string, arg3 []byte) error {
 	var arg3Copy []byte
 	if arg3 != nil {
@@ -463,6 +552,71 @@ func (fake *IOReadWriter) WriteFileReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *IOReadWriter) WriteFileStream(arg1 string, arg2 string, arg3 io.Reader) (int64, error) {
+	fake.writeFileStreamMutex.Lock()
+	ret, specificReturn := fake.writeFileStreamReturnsOnCall[len(fake.writeFileStreamArgsForCall)]
+	fake.writeFileStreamArgsForCall = append(fake.writeFileStreamArgsForCall, struct {
+		arg1 string
+		arg2 string
+		arg3 io.Reader
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("WriteFileStream", []interface{}{arg1, arg2, arg3})
+	fake.writeFileStreamMutex.Unlock()
+	if fake.WriteFileStreamStub != nil {
+		return fake.WriteFileStreamStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.writeFileStreamReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *IOReadWriter) WriteFileStreamCallCount() int {
+	fake.writeFileStreamMutex.RLock()
+	defer fake.writeFileStreamMutex.RUnlock()
+	return len(fake.writeFileStreamArgsForCall)
+}
+
+func (fake *IOReadWriter) WriteFileStreamCalls(stub func(string, string, io.Reader) (int64, error)) {
+	fake.writeFileStreamMutex.Lock()
+	defer fake.writeFileStreamMutex.Unlock()
+	fake.WriteFileStreamStub = stub
+}
+
+func (fake *IOReadWriter) WriteFileStreamArgsForCall(i int) (string, string, io.Reader) {
+	fake.writeFileStreamMutex.RLock()
+	defer fake.writeFileStreamMutex.RUnlock()
+	argsForCall := fake.writeFileStreamArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *IOReadWriter) WriteFileStreamReturns(result1 int64, result2 error) {
+	fake.writeFileStreamMutex.Lock()
+	defer fake.writeFileStreamMutex.Unlock()
+	fake.WriteFileStreamStub = nil
+	fake.writeFileStreamReturns = struct {
+		result1 int64
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *IOReadWriter) WriteFileStreamReturnsOnCall(i int, result1 int64, result2 error) {
+	fake.writeFileStreamMutex.Lock()
+	defer fake.writeFileStreamMutex.Unlock()
+	fake.WriteFileStreamStub = nil
+	if fake.writeFileStreamReturnsOnCall == nil {
+		fake.writeFileStreamReturnsOnCall = make(map[int]struct {
+			result1 int64
+			result2 error
+		})
+	}
+	fake.writeFileStreamReturnsOnCall[i] = struct {
+		result1 int64
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *IOReadWriter) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -476,8 +630,12 @@ func (fake *IOReadWriter) Invocations() map[string][][]interface{} {
 	defer fake.readFileMutex.RUnlock()
 	fake.removeMutex.RLock()
 	defer fake.removeMutex.RUnlock()
+	fake.renameMutex.RLock()
+	defer fake.renameMutex.RUnlock()
 	fake.writeFileMutex.RLock()
 	defer fake.writeFileMutex.RUnlock()
+	fake.writeFileStreamMutex.RLock()
+	defer fake.writeFileStreamMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
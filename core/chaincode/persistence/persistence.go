@@ -7,8 +7,11 @@ SPDX-License-Identifier: Apache-2.0
 package persistence
 
 import (
+	"context"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -31,6 +34,8 @@ type IOReadWriter interface {
 	ReadFile(string) ([]byte, error)
 	Remove(name string) error
 	WriteFile(string, string, []byte) error
+	WriteFileStream(string, string, io.Reader) (int64, error)
+	Rename(string, string) error
 	MakeDir(string, os.FileMode) error
 	Exists(path string) (bool, error)
 }
@@ -72,6 +77,41 @@ func (f *FilesystemIO) WriteFile(path, name string, data []byte) error {
 	return nil
 }
 
+// WriteFileStream writes the contents of the reader to a file on the
+// filesystem; like WriteFile it does so atomically through a temp file.
+// It returns the number of bytes written.
+func (f *FilesystemIO) WriteFileStream(path, name string, r io.Reader) (int64, error) {
+	if path == "" {
+		return 0, errors.New("empty path not allowed")
+	}
+	tmpFile, err := ioutil.TempFile(path, ".ccpackage.")
+	if err != nil {
+		return 0, errors.Wrapf(err, "error creating temp file in directory '%s'", path)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	n, err := io.Copy(tmpFile, r)
+	if err != nil {
+		tmpFile.Close()
+		return n, errors.Wrapf(err, "error writing to temp file '%s'", tmpFile.Name())
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return n, errors.Wrapf(err, "error closing temp file '%s'", tmpFile.Name())
+	}
+
+	if err := os.Rename(tmpFile.Name(), filepath.Join(path, name)); err != nil {
+		return n, errors.Wrapf(err, "error renaming temp file '%s'", tmpFile.Name())
+	}
+
+	return n, nil
+}
+
+// Rename renames a file on the filesystem
+func (f *FilesystemIO) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
 // Remove removes a file from the filesystem - used for rolling back an in-flight
 // Save operation upon a failure
 func (f *FilesystemIO) Remove(name string) error {
@@ -145,6 +185,17 @@ func (s *Store) Initialize() {
 // Save persists chaincode install package bytes. It returns
 // the hash of the chaincode install package
 func (s *Store) Save(label string, ccInstallPkg []byte) (string, error) {
+	return s.SaveWithContext(context.Background(), label, ccInstallPkg)
+}
+
+// SaveWithContext persists chaincode install package bytes like Save, but
+// aborts if the supplied context is done. If the context is cancelled while
+// the package is being written, the newly written package is removed again.
+func (s *Store) SaveWithContext(ctx context.Context, label string, ccInstallPkg []byte) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", errors.Wrap(err, "chaincode install package save aborted")
+	}
+
 	hash := util.ComputeSHA256(ccInstallPkg)
 	packageID := packageID(label, hash)
 
@@ -162,9 +213,81 @@ func (s *Store) Save(label string, ccInstallPkg []byte) (string, error) {
 		return "", err
 	}
 
+	if err := ctx.Err(); err != nil {
+		if rmErr := s.ReadWriter.Remove(ccInstallPkgFilePath); rmErr != nil {
+			logger.Errorf("error removing chaincode install package at %s after save was aborted: %s", ccInstallPkgFilePath, rmErr)
+		}
+		return "", errors.Wrap(err, "chaincode install package save aborted")
+	}
+
 	return packageID, nil
 }
 
+// SaveStream persists a chaincode install package read from the supplied
+// reader without holding the entire package in memory. The package is
+// staged in the store directory while its hash is computed, and the package
+// label is then read back from the metadata of the staged package. If
+// maxSize is positive, packages larger than maxSize bytes are rejected. It
+// returns the package ID and the metadata of the chaincode install package.
+func (s *Store) SaveStream(r io.Reader, maxSize int64) (string, *ChaincodePackageMetadata, error) {
+	stagedFileName := ".ccpackage.staged." + util.GenerateUUID()
+	stagedFilePath := filepath.Join(s.Path, stagedFileName)
+
+	if maxSize > 0 {
+		r = io.LimitReader(r, maxSize+1)
+	}
+
+	hasher := sha256.New()
+	n, err := s.ReadWriter.WriteFileStream(s.Path, stagedFileName, io.TeeReader(r, hasher))
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "error staging chaincode install package in %s", s.Path)
+	}
+	defer func() {
+		// the staged package is gone once it has been renamed
+		if exists, _ := s.ReadWriter.Exists(stagedFilePath); exists {
+			if err := s.ReadWriter.Remove(stagedFilePath); err != nil {
+				logger.Errorf("error removing staged chaincode install package at %s: %s", stagedFilePath, err)
+			}
+		}
+	}()
+
+	if maxSize > 0 && n > maxSize {
+		return "", nil, errors.Errorf("chaincode install package exceeds the maximum size of %d bytes", maxSize)
+	}
+
+	streamer := &ChaincodePackageStreamer{PackagePath: stagedFilePath}
+	codeStream, err := streamer.Code()
+	if err != nil {
+		return "", nil, err
+	}
+	codeStream.Close()
+
+	metadata, err := streamer.Metadata()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := ValidateLabel(metadata.Label); err != nil {
+		return "", nil, err
+	}
+
+	packageID := packageID(metadata.Label, hasher.Sum(nil))
+	ccInstallPkgFilePath := filepath.Join(s.Path, CCFileName(packageID))
+
+	if exists, _ := s.ReadWriter.Exists(ccInstallPkgFilePath); exists {
+		// chaincode install package was already installed
+		return packageID, metadata, nil
+	}
+
+	if err := s.ReadWriter.Rename(stagedFilePath, ccInstallPkgFilePath); err != nil {
+		err = errors.Wrapf(err, "error writing chaincode install package to %s", ccInstallPkgFilePath)
+		logger.Error(err.Error())
+		return "", nil, err
+	}
+
+	return packageID, metadata, nil
+}
+
 // Load loads a persisted chaincode install package bytes with
 // the given packageID.
 func (s *Store) Load(packageID string) ([]byte, error) {
@@ -189,6 +312,30 @@ func (s *Store) Load(packageID string) ([]byte, error) {
 	return ccInstallPkg, nil
 }
 
+// RetrieveHashByPackageID returns the hash of the persisted chaincode
+// install package with the given packageID.
+func (s *Store) RetrieveHashByPackageID(packageID string) ([]byte, error) {
+	ccInstallPkgFileName := CCFileName(packageID)
+	ccInstallPkgPath := filepath.Join(s.Path, ccInstallPkgFileName)
+
+	exists, err := s.ReadWriter.Exists(ccInstallPkgPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not determine whether chaincode install package '%s' exists", packageID)
+	}
+	if !exists {
+		return nil, &CodePackageNotFoundErr{
+			PackageID: packageID,
+		}
+	}
+
+	instCC, ok := installedChaincodeFromFilename(ccInstallPkgFileName)
+	if !ok {
+		return nil, errors.Errorf("package ID '%s' does not contain a valid hash", packageID)
+	}
+
+	return instCC.Hash, nil
+}
+
 // Delete deletes a persisted chaincode.  Note, there is no locking,
 // so this should only be performed if the chaincode has already
 // been marked built.
@@ -224,6 +371,24 @@ func (s *Store) ListInstalledChaincodes() ([]chaincode.InstalledChaincode, error
 	return installedChaincodes, nil
 }
 
+// Count returns the number of chaincodes installed in the persistence
+// store.  It counts the same entries as ListInstalledChaincodes, without
+// building the list.
+func (s *Store) Count() (int, error) {
+	files, err := s.ReadWriter.ReadDir(s.Path)
+	if err != nil {
+		return 0, errors.Wrapf(err, "error reading chaincode directory at %s", s.Path)
+	}
+
+	count := 0
+	for _, file := range files {
+		if _, isInstCC := installedChaincodeFromFilename(file.Name()); isInstCC {
+			count++
+		}
+	}
+	return count, nil
+}
+
 // GetChaincodeInstallPath returns the path where chaincodes
 // are installed
 func (s *Store) GetChaincodeInstallPath() string {
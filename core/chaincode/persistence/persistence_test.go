@@ -7,6 +7,8 @@ SPDX-License-Identifier: Apache-2.0
 package persistence_test
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -261,6 +263,138 @@ var _ = Describe("Persistence", func() {
 				Expect(err).To(MatchError(ContainSubstring("error writing chaincode install package to testcc.3fec0187440286d404241e871b44725310b11aaf43d100b053eae712fcabc66d.tar.gz: soccer")))
 			})
 		})
+
+		Context("when the context is cancelled before saving", func() {
+			It("returns an error without writing the package", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				packageID, err := store.SaveWithContext(ctx, "testcc", pkgBytes)
+				Expect(packageID).To(Equal(""))
+				Expect(err).To(MatchError("chaincode install package save aborted: context canceled"))
+				Expect(mockReadWriter.WriteFileCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the context is cancelled while writing the package", func() {
+			var cancel context.CancelFunc
+			var ctx context.Context
+
+			BeforeEach(func() {
+				ctx, cancel = context.WithCancel(context.Background())
+				mockReadWriter.WriteFileStub = func(string, string, []byte) error {
+					cancel()
+					return nil
+				}
+			})
+
+			It("removes the written package and returns an error", func() {
+				packageID, err := store.SaveWithContext(ctx, "testcc", pkgBytes)
+				Expect(packageID).To(Equal(""))
+				Expect(err).To(MatchError("chaincode install package save aborted: context canceled"))
+				Expect(mockReadWriter.RemoveCallCount()).To(Equal(1))
+				Expect(mockReadWriter.RemoveArgsForCall(0)).To(Equal("testcc.3fec0187440286d404241e871b44725310b11aaf43d100b053eae712fcabc66d.tar.gz"))
+			})
+		})
+	})
+
+	Describe("SaveStream", func() {
+		var (
+			tempDir  string
+			store    *persistence.Store
+			pkgBytes []byte
+		)
+
+		BeforeEach(func() {
+			var err error
+			tempDir, err = ioutil.TempDir("", "SaveStream")
+			Expect(err).NotTo(HaveOccurred())
+			store = persistence.NewStore(tempDir)
+
+			pkgBytes, err = ioutil.ReadFile("testdata/good-package.tar.gz")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tempDir)
+		})
+
+		It("saves the package under its package ID", func() {
+			packageID, metadata, err := store.SaveStream(bytes.NewReader(pkgBytes), 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(packageID).To(Equal(fmt.Sprintf("Real-Label:%x", util.ComputeSHA256(pkgBytes))))
+			Expect(metadata.Label).To(Equal("Real-Label"))
+
+			savedBytes, err := store.Load(packageID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(savedBytes).To(Equal(pkgBytes))
+
+			files, err := ioutil.ReadDir(tempDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files).To(HaveLen(1))
+		})
+
+		Context("when the package exceeds the maximum size", func() {
+			It("returns an error without saving the package", func() {
+				_, _, err := store.SaveStream(bytes.NewReader(pkgBytes), int64(len(pkgBytes)-1))
+				Expect(err).To(MatchError(fmt.Sprintf("chaincode install package exceeds the maximum size of %d bytes", len(pkgBytes)-1)))
+
+				files, err := ioutil.ReadDir(tempDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(files).To(BeEmpty())
+			})
+		})
+
+		Context("when the package is missing its code package", func() {
+			BeforeEach(func() {
+				var err error
+				pkgBytes, err = ioutil.ReadFile("testdata/missing-codepackage.tar.gz")
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("returns an error without saving the package", func() {
+				_, _, err := store.SaveStream(bytes.NewReader(pkgBytes), 0)
+				Expect(err).To(MatchError("could not get code package: did not find file 'code.tar.gz' in package"))
+
+				files, err := ioutil.ReadDir(tempDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(files).To(BeEmpty())
+			})
+		})
+
+		Context("when the package label is invalid", func() {
+			BeforeEach(func() {
+				var err error
+				pkgBytes, err = ioutil.ReadFile("testdata/bad-label.tar.gz")
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("returns an error", func() {
+				_, _, err := store.SaveStream(bytes.NewReader(pkgBytes), 0)
+				Expect(err).To(MatchError(ContainSubstring("invalid label")))
+			})
+		})
+
+		Context("when the package cannot be staged", func() {
+			var mockReadWriter *mock.IOReadWriter
+
+			BeforeEach(func() {
+				mockReadWriter = &mock.IOReadWriter{}
+				mockReadWriter.WriteFileStreamReturns(0, errors.New("disk full"))
+				store.ReadWriter = mockReadWriter
+			})
+
+			It("returns an error without renaming the package", func() {
+				_, _, err := store.SaveStream(bytes.NewReader(pkgBytes), 0)
+				Expect(err).To(MatchError(fmt.Sprintf("error staging chaincode install package in %s: disk full", tempDir)))
+
+				Expect(mockReadWriter.WriteFileStreamCallCount()).To(Equal(1))
+				path, name, _ := mockReadWriter.WriteFileStreamArgsForCall(0)
+				Expect(path).To(Equal(tempDir))
+				Expect(name).To(HavePrefix(".ccpackage.staged."))
+				Expect(mockReadWriter.RenameCallCount()).To(Equal(0))
+			})
+		})
 	})
 
 	Describe("Delete", func() {
@@ -356,6 +490,65 @@ var _ = Describe("Persistence", func() {
 		})
 	})
 
+	Describe("RetrieveHashByPackageID", func() {
+		var (
+			mockReadWriter *mock.IOReadWriter
+			store          *persistence.Store
+			hash           []byte
+			packageID      string
+		)
+
+		BeforeEach(func() {
+			mockReadWriter = &mock.IOReadWriter{}
+			mockReadWriter.ExistsReturns(true, nil)
+			store = &persistence.Store{
+				ReadWriter: mockReadWriter,
+				Path:       "foo",
+			}
+			hash = util.ComputeSHA256([]byte("cornerkick"))
+			packageID = fmt.Sprintf("label:%x", hash)
+		})
+
+		It("returns the hash of the installed package", func() {
+			retrievedHash, err := store.RetrieveHashByPackageID(packageID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(retrievedHash).To(Equal(hash))
+
+			Expect(mockReadWriter.ExistsCallCount()).To(Equal(1))
+			Expect(mockReadWriter.ExistsArgsForCall(0)).To(Equal(fmt.Sprintf("foo/label.%x.tar.gz", hash)))
+		})
+
+		Context("when the package isn't there", func() {
+			BeforeEach(func() {
+				mockReadWriter.ExistsReturns(false, nil)
+			})
+
+			It("returns an error", func() {
+				retrievedHash, err := store.RetrieveHashByPackageID(packageID)
+				Expect(err).To(Equal(&persistence.CodePackageNotFoundErr{PackageID: packageID}))
+				Expect(retrievedHash).To(BeNil())
+			})
+		})
+
+		Context("when an IO error occurred during stat", func() {
+			BeforeEach(func() {
+				mockReadWriter.ExistsReturns(false, errors.New("goodness me!"))
+			})
+
+			It("returns an error", func() {
+				_, err := store.RetrieveHashByPackageID(packageID)
+				Expect(err).To(MatchError(fmt.Sprintf("could not determine whether chaincode install package '%s' exists: goodness me!", packageID)))
+			})
+		})
+
+		Context("when the package ID does not contain a hash", func() {
+			It("returns an error", func() {
+				_, err := store.RetrieveHashByPackageID("label:not-a-hash")
+				Expect(err).To(MatchError("package ID 'label:not-a-hash' does not contain a valid hash"))
+			})
+		})
+	})
+
 	Describe("ListInstalledChaincodes", func() {
 		var (
 			mockReadWriter *mock.IOReadWriter
@@ -440,6 +633,49 @@ var _ = Describe("Persistence", func() {
 		})
 	})
 
+	Describe("Count", func() {
+		var (
+			mockReadWriter *mock.IOReadWriter
+			store          *persistence.Store
+		)
+
+		BeforeEach(func() {
+			mockReadWriter = &mock.IOReadWriter{}
+			mockFileInfo := &mock.OSFileInfo{}
+			mockFileInfo.NameReturns(fmt.Sprintf("%s.%x.tar.gz", "label1", util.ComputeSHA256([]byte("hash1"))))
+			mockFileInfo2 := &mock.OSFileInfo{}
+			mockFileInfo2.NameReturns(fmt.Sprintf("%s.%x.tar.gz", "label2", util.ComputeSHA256([]byte("hash2"))))
+			mockFileInfo3 := &mock.OSFileInfo{}
+			mockFileInfo3.NameReturns(fmt.Sprintf("%s.%x.tar.gz", "", "Musha rain dum a doo, dum a da"))
+			mockReadWriter.ReadDirReturns([]os.FileInfo{mockFileInfo, mockFileInfo2, mockFileInfo3}, nil)
+			store = &persistence.Store{
+				ReadWriter: mockReadWriter,
+			}
+		})
+
+		It("counts the installed chaincodes, ignoring extraneous files", func() {
+			count, err := store.Count()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(count).To(Equal(2))
+
+			installedChaincodes, err := store.ListInstalledChaincodes()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(installedChaincodes).To(HaveLen(count))
+		})
+
+		Context("when the directory can't be read", func() {
+			BeforeEach(func() {
+				mockReadWriter.ReadDirReturns(nil, errors.New("unreadable"))
+			})
+
+			It("returns an error", func() {
+				count, err := store.Count()
+				Expect(err).To(MatchError("error reading chaincode directory at : unreadable"))
+				Expect(count).To(Equal(0))
+			})
+		})
+	})
+
 	Describe("GetChaincodeInstallPath", func() {
 		var store *persistence.Store
 
@@ -65,6 +65,20 @@ func (_m *ApplicationCapabilities) KeyLevelEndorsement() bool {
 	return r0
 }
 
+// LifecycleCanonicalCollections provides a mock function with given fields:
+func (_m *ApplicationCapabilities) LifecycleCanonicalCollections() bool {
+	ret := _m.Called()
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func() bool); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	return r0
+}
+
 // LifecycleV20 provides a mock function with given fields:
 func (_m *ApplicationCapabilities) LifecycleV20() bool {
 	ret := _m.Called()
@@ -10,6 +10,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"io"
+	"io/ioutil"
 	"strings"
 )
 
@@ -26,9 +27,36 @@ func (pa PersistenceAdapter) GetDBArtifacts(codePackage []byte) ([]byte, error)
 	return pa(codePackage)
 }
 
+// GetDBArtifactsFromStream reads the whole code package into memory before
+// extracting its metadata; use a StreamPersistenceAdapter to avoid this.
+func (pa PersistenceAdapter) GetDBArtifactsFromStream(codePackage io.Reader) ([]byte, error) {
+	code, err := ioutil.ReadAll(codePackage)
+	if err != nil {
+		return nil, err
+	}
+	return pa(code)
+}
+
+// StreamPersistenceAdapter adapts a function extracting the metadata from a
+// code package stream to the persistence.MetadataProvider interface.
+type StreamPersistenceAdapter func(io.Reader) ([]byte, error)
+
+func (spa StreamPersistenceAdapter) GetDBArtifacts(codePackage []byte) ([]byte, error) {
+	return spa(bytes.NewReader(codePackage))
+}
+
+func (spa StreamPersistenceAdapter) GetDBArtifactsFromStream(codePackage io.Reader) ([]byte, error) {
+	return spa(codePackage)
+}
+
 // MetadataAsTarEntries extracts metadata from a chaincode package
 func MetadataAsTarEntries(code []byte) ([]byte, error) {
-	is := bytes.NewReader(code)
+	return MetadataAsTarEntriesFromReader(bytes.NewReader(code))
+}
+
+// MetadataAsTarEntriesFromReader extracts metadata from a chaincode package
+// read from the supplied reader
+func MetadataAsTarEntriesFromReader(is io.Reader) ([]byte, error) {
 	gr, err := gzip.NewReader(is)
 	if err != nil {
 		ccproviderLogger.Errorf("Failure opening codepackage gzip stream: %s", err)
@@ -87,3 +87,19 @@ func TestMetadata(t *testing.T) {
 	require.Nil(t, err)
 	require.Equal(t, count, 2)
 }
+
+func TestStreamPersistenceAdapter(t *testing.T) {
+	entries := []tarEntry{{"path/to/a/file", []byte("somdata")}, {ccPackageStatedbDir + "/m1", []byte("m1data")}}
+	cds := getCodePackage([]byte("cc code"), entries)
+	spa := StreamPersistenceAdapter(MetadataAsTarEntriesFromReader)
+
+	fromStream, err := spa.GetDBArtifactsFromStream(bytes.NewReader(cds))
+	require.NoError(t, err)
+	count, err := getNumEntries(fromStream)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	fromBytes, err := spa.GetDBArtifacts(cds)
+	require.NoError(t, err)
+	require.Equal(t, fromStream, fromBytes)
+}
@@ -46,6 +46,16 @@ type ApplicationCapabilities struct {
 	keyLevelEndorsementReturnsOnCall map[int]struct {
 		result1 bool
 	}
+	LifecycleCanonicalCollectionsStub        func() bool
+	lifecycleCanonicalCollectionsMutex       sync.RWMutex
+	lifecycleCanonicalCollectionsArgsForCall []struct {
+	}
+	lifecycleCanonicalCollectionsReturns struct {
+		result1 bool
+	}
+	lifecycleCanonicalCollectionsReturnsOnCall map[int]struct {
+		result1 bool
+	}
 	LifecycleV20Stub        func() bool
 	lifecycleV20Mutex       sync.RWMutex
 	lifecycleV20ArgsForCall []struct {
@@ -348,6 +358,58 @@ func (fake *ApplicationCapabilities) KeyLevelEndorsementReturnsOnCall(i int, res
 	}{result1}
 }
 
+func (fake *ApplicationCapabilities) LifecycleCanonicalCollections() bool {
+	fake.lifecycleCanonicalCollectionsMutex.Lock()
+	ret, specificReturn := fake.lifecycleCanonicalCollectionsReturnsOnCall[len(fake.lifecycleCanonicalCollectionsArgsForCall)]
+	fake.lifecycleCanonicalCollectionsArgsForCall = append(fake.lifecycleCanonicalCollectionsArgsForCall, struct {
+	}{})
+	fake.recordInvocation("LifecycleCanonicalCollections", []interface{}{})
+	fake.lifecycleCanonicalCollectionsMutex.Unlock()
+	if fake.LifecycleCanonicalCollectionsStub != nil {
+		return fake.LifecycleCanonicalCollectionsStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.lifecycleCanonicalCollectionsReturns
+	return fakeReturns.result1
+}
+
+func (fake *ApplicationCapabilities) LifecycleCanonicalCollectionsCallCount() int {
+	fake.lifecycleCanonicalCollectionsMutex.RLock()
+	defer fake.lifecycleCanonicalCollectionsMutex.RUnlock()
+	return len(fake.lifecycleCanonicalCollectionsArgsForCall)
+}
+
+func (fake *ApplicationCapabilities) LifecycleCanonicalCollectionsCalls(stub func() bool) {
+	fake.lifecycleCanonicalCollectionsMutex.Lock()
+	defer fake.lifecycleCanonicalCollectionsMutex.Unlock()
+	fake.LifecycleCanonicalCollectionsStub = stub
+}
+
+func (fake *ApplicationCapabilities) LifecycleCanonicalCollectionsReturns(result1 bool) {
+	fake.lifecycleCanonicalCollectionsMutex.Lock()
+	defer fake.lifecycleCanonicalCollectionsMutex.Unlock()
+	fake.LifecycleCanonicalCollectionsStub = nil
+	fake.lifecycleCanonicalCollectionsReturns = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *ApplicationCapabilities) LifecycleCanonicalCollectionsReturnsOnCall(i int, result1 bool) {
+	fake.lifecycleCanonicalCollectionsMutex.Lock()
+	defer fake.lifecycleCanonicalCollectionsMutex.Unlock()
+	fake.LifecycleCanonicalCollectionsStub = nil
+	if fake.lifecycleCanonicalCollectionsReturnsOnCall == nil {
+		fake.lifecycleCanonicalCollectionsReturnsOnCall = make(map[int]struct {
+			result1 bool
+		})
+	}
+	fake.lifecycleCanonicalCollectionsReturnsOnCall[i] = struct {
+		result1 bool
+	}{result1}
+}
+
 func (fake *ApplicationCapabilities) LifecycleV20() bool {
 	fake.lifecycleV20Mutex.Lock()
 	ret, specificReturn := fake.lifecycleV20ReturnsOnCall[len(fake.lifecycleV20ArgsForCall)]
@@ -827,6 +889,8 @@ func (fake *ApplicationCapabilities) Invocations() map[string][][]interface{} {
 	defer fake.forbidDuplicateTXIdInBlockMutex.RUnlock()
 	fake.keyLevelEndorsementMutex.RLock()
 	defer fake.keyLevelEndorsementMutex.RUnlock()
+	fake.lifecycleCanonicalCollectionsMutex.RLock()
+	defer fake.lifecycleCanonicalCollectionsMutex.RUnlock()
 	fake.lifecycleV20Mutex.RLock()
 	defer fake.lifecycleV20Mutex.RUnlock()
 	fake.metadataLifecycleMutex.RLock()
@@ -233,7 +233,7 @@ func serve(args []string) error {
 	chaincodeInstallPath := filepath.Join(coreconfig.GetPath("peer.fileSystemPath"), "lifecycle", "chaincodes")
 	ccStore := persistence.NewStore(chaincodeInstallPath)
 	ccPackageParser := &persistence.ChaincodePackageParser{
-		MetadataProvider: ccprovider.PersistenceAdapter(ccprovider.MetadataAsTarEntries),
+		MetadataProvider: ccprovider.StreamPersistenceAdapter(ccprovider.MetadataAsTarEntriesFromReader),
 	}
 
 	peerHost, _, err := net.SplitHostPort(coreConfig.PeerAddress)
@@ -354,7 +354,7 @@ func serve(args []string) error {
 		Serializer:          &lifecycle.Serializer{},
 		ChannelConfigSource: peerInstance,
 		ChaincodeStore:      ccStore,
-		PackageParser:       ccPackageParser,
+		PackageParser:       lifecycle.NewPackageParserRegistry(ccPackageParser),
 	}
 
 	privdataConfig := gossipprivdata.GlobalConfig()
@@ -628,6 +628,7 @@ func serve(args []string) error {
 		InstalledChaincodesLister: lifecycleCache,
 		ChaincodeBuilder:          containerRouter,
 		BuildRegistry:             buildRegistry,
+		Metrics:                   lifecycle.NewMetrics(metricsProvider),
 	}
 
 	lifecycleSCC := &lifecycle.SCC{